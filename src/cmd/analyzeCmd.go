@@ -5,31 +5,116 @@ import (
 	"os"
 
 	"github.com/jorgevvs2/dockeryzer/src/functions"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
 	"github.com/spf13/cobra"
 )
 
 var analyzeDockerfile bool
+var analyzeTarget string
+var analyzeOutput string
+var analyzeFailOn string
+var analyzeMinScore int
+var analyzeBaseline string
+var analyzeUpdateBaseline bool
+var analyzeConfig string
+var analyzePolicies []string
+var analyzeFix bool
+var analyzeDryRun bool
+var analyzeMetadataOnly bool
+var analyzeLayers bool
+var analyzeVulnDB string
 
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze [image|Dockerfile]",
 	Short: "Analyze Docker image or Dockerfile based on CIS Docker Benchmark",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			fmt.Println("Please provide an image name or Dockerfile path")
-			os.Exit(0)
+			utils.Fatal(utils.ExitUsage, "Please provide an image name or Dockerfile path")
+		}
+
+		format, err := report.ParseFormat(analyzeOutput)
+		if err != nil {
+			utils.Fatal(utils.ExitUsage, err)
+		}
+
+		gate := security.Gate{MinScore: analyzeMinScore}
+		if analyzeFailOn != "" {
+			gate.FailOn, err = security.ParseSeverity(analyzeFailOn)
+			if err != nil {
+				utils.Fatal(utils.ExitUsage, err)
+			}
+		}
+		if analyzeMinScore < 0 || analyzeMinScore > 100 {
+			utils.Fatal(utils.ExitUsage, fmt.Sprintf("--min-score must be between 0 and 100, got %d", analyzeMinScore))
 		}
 
 		target := args[0]
 
+		if analyzeUpdateBaseline && analyzeBaseline == "" {
+			utils.Fatal(utils.ExitUsage, "--update-baseline needs --baseline to name the file to write")
+		}
+
+		if analyzeDryRun && !analyzeFix {
+			utils.Fatal(utils.ExitUsage, "--dry-run applies to --fix")
+		}
+		if analyzeFix && analyzeUpdateBaseline {
+			utils.Fatal(utils.ExitUsage, "--fix cannot be combined with --update-baseline")
+		}
+		if analyzeDockerfile && (analyzeMetadataOnly || analyzeLayers || analyzeVulnDB != "") {
+			utils.Fatal(utils.ExitUsage, "--metadata-only, --layers and --vuln-db apply to images")
+		}
+		if analyzeMetadataOnly && analyzeLayers {
+			utils.Fatal(utils.ExitUsage, "--layers reads the image filesystem and cannot be combined with --metadata-only")
+		}
+
 		if analyzeDockerfile {
-			functions.AnalyzeDockerfile(target)
-		} else {
-			functions.AnalyzeImage(target)
+			os.Exit(functions.AnalyzeDockerfile(target, functions.DockerfileOptions{
+				Target:         analyzeTarget,
+				Format:         format,
+				Gate:           gate,
+				Baseline:       analyzeBaseline,
+				UpdateBaseline: analyzeUpdateBaseline,
+				Config:         analyzeConfig,
+				Policies:       analyzePolicies,
+				Fix:            analyzeFix,
+				DryRun:         analyzeDryRun,
+			}))
+		}
+
+		if analyzeBaseline != "" {
+			utils.Fatal(utils.ExitUsage, "--baseline applies to Dockerfile findings and needs --dockerfile")
+		}
+		if analyzeFix {
+			utils.Fatal(utils.ExitUsage, "--fix rewrites Dockerfiles and needs --dockerfile")
 		}
+		os.Exit(functions.AnalyzeImage(target, functions.ImageOptions{
+			Format:                format,
+			Gate:                  gate,
+			Config:                analyzeConfig,
+			Policies:              analyzePolicies,
+			MetadataOnly:          analyzeMetadataOnly,
+			Layers:                analyzeLayers,
+			VulnerabilityDatabase: analyzeVulnDB,
+		}))
 	},
 }
 
 func init() {
 	analyzeCmd.Flags().BoolVarP(&analyzeDockerfile, "dockerfile", "d", false, "Analyze a Dockerfile instead of an image")
+	analyzeCmd.Flags().StringVarP(&analyzeTarget, "target", "t", "", "Stage to analyze as the shipped image (defaults to the last stage)")
+	analyzeCmd.Flags().StringVarP(&analyzeOutput, "output", "o", "text", "Output format: text, json, sarif or junit")
+	analyzeCmd.Flags().StringVar(&analyzeFailOn, "fail-on", "", "Exit with code 1 when a finding has this severity or higher: low, medium or high")
+	analyzeCmd.Flags().IntVar(&analyzeMinScore, "min-score", 0, "Exit with code 1 when the security score is below this percentage")
+	analyzeCmd.Flags().StringVar(&analyzeBaseline, "baseline", "", "Baseline file with accepted findings, which are reported as suppressed")
+	analyzeCmd.Flags().BoolVar(&analyzeUpdateBaseline, "update-baseline", false, "Record the current findings in the --baseline file")
+	analyzeCmd.Flags().StringVarP(&analyzeConfig, "config", "c", "", "Project configuration file (defaults to the .dockeryzer.yaml found from the working directory upwards)")
+	analyzeCmd.Flags().StringArrayVarP(&analyzePolicies, "policy", "p", nil, "Policy file or directory with user-defined rules (repeatable)")
+	analyzeCmd.Flags().BoolVar(&analyzeFix, "fix", false, "Rewrite the Dockerfile to resolve the findings that have an automatic fix")
+	analyzeCmd.Flags().BoolVar(&analyzeDryRun, "dry-run", false, "With --fix, print the changes as a unified diff instead of writing them")
+	analyzeCmd.Flags().BoolVar(&analyzeLayers, "layers", false, "Show the size of every layer and the space wasted by files deleted or overwritten in later layers")
+	analyzeCmd.Flags().BoolVar(&analyzeMetadataOnly, "metadata-only", false, "Analyze only the image configuration, without exporting its filesystem")
+	analyzeCmd.Flags().StringVar(&analyzeVulnDB, "vuln-db", "", "Vulnerability database imported with vulndb import (defaults to the configured or default one, if any)")
 	rootCmd.AddCommand(analyzeCmd)
 }
@@ -2,29 +2,164 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/docker/go-units"
 	"github.com/jorgevvs2/dockeryzer/src/functions"
+	"github.com/jorgevvs2/dockeryzer/src/imagediff"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
 	"github.com/spf13/cobra"
-	"os"
 )
 
+var compareOutput string
+var compareConfig string
+var compareMetadataOnly bool
+var compareFiles bool
+var comparePath string
+var compareMinSize string
+var compareReference string
+var compareDockerfile bool
+var compareBuild bool
+var compareContext string
+var compareNoCache bool
+
 var compareCmd = &cobra.Command{
-	Use:   "compare",
-	Short: "Command to compare two Docker images",
-	Long:  ``,
+	Use:   "compare [image1] [image2] [image3...] | --dockerfile|--build [dockerfile1] [dockerfile2]",
+	Short: "Command to compare Docker images",
+	Long: `Compare shows what changed from image1 to image2: size, shared and unique
+layers, base OS, language runtimes, configuration (environment, command,
+ports, volumes, user, labels, working directory and health check) and the
+findings of the image rules. With --files it also lists the files that were
+added, removed or changed.
+
+With more images, or with --reference, compare ranks the images in a table of
+size, layers, runtime, security score and vulnerabilities, marking the best
+value of each column, and diffs the reference against each of the others.
+
+With --dockerfile, compare takes two Dockerfiles instead: it lists the stages
+added and removed, the base images changed, the instructions added, removed
+and moved, the changes that break the build cache of the instructions after
+them, and the rule failures of both files side by side.
+
+With --build, compare builds two Dockerfiles through the Docker daemon under
+temporary tags, by default the Dockerfile and the Dockeryzer.Dockerfile
+written by create, compares the images along with the build time and the
+cache hits of each build, and removes the images afterwards.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 
-		if len(args) < 2 {
-			fmt.Println("Please provide two images to compare")
-			os.Exit(0)
+		format, err := report.ParseFormat(compareOutput)
+		if err != nil {
+			utils.Fatal(utils.ExitUsage, err)
+		}
+		if format != report.FormatText && format != report.FormatJSON {
+			utils.Fatal(utils.ExitUsage, "compare supports the text and json output formats")
 		}
 
-		image1, image2 := args[0], args[1]
+		if !compareFiles && (comparePath != "" || compareMinSize != "") {
+			utils.Fatal(utils.ExitUsage, "--path and --min-size apply to --files")
+		}
+		if compareMetadataOnly && compareFiles {
+			utils.Fatal(utils.ExitUsage, "--files reads the image filesystems and cannot be combined with --metadata-only")
+		}
+		if compareDockerfile && compareBuild {
+			utils.Fatal(utils.ExitUsage, "--dockerfile and --build cannot be combined")
+		}
+		if !compareBuild && (compareContext != "." || compareNoCache) {
+			utils.Fatal(utils.ExitUsage, "--context and --no-cache apply to --build")
+		}
 
-		functions.Compare(image1, image2)
+		if compareBuild {
+			dockerfiles := args
+			if len(dockerfiles) == 0 {
+				dockerfiles = []string{filepath.Join(compareContext, "Dockerfile"), filepath.Join(compareContext, "Dockeryzer.Dockerfile")}
+			}
+			if len(dockerfiles) != 2 {
+				utils.Fatal(utils.ExitUsage, "Please provide the two Dockerfiles to build and compare, or none for the Dockerfile and the Dockeryzer.Dockerfile")
+			}
+			for _, path := range dockerfiles {
+				if _, err := os.Stat(path); err != nil && len(args) == 0 {
+					utils.Fatal(utils.ExitUsage, fmt.Sprintf("%s not found; run dockeryzer create to generate the Dockeryzer.Dockerfile, or give the two Dockerfiles to build", path))
+				} else if err != nil {
+					utils.Fatal(utils.ExitUsage, err)
+				}
+			}
+			if compareReference != "" {
+				utils.Fatal(utils.ExitUsage, "--reference applies to images, not to --build")
+			}
+			os.Exit(functions.CompareBuilds(dockerfiles[0], dockerfiles[1], functions.CompareOptions{
+				Format:       format,
+				Config:       compareConfig,
+				MetadataOnly: compareMetadataOnly,
+				Files:        compareFiles,
+				FileFilter:   fileFilter(),
+				Context:      compareContext,
+				NoCache:      compareNoCache,
+			}))
+		}
+
+		if compareDockerfile {
+			if len(args) != 2 {
+				utils.Fatal(utils.ExitUsage, "Please provide the two Dockerfiles to compare")
+			}
+			if compareReference != "" || compareMetadataOnly || compareFiles {
+				utils.Fatal(utils.ExitUsage, "--reference, --metadata-only and --files apply to images, not to --dockerfile")
+			}
+			os.Exit(functions.CompareDockerfiles(args[0], args[1], functions.CompareOptions{Format: format, Config: compareConfig}))
+		}
+
+		images := args
+		if compareReference != "" && !slices.Contains(images, compareReference) {
+			images = append([]string{compareReference}, images...)
+		}
+		if len(images) < 2 {
+			utils.Fatal(utils.ExitUsage, "Please provide at least two images to compare")
+		}
+
+		if compareFiles && len(images) > 2 && compareReference == "" {
+			utils.Fatal(utils.ExitUsage, "--files compares two images; use --reference to compare more images against one of them")
+		}
+		os.Exit(functions.Compare(images, functions.CompareOptions{
+			Format:       format,
+			Config:       compareConfig,
+			MetadataOnly: compareMetadataOnly,
+			Files:        compareFiles,
+			FileFilter:   fileFilter(),
+			Reference:    compareReference,
+		}))
 	},
 }
 
+// fileFilter returns the filter of --path and --min-size.
+func fileFilter() imagediff.FileFilter {
+	filter := imagediff.FileFilter{Pattern: comparePath}
+	if err := imagediff.ValidatePattern(comparePath); err != nil {
+		utils.Fatal(utils.ExitUsage, err)
+	}
+	if compareMinSize != "" {
+		size, err := units.FromHumanSize(compareMinSize)
+		if err != nil {
+			utils.Fatal(utils.ExitUsage, fmt.Sprintf("invalid --min-size %q, expected a size such as 500KB or 10MB", compareMinSize))
+		}
+		filter.MinSize = size
+	}
+	return filter
+}
+
 func init() {
+	compareCmd.Flags().StringVarP(&compareOutput, "output", "o", "text", "Output format: text or json")
+	compareCmd.Flags().StringVarP(&compareConfig, "config", "c", "", "Project configuration file (defaults to the .dockeryzer.yaml found from the working directory upwards)")
+	compareCmd.Flags().BoolVar(&compareMetadataOnly, "metadata-only", false, "Compare only the image configurations, without exporting their filesystems")
+	compareCmd.Flags().BoolVar(&compareFiles, "files", false, "List the files added, removed and changed, grouped by top-level directory")
+	compareCmd.Flags().StringVar(&comparePath, "path", "", "With --files, only compare the paths matching this glob, e.g. '/usr/**' or '*.so'")
+	compareCmd.Flags().StringVar(&compareMinSize, "min-size", "", "With --files, only list the changes of at least this size, e.g. 1MB")
+	compareCmd.Flags().StringVarP(&compareReference, "reference", "r", "", "Image the others are diffed against, added to the comparison when not listed")
+	compareCmd.Flags().BoolVar(&compareDockerfile, "dockerfile", false, "Compare two Dockerfiles instead of images")
+	compareCmd.Flags().BoolVar(&compareBuild, "build", false, "Build two Dockerfiles, by default the Dockerfile and the Dockeryzer.Dockerfile, and compare the images")
+	compareCmd.Flags().StringVar(&compareContext, "context", ".", "With --build, the build context directory")
+	compareCmd.Flags().BoolVar(&compareNoCache, "no-cache", false, "With --build, build both images without the build cache")
 	rootCmd.AddCommand(compareCmd)
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jorgevvs2/dockeryzer/src/functions"
+	"github.com/jorgevvs2/dockeryzer/src/sbom"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+	"github.com/spf13/cobra"
+)
+
+var sbomOutput string
+var sbomFile string
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom [image]",
+	Short: "Generate a software bill of materials of a Docker image",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			utils.Fatal(utils.ExitUsage, "Please provide an image name")
+		}
+
+		format, err := sbom.ParseFormat(sbomOutput)
+		if err != nil {
+			utils.Fatal(utils.ExitUsage, err)
+		}
+
+		os.Exit(functions.GenerateSBOM(args[0], functions.SBOMOptions{
+			Format: format,
+			File:   sbomFile,
+		}))
+	},
+}
+
+func init() {
+	sbomCmd.Flags().StringVarP(&sbomOutput, "output", "o", "cyclonedx", "Output format: cyclonedx or spdx")
+	sbomCmd.Flags().StringVarP(&sbomFile, "file", "f", "", "Write the SBOM to this file instead of the standard output")
+	rootCmd.AddCommand(sbomCmd)
+}
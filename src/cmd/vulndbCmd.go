@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jorgevvs2/dockeryzer/src/functions"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+	"github.com/spf13/cobra"
+)
+
+var vulndbDir string
+
+var vulndbCmd = &cobra.Command{
+	Use:   "vulndb",
+	Short: "Manage the local vulnerability database used by analyze",
+}
+
+var vulndbImportCmd = &cobra.Command{
+	Use:   "import [dump...]",
+	Short: "Import OSV advisories from JSON files, directories or zip archives",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			utils.Fatal(utils.ExitUsage, "Please provide OSV files, directories or zip archives to import")
+		}
+		os.Exit(functions.ImportVulnerabilities(vulndbDir, args))
+	},
+}
+
+var vulndbInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the ecosystems of the vulnerability database",
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(functions.PrintVulnerabilityDatabase(vulndbDir))
+	},
+}
+
+func init() {
+	vulndbCmd.PersistentFlags().StringVar(&vulndbDir, "db", "", "Database directory (defaults to $DOCKERYZER_VULN_DB or dockeryzer/vulndb in the user cache directory)")
+	vulndbCmd.AddCommand(vulndbImportCmd, vulndbInfoCmd)
+	rootCmd.AddCommand(vulndbCmd)
+}
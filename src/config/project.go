@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileNames are the project configuration files looked up by Find,
+// in order of preference.
+var ProjectFileNames = []string{".dockeryzer.yaml", ".dockeryzer.yml"}
+
+// Project is the team policy read from a .dockeryzer.yaml file.
+type Project struct {
+	// Path is the file the configuration was read from, empty for the defaults.
+	Path string `yaml:"-"`
+	// Rules tunes individual rules, keyed by rule ID (e.g. CIS-6.1).
+	Rules map[string]RuleConfig `yaml:"rules"`
+	// AllowedRegistries lists registries or repository prefixes trusted as
+	// base images in addition to the Docker Hub official images.
+	AllowedRegistries []string `yaml:"allowedRegistries"`
+	// MaxExposedPorts is the number of ports the final stage may expose, 1 when unset.
+	MaxExposedPorts int `yaml:"maxExposedPorts"`
+	// RequiredLabels are the LABEL keys the final stage must define.
+	RequiredLabels []string `yaml:"requiredLabels"`
+	// Policies are files or directories of user-defined rules, relative to
+	// the configuration file.
+	Policies []string `yaml:"policies"`
+	// VulnerabilityDatabase is the directory of the advisory database
+	// matched against image packages, relative to the configuration file.
+	VulnerabilityDatabase string `yaml:"vulnerabilityDatabase"`
+	// RuntimeEndOfLife is a file of runtime end-of-life dates merged over
+	// the embedded table, relative to the configuration file.
+	RuntimeEndOfLife string `yaml:"runtimeEndOfLife"`
+}
+
+// RuleConfig overrides the defaults of a single rule.
+type RuleConfig struct {
+	// Enabled turns the rule off when false. Rules are enabled by default.
+	Enabled *bool `yaml:"enabled"`
+	// Severity replaces the severity of the rule's findings: low, medium or high.
+	Severity string `yaml:"severity"`
+}
+
+// Find looks for a project configuration file in dir and its parents and
+// returns the path of the first one found, or an empty string.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range ProjectFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProject reads the project configuration at path. Unknown keys are
+// rejected so that typos do not silently change the policy.
+func LoadProject(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Project{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(project); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if project.MaxExposedPorts < 0 {
+		return nil, fmt.Errorf("%s: maxExposedPorts must not be negative", path)
+	}
+	for i, policy := range project.Policies {
+		if !filepath.IsAbs(policy) {
+			project.Policies[i] = filepath.Join(filepath.Dir(path), policy)
+		}
+	}
+	if project.VulnerabilityDatabase != "" && !filepath.IsAbs(project.VulnerabilityDatabase) {
+		project.VulnerabilityDatabase = filepath.Join(filepath.Dir(path), project.VulnerabilityDatabase)
+	}
+	if project.RuntimeEndOfLife != "" && !filepath.IsAbs(project.RuntimeEndOfLife) {
+		project.RuntimeEndOfLife = filepath.Join(filepath.Dir(path), project.RuntimeEndOfLife)
+	}
+	project.Path = path
+	return project, nil
+}
+
+// LoadProjectFrom finds and loads the project configuration that applies to
+// dir, returning an empty configuration when there is none.
+func LoadProjectFrom(dir string) (*Project, error) {
+	path, err := Find(dir)
+	if err != nil || path == "" {
+		return &Project{}, err
+	}
+	return LoadProject(path)
+}
+
+// RuleEnabled reports whether the rule with the given ID should run.
+func (p *Project) RuleEnabled(id string) bool {
+	rule, ok := p.Rules[id]
+	return !ok || rule.Enabled == nil || *rule.Enabled
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadProjectFromParentDirectory(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFile(t, filepath.Join(root, ".dockeryzer.yaml"), `
+rules:
+  CIS-4.6:
+    enabled: false
+  CIS-1.2:
+    severity: medium
+allowedRegistries:
+  - ghcr.io/acme
+maxExposedPorts: 2
+requiredLabels: [team]
+vulnerabilityDatabase: .cache/vulndb
+runtimeEndOfLife: eol.json
+`)
+
+	project, err := LoadProjectFrom(nested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if project.Path != filepath.Join(root, ".dockeryzer.yaml") {
+		t.Errorf("Unexpected path %q", project.Path)
+	}
+	if project.RuleEnabled("CIS-4.6") || !project.RuleEnabled("CIS-1.2") || !project.RuleEnabled("CIS-6.1") {
+		t.Errorf("Unexpected rule switches: %+v", project.Rules)
+	}
+	if project.Rules["CIS-1.2"].Severity != "medium" || project.MaxExposedPorts != 2 ||
+		len(project.AllowedRegistries) != 1 || len(project.RequiredLabels) != 1 ||
+		project.VulnerabilityDatabase != filepath.Join(root, ".cache", "vulndb") ||
+		project.RuntimeEndOfLife != filepath.Join(root, "eol.json") {
+		t.Errorf("Unexpected configuration: %+v", project)
+	}
+}
+
+func TestLoadProjectWithoutFile(t *testing.T) {
+	project, err := LoadProjectFrom(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if project.Path != "" || !project.RuleEnabled("CIS-4.1") {
+		t.Errorf("Expected the default configuration, got %+v", project)
+	}
+}
+
+func TestLoadProjectRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dockeryzer.yaml")
+	writeFile(t, path, "maxExposedPort: 2\n")
+
+	if _, err := LoadProject(path); err == nil {
+		t.Errorf("Expected a typo in a key to be rejected")
+	}
+}
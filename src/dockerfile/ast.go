@@ -0,0 +1,214 @@
+package dockerfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dockerfile is the parsed representation of a Dockerfile.
+type Dockerfile struct {
+	// Path is the file the Dockerfile was read from, empty when parsed from memory.
+	Path string
+	// Directives holds the parser directives (syntax, escape, check) keyed by lower-case name.
+	Directives map[string]string
+	// EscapeToken is the line continuation character, '\\' unless overridden by "# escape=".
+	EscapeToken rune
+	// Instructions lists every instruction in file order.
+	Instructions []*Instruction
+	// MetaArgs are the ARG instructions declared before the first FROM.
+	MetaArgs []*Instruction
+	// Stages lists the build stages in file order.
+	Stages []*Stage
+	// Comments lists every comment line that is not a parser directive.
+	Comments []Comment
+	// Lines keeps the raw source lines so findings can quote them.
+	Lines []string
+}
+
+// Instruction is a single Dockerfile instruction, possibly spanning several lines.
+type Instruction struct {
+	// Cmd is the upper-cased instruction keyword (RUN, COPY, FROM...).
+	Cmd string
+	// Flags are the leading "--name=value" options (e.g. --from=builder, --chown=app).
+	Flags []string
+	// Args are the instruction arguments: the array elements in JSON form,
+	// otherwise the whitespace separated words of Value.
+	Args []string
+	// Value is the argument text after the flags, with line continuations joined.
+	Value string
+	// JSONForm reports whether the arguments were written in exec (JSON array) form.
+	JSONForm bool
+	// Heredocs holds the here-documents attached to the instruction.
+	Heredocs []Heredoc
+	// Original is the instruction text as written, continuations included.
+	Original string
+	// Stage is the index of the stage the instruction belongs to, -1 before the first FROM.
+	Stage int
+	// StartLine and EndLine are the 1-based source lines covered by the instruction.
+	StartLine int
+	EndLine   int
+}
+
+// Heredoc is a here-document body attached to RUN, COPY or ADD.
+type Heredoc struct {
+	Name    string
+	Content string
+	// Expand is false when the delimiter was quoted, disabling variable expansion.
+	Expand bool
+	// ChompTabs is true for the "<<-" form, which strips leading tabs.
+	ChompTabs bool
+}
+
+// Comment is a "#" comment line.
+type Comment struct {
+	Text string
+	Line int
+}
+
+// Stage is a build stage started by a FROM instruction.
+type Stage struct {
+	Index int
+	// Name is the "AS name" alias, lower-cased, empty when the stage is unnamed.
+	Name string
+	// BaseImage is the FROM image with meta ARG defaults expanded.
+	BaseImage string
+	// Platform is the --platform flag value, if any.
+	Platform string
+	From     *Instruction
+	// Instructions lists the instructions of the stage, FROM excluded.
+	Instructions []*Instruction
+}
+
+// Flag returns the value of the "--name" flag and whether it was present.
+func (i *Instruction) Flag(name string) (string, bool) {
+	prefix := "--" + name
+	for _, flag := range i.Flags {
+		if flag == prefix {
+			return "", true
+		}
+		if strings.HasPrefix(flag, prefix+"=") {
+			return strings.TrimPrefix(flag, prefix+"="), true
+		}
+	}
+	return "", false
+}
+
+// Is reports whether the instruction is one of the given commands.
+func (i *Instruction) Is(cmds ...string) bool {
+	for _, cmd := range cmds {
+		if strings.EqualFold(i.Cmd, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the stage instructions matching any of the given commands.
+func (s *Stage) Find(cmds ...string) []*Instruction {
+	found := []*Instruction{}
+	for _, inst := range s.Instructions {
+		if inst.Is(cmds...) {
+			found = append(found, inst)
+		}
+	}
+	return found
+}
+
+// Last returns the last stage instruction matching any of the given commands, or nil.
+func (s *Stage) Last(cmds ...string) *Instruction {
+	found := s.Find(cmds...)
+	if len(found) == 0 {
+		return nil
+	}
+	return found[len(found)-1]
+}
+
+// Find returns every instruction of the file matching any of the given commands.
+func (d *Dockerfile) Find(cmds ...string) []*Instruction {
+	found := []*Instruction{}
+	for _, inst := range d.Instructions {
+		if inst.Is(cmds...) {
+			found = append(found, inst)
+		}
+	}
+	return found
+}
+
+// Stage returns the stage referenced by name or numeric index, or nil.
+func (d *Dockerfile) Stage(ref string) *Stage {
+	ref = strings.ToLower(ref)
+	for _, stage := range d.Stages {
+		if stage.Name != "" && stage.Name == ref {
+			return stage
+		}
+	}
+	for _, stage := range d.Stages {
+		if ref == strconv.Itoa(stage.Index) {
+			return stage
+		}
+	}
+	return nil
+}
+
+// IsStageReference reports whether image refers to an earlier build stage
+// rather than to an image.
+func (d *Dockerfile) IsStageReference(image string, before int) bool {
+	stage := d.Stage(image)
+	return stage != nil && stage.Index < before
+}
+
+// Label returns the stage name, or its index when the stage is unnamed.
+func (s *Stage) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strconv.Itoa(s.Index)
+}
+
+// Target returns the stage built for the given --target, or the last stage
+// when target is empty.
+func (d *Dockerfile) Target(target string) (*Stage, error) {
+	if len(d.Stages) == 0 {
+		return nil, fmt.Errorf("no FROM instruction found")
+	}
+	if target == "" {
+		return d.Stages[len(d.Stages)-1], nil
+	}
+	stage := d.Stage(target)
+	if stage == nil {
+		return nil, fmt.Errorf("target stage %q not found", target)
+	}
+	return stage, nil
+}
+
+// Parent returns the stage a stage is built FROM, or nil when it starts
+// from an image.
+func (d *Dockerfile) Parent(stage *Stage) *Stage {
+	parent := d.Stage(stage.BaseImage)
+	if parent == nil || parent.Index >= stage.Index {
+		return nil
+	}
+	return parent
+}
+
+// Lineage returns the stage followed by the stages it inherits from,
+// nearest first.
+func (d *Dockerfile) Lineage(stage *Stage) []*Stage {
+	lineage := []*Stage{}
+	for current := stage; current != nil; current = d.Parent(current) {
+		lineage = append(lineage, current)
+	}
+	return lineage
+}
+
+// LastInLineage returns the last instruction matching cmds that applies to
+// stage, looking through the stages it inherits from.
+func (d *Dockerfile) LastInLineage(stage *Stage, cmds ...string) *Instruction {
+	for _, current := range d.Lineage(stage) {
+		if inst := current.Last(cmds...); inst != nil {
+			return inst
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dockerfile
+
+import "strings"
+
+// KeyValue is a single key/value pair of an ENV, LABEL or ARG instruction.
+type KeyValue struct {
+	Key   string
+	Value string
+	// HasValue is false for "ARG NAME" declarations without a default.
+	HasValue bool
+}
+
+// KeyValues returns the pairs declared by an ENV, LABEL or ARG instruction,
+// handling quoted values and the legacy "ENV KEY value" form.
+func (i *Instruction) KeyValues() []KeyValue {
+	words := splitWords(i.Value)
+	if len(words) == 0 {
+		return nil
+	}
+
+	if i.Is("ENV", "LABEL") && !strings.Contains(words[0], "=") {
+		key, value := splitFirstWord(i.Value)
+		return []KeyValue{{Key: key, Value: unquote(value), HasValue: true}}
+	}
+
+	pairs := []KeyValue{}
+	for _, word := range words {
+		key, value, found := strings.Cut(word, "=")
+		pairs = append(pairs, KeyValue{Key: key, Value: value, HasValue: found})
+	}
+	return pairs
+}
+
+// splitWords splits text on unquoted whitespace and removes the quotes.
+func splitWords(text string) []string {
+	words := []string{}
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(text)
+	for idx := 0; idx < len(runes); idx++ {
+		r := runes[idx]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			if r == '\\' && quote == '"' && idx+1 < len(runes) {
+				idx++
+				r = runes[idx]
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == '\\' && idx+1 < len(runes):
+			idx++
+			current.WriteRune(runes[idx])
+			inWord = true
+		case isSpace(r):
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,355 @@
+package dockerfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var knownInstructions = map[string]bool{
+	"ADD": true, "ARG": true, "CMD": true, "COPY": true, "ENTRYPOINT": true,
+	"ENV": true, "EXPOSE": true, "FROM": true, "HEALTHCHECK": true, "LABEL": true,
+	"MAINTAINER": true, "ONBUILD": true, "RUN": true, "SHELL": true,
+	"STOPSIGNAL": true, "USER": true, "VOLUME": true, "WORKDIR": true,
+}
+
+// Instructions that accept leading "--flag" options.
+var flagInstructions = map[string]bool{
+	"ADD": true, "COPY": true, "FROM": true, "HEALTHCHECK": true, "RUN": true,
+}
+
+// Instructions that accept here-documents.
+var heredocInstructions = map[string]bool{
+	"ADD": true, "COPY": true, "RUN": true,
+}
+
+var knownDirectives = map[string]bool{
+	"syntax": true,
+	"escape": true,
+	"check":  true,
+}
+
+var directivePattern = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9_]*)\s*=\s*(.+?)\s*$`)
+
+// heredocPattern matches a shell word that starts a here-document, the way
+// BuildKit recognizes them.
+var heredocPattern = regexp.MustCompile(`^(\d*)<<(-?)(["']?)([A-Za-z_][A-Za-z0-9_]*)(["']?)$`)
+
+// ParseFile reads and parses the Dockerfile at path.
+func ParseFile(path string) (*Dockerfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	df, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	df.Path = path
+	return df, nil
+}
+
+// ParseString parses Dockerfile content held in memory.
+func ParseString(content string) (*Dockerfile, error) {
+	return Parse(strings.NewReader(content))
+}
+
+// Parse reads a Dockerfile and builds its instruction and stage model. It
+// understands parser directives, custom escape tokens, line continuations,
+// comments inside continuations, exec (JSON) form and here-documents.
+func Parse(r io.Reader) (*Dockerfile, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.Split(content, "\n")
+
+	df := &Dockerfile{
+		Directives:  map[string]string{},
+		EscapeToken: '\\',
+		Lines:       lines,
+	}
+
+	i := parseDirectives(df, lines)
+	if escape, ok := df.Directives["escape"]; ok {
+		if escape != "\\" && escape != "`" {
+			return nil, fmt.Errorf("invalid escape token %q: must be ` or \\", escape)
+		}
+		df.EscapeToken = rune(escape[0])
+	}
+
+	for i < len(lines) {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			df.Comments = append(df.Comments, Comment{Text: trimmed, Line: i + 1})
+			i++
+			continue
+		}
+
+		inst, next, err := parseInstruction(df, lines, i)
+		if err != nil {
+			return nil, err
+		}
+		addInstruction(df, inst)
+		i = next
+	}
+
+	return df, nil
+}
+
+// parseDirectives consumes the parser directives at the top of the file and
+// returns the index of the first line that is not one.
+func parseDirectives(df *Dockerfile, lines []string) int {
+	for i, line := range lines {
+		match := directivePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			return i
+		}
+		name := strings.ToLower(match[1])
+		if !knownDirectives[name] {
+			return i
+		}
+		if _, seen := df.Directives[name]; seen {
+			return i
+		}
+		df.Directives[name] = match[2]
+	}
+	return len(lines)
+}
+
+func parseInstruction(df *Dockerfile, lines []string, start int) (*Instruction, int, error) {
+	escape := string(df.EscapeToken)
+
+	var logical strings.Builder
+	i := start
+	for i < len(lines) {
+		line := strings.TrimRightFunc(lines[i], isSpace)
+		i++
+
+		if strings.HasSuffix(line, escape) {
+			logical.WriteString(strings.TrimSuffix(line, escape))
+			logical.WriteString(" ")
+			// Comment and empty lines are dropped inside a continuation.
+			for i < len(lines) {
+				next := strings.TrimSpace(lines[i])
+				if next == "" {
+					i++
+					continue
+				}
+				if strings.HasPrefix(next, "#") {
+					df.Comments = append(df.Comments, Comment{Text: next, Line: i + 1})
+					i++
+					continue
+				}
+				break
+			}
+			continue
+		}
+
+		logical.WriteString(line)
+		break
+	}
+
+	text := strings.TrimSpace(logical.String())
+	keyword, rest := splitFirstWord(text)
+	cmd := strings.ToUpper(keyword)
+	if !knownInstructions[cmd] {
+		return nil, 0, fmt.Errorf("line %d: unknown instruction: %s", start+1, keyword)
+	}
+
+	inst := &Instruction{
+		Cmd:       cmd,
+		Stage:     len(df.Stages) - 1,
+		StartLine: start + 1,
+	}
+
+	if flagInstructions[cmd] {
+		inst.Flags, rest = splitFlags(rest)
+	}
+	inst.Value = strings.TrimSpace(rest)
+
+	if strings.HasPrefix(inst.Value, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(inst.Value), &args); err == nil {
+			inst.JSONForm = true
+			inst.Args = args
+		}
+	}
+	if !inst.JSONForm {
+		inst.Args = strings.Fields(inst.Value)
+	}
+
+	if heredocInstructions[cmd] && !inst.JSONForm {
+		for _, word := range shellWords(inst.Value) {
+			match := heredocPattern.FindStringSubmatch(word)
+			if match == nil || match[3] != match[5] {
+				continue
+			}
+			heredoc := Heredoc{
+				Name:      match[4],
+				Expand:    match[3] == "",
+				ChompTabs: match[2] == "-",
+			}
+			var body []string
+			for i < len(lines) {
+				line := lines[i]
+				i++
+				check := line
+				if heredoc.ChompTabs {
+					check = strings.TrimLeft(line, "\t")
+					line = check
+				}
+				if check == heredoc.Name {
+					break
+				}
+				body = append(body, line)
+			}
+			if len(body) > 0 {
+				heredoc.Content = strings.Join(body, "\n") + "\n"
+			}
+			inst.Heredocs = append(inst.Heredocs, heredoc)
+		}
+	}
+
+	inst.EndLine = i
+	inst.Original = strings.Join(lines[start:i], "\n")
+	return inst, i, nil
+}
+
+// shellWords splits text into its whitespace separated shell words. Quoted
+// text and arithmetic expansions $((...)) stay inside their word, so a <<
+// they contain never starts a here-document.
+func shellWords(text string) []string {
+	words := []string{}
+	var word strings.Builder
+	var quote byte
+	arithmetic := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < len(text) {
+				word.WriteByte(c)
+				i++
+				c = text[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(text):
+			word.WriteByte(c)
+			i++
+			c = text[i]
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(text[i:], "$(("):
+			arithmetic++
+			word.WriteString("$(")
+			i += 2
+			c = '('
+		case arithmetic > 0 && strings.HasPrefix(text[i:], "))"):
+			arithmetic--
+			word.WriteByte(')')
+			i++
+		case arithmetic == 0 && (c == ' ' || c == '\t' || c == '\n'):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteByte(c)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+func addInstruction(df *Dockerfile, inst *Instruction) {
+	df.Instructions = append(df.Instructions, inst)
+
+	if inst.Cmd == "FROM" {
+		stage := &Stage{
+			Index: len(df.Stages),
+			From:  inst,
+		}
+		inst.Stage = stage.Index
+		if platform, ok := inst.Flag("platform"); ok {
+			stage.Platform = platform
+		}
+		if len(inst.Args) > 0 {
+			stage.BaseImage = expandMetaArgs(inst.Args[0], df.MetaArgs)
+		}
+		if len(inst.Args) >= 3 && strings.EqualFold(inst.Args[1], "AS") {
+			stage.Name = strings.ToLower(inst.Args[2])
+		}
+		df.Stages = append(df.Stages, stage)
+		return
+	}
+
+	if len(df.Stages) == 0 {
+		if inst.Cmd == "ARG" {
+			df.MetaArgs = append(df.MetaArgs, inst)
+		}
+		return
+	}
+
+	stage := df.Stages[len(df.Stages)-1]
+	stage.Instructions = append(stage.Instructions, inst)
+}
+
+// expandMetaArgs substitutes $VAR, ${VAR} and ${VAR:-default} references
+// using the defaults of the ARG instructions declared before the first FROM.
+func expandMetaArgs(value string, metaArgs []*Instruction) string {
+	defaults := map[string]string{}
+	for _, arg := range metaArgs {
+		for _, kv := range arg.KeyValues() {
+			defaults[kv.Key] = kv.Value
+		}
+	}
+
+	return os.Expand(value, func(name string) string {
+		fallback := ""
+		if idx := strings.Index(name, ":-"); idx >= 0 {
+			name, fallback = name[:idx], name[idx+2:]
+		}
+		if v, ok := defaults[name]; ok && v != "" {
+			return v
+		}
+		return fallback
+	})
+}
+
+func splitFirstWord(text string) (string, string) {
+	idx := strings.IndexFunc(text, isSpace)
+	if idx < 0 {
+		return text, ""
+	}
+	return text[:idx], strings.TrimLeftFunc(text[idx:], isSpace)
+}
+
+func splitFlags(rest string) ([]string, string) {
+	flags := []string{}
+	for strings.HasPrefix(rest, "--") {
+		var flag string
+		flag, rest = splitFirstWord(rest)
+		flags = append(flags, flag)
+	}
+	return flags, rest
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
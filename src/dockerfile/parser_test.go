@@ -0,0 +1,241 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, content string) *Dockerfile {
+	t.Helper()
+	df, err := ParseString(content)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return df
+}
+
+func TestParseLineContinuations(t *testing.T) {
+	df := mustParse(t, `FROM alpine:3.19
+RUN apk add --no-cache curl \
+    # comments are dropped inside continuations
+    git \
+
+    bash
+USER app
+`)
+
+	if len(df.Instructions) != 3 {
+		t.Fatalf("Expected 3 instructions, got %d", len(df.Instructions))
+	}
+
+	run := df.Instructions[1]
+	if run.Cmd != "RUN" {
+		t.Errorf("Expected RUN, got %s", run.Cmd)
+	}
+	if run.StartLine != 2 || run.EndLine != 6 {
+		t.Errorf("Expected lines 2-6, got %d-%d", run.StartLine, run.EndLine)
+	}
+	if !strings.Contains(run.Value, "git") || !strings.Contains(run.Value, "bash") {
+		t.Errorf("Expected continuation to be joined, got %q", run.Value)
+	}
+	if len(df.Comments) != 1 || df.Comments[0].Line != 3 {
+		t.Errorf("Expected the inner comment on line 3, got %+v", df.Comments)
+	}
+}
+
+func TestParseLowercaseAndJSONForm(t *testing.T) {
+	df := mustParse(t, `from node:20-alpine
+cmd ["node", "server.js"]
+entrypoint /bin/sh -c "echo hi"
+`)
+
+	cmd := df.Instructions[1]
+	if cmd.Cmd != "CMD" || !cmd.JSONForm {
+		t.Errorf("Expected CMD in JSON form, got %s (json=%v)", cmd.Cmd, cmd.JSONForm)
+	}
+	if len(cmd.Args) != 2 || cmd.Args[1] != "server.js" {
+		t.Errorf("Unexpected JSON args: %v", cmd.Args)
+	}
+
+	entrypoint := df.Instructions[2]
+	if entrypoint.JSONForm {
+		t.Errorf("Expected ENTRYPOINT in shell form")
+	}
+}
+
+func TestParseEscapeDirective(t *testing.T) {
+	df := mustParse(t, "# escape=`\nFROM mcr.microsoft.com/windows/servercore:ltsc2022\nRUN dir C:\\ `\n    && echo done\n")
+
+	if df.EscapeToken != '`' {
+		t.Errorf("Expected backtick escape token, got %q", df.EscapeToken)
+	}
+	if len(df.Comments) != 0 {
+		t.Errorf("Directive should not be reported as a comment")
+	}
+	run := df.Instructions[1]
+	if !strings.Contains(run.Value, `C:\`) || !strings.Contains(run.Value, "echo done") {
+		t.Errorf("Unexpected RUN value: %q", run.Value)
+	}
+}
+
+func TestParseDirectiveOnlyAtTop(t *testing.T) {
+	df := mustParse(t, "FROM alpine:3.19\n# escape=`\nRUN echo \\\n  ok\n")
+
+	if df.EscapeToken != '\\' {
+		t.Errorf("Directive after an instruction must be ignored")
+	}
+	if len(df.Instructions) != 2 {
+		t.Errorf("Expected 2 instructions, got %d", len(df.Instructions))
+	}
+}
+
+func TestParseShiftIsNotHeredoc(t *testing.T) {
+	tests := []struct {
+		name string
+		run  string
+	}{
+		{"Arithmetic", "RUN echo $((1<<2))"},
+		{"Spaced arithmetic", "RUN echo $(( 1 <<EOF ))"},
+		{"Double quotes", `RUN echo "a <<EOF"`},
+		{"Single quotes", "RUN echo 'cat <<EOF'"},
+		{"Inside a word", "RUN echo a<<EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := mustParse(t, "FROM alpine:3.19\n"+tt.run+"\nUSER app\nCMD [\"sh\"]\n")
+			if len(df.Instructions) != 4 {
+				t.Fatalf("Expected 4 instructions, got %d", len(df.Instructions))
+			}
+			run := df.Instructions[1]
+			if len(run.Heredocs) != 0 || run.StartLine != 2 || run.EndLine != 2 {
+				t.Errorf("Expected a single line RUN without heredoc, got lines %d-%d and %+v", run.StartLine, run.EndLine, run.Heredocs)
+			}
+			if len(df.Find("USER")) != 1 || len(df.Find("CMD")) != 1 {
+				t.Errorf("Expected USER and CMD after the RUN to be parsed")
+			}
+		})
+	}
+}
+
+func TestParseHeredoc(t *testing.T) {
+	df := mustParse(t, `FROM alpine:3.19
+RUN <<EOF
+apk add --no-cache curl
+echo "USER root"
+EOF
+RUN 3<<INPUT cat <&3 > /tmp/input
+value
+INPUT
+COPY <<-"CONF" /etc/app.conf
+	key=value
+	CONF
+USER app
+`)
+
+	run := df.Instructions[1]
+	if len(run.Heredocs) != 1 {
+		t.Fatalf("Expected 1 heredoc, got %d", len(run.Heredocs))
+	}
+	if !strings.Contains(run.Heredocs[0].Content, "apk add") {
+		t.Errorf("Unexpected heredoc content: %q", run.Heredocs[0].Content)
+	}
+	if run.EndLine != 5 {
+		t.Errorf("Expected heredoc to end on line 5, got %d", run.EndLine)
+	}
+
+	fd := df.Instructions[2]
+	if len(fd.Heredocs) != 1 || fd.Heredocs[0].Name != "INPUT" || fd.EndLine != 8 {
+		t.Errorf("Unexpected heredoc with a file descriptor: %+v", fd.Heredocs)
+	}
+
+	copyInst := df.Instructions[3]
+	if len(copyInst.Heredocs) != 1 || copyInst.Heredocs[0].Expand || !copyInst.Heredocs[0].ChompTabs {
+		t.Errorf("Unexpected COPY heredoc: %+v", copyInst.Heredocs)
+	}
+	if copyInst.Heredocs[0].Content != "key=value\n" {
+		t.Errorf("Expected tabs to be stripped, got %q", copyInst.Heredocs[0].Content)
+	}
+
+	if len(df.Find("USER")) != 1 {
+		t.Errorf("The USER inside the heredoc body must not be parsed as an instruction")
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	df := mustParse(t, `ARG GO_VERSION=1.22
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION}-alpine AS Builder
+RUN go build -o /app
+FROM alpine:3.19
+COPY --from=builder /app /app
+`)
+
+	if len(df.MetaArgs) != 1 {
+		t.Errorf("Expected 1 meta ARG, got %d", len(df.MetaArgs))
+	}
+	if len(df.Stages) != 2 {
+		t.Fatalf("Expected 2 stages, got %d", len(df.Stages))
+	}
+
+	builder := df.Stages[0]
+	if builder.Name != "builder" || builder.BaseImage != "golang:1.22-alpine" || builder.Platform != "$BUILDPLATFORM" {
+		t.Errorf("Unexpected builder stage: %+v", builder)
+	}
+
+	copyInst := df.Stages[1].Instructions[0]
+	if from, ok := copyInst.Flag("from"); !ok || from != "builder" {
+		t.Errorf("Expected --from=builder flag, got %v", copyInst.Flags)
+	}
+	if copyInst.Stage != 1 {
+		t.Errorf("Expected COPY in stage 1, got %d", copyInst.Stage)
+	}
+	if !df.IsStageReference("builder", 1) {
+		t.Errorf("Expected builder to be a stage reference")
+	}
+}
+
+func TestParseUnknownInstruction(t *testing.T) {
+	_, err := ParseString("FROM alpine\nRUNN echo\n")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error pointing to line 2, got %v", err)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []KeyValue
+	}{
+		{
+			name:     "Quoted ENV values",
+			content:  `ENV A=1 B="two words" C='x'`,
+			expected: []KeyValue{{"A", "1", true}, {"B", "two words", true}, {"C", "x", true}},
+		},
+		{
+			name:     "Legacy ENV form",
+			content:  `ENV PATH /usr/local/bin:/usr/bin`,
+			expected: []KeyValue{{"PATH", "/usr/local/bin:/usr/bin", true}},
+		},
+		{
+			name:     "ARG without default",
+			content:  `ARG TOKEN`,
+			expected: []KeyValue{{"TOKEN", "", false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := mustParse(t, tt.content)
+			pairs := df.Instructions[0].KeyValues()
+			if len(pairs) != len(tt.expected) {
+				t.Fatalf("Expected %d pairs, got %v", len(tt.expected), pairs)
+			}
+			for i := range pairs {
+				if pairs[i] != tt.expected[i] {
+					t.Errorf("Expected %+v, got %+v", tt.expected[i], pairs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+// Package eol tells whether the language runtimes found in images are still
+// supported, from a table of the end-of-life dates of their releases. The
+// table ships embedded in dockeryzer and a local file can add releases or
+// correct dates without waiting for a new version.
+package eol
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the environment variable pointing to a local table merged
+// over the embedded one.
+const FileEnv = "DOCKERYZER_EOL_FILE"
+
+// NoticePeriod is how long before its end of life a release is reported as
+// approaching it.
+const NoticePeriod = 180 * 24 * time.Hour
+
+// States of a runtime version.
+const (
+	// StateSupported is a release with no end of life in the notice period,
+	// or a release newer than those in the table.
+	StateSupported = "supported"
+	// StateApproaching is a release whose end of life is in the notice period.
+	StateApproaching = "approaching"
+	// StateEnded is a release past its end of life, or older than the
+	// releases in the table.
+	StateEnded = "ended"
+	// StateUnlisted is a version the table has no release for.
+	StateUnlisted = "unlisted"
+)
+
+//go:embed runtimes.json
+var embedded []byte
+
+// Release is a release cycle of a runtime, e.g. Python 3.12 or Node.js 20.
+type Release struct {
+	// Release is the cycle, matching the versions that start with it.
+	Release string `json:"release"`
+	// Released is the date of the first release of the cycle, as
+	// YYYY-MM-DD, or empty when it is unknown.
+	Released string `json:"released,omitempty"`
+	// EOL is the date support ends, as YYYY-MM-DD, or empty when it is not
+	// announced yet.
+	EOL string `json:"eol,omitempty"`
+	// LTS marks the long-term support releases. When a runtime has them,
+	// only they are recommended as upgrade targets.
+	LTS bool `json:"lts,omitempty"`
+	// derived marks an EOL taken from the release of a newer cycle.
+	derived bool
+}
+
+// Runtime is the table of one runtime.
+type Runtime struct {
+	// Source is where the dates come from.
+	Source string `json:"source,omitempty"`
+	// SupportedReleases is, for runtimes that support a fixed number of
+	// releases at a time such as Go, that number. A release without EOL
+	// then ends when the release that many cycles newer comes out.
+	SupportedReleases int       `json:"supportedReleases,omitempty"`
+	Releases          []Release `json:"releases"`
+}
+
+// Dataset is the end-of-life table, keyed by the runtime names of the
+// language detection (Node.js, Python, Java, Go, PHP, Ruby and .NET).
+type Dataset struct {
+	Runtimes map[string]Runtime `json:"runtimes"`
+}
+
+// Status is the support status of a runtime version.
+type Status struct {
+	Runtime string
+	// Cycle is the release cycle of the version, e.g. 3.8 for 3.8.18.
+	Cycle string
+	// State is one of the State constants.
+	State string
+	// Release is the release of the version, nil when the table has none.
+	Release *Release
+	// Upgrade is the recommended release for versions that are not
+	// supported, nil when there is none.
+	Upgrade *Release
+}
+
+// Embedded returns the table shipped with dockeryzer.
+func Embedded() *Dataset {
+	dataset, err := parse(embedded)
+	if err != nil {
+		panic("eol: invalid embedded table: " + err.Error())
+	}
+	return dataset
+}
+
+// Load returns the embedded table merged with the file at path, when path
+// is not empty. Releases of the file replace the embedded releases of the
+// same cycle.
+func Load(path string) (*Dataset, error) {
+	dataset := Embedded()
+	if path == "" {
+		return dataset, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	override, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dataset.merge(override)
+	return dataset, nil
+}
+
+// parse decodes and validates a table. Unknown keys are rejected so that
+// typos do not silently drop dates.
+func parse(data []byte) (*Dataset, error) {
+	dataset := &Dataset{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dataset); err != nil {
+		return nil, err
+	}
+	for name, runtime := range dataset.Runtimes {
+		for _, release := range runtime.Releases {
+			if parseVersion(release.Release) == nil {
+				return nil, fmt.Errorf("%s: invalid release %q", name, release.Release)
+			}
+			if _, err := release.Start(); err != nil {
+				return nil, fmt.Errorf("%s %s: invalid released %q, expected YYYY-MM-DD", name, release.Release, release.Released)
+			}
+			if _, err := release.End(); err != nil {
+				return nil, fmt.Errorf("%s %s: invalid eol %q, expected YYYY-MM-DD", name, release.Release, release.EOL)
+			}
+		}
+		dataset.Runtimes[name] = sortReleases(runtime)
+	}
+	return dataset, nil
+}
+
+func (d *Dataset) merge(override *Dataset) {
+	if d.Runtimes == nil {
+		d.Runtimes = map[string]Runtime{}
+	}
+	for name, runtime := range override.Runtimes {
+		merged := d.Runtimes[name]
+		if runtime.Source != "" {
+			merged.Source = runtime.Source
+		}
+		if runtime.SupportedReleases != 0 {
+			merged.SupportedReleases = runtime.SupportedReleases
+		}
+		for _, release := range runtime.Releases {
+			i := slices.IndexFunc(merged.Releases, func(r Release) bool {
+				return compareVersions(parseVersion(r.Release), parseVersion(release.Release)) == 0
+			})
+			if i >= 0 {
+				merged.Releases[i] = release
+			} else {
+				merged.Releases = append(merged.Releases, release)
+			}
+		}
+		d.Runtimes[name] = sortReleases(merged)
+	}
+}
+
+// sortReleases orders the releases of a runtime from the newest and derives
+// the EOL of the releases without one when the runtime sets
+// SupportedReleases.
+func sortReleases(runtime Runtime) Runtime {
+	runtime.Releases = slices.Clone(runtime.Releases)
+	slices.SortStableFunc(runtime.Releases, func(a, b Release) int {
+		return compareVersions(parseVersion(b.Release), parseVersion(a.Release))
+	})
+	for i := range runtime.Releases {
+		release := &runtime.Releases[i]
+		if release.derived {
+			release.EOL, release.derived = "", false
+		}
+		if n := runtime.SupportedReleases; n > 0 && release.EOL == "" && i >= n && runtime.Releases[i-n].Released != "" {
+			release.EOL, release.derived = runtime.Releases[i-n].Released, true
+		}
+	}
+	return runtime
+}
+
+// Start returns the release date of the release, the zero time when it is
+// unknown.
+func (r Release) Start() (time.Time, error) {
+	if r.Released == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, r.Released)
+}
+
+// End returns the end-of-life date of the release, the zero time when it
+// is not announced.
+func (r Release) End() (time.Time, error) {
+	if r.EOL == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, r.EOL)
+}
+
+// state returns the state of the release at now.
+func (r Release) state(now time.Time) string {
+	end, _ := r.End()
+	switch {
+	case end.IsZero():
+		return StateSupported
+	case !now.Before(end):
+		return StateEnded
+	case now.Add(NoticePeriod).After(end):
+		return StateApproaching
+	default:
+		return StateSupported
+	}
+}
+
+// Has reports whether the table covers the runtime.
+func (d *Dataset) Has(runtime string) bool {
+	_, ok := d.Runtimes[runtime]
+	return ok
+}
+
+// Evaluate returns the status of a version of runtime at now. version may
+// carry a prefix or suffix, e.g. jdk-17.0.9+9, and Java versions may use
+// the legacy 1.8 numbering.
+func (d *Dataset) Evaluate(runtime, version string, now time.Time) Status {
+	status := Status{Runtime: runtime, State: StateUnlisted}
+	releases := d.Runtimes[runtime].Releases
+	parts := parseVersion(version)
+	if runtime == "Java" && len(parts) > 1 && parts[0] == 1 {
+		parts = parts[1:]
+	}
+	if len(releases) == 0 || parts == nil {
+		return status
+	}
+
+	for i, release := range releases {
+		cycle := parseVersion(release.Release)
+		if compareVersions(cycle, prefix(parts, len(cycle))) == 0 {
+			status.Cycle = release.Release
+			status.Release = &releases[i]
+			status.State = release.state(now)
+			break
+		}
+	}
+	if status.Release == nil {
+		newest := parseVersion(releases[0].Release)
+		oldest := parseVersion(releases[len(releases)-1].Release)
+		switch {
+		case compareVersions(prefix(parts, len(newest)), newest) > 0:
+			status.Cycle = formatVersion(prefix(parts, len(newest)))
+			status.State = StateSupported
+		case compareVersions(prefix(parts, len(oldest)), oldest) < 0:
+			status.Cycle = formatVersion(prefix(parts, len(oldest)))
+			status.State = StateEnded
+		default:
+			status.Cycle = formatVersion(prefix(parts, len(oldest)))
+		}
+	}
+
+	if status.State != StateSupported {
+		status.Upgrade = d.upgrade(runtime, now)
+	}
+	return status
+}
+
+// upgrade returns the newest release of runtime out and supported at now,
+// limited to the LTS releases when the runtime has them.
+func (d *Dataset) upgrade(runtime string, now time.Time) *Release {
+	releases := d.Runtimes[runtime].Releases
+	lts := slices.ContainsFunc(releases, func(r Release) bool { return r.LTS })
+	for i, release := range releases {
+		if start, _ := release.Start(); now.Before(start) {
+			continue
+		}
+		if (!lts || release.LTS) && release.state(now) == StateSupported {
+			return &releases[i]
+		}
+	}
+	return nil
+}
+
+// Message explains a status that is not supported, e.g. "Python 3.8
+// reached EOL on 2024-10-07. Upgrade to Python 3.13, supported until
+// 2029-10-31.".
+func (s Status) Message() string {
+	var b strings.Builder
+	name := s.Runtime + " " + s.Cycle
+	switch {
+	case s.State == StateEnded && s.Release != nil:
+		fmt.Fprintf(&b, "%s reached EOL on %s.", name, s.Release.EOL)
+	case s.State == StateEnded:
+		fmt.Fprintf(&b, "%s reached EOL.", name)
+	case s.State == StateApproaching:
+		fmt.Fprintf(&b, "%s reaches EOL on %s.", name, s.Release.EOL)
+	case s.State == StateUnlisted:
+		fmt.Fprintf(&b, "%s is not in the end-of-life table, check that it is still supported.", name)
+	case s.Release != nil && s.Release.EOL != "":
+		fmt.Fprintf(&b, "%s is supported until %s.", name, s.Release.EOL)
+	default:
+		fmt.Fprintf(&b, "%s is supported.", name)
+	}
+
+	if s.Upgrade != nil {
+		fmt.Fprintf(&b, " Upgrade to %s %s", s.Runtime, s.Upgrade.Release)
+		if s.Upgrade.LTS {
+			b.WriteString(" (LTS)")
+		}
+		if s.Upgrade.EOL != "" {
+			fmt.Fprintf(&b, ", supported until %s", s.Upgrade.EOL)
+		}
+		b.WriteString(".")
+	}
+	return b.String()
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// parseVersion returns the numeric components of the first version in s,
+// nil when there is none.
+func parseVersion(s string) []int {
+	match := versionPattern.FindString(s)
+	if match == "" {
+		return nil
+	}
+	parts := []int{}
+	for _, field := range strings.Split(match, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// prefix returns the first n components of parts, padded with zeros, so
+// that 8 matches the release 8.0.
+func prefix(parts []int, n int) []int {
+	result := make([]int, n)
+	copy(result, parts)
+	return result
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func formatVersion(parts []int) string {
+	fields := make([]string, len(parts))
+	for i, n := range parts {
+		fields[i] = strconv.Itoa(n)
+	}
+	return strings.Join(fields, ".")
+}
@@ -0,0 +1,150 @@
+package eol
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestEvaluate(t *testing.T) {
+	dataset := Embedded()
+	tests := []struct {
+		runtime string
+		version string
+		now     string
+		cycle   string
+		state   string
+		upgrade string
+	}{
+		{"Python", "3.8.18", "2024-10-07", "3.8", StateEnded, "3.13"},
+		{"Python", "3.8.18", "2024-10-06", "3.8", StateApproaching, "3.12"},
+		{"Python", "3.8.18", "2024-01-01", "3.8", StateSupported, ""},
+		{"Python", "2.6.9", "2024-01-01", "2.6", StateEnded, "3.12"},
+		{"Python", "3.99.0", "2024-01-01", "3.99", StateSupported, ""},
+		{"Node.js", "16.20.2", "2024-01-01", "16", StateEnded, "20"},
+		{"Node.js", "16.20.2", "2025-05-06", "16", StateEnded, "24"},
+		{"Node.js", "v20.11.1", "2024-01-01", "20", StateSupported, ""},
+		{"Java", "jdk-19.0.2+7", "2024-01-01", "19", StateEnded, "21"},
+		{"Java", "1.8.0_392", "2024-01-01", "8", StateSupported, ""},
+		{".NET", "8", "2026-06-01", "8.0", StateApproaching, "10.0"},
+		{"Go", "1.24.2", "2026-01-01", "1.24", StateApproaching, "1.25"},
+		{"Go", "1.24.2", "2026-10-16", "1.24", StateEnded, "1.26"},
+		{"Go", "1.25.1", "2026-10-16", "1.25", StateSupported, ""},
+		{"Go", "1.19.13", "2026-10-16", "1.19", StateEnded, "1.26"},
+		{"PHP", "6.0.0", "2024-01-01", "6.0", StateUnlisted, "8.3"},
+		{"Rust", "1.70.0", "2024-01-01", "", StateUnlisted, ""},
+		{"Python", "unknown", "2024-01-01", "", StateUnlisted, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.runtime+" "+tt.version+" "+tt.now, func(t *testing.T) {
+			status := dataset.Evaluate(tt.runtime, tt.version, date(tt.now))
+			if status.Cycle != tt.cycle || status.State != tt.state {
+				t.Errorf("Expected %s %s, got %s %s", tt.cycle, tt.state, status.Cycle, status.State)
+			}
+			upgrade := ""
+			if status.Upgrade != nil {
+				upgrade = status.Upgrade.Release
+			}
+			if upgrade != tt.upgrade {
+				t.Errorf("Expected upgrade %q, got %q", tt.upgrade, upgrade)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	dataset := Embedded()
+	tests := []struct {
+		runtime string
+		version string
+		now     string
+		message string
+	}{
+		{"Python", "3.8.18", "2024-11-01", "Python 3.8 reached EOL on 2024-10-07. Upgrade to Python 3.13, supported until 2029-10-31."},
+		{"Node.js", "20.11.1", "2026-01-01", "Node.js 20 reaches EOL on 2026-04-30. Upgrade to Node.js 24 (LTS), supported until 2028-04-30."},
+		{"Ruby", "2.2.10", "2024-01-01", "Ruby 2.2 reached EOL. Upgrade to Ruby 3.3, supported until 2027-03-31."},
+		{"PHP", "8.3.1", "2024-01-01", "PHP 8.3 is supported until 2027-12-31."},
+		{"Go", "1.25.1", "2026-01-01", "Go 1.25 is supported."},
+	}
+
+	for _, tt := range tests {
+		if message := dataset.Evaluate(tt.runtime, tt.version, date(tt.now)).Message(); message != tt.message {
+			t.Errorf("Expected %q, got %q", tt.message, message)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "eol.json")
+	content := `{
+  "runtimes": {
+    "Go": {"releases": [{"release": "1.24", "eol": "2026-02-10"}, {"release": "1.26"}]},
+    "Deno": {"source": "https://deno.com", "releases": [{"release": "2.1", "eol": "2025-07-01", "lts": true}]}
+  }
+}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dataset, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := dataset.Evaluate("Go", "1.24.2", date("2026-03-01")); status.State != StateEnded || status.Upgrade.Release != "1.26" {
+		t.Errorf("Expected the overridden Go 1.24 to be past EOL, got %+v", status)
+	}
+	if releases := dataset.Runtimes["Go"].Releases; releases[0].Release != "1.26" || releases[1].Release != "1.25" || dataset.Runtimes["Go"].Source == "" {
+		t.Errorf("Expected the merged releases from the newest, got %+v", dataset.Runtimes["Go"])
+	}
+	if !dataset.Has("Deno") || Embedded().Has("Deno") {
+		t.Errorf("Expected Deno only in the merged table")
+	}
+
+	newer := filepath.Join(dir, "newer.json")
+	if err := os.WriteFile(newer, []byte(`{"runtimes": {"Go": {"releases": [{"release": "1.27", "released": "2026-08-11"}]}}}`), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dataset, err = Load(newer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := dataset.Evaluate("Go", "1.25.1", date("2026-10-16")); status.State != StateEnded || status.Release.EOL != "2026-08-11" || status.Upgrade.Release != "1.27" {
+		t.Errorf("Expected Go 1.25 to end with the release of Go 1.27, got %+v", status)
+	}
+	if status := dataset.Evaluate("Go", "1.26.0", date("2026-10-16")); status.State != StateSupported || status.Release.EOL != "" {
+		t.Errorf("Expected Go 1.26 to be supported, got %+v", status)
+	}
+
+	if dataset, err := Load(""); err != nil || len(dataset.Runtimes) != len(Embedded().Runtimes) {
+		t.Errorf("Expected the embedded table, got %v (%v)", dataset, err)
+	}
+
+	for name, content := range map[string]string{
+		"date.json":     `{"runtimes": {"Go": {"releases": [{"release": "1.24", "eol": "Feb 2026"}]}}}`,
+		"release.json":  `{"runtimes": {"Go": {"releases": [{"release": "latest"}]}}}`,
+		"released.json": `{"runtimes": {"Go": {"releases": [{"release": "1.26", "released": "soon"}]}}}`,
+		"unknown.json":  `{"runtimes": {"Go": {"releases": [{"release": "1.24", "end": "2026-02-10"}]}}}`,
+		"broken.json":   `{"runtimes": `,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected an error loading %s", name)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected an error loading a missing file")
+	}
+}
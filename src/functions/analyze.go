@@ -3,25 +3,283 @@ package functions
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/jorgevvs2/dockeryzer/src/config"
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+	"github.com/jorgevvs2/dockeryzer/src/eol"
+	"github.com/jorgevvs2/dockeryzer/src/report"
 	"github.com/jorgevvs2/dockeryzer/src/security"
 	"github.com/jorgevvs2/dockeryzer/src/utils"
+	"github.com/jorgevvs2/dockeryzer/src/vuln"
 )
 
-func AnalyzeImage(name string) {
-	imageInspect := utils.GetDockerImageInspectByIdOrName(name)
-	utils.PrintImageAnalyzeResults(name, imageInspect)
+// ImageOptions are the settings of AnalyzeImage.
+type ImageOptions struct {
+	Format report.Format
+	Gate   security.Gate
+	// Config is the project configuration file, looked up from the working
+	// directory when empty.
+	Config string
+	// Policies are policy files or directories added to the configured ones.
+	Policies []string
+	// MetadataOnly skips exporting the image, and with it the filesystem rules.
+	MetadataOnly bool
+	// Layers adds the per-layer size breakdown and wasted space report.
+	Layers bool
+	// VulnerabilityDatabase is the advisory database directory, taken from
+	// the configuration or the default location when empty.
+	VulnerabilityDatabase string
 }
 
-func AnalyzeDockerfile(path string) {
-	content, err := os.ReadFile(path)
+// AnalyzeImage prints the analysis of an image and returns the exit code,
+// ExitPolicyViolation when the image policy rules break the gate.
+func AnalyzeImage(name string, options ImageOptions) int {
+	analyzer, code := newAnalyzer(options.Config, options.Policies, options.VulnerabilityDatabase)
+	if analyzer == nil {
+		return code
+	}
+
+	imageInspect, filesystem, err := utils.LoadImage(name, options.MetadataOnly)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the image:", err)
+		return utils.LoadImageExitCode(name)
+	}
+	if filesystem != nil {
+		defer filesystem.Close()
+	}
+	target := &security.ImageTarget{Name: name, Inspect: imageInspect, Filesystem: filesystem}
+	results := analyzer.AnalyzeImage(target)
+
+	var breakdown *utils.LayerBreakdown
+	if options.Layers {
+		layers := utils.GetLayerBreakdown(target.Filesystem)
+		breakdown = &layers
+	}
+
+	if options.Format == report.FormatText {
+		if breakdown != nil {
+			utils.PrintImageAnalyzeResultsWithLayers(name, imageInspect, filesystem, *breakdown)
+		} else {
+			utils.PrintImageAnalyzeResults(name, imageInspect, filesystem)
+		}
+		if len(results) > 0 {
+			security.PrintCISResults(results)
+		}
+	} else {
+		analysis := utils.GetImageAnalysis(name, imageInspect, filesystem)
+		if breakdown != nil {
+			analysis.LayerBreakdown = breakdown
+			analysis.Suggestions = utils.GetImageSuggestionsWithLayers(imageInspect, filesystem, *breakdown)
+		}
+		imageReport := report.NewImageReport(analysis, results)
+		if err := report.WriteImageReport(os.Stdout, options.Format, imageReport); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+			return utils.ExitFailure
+		}
+	}
+
+	return checkGate(options.Gate, results)
+}
+
+// DockerfileOptions are the settings of AnalyzeDockerfile.
+type DockerfileOptions struct {
+	// Target is the stage analyzed as the shipped image, empty for the last one.
+	Target string
+	Format report.Format
+	Gate   security.Gate
+	// Baseline is the baseline file whose findings are suppressed, empty for none.
+	Baseline string
+	// UpdateBaseline records the current failures in Baseline instead of applying it.
+	UpdateBaseline bool
+	// Config is the project configuration file, looked up from the working
+	// directory when empty.
+	Config string
+	// Policies are policy files or directories added to the configured ones.
+	Policies []string
+	// Fix rewrites the Dockerfile to resolve the fixable findings before
+	// analyzing it.
+	Fix bool
+	// DryRun prints the fixes as a unified diff instead of applying them.
+	DryRun bool
+}
+
+// AnalyzeDockerfile prints the CIS results of a Dockerfile and returns the
+// exit code, ExitPolicyViolation when the results break the gate.
+func AnalyzeDockerfile(path string, options DockerfileOptions) int {
+	df, err := dockerfile.ParseFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read Dockerfile:", err)
+		return utils.ExitUsage
+	}
+
+	analyzer, code := newAnalyzer(options.Config, options.Policies, "")
+	if analyzer == nil {
+		return code
+	}
+
+	var baseline *security.Baseline
+	if options.Baseline != "" && !options.UpdateBaseline {
+		baseline, err = security.LoadBaseline(options.Baseline)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read baseline:", err)
+			return utils.ExitUsage
+		}
+	}
+
+	if options.Fix {
+		df, code = fixDockerfile(df, analyzer, baseline, options)
+		if df == nil {
+			return code
+		}
+	}
+
+	results, err := analyzer.Analyze(df, options.Target)
 	if err != nil {
-		fmt.Println("Failed to read Dockerfile:", err)
-		return
+		fmt.Fprintln(os.Stderr, "Failed to analyze Dockerfile:", err)
+		return utils.ExitUsage
+	}
+
+	if options.UpdateBaseline {
+		baseline := security.NewBaseline(results)
+		if err := baseline.Save(options.Baseline); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write baseline:", err)
+			return utils.ExitFailure
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %d finding(s) in %s\n", len(baseline.Findings), options.Baseline)
+		return utils.ExitOK
 	}
 
-	analyzer := security.NewCISAnalyzer()
-	results := analyzer.Analyze(string(content))
+	if baseline != nil {
+		baseline.Apply(results)
+	}
 
-	security.PrintCISResults(results)
+	if options.Format == report.FormatText {
+		security.PrintCISResults(results)
+	} else {
+		dockerfileReport := report.NewDockerfileReport(path, options.Target, results)
+		if err := report.WriteDockerfileReport(os.Stdout, options.Format, dockerfileReport); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+			return utils.ExitFailure
+		}
+	}
+
+	return checkGate(options.Gate, results)
+}
+
+// fixDockerfile applies the automatic fixes to df and writes the result back
+// to its file, or prints them as a unified diff with options.DryRun. It
+// returns the Dockerfile to analyze, or nil and the exit code when there is
+// nothing left to do.
+func fixDockerfile(df *dockerfile.Dockerfile, analyzer *security.CISAnalyzer, baseline *security.Baseline, options DockerfileOptions) (*dockerfile.Dockerfile, int) {
+	source := strings.Join(df.Lines, "\n")
+	fixed, edits, err := analyzer.Fix(df, security.FixOptions{
+		Target:   options.Target,
+		PinImage: utils.PinImage,
+		Baseline: baseline,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to fix Dockerfile:", err)
+		return nil, utils.ExitFailure
+	}
+
+	for _, edit := range edits {
+		fmt.Fprintf(os.Stderr, "Fixed %s: %s\n", edit.RuleID, edit.Summary)
+	}
+	if len(edits) == 0 {
+		fmt.Fprintln(os.Stderr, "No automatic fixes available")
+	}
+
+	if options.DryRun {
+		fmt.Print(report.UnifiedDiff("a/"+df.Path, "b/"+df.Path, source, fixed))
+		return nil, utils.ExitOK
+	}
+	if len(edits) == 0 {
+		return df, utils.ExitOK
+	}
+
+	info, err := os.Stat(df.Path)
+	if err == nil {
+		err = os.WriteFile(df.Path, []byte(fixed), info.Mode().Perm())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write Dockerfile:", err)
+		return nil, utils.ExitFailure
+	}
+
+	fixedDockerfile, err := dockerfile.ParseFile(df.Path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read Dockerfile:", err)
+		return nil, utils.ExitFailure
+	}
+	return fixedDockerfile, utils.ExitOK
+}
+
+// newAnalyzer builds the analyzer for the configuration at configPath or,
+// when configPath is empty, the .dockeryzer.yaml found from the working
+// directory upwards. vulnDB overrides the configured advisory database,
+// which defaults to the one imported in vuln.DefaultDir, if any. On failure
+// it returns a nil analyzer and the exit code.
+func newAnalyzer(configPath string, policies []string, vulnDB string) (*security.CISAnalyzer, int) {
+	var project *config.Project
+	var err error
+	if configPath != "" {
+		project, err = config.LoadProject(configPath)
+	} else {
+		project, err = config.LoadProjectFrom(".")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read configuration:", err)
+		return nil, utils.ExitUsage
+	}
+	project.Policies = append(project.Policies, policies...)
+	if vulnDB != "" {
+		project.VulnerabilityDatabase = vulnDB
+	} else if project.VulnerabilityDatabase == "" && vuln.Imported(vuln.DefaultDir()) {
+		project.VulnerabilityDatabase = vuln.DefaultDir()
+	}
+
+	if code := useRuntimeEndOfLife(project.RuntimeEndOfLife); code != utils.ExitOK {
+		return nil, code
+	}
+
+	analyzer, err := security.NewConfiguredCISAnalyzer(project)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return nil, utils.ExitUsage
+	}
+	return analyzer, utils.ExitOK
+}
+
+// useRuntimeEndOfLife merges the runtime end-of-life dates of the file at
+// path, or else of the file named by DOCKERYZER_EOL_FILE, over the embedded
+// table used by the language checks.
+func useRuntimeEndOfLife(path string) int {
+	if path == "" {
+		path = os.Getenv(eol.FileEnv)
+	}
+	if path == "" {
+		return utils.ExitOK
+	}
+	dataset, err := eol.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the runtime end-of-life table:", err)
+		return utils.ExitUsage
+	}
+	utils.UseRuntimeEndOfLife(dataset)
+	return utils.ExitOK
+}
+
+// checkGate reports on stderr why results break gate, keeping stdout clean
+// for machine-readable reports.
+func checkGate(gate security.Gate, results []security.CISResult) int {
+	reasons := gate.Check(results)
+	if reasons == nil {
+		return utils.ExitOK
+	}
+
+	for _, reason := range reasons {
+		fmt.Fprintln(os.Stderr, "Failing:", reason)
+	}
+	return utils.ExitPolicyViolation
 }
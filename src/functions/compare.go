@@ -1,22 +1,296 @@
 package functions
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+	"github.com/jorgevvs2/dockeryzer/src/imagediff"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
 	"github.com/jorgevvs2/dockeryzer/src/utils"
 )
 
-func Compare(image1, image2 string) {
-	image1Inspect := utils.GetDockerImageInspectByIdOrName(image1)
-	image2Inspect := utils.GetDockerImageInspectByIdOrName(image2)
+// CompareOptions are the settings of Compare.
+type CompareOptions struct {
+	// Format is FormatText or FormatJSON.
+	Format report.Format
+	// Config is the project configuration file, looked up from the working
+	// directory when empty.
+	Config string
+	// MetadataOnly compares the image configurations only, without
+	// exporting the filesystems.
+	MetadataOnly bool
+	// Files adds the file-level comparison of the filesystems, limited by
+	// FileFilter.
+	Files      bool
+	FileFilter imagediff.FileFilter
+	// Reference is the image, one of those compared, that the others are
+	// diffed against in the matrix. Empty for none.
+	Reference string
+	// Context is the build context of CompareBuilds.
+	Context string
+	// NoCache builds the images of CompareBuilds without the build cache,
+	// so that neither build reuses the layers of the other.
+	NoCache bool
+}
+
+// Compare prints the differences between images and returns the exit code.
+// Two images without a reference are diffed from the first to the second;
+// otherwise the images are ranked side by side in a matrix.
+func Compare(images []string, options CompareOptions) int {
+	analyzer, code := newAnalyzer(options.Config, nil, "")
+	if analyzer == nil {
+		return code
+	}
+
+	loaded := []imagediff.Image{}
+	for _, reference := range images {
+		img, code := loadCompareImage(reference, options.MetadataOnly, analyzer)
+		if code != utils.ExitOK {
+			return code
+		}
+		if img.Filesystem != nil {
+			defer img.Filesystem.Close()
+		}
+		loaded = append(loaded, img)
+	}
+
+	if len(loaded) == 2 && options.Reference == "" {
+		return comparePair(loaded[0], loaded[1], options, nil)
+	}
+
+	matrix := imagediff.NewMatrix(loaded, options.Reference)
+	if options.Files {
+		i := slices.IndexFunc(loaded, func(img imagediff.Image) bool { return img.Name == options.Reference })
+		others := slices.Delete(slices.Clone(loaded), i, i+1)
+		for j := range matrix.Diffs {
+			if code := compareFiles(&matrix.Diffs[j], loaded[i], others[j], options.FileFilter); code != utils.ExitOK {
+				return code
+			}
+		}
+	}
+	if err := imagediff.WriteMatrix(os.Stdout, options.Format, matrix); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+		return utils.ExitFailure
+	}
+	return utils.ExitOK
+}
+
+// comparePair prints the details of two images and the differences from
+// the first to the second, with their builds when compare built them.
+func comparePair(oldImage, newImage imagediff.Image, options CompareOptions, build *imagediff.BuildChange) int {
+	diff := imagediff.Compare(oldImage, newImage)
+	diff.Build = build
+	if options.Files {
+		if code := compareFiles(&diff, oldImage, newImage, options.FileFilter); code != utils.ExitOK {
+			return code
+		}
+	}
+	if options.Format == report.FormatText {
+		utils.PrintImageCompareResults(oldImage.Name, oldImage.Inspect, oldImage.Filesystem)
+		fmt.Println()
+		utils.PrintImageCompareResults(newImage.Name, newImage.Inspect, newImage.Filesystem)
+		fmt.Println()
+	}
+	if err := imagediff.Write(os.Stdout, options.Format, diff); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+		return utils.ExitFailure
+	}
+	return utils.ExitOK
+}
+
+// compareFiles adds the file-level comparison of the images to diff.
+func compareFiles(diff *imagediff.Diff, oldImage, newImage imagediff.Image, filter imagediff.FileFilter) int {
+	files, err := imagediff.CompareFiles(oldImage.Filesystem, newImage.Filesystem, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to compare the files:", err)
+		return utils.ExitFailure
+	}
+	diff.Files = &files
+	return utils.ExitOK
+}
+
+// CompareBuilds builds two Dockerfiles in options.Context under temporary
+// tags, one after the other, prints the differences between the images with
+// the duration and cache hits of the builds, and removes the images. An
+// interrupt stops the running build and removes the images built so far. It
+// returns the exit code.
+func CompareBuilds(oldDockerfile, newDockerfile string, options CompareOptions) int {
+	analyzer, code := newAnalyzer(options.Config, nil, "")
+	if analyzer == nil {
+		return code
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	built := &builtImages{}
+	defer built.remove()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+			fmt.Fprintln(os.Stderr, "Interrupted, removing the images built so far...")
+			built.remove()
+			os.Exit(utils.ExitFailure)
+		case <-ctx.Done():
+		}
+	}()
+
+	images := []imagediff.Image{}
+	builds := []utils.BuildStats{}
+	run := time.Now().UnixNano()
+	for i, path := range []string{oldDockerfile, newDockerfile} {
+		tag := fmt.Sprintf("dockeryzer-compare:%d-%d", run, i)
+		fmt.Fprintf(os.Stderr, "Building %s as %s...\n", path, tag)
+		stats, err := utils.BuildImage(ctx, options.Context, path, tag, options.NoCache)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to build the image:", err)
+			return utils.ExitDockerError
+		}
+		built.add(tag)
+		if !options.NoCache {
+			stats.Order = i + 1
+		}
+
+		img, code := loadCompareImage(tag, options.MetadataOnly, analyzer)
+		if code != utils.ExitOK {
+			return code
+		}
+		if img.Filesystem != nil {
+			built.addFilesystem(img.Filesystem)
+		}
+		img.Name = path
+		images = append(images, img)
+		builds = append(builds, stats)
+	}
+
+	return comparePair(images[0], images[1], options, &imagediff.BuildChange{Old: builds[0], New: builds[1]})
+}
+
+// builtImages are the images tagged by CompareBuilds and the filesystems
+// exported from them. They are removed once, when CompareBuilds returns or
+// is interrupted, whichever comes first.
+type builtImages struct {
+	mu          sync.Mutex
+	tags        []string
+	filesystems []io.Closer
+	removed     bool
+}
+
+// add records a built image, removing it right away when the others are
+// already gone.
+func (b *builtImages) add(tag string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.removed {
+		removeBuiltImage(tag)
+		return
+	}
+	b.tags = append(b.tags, tag)
+}
+
+// addFilesystem records the filesystem exported from a built image.
+func (b *builtImages) addFilesystem(filesystem io.Closer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.removed {
+		filesystem.Close()
+		return
+	}
+	b.filesystems = append(b.filesystems, filesystem)
+}
+
+// remove closes the filesystems and removes the images recorded so far.
+func (b *builtImages) remove() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.removed {
+		return
+	}
+	b.removed = true
+	for _, filesystem := range b.filesystems {
+		filesystem.Close()
+	}
+	for _, tag := range b.tags {
+		removeBuiltImage(tag)
+	}
+}
+
+// removeBuiltImage removes an image built by CompareBuilds, warning when it
+// cannot.
+func removeBuiltImage(tag string) {
+	if err := utils.RemoveImage(tag); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove %s: %v\n", tag, err)
+	}
+}
+
+// CompareDockerfiles prints the semantic differences from the Dockerfile at
+// oldPath to the one at newPath and the delta of their rule results, and
+// returns the exit code.
+func CompareDockerfiles(oldPath, newPath string, options CompareOptions) int {
+	analyzer, code := newAnalyzer(options.Config, nil, "")
+	if analyzer == nil {
+		return code
+	}
 
-	utils.PrintImageCompareResults(image1, image1Inspect)
-	fmt.Println()
-	utils.PrintImageCompareResults(image2, image2Inspect)
-	fmt.Println()
+	oldDockerfile, code := loadCompareDockerfile(oldPath, analyzer)
+	if code != utils.ExitOK {
+		return code
+	}
+	newDockerfile, code := loadCompareDockerfile(newPath, analyzer)
+	if code != utils.ExitOK {
+		return code
+	}
+
+	diff := imagediff.CompareDockerfiles(oldDockerfile, newDockerfile)
+	if err := imagediff.WriteDockerfileDiff(os.Stdout, options.Format, diff); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+		return utils.ExitFailure
+	}
+	return utils.ExitOK
+}
+
+// loadCompareDockerfile parses a Dockerfile and runs the Dockerfile rules
+// against its last stage.
+func loadCompareDockerfile(path string, analyzer *security.CISAnalyzer) (imagediff.Dockerfile, int) {
+	df, err := dockerfile.ParseFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read Dockerfile:", err)
+		return imagediff.Dockerfile{}, utils.ExitUsage
+	}
+	results, err := analyzer.Analyze(df, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to analyze Dockerfile:", err)
+		return imagediff.Dockerfile{}, utils.ExitUsage
+	}
+	return imagediff.Dockerfile{Name: path, Dockerfile: df, Results: results}, utils.ExitOK
+}
 
-	fmt.Println("Differences:")
-	utils.PrintImageCompareLayersResults(image1, image1Inspect, image2, image2Inspect)
-	utils.PrintImageCompareSizeResults(image1, image1Inspect, image2, image2Inspect)
-	//utils.PrintImageCompareNodeJsResults(image1, image1Inspect, image2, image2Inspect)
+// loadCompareImage reads an image from any source and runs the image rules
+// against it.
+func loadCompareImage(reference string, metadataOnly bool, analyzer *security.CISAnalyzer) (imagediff.Image, int) {
+	imageInspect, filesystem, err := utils.LoadImage(reference, metadataOnly)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the image:", err)
+		return imagediff.Image{}, utils.LoadImageExitCode(reference)
+	}
+	target := &security.ImageTarget{Name: reference, Inspect: imageInspect, Filesystem: filesystem}
+	return imagediff.Image{
+		Name:       reference,
+		Inspect:    imageInspect,
+		Filesystem: filesystem,
+		Results:    analyzer.AnalyzeImage(target),
+	}, utils.ExitOK
 }
@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jorgevvs2/dockeryzer/src/sbom"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+// SBOMOptions are the settings of GenerateSBOM.
+type SBOMOptions struct {
+	Format sbom.Format
+	// File is where the SBOM is written, standard output when empty.
+	File string
+}
+
+// GenerateSBOM writes the software bill of materials of an image and returns
+// the exit code.
+func GenerateSBOM(name string, options SBOMOptions) int {
+	_, filesystem, err := utils.LoadImage(name, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the image:", err)
+		return utils.LoadImageExitCode(name)
+	}
+	defer filesystem.Close()
+
+	document, err := sbom.Generate(name, filesystem)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the image filesystem:", err)
+		return utils.ExitFailure
+	}
+
+	var w io.Writer = os.Stdout
+	if options.File != "" {
+		f, err := os.Create(options.File)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write SBOM:", err)
+			return utils.ExitFailure
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := sbom.Write(w, options.Format, document); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write SBOM:", err)
+		return utils.ExitFailure
+	}
+	if options.File != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d packages to %s\n", len(document.Packages), options.File)
+	}
+	return utils.ExitOK
+}
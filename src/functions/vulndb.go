@@ -0,0 +1,65 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+	"github.com/jorgevvs2/dockeryzer/src/vuln"
+)
+
+// databaseDir returns dir, or the default database directory when empty.
+func databaseDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return vuln.DefaultDir()
+}
+
+// ImportVulnerabilities adds the advisories of OSV dumps to the database in
+// dir and returns the exit code.
+func ImportVulnerabilities(dir string, sources []string) int {
+	dir = databaseDir(dir)
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "No cache directory found, set %s or use --db\n", vuln.DatabaseEnv)
+		return utils.ExitUsage
+	}
+
+	stats, err := vuln.Import(dir, sources...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to import advisories:", err)
+		return utils.ExitFailure
+	}
+	fmt.Printf("Imported %d advisories into %s\n", stats.Advisories, dir)
+	if stats.Withdrawn > 0 {
+		fmt.Printf("Removed %d withdrawn advisories\n", stats.Withdrawn)
+	}
+	if len(stats.Ecosystems) > 0 {
+		fmt.Println("Updated ecosystems:", strings.Join(stats.Ecosystems, ", "))
+	}
+	return utils.ExitOK
+}
+
+// PrintVulnerabilityDatabase prints the ecosystems of the database in dir
+// and returns the exit code.
+func PrintVulnerabilityDatabase(dir string) int {
+	db, err := vuln.Open(databaseDir(dir))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read the vulnerability database:", err)
+		return utils.ExitUsage
+	}
+
+	fmt.Println("Vulnerability database:", db.Dir)
+	fmt.Println("Updated:", db.Updated.Format("2006-01-02 15:04 MST"))
+	names := make([]string, 0, len(db.Ecosystems))
+	for name := range db.Ecosystems {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Printf("  %-24s %7d advisories\n", name, db.Ecosystems[name].Advisories)
+	}
+	return utils.ExitOK
+}
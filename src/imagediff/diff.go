@@ -0,0 +1,445 @@
+// Package imagediff compares two images: their configuration, the layers
+// they share, their base OS and language runtimes, and the findings of the
+// image rules. The result is a typed Diff rendered as text or JSON. It also
+// ranks several images in a Matrix and compares two Dockerfiles stage by
+// stage in a DockerfileDiff.
+package imagediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+)
+
+// Kinds of change.
+const (
+	Added     = "added"
+	Removed   = "removed"
+	Changed   = "changed"
+	Unchanged = "unchanged"
+	// Moved is an instruction of a Dockerfile stage that changed place.
+	Moved = "moved"
+)
+
+// Image is one side of a comparison.
+type Image struct {
+	Name    string
+	Inspect image.InspectResponse
+	// Filesystem is nil when only the configuration was read. The layer
+	// sizes and the base OS are then unknown, and the runtimes are detected
+	// from the configuration alone.
+	Filesystem *imagefs.Image
+	// Results are the results of the image rules.
+	Results []security.CISResult
+}
+
+// Summary describes an image of the comparison.
+type Summary struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Layers    int    `json:"layers"`
+	// Score and Grade rate the results of the image rules, see
+	// security.Evaluate.
+	Score int    `json:"score"`
+	Grade string `json:"grade"`
+}
+
+// Diff is the difference from the image Old to the image New.
+type Diff struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	Old           Summary        `json:"old"`
+	New           Summary        `json:"new"`
+	Config        []ConfigChange `json:"config"`
+	Layers        LayerDiff      `json:"layers"`
+	// BaseOS is nil when the base OS of neither image is known.
+	BaseOS   *BaseOSChange   `json:"baseOS,omitempty"`
+	Runtimes []RuntimeChange `json:"runtimes"`
+	Findings FindingDiff     `json:"findings"`
+	// Files is only filled in when the filesystems are compared, see
+	// CompareFiles.
+	Files *FileDiff `json:"files,omitempty"`
+	// Build is only filled in when compare built both images itself.
+	Build *BuildChange `json:"build,omitempty"`
+}
+
+// BuildChange puts side by side the builds of both images.
+type BuildChange struct {
+	Old utils.BuildStats `json:"old"`
+	New utils.BuildStats `json:"new"`
+}
+
+// ConfigChange is a change of one configuration field. Keyed fields (Env,
+// ExposedPorts, Volumes and Labels) have one change per key; the others
+// have a single change with an empty Key.
+type ConfigChange struct {
+	Field string `json:"field"`
+	Key   string `json:"key,omitempty"`
+	// Kind is Added, Removed or Changed.
+	Kind string `json:"kind"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Layer is a layer of one or both images.
+type Layer struct {
+	Digest string `json:"digest"`
+	// SizeBytes and Instruction are only known when the filesystem was read.
+	SizeBytes   int64  `json:"sizeBytes,omitempty"`
+	Instruction string `json:"instruction,omitempty"`
+}
+
+// LayerDiff splits the layers of the images by digest.
+type LayerDiff struct {
+	// Shared are the layers of both images, in the order of Old.
+	Shared  []Layer `json:"shared"`
+	OldOnly []Layer `json:"oldOnly"`
+	NewOnly []Layer `json:"newOnly"`
+	// CommonBase is the number of layers both images start with, which is
+	// usually the base image they have in common.
+	CommonBase   int   `json:"commonBase"`
+	SharedBytes  int64 `json:"sharedBytes"`
+	OldOnlyBytes int64 `json:"oldOnlyBytes"`
+	NewOnlyBytes int64 `json:"newOnlyBytes"`
+}
+
+// BaseOSChange compares the base OS of the images. Old or New is nil when
+// the base OS of that image is unknown.
+type BaseOSChange struct {
+	// Kind is Added, Removed, Changed or Unchanged.
+	Kind string        `json:"kind"`
+	Old  *utils.OSInfo `json:"old,omitempty"`
+	New  *utils.OSInfo `json:"new,omitempty"`
+}
+
+// RuntimeChange compares a language runtime of the images.
+type RuntimeChange struct {
+	Name string `json:"name"`
+	// Kind is Added, Removed, Changed or Unchanged.
+	Kind string              `json:"kind"`
+	Old  *utils.LanguageInfo `json:"old,omitempty"`
+	New  *utils.LanguageInfo `json:"new,omitempty"`
+}
+
+// FindingDiff compares the failed results of the image rules.
+type FindingDiff struct {
+	// Fixed fail in Old only and Introduced in New only.
+	Fixed      []security.CISResult `json:"fixed"`
+	Introduced []security.CISResult `json:"introduced"`
+	// Remaining is the number of failures of both images.
+	Remaining int `json:"remaining"`
+}
+
+// Compare returns the difference from old to new.
+func Compare(old, new Image) Diff {
+	return Diff{
+		SchemaVersion: report.SchemaVersion,
+		Old:           summarize(old),
+		New:           summarize(new),
+		Config:        compareConfig(old.Inspect.Config, new.Inspect.Config),
+		Layers:        compareLayers(old, new),
+		BaseOS:        compareBaseOS(utils.DetectOS(old.Filesystem), utils.DetectOS(new.Filesystem)),
+		Runtimes: compareRuntimes(
+			utils.DetectLanguages(old.Inspect, old.Filesystem),
+			utils.DetectLanguages(new.Inspect, new.Filesystem)),
+		Findings: compareFindings(old.Results, new.Results),
+	}
+}
+
+func summarize(img Image) Summary {
+	card := security.Evaluate(img.Results)
+	return Summary{
+		Name:      img.Name,
+		ID:        img.Inspect.ID,
+		SizeBytes: img.Inspect.Size,
+		Layers:    len(img.Inspect.RootFS.Layers),
+		Score:     card.Score,
+		Grade:     card.Grade,
+	}
+}
+
+// compareConfig lists the changes of the fields that affect how a
+// container of the image runs.
+func compareConfig(old, new *dockerspec.DockerOCIImageConfig) []ConfigChange {
+	if old == nil {
+		old = &dockerspec.DockerOCIImageConfig{}
+	}
+	if new == nil {
+		new = &dockerspec.DockerOCIImageConfig{}
+	}
+
+	changes := []ConfigChange{}
+	changes = append(changes, compareKeys("Env", envMap(old.Env), envMap(new.Env))...)
+	changes = append(changes, compareValue("Entrypoint", formatCommand(old.Entrypoint), formatCommand(new.Entrypoint))...)
+	changes = append(changes, compareValue("Cmd", formatCommand(old.Cmd), formatCommand(new.Cmd))...)
+	changes = append(changes, compareValue("WorkingDir", old.WorkingDir, new.WorkingDir)...)
+	changes = append(changes, compareValue("User", old.User, new.User)...)
+	changes = append(changes, compareKeys("ExposedPorts", setMap(old.ExposedPorts), setMap(new.ExposedPorts))...)
+	changes = append(changes, compareKeys("Volumes", setMap(old.Volumes), setMap(new.Volumes))...)
+	changes = append(changes, compareKeys("Labels", old.Labels, new.Labels)...)
+	changes = append(changes, compareValue("Healthcheck", formatHealthcheck(old.Healthcheck), formatHealthcheck(new.Healthcheck))...)
+	return changes
+}
+
+func compareValue(field, old, new string) []ConfigChange {
+	switch {
+	case old == new:
+		return nil
+	case old == "":
+		return []ConfigChange{{Field: field, Kind: Added, New: new}}
+	case new == "":
+		return []ConfigChange{{Field: field, Kind: Removed, Old: old}}
+	default:
+		return []ConfigChange{{Field: field, Kind: Changed, Old: old, New: new}}
+	}
+}
+
+// compareKeys compares the keys of a field in alphabetical order.
+func compareKeys(field string, old, new map[string]string) []ConfigChange {
+	keys := []string{}
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	changes := []ConfigChange{}
+	for _, key := range keys {
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+		switch {
+		case !inOld:
+			changes = append(changes, ConfigChange{Field: field, Key: key, Kind: Added, New: newValue})
+		case !inNew:
+			changes = append(changes, ConfigChange{Field: field, Key: key, Kind: Removed, Old: oldValue})
+		case oldValue != newValue:
+			changes = append(changes, ConfigChange{Field: field, Key: key, Kind: Changed, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func envMap(env []string) map[string]string {
+	values := map[string]string{}
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		values[name] = value
+	}
+	return values
+}
+
+func setMap(set map[string]struct{}) map[string]string {
+	values := map[string]string{}
+	for key := range set {
+		values[key] = ""
+	}
+	return values
+}
+
+// formatCommand formats a command in exec form, e.g. ["node","index.js"].
+func formatCommand(command []string) string {
+	if len(command) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(command)
+	return string(data)
+}
+
+// formatHealthcheck formats a health check the way it is written in a
+// Dockerfile, e.g. "--interval=30s --retries=3 CMD curl -f localhost".
+func formatHealthcheck(health *dockerspec.HealthcheckConfig) string {
+	if health == nil || len(health.Test) == 0 {
+		return ""
+	}
+	fields := []string{}
+	for _, option := range []struct {
+		name  string
+		value fmt.Stringer
+	}{
+		{"interval", health.Interval},
+		{"timeout", health.Timeout},
+		{"start-period", health.StartPeriod},
+		{"start-interval", health.StartInterval},
+	} {
+		if option.value.String() != "0s" {
+			fields = append(fields, fmt.Sprintf("--%s=%s", option.name, option.value))
+		}
+	}
+	if health.Retries != 0 {
+		fields = append(fields, fmt.Sprintf("--retries=%d", health.Retries))
+	}
+
+	switch health.Test[0] {
+	case "NONE":
+		fields = append(fields, "NONE")
+	case "CMD-SHELL":
+		fields = append(fields, "CMD "+strings.Join(health.Test[1:], " "))
+	default:
+		fields = append(fields, "CMD "+formatCommand(health.Test[1:]))
+	}
+	return strings.Join(fields, " ")
+}
+
+// compareLayers matches the layers of the images by their digest. The
+// digests of the image configuration are the reference; the filesystem,
+// when read, adds the sizes and instructions.
+func compareLayers(old, new Image) LayerDiff {
+	oldLayers := layers(old)
+	newLayers := layers(new)
+	diff := LayerDiff{Shared: []Layer{}, OldOnly: []Layer{}, NewOnly: []Layer{}}
+
+	for diff.CommonBase < min(len(oldLayers), len(newLayers)) && oldLayers[diff.CommonBase].Digest == newLayers[diff.CommonBase].Digest {
+		diff.CommonBase++
+	}
+
+	inNew := map[string]Layer{}
+	for _, layer := range newLayers {
+		inNew[layer.Digest] = layer
+	}
+	inOld := map[string]bool{}
+	for _, layer := range oldLayers {
+		inOld[layer.Digest] = true
+		if shared, ok := inNew[layer.Digest]; ok {
+			layer.SizeBytes = max(layer.SizeBytes, shared.SizeBytes)
+			if layer.Instruction == "" {
+				layer.Instruction = shared.Instruction
+			}
+			diff.Shared = append(diff.Shared, layer)
+			diff.SharedBytes += layer.SizeBytes
+		} else {
+			diff.OldOnly = append(diff.OldOnly, layer)
+			diff.OldOnlyBytes += layer.SizeBytes
+		}
+	}
+	for _, layer := range newLayers {
+		if !inOld[layer.Digest] {
+			diff.NewOnly = append(diff.NewOnly, layer)
+			diff.NewOnlyBytes += layer.SizeBytes
+		}
+	}
+	return diff
+}
+
+func layers(img Image) []Layer {
+	read := map[string]*imagefs.Layer{}
+	if img.Filesystem != nil {
+		for _, layer := range img.Filesystem.Layers {
+			read[layer.DiffID] = layer
+		}
+	}
+
+	result := []Layer{}
+	for _, digest := range img.Inspect.RootFS.Layers {
+		layer := Layer{Digest: digest}
+		if fsLayer, ok := read[digest]; ok {
+			layer.SizeBytes = fsLayer.Size
+			layer.Instruction = utils.LayerInstruction(fsLayer.CreatedBy)
+		}
+		result = append(result, layer)
+	}
+	return result
+}
+
+func compareBaseOS(old, new *utils.OSInfo) *BaseOSChange {
+	switch {
+	case old == nil && new == nil:
+		return nil
+	case old == nil:
+		return &BaseOSChange{Kind: Added, New: new}
+	case new == nil:
+		return &BaseOSChange{Kind: Removed, Old: old}
+	case *old == *new:
+		return &BaseOSChange{Kind: Unchanged, Old: old, New: new}
+	default:
+		return &BaseOSChange{Kind: Changed, Old: old, New: new}
+	}
+}
+
+// compareRuntimes pairs the runtimes of the images by name, in the order of
+// New followed by those removed from Old.
+func compareRuntimes(old, new []utils.LanguageInfo) []RuntimeChange {
+	changes := []RuntimeChange{}
+	for i := range new {
+		change := RuntimeChange{Name: new[i].Name, Kind: Added, New: &new[i]}
+		if j := slices.IndexFunc(old, func(lang utils.LanguageInfo) bool { return lang.Name == new[i].Name }); j >= 0 {
+			change.Old = &old[j]
+			change.Kind = Changed
+			if old[j].Version == new[i].Version {
+				change.Kind = Unchanged
+			}
+		}
+		changes = append(changes, change)
+	}
+	for i := range old {
+		if !slices.ContainsFunc(new, func(lang utils.LanguageInfo) bool { return lang.Name == old[i].Name }) {
+			changes = append(changes, RuntimeChange{Name: old[i].Name, Kind: Removed, Old: &old[i]})
+		}
+	}
+	return changes
+}
+
+// compareFindings matches the failures of the images by fingerprint, which
+// identifies a finding by its rule and message.
+func compareFindings(old, new []security.CISResult) FindingDiff {
+	diff := FindingDiff{Fixed: []security.CISResult{}, Introduced: []security.CISResult{}}
+	oldFailures := failures(old)
+	newFailures := failures(new)
+	for fingerprint, result := range oldFailures {
+		if _, ok := newFailures[fingerprint]; ok {
+			diff.Remaining++
+		} else {
+			diff.Fixed = append(diff.Fixed, result)
+		}
+	}
+	for fingerprint, result := range newFailures {
+		if _, ok := oldFailures[fingerprint]; !ok {
+			diff.Introduced = append(diff.Introduced, result)
+		}
+	}
+	sortResults(diff.Fixed)
+	sortResults(diff.Introduced)
+	return diff
+}
+
+func failures(results []security.CISResult) map[string]security.CISResult {
+	failed := map[string]security.CISResult{}
+	for _, r := range results {
+		if !r.Failed() {
+			continue
+		}
+		fingerprint := r.Fingerprint
+		if fingerprint == "" {
+			fingerprint = security.Fingerprint(r)
+		}
+		failed[fingerprint] = r
+	}
+	return failed
+}
+
+// sortResults orders results by severity, then rule and message.
+func sortResults(results []security.CISResult) {
+	slices.SortFunc(results, func(a, b security.CISResult) int {
+		if a.Severity != b.Severity {
+			if security.AtLeast(a.Severity, b.Severity) {
+				return -1
+			}
+			return 1
+		}
+		if a.RuleID != b.RuleID {
+			return strings.Compare(a.RuleID, b.RuleID)
+		}
+		return strings.Compare(a.Message, b.Message)
+	})
+}
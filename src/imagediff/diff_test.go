@@ -0,0 +1,202 @@
+package imagediff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func newInspect(config ocispec.ImageConfig, health *dockerspec.HealthcheckConfig, size int64, layers ...string) image.InspectResponse {
+	return image.InspectResponse{
+		Size:   size,
+		RootFS: image.RootFS{Type: "layers", Layers: layers},
+		Config: &dockerspec.DockerOCIImageConfig{
+			ImageConfig:             config,
+			DockerOCIImageConfigExt: dockerspec.DockerOCIImageConfigExt{Healthcheck: health},
+		},
+	}
+}
+
+func failure(ruleID, severity, message string) security.CISResult {
+	return security.CISResult{RuleID: ruleID, Severity: severity, Message: message}
+}
+
+func TestCompare(t *testing.T) {
+	oldImage := Image{
+		Name: "app:1",
+		Inspect: newInspect(ocispec.ImageConfig{
+			Env:          []string{"PATH=/usr/bin", "NODE_VERSION=18.19.0", "DEBUG=1"},
+			Cmd:          []string{"node", "server.js"},
+			WorkingDir:   "/app",
+			ExposedPorts: map[string]struct{}{"3000/tcp": {}},
+			Labels:       map[string]string{"maintainer": "ops"},
+		}, nil, 200_000_000, "sha256:base", "sha256:node18", "sha256:app1"),
+		Results: []security.CISResult{
+			failure("DKZ-IMG-USER", security.SeverityHigh, "The image runs as root"),
+			failure("DKZ-VULN", security.SeverityMedium, "CVE-2024-1 in openssl 3.0.1"),
+			{RuleID: "DKZ-IMG-HEALTH", Passed: true},
+		},
+	}
+	newImage := Image{
+		Name: "app:2",
+		Inspect: newInspect(ocispec.ImageConfig{
+			Env:          []string{"PATH=/usr/bin", "NODE_VERSION=20.11.1"},
+			Cmd:          []string{"node", "server.js"},
+			WorkingDir:   "/app",
+			User:         "node",
+			ExposedPorts: map[string]struct{}{"3000/tcp": {}, "9229/tcp": {}},
+			Labels:       map[string]string{"maintainer": "platform"},
+		}, &dockerspec.HealthcheckConfig{Test: []string{"CMD-SHELL", "curl -f localhost:3000"}, Interval: 30 * time.Second, Retries: 3},
+			150_000_000, "sha256:base", "sha256:node20", "sha256:app1", "sha256:app2"),
+		Results: []security.CISResult{
+			{RuleID: "DKZ-IMG-USER", Passed: true},
+			failure("DKZ-VULN", security.SeverityMedium, "CVE-2024-1 in openssl 3.0.1"),
+			failure("DKZ-VULN", security.SeverityLow, "CVE-2024-2 in zlib 1.2.13"),
+		},
+	}
+
+	diff := Compare(oldImage, newImage)
+
+	config := []string{}
+	for _, change := range diff.Config {
+		config = append(config, fmt.Sprintf("%s %s %s %q %q", change.Kind, change.Field, change.Key, change.Old, change.New))
+	}
+	want := []string{
+		`removed Env DEBUG "1" ""`,
+		`changed Env NODE_VERSION "18.19.0" "20.11.1"`,
+		`added User  "" "node"`,
+		`added ExposedPorts 9229/tcp "" ""`,
+		`changed Labels maintainer "ops" "platform"`,
+		`added Healthcheck  "" "--interval=30s --retries=3 CMD curl -f localhost:3000"`,
+	}
+	if strings.Join(config, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got config changes\n%s\nwant\n%s", strings.Join(config, "\n"), strings.Join(want, "\n"))
+	}
+
+	layers := diff.Layers
+	if len(layers.Shared) != 2 || len(layers.OldOnly) != 1 || layers.OldOnly[0].Digest != "sha256:node18" ||
+		len(layers.NewOnly) != 2 || layers.CommonBase != 1 {
+		t.Errorf("Unexpected layers %+v", layers)
+	}
+
+	if len(diff.Runtimes) != 1 || diff.Runtimes[0].Kind != Changed || diff.Runtimes[0].Old.Version != "18.19.0" || diff.Runtimes[0].New.Version != "20.11.1" {
+		t.Errorf("Unexpected runtimes %+v", diff.Runtimes)
+	}
+	if diff.BaseOS != nil {
+		t.Errorf("Expected no base OS without filesystems, got %+v", diff.BaseOS)
+	}
+
+	findings := diff.Findings
+	if len(findings.Fixed) != 1 || findings.Fixed[0].RuleID != "DKZ-IMG-USER" ||
+		len(findings.Introduced) != 1 || findings.Introduced[0].Message != "CVE-2024-2 in zlib 1.2.13" || findings.Remaining != 1 {
+		t.Errorf("Unexpected findings %+v", findings)
+	}
+	if diff.Old.Score >= diff.New.Score {
+		t.Errorf("Expected the score to improve, got %d -> %d", diff.Old.Score, diff.New.Score)
+	}
+}
+
+func TestCompareRuntimes(t *testing.T) {
+	oldImage := Image{Name: "a", Inspect: newInspect(ocispec.ImageConfig{Env: []string{"PYTHON_VERSION=3.12.1", "JAVA_VERSION=21.0.2"}}, nil, 0)}
+	newImage := Image{Name: "b", Inspect: newInspect(ocispec.ImageConfig{Env: []string{"JAVA_VERSION=21.0.2", "NODE_VERSION=20.11.1"}}, nil, 0)}
+
+	got := []string{}
+	for _, change := range Compare(oldImage, newImage).Runtimes {
+		got = append(got, change.Name+" "+change.Kind)
+	}
+	want := "Node.js added\nJava unchanged\nPython removed"
+	if strings.Join(got, "\n") != want {
+		t.Errorf("got runtimes\n%s\nwant\n%s", strings.Join(got, "\n"), want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	oldImage := Image{
+		Name:    "app:1",
+		Inspect: newInspect(ocispec.ImageConfig{Cmd: []string{"./server"}}, nil, 100_000_000, "sha256:0123456789abcdef0123", "sha256:1111"),
+		Results: []security.CISResult{failure("DKZ-IMG-USER", security.SeverityHigh, "The image runs as root")},
+	}
+	newImage := Image{
+		Name:    "app:2",
+		Inspect: newInspect(ocispec.ImageConfig{Cmd: []string{"./server", "--port=8080"}}, nil, 80_000_000, "sha256:0123456789abcdef0123", "sha256:2222"),
+		Results: []security.CISResult{{RuleID: "DKZ-IMG-USER", Passed: true}},
+	}
+	diff := Compare(oldImage, newImage)
+
+	var text bytes.Buffer
+	if err := Write(&text, report.FormatText, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Differences from app:1 to app:2:",
+		"  - Size: 100MB -> 80MB (20.00% smaller)",
+		"  - Layers: 2 -> 2, 1 shared, the first 1 in common",
+		"- sha256:1111",
+		"+ sha256:2222",
+		"  - Runtimes: <none detected>",
+		`      Cmd: ["./server"] -> ["./server","--port=8080"]`,
+		"Fixed [HIGH] DKZ-IMG-USER - The image runs as root",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+
+	var output bytes.Buffer
+	if err := Write(&output, report.FormatJSON, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"schemaVersion", "old", "new", "config", "layers", "runtimes", "findings"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, output.String())
+		}
+	}
+
+	if err := Write(&output, report.FormatSARIF, diff); err == nil {
+		t.Errorf("Expected an error for the SARIF format")
+	}
+
+	diff.Build = &BuildChange{
+		Old: utils.BuildStats{Dockerfile: "Dockerfile", DurationSeconds: 42.3, Steps: 5, Cached: 0, Order: 1},
+		New: utils.BuildStats{Dockerfile: "Dockeryzer.Dockerfile", DurationSeconds: 12.04, Steps: 6, Cached: 4, Order: 2},
+	}
+	text.Reset()
+	Write(&text, report.FormatText, diff)
+	for _, expected := range []string{
+		"  - Builds: Dockerfile first, then Dockeryzer.Dockerfile, which may reuse its cached steps",
+		"  - Build time: 42.3s -> 12.0s",
+		"  - Build cache: 0 of 5 step(s) cached -> 4 of 6",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+
+	output.Reset()
+	Write(&output, report.FormatJSON, diff)
+	if !strings.Contains(output.String(), `"order": 2`) {
+		t.Errorf("Expected the build order in the JSON output, got:\n%s", output.String())
+	}
+
+	diff.Build.Old.Order, diff.Build.New.Order = 0, 0
+	diff.Build.Old.NoCache, diff.Build.New.NoCache = true, true
+	text.Reset()
+	Write(&text, report.FormatText, diff)
+	if !strings.Contains(text.String(), "  - Builds: both without the build cache") {
+		t.Errorf("Expected the builds without cache, got:\n%s", text.String())
+	}
+}
@@ -0,0 +1,348 @@
+package imagediff
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+)
+
+// Dockerfile is one side of a Dockerfile comparison.
+type Dockerfile struct {
+	Name       string
+	Dockerfile *dockerfile.Dockerfile
+	// Results are the results of the Dockerfile rules.
+	Results []security.CISResult
+}
+
+// DockerfileSummary describes one side of a Dockerfile comparison.
+type DockerfileSummary struct {
+	Name         string `json:"name"`
+	Stages       int    `json:"stages"`
+	Instructions int    `json:"instructions"`
+	// Score and Grade are the security score of the Dockerfile rules.
+	Score int    `json:"score"`
+	Grade string `json:"grade"`
+}
+
+// DockerfileDiff is the semantic difference between two Dockerfiles.
+type DockerfileDiff struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Old           DockerfileSummary `json:"old"`
+	New           DockerfileSummary `json:"new"`
+	// Stages are the stages of the new Dockerfile in order, followed by the
+	// removed ones.
+	Stages   []StageChange `json:"stages"`
+	Findings FindingDiff   `json:"findings"`
+	// Rules puts side by side the failures of every rule, by rule ID.
+	Rules []RuleDelta `json:"rules"`
+}
+
+// StageChange is a build stage added, removed, changed or unchanged.
+// Stages are matched by name; unnamed stages are matched in order from the
+// last one.
+type StageChange struct {
+	// Stage is the label of the stage in the new file, or in the old one
+	// when removed.
+	Stage string `json:"stage"`
+	// Kind is Added, Removed, Changed or Unchanged.
+	Kind    string `json:"kind"`
+	OldBase string `json:"oldBase,omitempty"`
+	NewBase string `json:"newBase,omitempty"`
+	// Instructions are the instruction changes of a stage in both files, in
+	// the order of the new file.
+	Instructions []InstructionChange `json:"instructions"`
+	// CacheBreak is the earliest change of the stage that rebuilds
+	// instructions left unchanged after it, nil when none.
+	CacheBreak *CacheBreak `json:"cacheBreak,omitempty"`
+}
+
+// InstructionChange is an instruction added, removed or moved within its
+// stage. Instructions are compared with their flags and arguments,
+// regardless of line continuations and spacing.
+type InstructionChange struct {
+	// Kind is Added, Removed or Moved.
+	Kind        string `json:"kind"`
+	Instruction string `json:"instruction"`
+	OldLine     int    `json:"oldLine,omitempty"`
+	NewLine     int    `json:"newLine,omitempty"`
+}
+
+// CacheBreak is a change that invalidates the build cache of the
+// instructions following it in its stage.
+type CacheBreak struct {
+	// Line is the line of the new file where the cache breaks.
+	Line        int    `json:"line"`
+	Instruction string `json:"instruction"`
+	// Rebuilt counts the unchanged instructions after the change, which
+	// were cached before and now build again.
+	Rebuilt int `json:"rebuilt"`
+}
+
+// RuleDelta counts the failures of a rule in both Dockerfiles.
+type RuleDelta struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description,omitempty"`
+	Old         int    `json:"old"`
+	New         int    `json:"new"`
+}
+
+// CompareDockerfiles returns the difference from old to new.
+func CompareDockerfiles(old, new Dockerfile) DockerfileDiff {
+	matches := matchStages(old.Dockerfile.Stages, new.Dockerfile.Stages)
+	return DockerfileDiff{
+		SchemaVersion: report.SchemaVersion,
+		Old:           summarizeDockerfile(old),
+		New:           summarizeDockerfile(new),
+		Stages:        compareStages(old.Dockerfile, new.Dockerfile, matches),
+		Findings:      compareFindings(relabelResults(old.Results, matches), new.Results),
+		Rules:         compareRules(old.Results, new.Results),
+	}
+}
+
+// relabelResults gives the old results the label of the matching new stage,
+// so that a finding of a stage whose index changed is not seen as fixed and
+// introduced again.
+func relabelResults(results []security.CISResult, matches map[*dockerfile.Stage]*dockerfile.Stage) []security.CISResult {
+	labels := map[string]string{}
+	for newStage, oldStage := range matches {
+		labels[oldStage.Label()] = newStage.Label()
+	}
+	relabeled := []security.CISResult{}
+	for _, r := range results {
+		if label, ok := labels[r.Stage]; ok && label != r.Stage {
+			r.Stage = label
+			r.Fingerprint = security.Fingerprint(r)
+		}
+		relabeled = append(relabeled, r)
+	}
+	return relabeled
+}
+
+func summarizeDockerfile(df Dockerfile) DockerfileSummary {
+	card := security.Evaluate(df.Results)
+	return DockerfileSummary{
+		Name:         df.Name,
+		Stages:       len(df.Dockerfile.Stages),
+		Instructions: len(df.Dockerfile.Instructions),
+		Score:        card.Score,
+		Grade:        card.Grade,
+	}
+}
+
+func compareStages(old, new *dockerfile.Dockerfile, matches map[*dockerfile.Stage]*dockerfile.Stage) []StageChange {
+	changes := []StageChange{}
+	for _, stage := range new.Stages {
+		oldStage, ok := matches[stage]
+		if !ok {
+			changes = append(changes, StageChange{Stage: stage.Label(), Kind: Added, NewBase: stage.BaseImage, Instructions: []InstructionChange{}})
+			continue
+		}
+		changes = append(changes, compareStage(oldStage, stage))
+	}
+	matched := map[*dockerfile.Stage]bool{}
+	for _, oldStage := range matches {
+		matched[oldStage] = true
+	}
+	for _, stage := range old.Stages {
+		if !matched[stage] {
+			changes = append(changes, StageChange{Stage: stage.Label(), Kind: Removed, OldBase: stage.BaseImage, Instructions: []InstructionChange{}})
+		}
+	}
+	return changes
+}
+
+// matchStages pairs the new stages with the old ones: named stages by name,
+// and unnamed stages in order from the last, so that the final stages match
+// when neither is named.
+func matchStages(old, new []*dockerfile.Stage) map[*dockerfile.Stage]*dockerfile.Stage {
+	matches := map[*dockerfile.Stage]*dockerfile.Stage{}
+	oldUnnamed := []*dockerfile.Stage{}
+	for _, stage := range old {
+		if stage.Name == "" {
+			oldUnnamed = append(oldUnnamed, stage)
+		}
+	}
+	newUnnamed := []*dockerfile.Stage{}
+	for _, stage := range new {
+		if stage.Name == "" {
+			newUnnamed = append(newUnnamed, stage)
+			continue
+		}
+		if i := slices.IndexFunc(old, func(oldStage *dockerfile.Stage) bool { return oldStage.Name == stage.Name }); i >= 0 {
+			matches[stage] = old[i]
+		}
+	}
+	for i := 1; i <= min(len(oldUnnamed), len(newUnnamed)); i++ {
+		matches[newUnnamed[len(newUnnamed)-i]] = oldUnnamed[len(oldUnnamed)-i]
+	}
+	return matches
+}
+
+// compareStage aligns the instructions of both stages on their longest
+// common subsequence. An instruction removed at one place and added at
+// another is moved.
+func compareStage(old, new *dockerfile.Stage) StageChange {
+	change := StageChange{Stage: new.Label(), Kind: Unchanged, OldBase: old.BaseImage, NewBase: new.BaseImage, Instructions: []InstructionChange{}}
+
+	oldKeys, newKeys := instructionKeys(old.Instructions), instructionKeys(new.Instructions)
+	common := commonSubsequence(oldKeys, newKeys)
+
+	// kept marks the instructions of the new stage that are in the common
+	// subsequence.
+	kept := make([]bool, len(newKeys))
+	i, j := 0, 0
+	for _, pair := range append(common, [2]int{len(oldKeys), len(newKeys)}) {
+		for ; i < pair[0]; i++ {
+			change.Instructions = append(change.Instructions, InstructionChange{Kind: Removed, Instruction: oldKeys[i], OldLine: old.Instructions[i].StartLine})
+		}
+		for ; j < pair[1]; j++ {
+			change.Instructions = append(change.Instructions, InstructionChange{Kind: Added, Instruction: newKeys[j], NewLine: new.Instructions[j].StartLine})
+		}
+		if j < len(newKeys) {
+			kept[j] = true
+		}
+		i, j = pair[0]+1, pair[1]+1
+	}
+	change.Instructions = pairMoves(change.Instructions)
+	change.CacheBreak = cacheBreak(old, new, oldKeys, newKeys, kept)
+
+	if old.BaseImage != new.BaseImage || len(change.Instructions) > 0 {
+		change.Kind = Changed
+	}
+	return change
+}
+
+// cacheBreak finds where the build cache of the new stage stops matching
+// the old one: at FROM when the base image changed, otherwise at the first
+// instruction that differs. Every instruction after it builds again.
+func cacheBreak(old, new *dockerfile.Stage, oldKeys, newKeys []string, kept []bool) *CacheBreak {
+	var result *CacheBreak
+	breakAt := 0
+	if old.BaseImage != new.BaseImage {
+		result = &CacheBreak{Line: new.From.StartLine, Instruction: instructionKey(new.From)}
+	} else {
+		for breakAt < len(oldKeys) && breakAt < len(newKeys) && oldKeys[breakAt] == newKeys[breakAt] {
+			breakAt++
+		}
+		if breakAt == len(newKeys) {
+			return nil
+		}
+		result = &CacheBreak{Line: new.Instructions[breakAt].StartLine, Instruction: newKeys[breakAt]}
+	}
+	for _, k := range kept[breakAt:] {
+		if k {
+			result.Rebuilt++
+		}
+	}
+	if result.Rebuilt == 0 {
+		return nil
+	}
+	return result
+}
+
+// pairMoves turns each removal matched by the addition of the same
+// instruction into a single move, kept at the place of the addition.
+func pairMoves(changes []InstructionChange) []InstructionChange {
+	moved := map[int]bool{}
+	for i, change := range changes {
+		if change.Kind != Added {
+			continue
+		}
+		for k, other := range changes {
+			if other.Kind == Removed && other.Instruction == change.Instruction && !moved[k] {
+				moved[k] = true
+				changes[i] = InstructionChange{Kind: Moved, Instruction: change.Instruction, OldLine: other.OldLine, NewLine: change.NewLine}
+				break
+			}
+		}
+	}
+
+	paired := []InstructionChange{}
+	for i, change := range changes {
+		if !moved[i] {
+			paired = append(paired, change)
+		}
+	}
+	return paired
+}
+
+// commonSubsequence returns the index pairs of the longest common
+// subsequence of a and b, in order.
+func commonSubsequence(a, b []string) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	pairs := [][2]int{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+func instructionKeys(instructions []*dockerfile.Instruction) []string {
+	keys := []string{}
+	for _, inst := range instructions {
+		keys = append(keys, instructionKey(inst))
+	}
+	return keys
+}
+
+// instructionKey is the instruction with its flags, arguments and
+// here-documents, whitespace normalized.
+func instructionKey(inst *dockerfile.Instruction) string {
+	words := append([]string{inst.Cmd}, inst.Flags...)
+	words = append(words, strings.Fields(inst.Value)...)
+	for _, heredoc := range inst.Heredocs {
+		words = append(words, strings.Fields(heredoc.Content)...)
+	}
+	return strings.Join(words, " ")
+}
+
+// compareRules counts the failures of every rule that ran on either side.
+func compareRules(old, new []security.CISResult) []RuleDelta {
+	rules := map[string]*RuleDelta{}
+	count := func(results []security.CISResult, side func(*RuleDelta) *int) {
+		for _, r := range results {
+			rule, ok := rules[r.RuleID]
+			if !ok {
+				rule = &RuleDelta{RuleID: r.RuleID, Description: r.Description}
+				rules[r.RuleID] = rule
+			}
+			if r.Failed() {
+				*side(rule)++
+			}
+		}
+	}
+	count(old, func(rule *RuleDelta) *int { return &rule.Old })
+	count(new, func(rule *RuleDelta) *int { return &rule.New })
+
+	deltas := []RuleDelta{}
+	for _, rule := range rules {
+		deltas = append(deltas, *rule)
+	}
+	slices.SortFunc(deltas, func(a, b RuleDelta) int { return strings.Compare(a.RuleID, b.RuleID) })
+	return deltas
+}
@@ -0,0 +1,175 @@
+package imagediff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+)
+
+func parseDockerfile(t *testing.T, name, content string, results ...security.CISResult) Dockerfile {
+	t.Helper()
+	df, err := dockerfile.ParseString(content)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return Dockerfile{Name: name, Dockerfile: df, Results: results}
+}
+
+func TestCompareDockerfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name: "Unchanged",
+			old:  "FROM node:20\nRUN npm ci\n",
+			new:  "FROM node:20\nRUN   npm \\\n    ci\n",
+			expected: []string{
+				"0 unchanged node:20 -> node:20",
+			},
+		},
+		{
+			name: "Stages added and removed",
+			old:  "FROM node:20 AS build\nRUN npm run build\nFROM node:20 AS test\nRUN npm test\n",
+			new:  "FROM node:20 AS build\nRUN npm run build\nFROM nginx:1.27 AS web\nCOPY --from=build /app/dist /usr/share/nginx/html\n",
+			expected: []string{
+				"build unchanged node:20 -> node:20",
+				"web added  -> nginx:1.27",
+				"test removed node:20 -> ",
+			},
+		},
+		{
+			name: "Unnamed final stages",
+			old:  "FROM node:20\nRUN npm ci\n",
+			new:  "FROM node:20 AS deps\nRUN npm ci\nFROM node:20-slim\nRUN npm ci\n",
+			expected: []string{
+				"deps added  -> node:20",
+				"1 changed node:20 -> node:20-slim",
+				"  cache at 3 (FROM node:20-slim), 1 rebuilt",
+			},
+		},
+		{
+			name: "Base image changed",
+			old:  "FROM node:18\nWORKDIR /app\nRUN npm ci\n",
+			new:  "FROM node:20-alpine\nWORKDIR /app\nRUN npm ci\n",
+			expected: []string{
+				"0 changed node:18 -> node:20-alpine",
+				"  cache at 1 (FROM node:20-alpine), 2 rebuilt",
+			},
+		},
+		{
+			name: "Instructions reordered",
+			old:  "FROM node:20\nWORKDIR /app\nCOPY . .\nCOPY package.json ./\nRUN npm ci\nCMD [\"node\", \"server.js\"]\n",
+			new:  "FROM node:20\nWORKDIR /app\nCOPY package.json ./\nRUN npm ci\nCOPY . .\nCMD [\"node\", \"server.js\"]\n",
+			expected: []string{
+				"0 changed node:20 -> node:20",
+				"  moved 3 5 COPY . .",
+				"  cache at 3 (COPY package.json ./), 3 rebuilt",
+			},
+		},
+		{
+			name: "Cache busting change early in the file",
+			old:  "FROM node:20\nARG VERSION=1\nRUN apt-get update\nRUN npm ci\n",
+			new:  "FROM node:20\nARG VERSION=2\nRUN apt-get update\nRUN npm ci\nUSER node\n",
+			expected: []string{
+				"0 changed node:20 -> node:20",
+				"  removed 2 0 ARG VERSION=1",
+				"  added 0 2 ARG VERSION=2",
+				"  added 0 5 USER node",
+				"  cache at 2 (ARG VERSION=2), 2 rebuilt",
+			},
+		},
+		{
+			name: "Appended instructions keep the cache",
+			old:  "FROM node:20\nRUN npm ci\n",
+			new:  "FROM node:20\nRUN npm ci\nUSER node\n",
+			expected: []string{
+				"0 changed node:20 -> node:20",
+				"  added 0 3 USER node",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := CompareDockerfiles(parseDockerfile(t, "old", tt.old), parseDockerfile(t, "new", tt.new))
+			got := []string{}
+			for _, stage := range diff.Stages {
+				got = append(got, fmt.Sprintf("%s %s %s -> %s", stage.Stage, stage.Kind, stage.OldBase, stage.NewBase))
+				for _, change := range stage.Instructions {
+					got = append(got, fmt.Sprintf("  %s %d %d %s", change.Kind, change.OldLine, change.NewLine, change.Instruction))
+				}
+				if stage.CacheBreak != nil {
+					got = append(got, fmt.Sprintf("  cache at %d (%s), %d rebuilt", stage.CacheBreak.Line, stage.CacheBreak.Instruction, stage.CacheBreak.Rebuilt))
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestWriteDockerfileDiff(t *testing.T) {
+	oldDockerfile := parseDockerfile(t, "Dockerfile", "FROM node:18\nCOPY . .\nRUN npm ci\n",
+		failure("DKZ-USER", security.SeverityHigh, "The final stage runs as root"),
+		failure("DKZ-PIN", security.SeverityMedium, "node:18 is not pinned"))
+	newDockerfile := parseDockerfile(t, "Dockeryzer.Dockerfile", "FROM node:20-alpine\nCOPY . .\nRUN npm ci\nUSER node\n",
+		security.CISResult{RuleID: "DKZ-USER", Passed: true},
+		failure("DKZ-PIN", security.SeverityMedium, "node:20-alpine is not pinned"))
+	diff := CompareDockerfiles(oldDockerfile, newDockerfile)
+
+	stageResult := security.CISResult{RuleID: "DKZ-HEALTH", Severity: security.SeverityLow, Message: "Missing HEALTHCHECK", Stage: "0"}
+	oldDockerfile.Results = append(oldDockerfile.Results, stageResult)
+	stageResult.Stage = "1"
+	newDockerfile = parseDockerfile(t, "Dockeryzer.Dockerfile", "FROM node:20 AS deps\nRUN npm ci\nFROM node:20-alpine\nCOPY . .\nRUN npm ci\nUSER node\n",
+		append(newDockerfile.Results, stageResult)...)
+	if findings := CompareDockerfiles(oldDockerfile, newDockerfile).Findings; findings.Remaining != 1 || len(findings.Introduced) != 1 {
+		t.Errorf("Expected the finding of the final stage to remain, got %+v", findings)
+	}
+
+	if len(diff.Rules) != 2 || diff.Rules[1].RuleID != "DKZ-USER" || diff.Rules[1].Old != 1 || diff.Rules[1].New != 0 {
+		t.Errorf("Unexpected rules %+v", diff.Rules)
+	}
+
+	var text bytes.Buffer
+	if err := WriteDockerfileDiff(&text, report.FormatText, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Differences from Dockerfile to Dockeryzer.Dockerfile:",
+		"  - Stages: 1 -> 1, instructions: 3 -> 4",
+		"          Base image: node:18 -> node:20-alpine",
+		"+    4  USER node",
+		"Cache: the change at line 1 (FROM node:20-alpine) rebuilds 2 unchanged instruction(s) after it",
+		"Fixed [HIGH] DKZ-USER - The final stage runs as root",
+		"Introduced [MEDIUM] DKZ-PIN - node:20-alpine is not pinned",
+		"RULE        OLD    NEW",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+
+	var output bytes.Buffer
+	if err := WriteDockerfileDiff(&output, report.FormatJSON, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"schemaVersion", "old", "new", "stages", "findings", "rules"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, output.String())
+		}
+	}
+}
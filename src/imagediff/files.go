@@ -0,0 +1,239 @@
+package imagediff
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// FileFilter selects the paths of a file comparison.
+type FileFilter struct {
+	// Pattern is a glob the paths must match, empty for all of them. A
+	// pattern without a slash matches the file name, e.g. *.so; otherwise it
+	// matches the path from the root, where ** stands for any number of
+	// directories, e.g. /usr/**/node_modules. A pattern matching a directory
+	// matches everything under it.
+	Pattern string
+	// MinSize leaves out of the file lists the changes of fewer bytes. They
+	// still count in the totals of their directory.
+	MinSize int64
+}
+
+// FileChange is a path added, removed or changed from one image to the
+// other. Directories are left out: their changes show through their files.
+type FileChange struct {
+	Path string `json:"path"`
+	// Kind is Added, Removed or Changed. A path is changed when its content,
+	// size, mode or link target differs.
+	Kind    string `json:"kind"`
+	OldSize int64  `json:"oldSize"`
+	NewSize int64  `json:"newSize"`
+	Delta   int64  `json:"delta"`
+}
+
+// FileChanges counts the changes of a set of paths.
+type FileChanges struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+	// Delta is the growth in bytes, negative when the files shrink.
+	Delta int64 `json:"delta"`
+}
+
+// DirectoryChange groups the changes under a top-level directory, e.g.
+// /usr, with "/" for the files at the root.
+type DirectoryChange struct {
+	Directory string `json:"directory"`
+	FileChanges
+	// Files are the changes of at least FileFilter.MinSize bytes, the
+	// largest first.
+	Files []FileChange `json:"files"`
+}
+
+// FileDiff is the difference between the filesystems of two images.
+type FileDiff struct {
+	FileChanges
+	// Directories are ordered by the size of their change, largest first.
+	Directories []DirectoryChange `json:"directories"`
+}
+
+// ValidatePattern reports whether pattern is a well-formed FileFilter
+// pattern.
+func ValidatePattern(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// CompareFiles compares the merged filesystems of two images. Files written
+// by a layer both images share are equal; the others are compared by size,
+// mode and link target and, when those match, by content.
+func CompareFiles(old, new *imagefs.Image, filter FileFilter) (FileDiff, error) {
+	oldFiles := selectFiles(old, filter.Pattern)
+	newFiles := selectFiles(new, filter.Pattern)
+
+	changes := []FileChange{}
+	candidates := map[string]bool{}
+	for p, oldFile := range oldFiles {
+		newFile, ok := newFiles[p]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: p, Kind: Removed, OldSize: oldFile.Size, Delta: -oldFile.Size})
+		case sameLayer(old, oldFile, new, newFile):
+		case oldFile.Size != newFile.Size || oldFile.Mode != newFile.Mode || oldFile.Linkname != newFile.Linkname:
+			changes = append(changes, FileChange{Path: p, Kind: Changed, OldSize: oldFile.Size, NewSize: newFile.Size, Delta: newFile.Size - oldFile.Size})
+		case oldFile.Mode.IsRegular() && oldFile.Linkname == "":
+			candidates[p] = true
+		}
+	}
+	for p, newFile := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			changes = append(changes, FileChange{Path: p, Kind: Added, NewSize: newFile.Size, Delta: newFile.Size})
+		}
+	}
+
+	if len(candidates) > 0 {
+		oldSums, err := checksums(old, candidates)
+		if err != nil {
+			return FileDiff{}, err
+		}
+		newSums, err := checksums(new, candidates)
+		if err != nil {
+			return FileDiff{}, err
+		}
+		for p := range candidates {
+			if oldSums[p] != newSums[p] {
+				size := oldFiles[p].Size
+				changes = append(changes, FileChange{Path: p, Kind: Changed, OldSize: size, NewSize: size})
+			}
+		}
+	}
+
+	return groupFiles(changes, filter.MinSize), nil
+}
+
+// selectFiles returns the files of img matching pattern, by path.
+func selectFiles(img *imagefs.Image, pattern string) map[string]*imagefs.File {
+	files := map[string]*imagefs.File{}
+	for _, file := range img.Files() {
+		if file.Mode.IsDir() || pattern != "" && !matchPattern(pattern, file.Path) {
+			continue
+		}
+		files[file.Path] = file
+	}
+	return files
+}
+
+func matchPattern(pattern, p string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(p, "/"), "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := range len(segments) + 1 {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchSegments(pattern[1:], segments[1:])
+}
+
+// sameLayer reports whether both files come from the same layer, which
+// makes them equal.
+func sameLayer(old *imagefs.Image, oldFile *imagefs.File, new *imagefs.Image, newFile *imagefs.File) bool {
+	oldLayer, newLayer := old.Layers[oldFile.Layer].DiffID, new.Layers[newFile.Layer].DiffID
+	return oldLayer != "" && oldLayer == newLayer
+}
+
+// checksums returns the SHA-256 of the content of the files of img in paths.
+func checksums(img *imagefs.Image, paths map[string]bool) (map[string][sha256.Size]byte, error) {
+	sums := map[string][sha256.Size]byte{}
+	err := img.Walk(func(file *imagefs.File) bool { return paths[file.Path] }, func(file *imagefs.File, content io.Reader) error {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, content); err != nil {
+			return err
+		}
+		sums[file.Path] = [sha256.Size]byte(hash.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
+
+// groupFiles groups changes by top-level directory.
+func groupFiles(changes []FileChange, minSize int64) FileDiff {
+	diff := FileDiff{Directories: []DirectoryChange{}}
+	directories := map[string]*DirectoryChange{}
+	for _, change := range changes {
+		name := topLevelDirectory(change.Path)
+		directory, ok := directories[name]
+		if !ok {
+			directory = &DirectoryChange{Directory: name, Files: []FileChange{}}
+			directories[name] = directory
+		}
+		directory.count(change)
+		diff.count(change)
+		if abs(change.Delta) >= minSize || minSize == 0 {
+			directory.Files = append(directory.Files, change)
+		}
+	}
+
+	for _, directory := range directories {
+		slices.SortFunc(directory.Files, func(a, b FileChange) int {
+			return cmp.Or(cmp.Compare(abs(b.Delta), abs(a.Delta)), strings.Compare(a.Path, b.Path))
+		})
+		diff.Directories = append(diff.Directories, *directory)
+	}
+	slices.SortFunc(diff.Directories, func(a, b DirectoryChange) int {
+		return cmp.Or(cmp.Compare(abs(b.Delta), abs(a.Delta)), strings.Compare(a.Directory, b.Directory))
+	})
+	return diff
+}
+
+func (c *FileChanges) count(change FileChange) {
+	switch change.Kind {
+	case Added:
+		c.Added++
+	case Removed:
+		c.Removed++
+	default:
+		c.Changed++
+	}
+	c.Delta += change.Delta
+}
+
+func topLevelDirectory(p string) string {
+	first, _, nested := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	if !nested {
+		return "/"
+	}
+	return "/" + first
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
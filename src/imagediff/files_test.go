@@ -0,0 +1,133 @@
+package imagediff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs/imagefstest"
+)
+
+func TestCompareFiles(t *testing.T) {
+	base := []imagefstest.File{{Name: "etc/os-release", Content: []byte("ID=alpine\n")}, {Name: "bin/sh", Content: []byte("shell")}}
+	oldImage := imagefstest.New(t, base, []imagefstest.File{
+		{Name: "usr/lib/node_modules/a.js", Content: []byte(strings.Repeat("a", 100))},
+		{Name: "app/server", Content: []byte("v1")},
+		{Name: "app/config", Content: []byte("same")},
+		{Name: "app/README", Content: []byte("x")},
+	})
+	newImage := imagefstest.New(t, base, []imagefstest.File{
+		{Name: "usr/lib/node_modules/a.js", Content: []byte(strings.Repeat("a", 200))},
+		{Name: "usr/lib/node_modules/b.js", Content: []byte(strings.Repeat("b", 50))},
+		{Name: "app/server", Content: []byte("v2")},
+		{Name: "app/config", Content: []byte("same")},
+	})
+
+	tests := []struct {
+		name     string
+		filter   FileFilter
+		expected []string
+	}{
+		{
+			name:   "All files",
+			filter: FileFilter{},
+			expected: []string{
+				"/usr 1 0 1 +150",
+				"  changed /usr/lib/node_modules/a.js +100",
+				"  added /usr/lib/node_modules/b.js +50",
+				"/app 0 1 1 -1",
+				"  removed /app/README -1",
+				"  changed /app/server +0",
+			},
+		},
+		{
+			name:   "Minimum size",
+			filter: FileFilter{MinSize: 60},
+			expected: []string{
+				"/usr 1 0 1 +150",
+				"  changed /usr/lib/node_modules/a.js +100",
+				"/app 0 1 1 -1",
+			},
+		},
+		{
+			name:     "File name pattern",
+			filter:   FileFilter{Pattern: "b.*"},
+			expected: []string{"/usr 1 0 0 +50", "  added /usr/lib/node_modules/b.js +50"},
+		},
+		{
+			name:     "Directory pattern",
+			filter:   FileFilter{Pattern: "/app/server"},
+			expected: []string{"/app 0 0 1 +0", "  changed /app/server +0"},
+		},
+		{
+			name:     "No changes",
+			filter:   FileFilter{Pattern: "/etc"},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := CompareFiles(oldImage, newImage, tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := []string{}
+			for _, directory := range diff.Directories {
+				got = append(got, fmt.Sprintf("%s %d %d %d %+d", directory.Directory, directory.Added, directory.Removed, directory.Changed, directory.Delta))
+				for _, file := range directory.Files {
+					got = append(got, fmt.Sprintf("  %s %s %+d", file.Kind, file.Path, file.Delta))
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+
+	files, _ := CompareFiles(oldImage, newImage, FileFilter{})
+	var text bytes.Buffer
+	writeFiles(&text, files)
+	for _, expected := range []string{
+		"  - Files: 1 added, 1 removed, 2 changed (+149B)",
+		"      /usr: 1 added, 0 removed, 1 changed (+150B)",
+		"+       50B  /usr/lib/node_modules/b.js",
+		"~     +100B  /usr/lib/node_modules/a.js",
+		"-        1B  /app/README",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"*.so", "/usr/lib/libc.so", true},
+		{"*.so", "/usr/lib/libc.so.6", false},
+		{"/usr/lib", "/usr/lib/libc.so", true},
+		{"/usr/lib", "/usr/libexec/git", false},
+		{"/usr/*/node_modules", "/usr/lib/node_modules/a/index.js", true},
+		{"/usr/**/node_modules", "/usr/local/lib/node_modules/a.js", true},
+		{"/**/*.pyc", "/app/pkg/__pycache__/mod.pyc", true},
+		{"/app/**", "/srv/app/main", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path); got != tt.expected {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.expected)
+		}
+	}
+
+	if err := ValidatePattern("/usr/[lib"); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+	if err := ValidatePattern("/usr/**/*.so"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
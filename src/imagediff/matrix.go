@@ -0,0 +1,153 @@
+package imagediff
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+// Columns of the matrix.
+const (
+	ColumnSize            = "size"
+	ColumnLayers          = "layers"
+	ColumnRuntime         = "runtime"
+	ColumnScore           = "score"
+	ColumnVulnerabilities = "vulnerabilities"
+)
+
+// statusRanks orders the runtime statuses from the worst to the best.
+var statusRanks = map[string]int{"error": 1, "warning": 2, "success": 3}
+
+// Row is an image of the matrix.
+type Row struct {
+	Summary
+	// Runtime is the most confident runtime of the image, nil when none is
+	// detected.
+	Runtime *utils.LanguageInfo `json:"runtime"`
+	// Vulnerabilities is the number of known vulnerabilities, nil when no
+	// vulnerability database was available.
+	Vulnerabilities *int `json:"vulnerabilities"`
+	// Best lists the columns where the image has the best value.
+	Best []string `json:"best"`
+}
+
+// Matrix ranks several images side by side.
+type Matrix struct {
+	SchemaVersion int   `json:"schemaVersion"`
+	Rows          []Row `json:"images"`
+	// Reference is the image the others are compared against, empty for
+	// none.
+	Reference string `json:"reference,omitempty"`
+	// Diffs are the differences from the reference to each other image.
+	Diffs []Diff `json:"diffs,omitempty"`
+}
+
+// NewMatrix ranks images. With a reference, which must be one of images,
+// the matrix holds the diffs from it to the others.
+func NewMatrix(images []Image, reference string) Matrix {
+	matrix := Matrix{SchemaVersion: report.SchemaVersion, Rows: []Row{}, Reference: reference}
+	for _, img := range images {
+		row := Row{Summary: summarize(img), Best: []string{}}
+		if languages := utils.DetectLanguages(img.Inspect, img.Filesystem); len(languages) > 0 {
+			row.Runtime = &languages[0]
+		}
+		row.Vulnerabilities = countVulnerabilities(img.Results)
+		matrix.Rows = append(matrix.Rows, row)
+	}
+
+	markBest(matrix.Rows, ColumnSize, func(a, b Row) int { return cmp.Compare(b.SizeBytes, a.SizeBytes) })
+	markBest(matrix.Rows, ColumnLayers, func(a, b Row) int { return cmp.Compare(b.Layers, a.Layers) })
+	markBest(matrix.Rows, ColumnRuntime, compareRuntimeRows)
+	markBest(matrix.Rows, ColumnScore, func(a, b Row) int { return cmp.Compare(a.Score, b.Score) })
+	markBest(matrix.Rows, ColumnVulnerabilities, compareVulnerabilityRows)
+
+	if reference == "" {
+		return matrix
+	}
+	i := slices.IndexFunc(images, func(img Image) bool { return img.Name == reference })
+	for j, img := range images {
+		if j != i {
+			matrix.Diffs = append(matrix.Diffs, Compare(images[i], img))
+		}
+	}
+	return matrix
+}
+
+// markBest adds column to the Best of the rows that no other row beats,
+// where compare returns a positive number when a is better than b. Nothing
+// is marked when all the rows are equal.
+func markBest(rows []Row, column string, compare func(a, b Row) int) {
+	best := []int{}
+	for i := range rows {
+		beaten := slices.ContainsFunc(rows, func(other Row) bool { return compare(other, rows[i]) > 0 })
+		if !beaten {
+			best = append(best, i)
+		}
+	}
+	if len(best) == len(rows) {
+		return
+	}
+	for _, i := range best {
+		rows[i].Best = append(rows[i].Best, column)
+	}
+}
+
+// compareRuntimeRows prefers the runtimes with the better support status
+// and, for the same runtime, the newer version. Rows without a runtime are
+// the worst.
+func compareRuntimeRows(a, b Row) int {
+	rank := func(row Row) int {
+		if row.Runtime == nil {
+			return 0
+		}
+		return statusRanks[row.Runtime.Color]
+	}
+	if order := cmp.Compare(rank(a), rank(b)); order != 0 || a.Runtime == nil || b.Runtime == nil || a.Runtime.Name != b.Runtime.Name {
+		return order
+	}
+	switch {
+	case utils.NewerVersion(a.Runtime.Version, b.Runtime.Version):
+		return 1
+	case utils.NewerVersion(b.Runtime.Version, a.Runtime.Version):
+		return -1
+	default:
+		return 0
+	}
+}
+
+// compareVulnerabilityRows prefers the fewer vulnerabilities. Images that
+// were not scanned are the worst.
+func compareVulnerabilityRows(a, b Row) int {
+	switch {
+	case a.Vulnerabilities == nil && b.Vulnerabilities == nil:
+		return 0
+	case a.Vulnerabilities == nil:
+		return -1
+	case b.Vulnerabilities == nil:
+		return 1
+	default:
+		return cmp.Compare(*b.Vulnerabilities, *a.Vulnerabilities)
+	}
+}
+
+// countVulnerabilities counts the failed vulnerability results, returning
+// nil when the vulnerability rule did not run.
+func countVulnerabilities(results []security.CISResult) *int {
+	scanned, count := false, 0
+	for _, r := range results {
+		if r.Category != security.CategoryVulnerabilities {
+			continue
+		}
+		scanned = true
+		if r.Failed() {
+			count++
+		}
+	}
+	if !scanned {
+		return nil
+	}
+	return &count
+}
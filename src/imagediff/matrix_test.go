@@ -0,0 +1,128 @@
+package imagediff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func vulnerability(message string) security.CISResult {
+	return security.CISResult{RuleID: "DKZ-VULN", Severity: security.SeverityHigh, Message: message, Category: security.CategoryVulnerabilities}
+}
+
+func matrixImages() []Image {
+	return []Image{
+		{
+			Name:    "app:alpine",
+			Inspect: newInspect(ocispec.ImageConfig{Env: []string{"NODE_VERSION=20.11.1"}}, nil, 50_000_000, "sha256:alpine", "sha256:app"),
+			Results: []security.CISResult{
+				failure("DKZ-IMG-USER", security.SeverityHigh, "The image runs as root"),
+				vulnerability("CVE-2024-1 in openssl 3.0.1"),
+			},
+		},
+		{
+			Name:    "app:slim",
+			Inspect: newInspect(ocispec.ImageConfig{Env: []string{"NODE_VERSION=20.12.0"}}, nil, 80_000_000, "sha256:debian", "sha256:node", "sha256:app"),
+			Results: []security.CISResult{
+				{RuleID: "DKZ-IMG-USER", Passed: true},
+				{RuleID: "DKZ-VULN", Passed: true, Category: security.CategoryVulnerabilities},
+			},
+		},
+		{
+			Name:    "app:distroless",
+			Inspect: newInspect(ocispec.ImageConfig{}, nil, 50_000_000, "sha256:distroless", "sha256:app"),
+			Results: []security.CISResult{{RuleID: "DKZ-IMG-USER", Passed: true}},
+		},
+	}
+}
+
+func TestNewMatrix(t *testing.T) {
+	matrix := NewMatrix(matrixImages(), "")
+
+	got := []string{}
+	for _, row := range matrix.Rows {
+		got = append(got, row.Name+" "+strings.Join(row.Best, ","))
+	}
+	want := []string{
+		"app:alpine size,layers",
+		"app:slim runtime,score,vulnerabilities",
+		"app:distroless size,layers,score",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got best values\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if alpine := matrix.Rows[0]; alpine.Vulnerabilities == nil || *alpine.Vulnerabilities != 1 {
+		t.Errorf("Expected 1 vulnerability for app:alpine, got %v", alpine.Vulnerabilities)
+	}
+	if distroless := matrix.Rows[2]; distroless.Vulnerabilities != nil || distroless.Runtime != nil {
+		t.Errorf("Expected app:distroless without vulnerability scan nor runtime, got %+v", distroless)
+	}
+	if len(matrix.Diffs) != 0 {
+		t.Errorf("Expected no diffs without a reference, got %d", len(matrix.Diffs))
+	}
+
+	referenced := NewMatrix(matrixImages(), "app:slim")
+	if len(referenced.Diffs) != 2 || referenced.Diffs[0].Old.Name != "app:slim" ||
+		referenced.Diffs[0].New.Name != "app:alpine" || referenced.Diffs[1].New.Name != "app:distroless" {
+		t.Errorf("Unexpected diffs %+v", referenced.Diffs)
+	}
+}
+
+func TestNewMatrixTies(t *testing.T) {
+	images := matrixImages()[:1]
+	images = append(images, images[0])
+	images[1].Name = "app:copy"
+
+	for _, row := range NewMatrix(images, "").Rows {
+		if len(row.Best) != 0 {
+			t.Errorf("Expected no best values for equal images, got %v", row.Best)
+		}
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	matrix := NewMatrix(matrixImages(), "app:slim")
+
+	var text bytes.Buffer
+	if err := WriteMatrix(&text, report.FormatText, matrix); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Comparison of 3 images:",
+		"IMAGE",
+		"app:slim (reference)",
+		"Node.js 20.12.0*",
+		"n/a",
+		"  * best value of the column",
+		"Differences from app:slim to app:alpine:",
+		"Differences from app:slim to app:distroless:",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+
+	var output bytes.Buffer
+	if err := WriteMatrix(&output, report.FormatJSON, matrix); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"schemaVersion", "images", "reference", "diffs"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, output.String())
+		}
+	}
+
+	if err := WriteMatrix(&output, report.FormatSARIF, matrix); err == nil {
+		t.Errorf("Expected an error for the SARIF format")
+	}
+}
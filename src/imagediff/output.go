@@ -0,0 +1,427 @@
+package imagediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/jorgevvs2/dockeryzer/src/report"
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+// shortDigestLength is the number of characters of the layer digests shown
+// in the text output, "sha256:" included.
+const shortDigestLength = 19
+
+// maxDirectoryFiles bounds the files listed per directory in the text
+// output.
+const maxDirectoryFiles = 10
+
+// maxInstructionWidth bounds the instructions of the layers in the text
+// output.
+const maxInstructionWidth = 70
+
+// Write renders diff as text or JSON.
+func Write(w io.Writer, format report.Format, diff Diff) error {
+	switch format {
+	case report.FormatText:
+		writeText(w, diff)
+		return nil
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	default:
+		return fmt.Errorf("format %q is not supported by compare (use text or json)", format)
+	}
+}
+
+func writeText(w io.Writer, diff Diff) {
+	fmt.Fprintf(w, "Differences from %s to %s:\n", diff.Old.Name, diff.New.Name)
+	if diff.Build != nil {
+		writeBuild(w, *diff.Build)
+	}
+	writeSize(w, diff.Old, diff.New)
+	writeLayers(w, diff)
+	writeBaseOS(w, diff.BaseOS)
+	writeRuntimes(w, diff.Runtimes)
+	writeConfig(w, diff.Config)
+	writeFindings(w, security.ScoreCard{Score: diff.Old.Score, Grade: diff.Old.Grade},
+		security.ScoreCard{Score: diff.New.Score, Grade: diff.New.Grade}, diff.Findings)
+	if diff.Files != nil {
+		writeFiles(w, *diff.Files)
+	}
+}
+
+func writeBuild(w io.Writer, build BuildChange) {
+	duration := fmt.Sprintf("%.1fs", build.New.DurationSeconds)
+	switch {
+	case build.New.DurationSeconds < build.Old.DurationSeconds:
+		duration = utils.SuccessSprintf("%s", duration)
+	case build.New.DurationSeconds > build.Old.DurationSeconds:
+		duration = utils.ErrorSprintf("%s", duration)
+	}
+	if build.Old.NoCache && build.New.NoCache {
+		fmt.Fprintln(w, "  - Builds: both without the build cache")
+	} else if build.Old.Order > 0 && build.New.Order > 0 {
+		first, second := build.Old, build.New
+		if second.Order < first.Order {
+			first, second = second, first
+		}
+		fmt.Fprintf(w, "  - Builds: %s first, then %s, which may reuse its cached steps\n", first.Dockerfile, second.Dockerfile)
+	}
+	fmt.Fprintf(w, "  - Build time: %.1fs -> %s\n", build.Old.DurationSeconds, duration)
+	fmt.Fprintf(w, "  - Build cache: %d of %d step(s) cached -> %d of %d\n", build.Old.Cached, build.Old.Steps, build.New.Cached, build.New.Steps)
+}
+
+func writeSize(w io.Writer, old, new Summary) {
+	oldSize, newSize := units.HumanSize(float64(old.SizeBytes)), units.HumanSize(float64(new.SizeBytes))
+	switch {
+	case old.SizeBytes == new.SizeBytes:
+		fmt.Fprintf(w, "  - Size: %s (unchanged)\n", newSize)
+	case new.SizeBytes < old.SizeBytes:
+		fmt.Fprintf(w, "  - Size: %s -> %s (%s)\n", oldSize, newSize,
+			utils.SuccessSprintf("%.2f%% smaller", 100-100*float64(new.SizeBytes)/float64(old.SizeBytes)))
+	case old.SizeBytes == 0:
+		fmt.Fprintf(w, "  - Size: %s -> %s\n", oldSize, newSize)
+	default:
+		fmt.Fprintf(w, "  - Size: %s -> %s (%s)\n", oldSize, newSize,
+			utils.ErrorSprintf("%.2f%% bigger", 100*float64(new.SizeBytes)/float64(old.SizeBytes)-100))
+	}
+}
+
+func writeLayers(w io.Writer, diff Diff) {
+	layers := diff.Layers
+	fmt.Fprintf(w, "  - Layers: %d -> %d, %d shared", diff.Old.Layers, diff.New.Layers, len(layers.Shared))
+	if layers.CommonBase > 0 {
+		fmt.Fprintf(w, ", the first %d in common", layers.CommonBase)
+	}
+	fmt.Fprintln(w)
+	if layers.SharedBytes+layers.OldOnlyBytes+layers.NewOnlyBytes > 0 {
+		fmt.Fprintf(w, "      Shared %s, only in %s %s, only in %s %s\n",
+			units.HumanSize(float64(layers.SharedBytes)),
+			diff.Old.Name, units.HumanSize(float64(layers.OldOnlyBytes)),
+			diff.New.Name, units.HumanSize(float64(layers.NewOnlyBytes)))
+	}
+	for _, layer := range layers.OldOnly {
+		fmt.Fprintf(w, "      %s\n", utils.ErrorSprintf("- %s", formatLayer(layer)))
+	}
+	for _, layer := range layers.NewOnly {
+		fmt.Fprintf(w, "      %s\n", utils.SuccessSprintf("+ %s", formatLayer(layer)))
+	}
+}
+
+// formatLayer describes a layer by its size and instruction when the
+// filesystem was read, and by its digest otherwise.
+func formatLayer(layer Layer) string {
+	if layer.Instruction == "" {
+		digest := layer.Digest
+		if len(digest) > shortDigestLength {
+			digest = digest[:shortDigestLength]
+		}
+		return digest
+	}
+	return fmt.Sprintf("%9s  %s", units.HumanSize(float64(layer.SizeBytes)), shorten(layer.Instruction))
+}
+
+func writeBaseOS(w io.Writer, change *BaseOSChange) {
+	if change == nil {
+		return
+	}
+	switch change.Kind {
+	case Unchanged:
+		fmt.Fprintf(w, "  - Base OS: %s (unchanged)\n", change.New.PrettyName)
+	case Added:
+		fmt.Fprintf(w, "  - Base OS: unknown -> %s\n", change.New.PrettyName)
+	case Removed:
+		fmt.Fprintf(w, "  - Base OS: %s -> unknown\n", change.Old.PrettyName)
+	default:
+		fmt.Fprintf(w, "  - Base OS: %s -> %s\n", change.Old.PrettyName, change.New.PrettyName)
+	}
+}
+
+func writeRuntimes(w io.Writer, changes []RuntimeChange) {
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "  - Runtimes: %s\n", utils.WarningSprintf("<none detected>"))
+		return
+	}
+	fmt.Fprintln(w, "  - Runtimes:")
+	for _, change := range changes {
+		switch change.Kind {
+		case Added:
+			fmt.Fprintf(w, "      %s: added %s\n", change.Name, utils.LanguageVersionWithColor(*change.New))
+		case Removed:
+			fmt.Fprintf(w, "      %s: removed %s\n", change.Name, utils.LanguageVersionWithColor(*change.Old))
+		case Unchanged:
+			fmt.Fprintf(w, "      %s: %s (unchanged)\n", change.Name, utils.LanguageVersionWithColor(*change.New))
+		default:
+			fmt.Fprintf(w, "      %s: %s -> %s\n", change.Name,
+				utils.LanguageVersionWithColor(*change.Old), utils.LanguageVersionWithColor(*change.New))
+		}
+	}
+}
+
+func writeConfig(w io.Writer, changes []ConfigChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  - Configuration: unchanged")
+		return
+	}
+	fmt.Fprintln(w, "  - Configuration:")
+	for _, change := range changes {
+		name := change.Field
+		if change.Key != "" {
+			name += " " + change.Key
+		}
+		switch {
+		case change.Kind == Added && change.New == "":
+			fmt.Fprintf(w, "      %s\n", utils.SuccessSprintf("+ %s", name))
+		case change.Kind == Added:
+			fmt.Fprintf(w, "      %s\n", utils.SuccessSprintf("+ %s: %s", name, change.New))
+		case change.Kind == Removed && change.Old == "":
+			fmt.Fprintf(w, "      %s\n", utils.ErrorSprintf("- %s", name))
+		case change.Kind == Removed:
+			fmt.Fprintf(w, "      %s\n", utils.ErrorSprintf("- %s: %s", name, change.Old))
+		default:
+			fmt.Fprintf(w, "      %s: %s -> %s\n", name, change.Old, change.New)
+		}
+	}
+}
+
+func writeFindings(w io.Writer, old, new security.ScoreCard, findings FindingDiff) {
+	score := fmt.Sprintf("%d%% (grade %s)", new.Score, new.Grade)
+	switch {
+	case new.Score > old.Score:
+		score = utils.SuccessSprintf("%s", score)
+	case new.Score < old.Score:
+		score = utils.ErrorSprintf("%s", score)
+	}
+	fmt.Fprintf(w, "  - Security score: %d%% (grade %s) -> %s\n", old.Score, old.Grade, score)
+
+	for _, r := range findings.Fixed {
+		fmt.Fprintf(w, "      %s\n", utils.SuccessSprintf("Fixed %s", formatFinding(r)))
+	}
+	for _, r := range findings.Introduced {
+		fmt.Fprintf(w, "      %s\n", utils.ErrorSprintf("Introduced %s", formatFinding(r)))
+	}
+	if findings.Remaining > 0 {
+		fmt.Fprintf(w, "      %d finding(s) remain in both\n", findings.Remaining)
+	}
+}
+
+func formatFinding(r security.CISResult) string {
+	return fmt.Sprintf("[%s] %s - %s", r.Severity, r.RuleID, r.Message)
+}
+
+func writeFiles(w io.Writer, files FileDiff) {
+	if len(files.Directories) == 0 {
+		fmt.Fprintln(w, "  - Files: unchanged")
+		return
+	}
+	fmt.Fprintf(w, "  - Files: %s\n", formatFileChanges(files.FileChanges))
+	for _, directory := range files.Directories {
+		fmt.Fprintf(w, "      %s: %s\n", directory.Directory, formatFileChanges(directory.FileChanges))
+		for i, file := range directory.Files {
+			if i == maxDirectoryFiles {
+				fmt.Fprintf(w, "          ... and %d more\n", len(directory.Files)-maxDirectoryFiles)
+				break
+			}
+			switch file.Kind {
+			case Added:
+				fmt.Fprintf(w, "          %s\n", utils.SuccessSprintf("+ %9s  %s", units.HumanSize(float64(file.NewSize)), file.Path))
+			case Removed:
+				fmt.Fprintf(w, "          %s\n", utils.ErrorSprintf("- %9s  %s", units.HumanSize(float64(file.OldSize)), file.Path))
+			default:
+				fmt.Fprintf(w, "          ~ %9s  %s\n", formatDelta(file.Delta), file.Path)
+			}
+		}
+	}
+}
+
+// formatFileChanges summarizes changes, e.g. "3 added, 1 removed, 2
+// changed (+12.5MB)".
+func formatFileChanges(changes FileChanges) string {
+	return fmt.Sprintf("%d added, %d removed, %d changed (%s)", changes.Added, changes.Removed, changes.Changed, formatDelta(changes.Delta))
+}
+
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + units.HumanSize(float64(-delta))
+	}
+	return "+" + units.HumanSize(float64(delta))
+}
+
+// WriteMatrix renders matrix as text or JSON.
+func WriteMatrix(w io.Writer, format report.Format, matrix Matrix) error {
+	switch format {
+	case report.FormatText:
+		writeMatrixText(w, matrix)
+		return nil
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(matrix)
+	default:
+		return fmt.Errorf("format %q is not supported by compare (use text or json)", format)
+	}
+}
+
+// writeMatrixText prints the matrix as a table, with the best value of
+// each column in green and followed by an asterisk.
+func writeMatrixText(w io.Writer, matrix Matrix) {
+	header := []string{"IMAGE", "SIZE", "LAYERS", "RUNTIME", "SCORE", "VULNERABILITIES"}
+	columns := []string{"", ColumnSize, ColumnLayers, ColumnRuntime, ColumnScore, ColumnVulnerabilities}
+	cells := [][]string{}
+	for _, row := range matrix.Rows {
+		name := row.Name
+		if row.Name == matrix.Reference {
+			name += " (reference)"
+		}
+		runtime := "<none detected>"
+		if row.Runtime != nil {
+			runtime = row.Runtime.Name + " " + row.Runtime.Version
+		}
+		vulnerabilities := "n/a"
+		if row.Vulnerabilities != nil {
+			vulnerabilities = fmt.Sprint(*row.Vulnerabilities)
+		}
+		line := []string{name, units.HumanSize(float64(row.SizeBytes)), fmt.Sprint(row.Layers), runtime,
+			fmt.Sprintf("%d%% (%s)", row.Score, row.Grade), vulnerabilities}
+		for i, column := range columns {
+			if column != "" && slices.Contains(row.Best, column) {
+				line[i] += "*"
+			}
+		}
+		cells = append(cells, line)
+	}
+
+	widths := make([]int, len(header))
+	for _, line := range append([][]string{header}, cells...) {
+		for i, cell := range line {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	fmt.Fprintf(w, "Comparison of %d images:\n", len(matrix.Rows))
+	writeRow(w, header, widths, nil)
+	for i, line := range cells {
+		writeRow(w, line, widths, func(j int) bool { return j > 0 && slices.Contains(matrix.Rows[i].Best, columns[j]) })
+	}
+	fmt.Fprintln(w, "  * best value of the column")
+
+	for _, diff := range matrix.Diffs {
+		fmt.Fprintln(w)
+		writeText(w, diff)
+	}
+}
+
+// writeRow prints the cells of a table row padded to widths, in green when
+// best reports them as the best of their column.
+func writeRow(w io.Writer, cells []string, widths []int, best func(i int) bool) {
+	var line strings.Builder
+	line.WriteString(" ")
+	for i, cell := range cells {
+		padding := strings.Repeat(" ", widths[i]-len(cell))
+		if best != nil && best(i) {
+			cell = utils.SuccessSprintf("%s", cell)
+		}
+		fmt.Fprintf(&line, " %s%s ", cell, padding)
+	}
+	fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+}
+
+// WriteDockerfileDiff renders diff as text or JSON.
+func WriteDockerfileDiff(w io.Writer, format report.Format, diff DockerfileDiff) error {
+	switch format {
+	case report.FormatText:
+		writeDockerfileText(w, diff)
+		return nil
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	default:
+		return fmt.Errorf("format %q is not supported by compare (use text or json)", format)
+	}
+}
+
+func writeDockerfileText(w io.Writer, diff DockerfileDiff) {
+	fmt.Fprintf(w, "Differences from %s to %s:\n", diff.Old.Name, diff.New.Name)
+	fmt.Fprintf(w, "  - Stages: %d -> %d, instructions: %d -> %d\n", diff.Old.Stages, diff.New.Stages, diff.Old.Instructions, diff.New.Instructions)
+	for _, stage := range diff.Stages {
+		writeStage(w, stage)
+	}
+	writeFindings(w, security.ScoreCard{Score: diff.Old.Score, Grade: diff.Old.Grade},
+		security.ScoreCard{Score: diff.New.Score, Grade: diff.New.Grade}, diff.Findings)
+	writeRules(w, diff.Rules)
+}
+
+func writeStage(w io.Writer, stage StageChange) {
+	switch stage.Kind {
+	case Added:
+		fmt.Fprintf(w, "      %s\n", utils.SuccessSprintf("+ stage %s FROM %s", stage.Stage, stage.NewBase))
+		return
+	case Removed:
+		fmt.Fprintf(w, "      %s\n", utils.ErrorSprintf("- stage %s FROM %s", stage.Stage, stage.OldBase))
+		return
+	case Unchanged:
+		fmt.Fprintf(w, "      stage %s (unchanged)\n", stage.Stage)
+		return
+	}
+
+	fmt.Fprintf(w, "      stage %s:\n", stage.Stage)
+	if stage.OldBase != stage.NewBase {
+		fmt.Fprintf(w, "          Base image: %s -> %s\n", stage.OldBase, stage.NewBase)
+	}
+	for _, change := range stage.Instructions {
+		instruction := shorten(change.Instruction)
+		switch change.Kind {
+		case Added:
+			fmt.Fprintf(w, "          %s\n", utils.SuccessSprintf("+ %4d  %s", change.NewLine, instruction))
+		case Removed:
+			fmt.Fprintf(w, "          %s\n", utils.ErrorSprintf("- %4d  %s", change.OldLine, instruction))
+		default:
+			fmt.Fprintf(w, "          ~ %4d  %s (moved from line %d)\n", change.NewLine, instruction, change.OldLine)
+		}
+	}
+	if stage.CacheBreak != nil {
+		fmt.Fprintf(w, "          %s\n", utils.WarningSprintf("Cache: the change at line %d (%s) rebuilds %d unchanged instruction(s) after it",
+			stage.CacheBreak.Line, shorten(stage.CacheBreak.Instruction), stage.CacheBreak.Rebuilt))
+	}
+}
+
+// writeRules prints side by side the failures of the rules failing in
+// either Dockerfile.
+func writeRules(w io.Writer, rules []RuleDelta) {
+	failing := slices.DeleteFunc(slices.Clone(rules), func(rule RuleDelta) bool { return rule.Old == 0 && rule.New == 0 })
+	if len(failing) == 0 {
+		return
+	}
+	width := len("RULE")
+	for _, rule := range failing {
+		width = max(width, len(rule.RuleID))
+	}
+	fmt.Fprintln(w, "  - Rule failures:")
+	fmt.Fprintf(w, "      %-*s  %5s  %5s\n", width, "RULE", "OLD", "NEW")
+	for _, rule := range failing {
+		line := fmt.Sprintf("%-*s  %5d  %5d", width, rule.RuleID, rule.Old, rule.New)
+		switch {
+		case rule.New < rule.Old:
+			line = utils.SuccessSprintf("%s", line)
+		case rule.New > rule.Old:
+			line = utils.ErrorSprintf("%s", line)
+		}
+		fmt.Fprintf(w, "      %s\n", line)
+	}
+}
+
+// shorten bounds an instruction to maxInstructionWidth.
+func shorten(instruction string) string {
+	if len(instruction) > maxInstructionWidth {
+		return instruction[:maxInstructionWidth-3] + "..."
+	}
+	return instruction
+}
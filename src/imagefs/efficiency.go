@@ -0,0 +1,63 @@
+package imagefs
+
+import "sort"
+
+// WastedFile is a path whose bytes are shipped in a layer but hidden in the
+// final filesystem, because a later layer deleted or overwrote it.
+type WastedFile struct {
+	Path string `json:"path"`
+	// Size is the sum of the sizes of the hidden copies.
+	Size int64 `json:"sizeBytes"`
+	// Copies is the number of hidden copies.
+	Copies int `json:"copies"`
+}
+
+// Efficiency measures how much of the layer data ends up in the final
+// filesystem, like dive does.
+type Efficiency struct {
+	// TotalBytes is the size of the files of all layers.
+	TotalBytes int64 `json:"totalBytes"`
+	// WastedBytes is the part of TotalBytes hidden by later layers.
+	WastedBytes int64 `json:"wastedBytes"`
+	// Score is the percentage of TotalBytes visible in the final filesystem.
+	Score float64 `json:"score"`
+	// Files are the wasted paths, biggest first.
+	Files []WastedFile `json:"files"`
+}
+
+// Efficiency reports the bytes of the layers that are deleted or
+// overwritten by later layers.
+func (img *Image) Efficiency() Efficiency {
+	efficiency := Efficiency{Score: 100, Files: []WastedFile{}}
+	wasted := map[string]*WastedFile{}
+	for _, layer := range img.Layers {
+		efficiency.TotalBytes += layer.Size
+		for _, file := range layer.Files {
+			if file.Size == 0 || img.files[file.Path] == file {
+				continue
+			}
+			w, ok := wasted[file.Path]
+			if !ok {
+				w = &WastedFile{Path: file.Path}
+				wasted[file.Path] = w
+			}
+			w.Size += file.Size
+			w.Copies++
+			efficiency.WastedBytes += file.Size
+		}
+	}
+
+	for _, w := range wasted {
+		efficiency.Files = append(efficiency.Files, *w)
+	}
+	sort.Slice(efficiency.Files, func(i, j int) bool {
+		if efficiency.Files[i].Size != efficiency.Files[j].Size {
+			return efficiency.Files[i].Size > efficiency.Files[j].Size
+		}
+		return efficiency.Files[i].Path < efficiency.Files[j].Path
+	})
+	if efficiency.TotalBytes > 0 {
+		efficiency.Score = 100 * float64(efficiency.TotalBytes-efficiency.WastedBytes) / float64(efficiency.TotalBytes)
+	}
+	return efficiency
+}
@@ -0,0 +1,422 @@
+// Package imagefs reads the configuration and the filesystem of a container
+// image, layer by layer and merged, from the archive written by docker save,
+// an OCI image layout or a registry.
+package imagefs
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// File is an entry of the image filesystem.
+type File struct {
+	// Path is absolute and slash-separated, e.g. /usr/bin/env.
+	Path     string
+	Size     int64
+	Mode     fs.FileMode
+	UID, GID int
+	// Linkname is the target of symbolic and hard links.
+	Linkname string
+	// Layer is the index of the layer that last wrote the file.
+	Layer int
+}
+
+// Layer is the change set of a single image layer.
+type Layer struct {
+	// DiffID is the digest of the uncompressed layer, empty when the archive
+	// does not say.
+	DiffID string
+	// CreatedBy is the command that created the layer, from the image history.
+	CreatedBy string
+	// Size is the sum of the sizes of the files the layer writes.
+	Size int64
+	// Files are the entries the layer adds or changes, in archive order.
+	Files []*File
+	// Deleted are the paths the layer removes through whiteouts. An opaque
+	// directory is recorded as its path followed by "/".
+	Deleted []string
+	archive string
+}
+
+// Image is an image filesystem read from an archive, an OCI layout or a
+// registry.
+type Image struct {
+	// ID is the digest of the image configuration.
+	ID string
+	// RepoTags are the tags the image was saved or pulled with.
+	RepoTags []string
+	// Repository is the repository the image was pulled from, empty for
+	// images read from disk.
+	Repository string
+	// Digest is the digest of the image manifest, empty for docker save
+	// archives that do not record it.
+	Digest string
+	// Config is the image configuration, with the history of the layers.
+	Config dockerspec.DockerOCIImage
+	// Layers are empty when only the configuration was pulled.
+	Layers []*Layer
+	files  map[string]*File
+	// compressedSize is the size of the layers in the registry, for images
+	// pulled without them.
+	compressedSize int64
+	// dir holds the extracted archive; it is removed by Close when temporary.
+	dir       string
+	temporary bool
+}
+
+// manifestEntry is an element of the manifest.json of a docker save archive.
+type manifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// ReadArchive extracts a docker save archive into a temporary directory and
+// loads it. Close removes the directory.
+func ReadArchive(r io.Reader) (*Image, error) {
+	dir, err := os.MkdirTemp("", "dockeryzer-image-")
+	if err != nil {
+		return nil, err
+	}
+	if err := extract(r, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	img, err := Load(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	img.temporary = true
+	return img, nil
+}
+
+// extract writes the regular files of a tar stream below dir.
+func extract(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading image archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean("/" + header.Name)[1:]
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Load reads an extracted docker save archive, or an OCI image layout.
+func Load(dir string) (*Image, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, statErr := os.Stat(filepath.Join(dir, ociLayoutFile)); statErr == nil {
+			return LoadOCILayout(dir)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a docker save archive: %w", err)
+	}
+	var manifest []manifestEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	if len(manifest) == 0 {
+		return nil, errors.New("manifest.json lists no image")
+	}
+
+	entry := manifest[0]
+	layers := []string{}
+	for _, name := range entry.Layers {
+		layers = append(layers, archivePath(dir, name))
+	}
+	img, err := newImage(dir, archivePath(dir, entry.Config), layers)
+	if err != nil {
+		return nil, err
+	}
+	img.RepoTags = entry.RepoTags
+	return img, nil
+}
+
+// newImage reads the configuration file and the layer tarballs of an image
+// and merges the layers.
+func newImage(dir, config string, layers []string) (*Image, error) {
+	data, err := os.ReadFile(config)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{ID: digest.FromBytes(data).String(), dir: dir}
+	if err := json.Unmarshal(data, &img.Config); err != nil {
+		return nil, fmt.Errorf("image config: %w", err)
+	}
+
+	history := []string{}
+	for _, h := range img.Config.History {
+		if !h.EmptyLayer {
+			history = append(history, h.CreatedBy)
+		}
+	}
+	for i, name := range layers {
+		layer := &Layer{archive: name}
+		if i < len(img.Config.RootFS.DiffIDs) {
+			layer.DiffID = img.Config.RootFS.DiffIDs[i].String()
+		}
+		if i < len(history) {
+			layer.CreatedBy = history[i]
+		}
+		if err := layer.read(i); err != nil {
+			return nil, fmt.Errorf("layer %s: %w", filepath.Base(name), err)
+		}
+		img.Layers = append(img.Layers, layer)
+	}
+	img.merge()
+	return img, nil
+}
+
+// archivePath resolves a path of manifest.json inside the extracted archive.
+func archivePath(dir, name string) string {
+	return filepath.Join(dir, filepath.FromSlash(path.Clean("/" + name)[1:]))
+}
+
+// Close removes the files of an image read with ReadArchive or Pull.
+func (img *Image) Close() error {
+	if !img.temporary {
+		return nil
+	}
+	return os.RemoveAll(img.dir)
+}
+
+// openLayer opens a layer tarball, decompressing it when it is gzipped.
+func openLayer(name string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), f, nil
+	}
+	return tar.NewReader(br), f, nil
+}
+
+// read lists the entries and whiteouts of the layer.
+func (l *Layer) read(index int) error {
+	tr, closer, err := openLayer(l.archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + header.Name)
+		dir, base := path.Split(name)
+		switch {
+		case base == opaqueWhiteout:
+			l.Deleted = append(l.Deleted, dir)
+		case strings.HasPrefix(base, whiteoutPrefix):
+			l.Deleted = append(l.Deleted, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		case name != "/":
+			file := &File{
+				Path:     name,
+				Mode:     header.FileInfo().Mode(),
+				UID:      header.Uid,
+				GID:      header.Gid,
+				Linkname: header.Linkname,
+				Layer:    index,
+			}
+			if header.Typeflag == tar.TypeReg {
+				file.Size = header.Size
+			}
+			l.Size += file.Size
+			l.Files = append(l.Files, file)
+		}
+	}
+}
+
+// merge applies the layers in order to build the final filesystem.
+func (img *Image) merge() {
+	img.files = map[string]*File{}
+	for _, layer := range img.Layers {
+		for _, deleted := range layer.Deleted {
+			img.remove(deleted)
+		}
+		for _, file := range layer.Files {
+			if previous, ok := img.files[file.Path]; ok && previous.Mode.IsDir() && !file.Mode.IsDir() {
+				img.remove(file.Path)
+			}
+			img.files[file.Path] = file
+		}
+	}
+}
+
+// remove deletes p and everything below it. A path ending in "/" removes
+// the contents of the directory only.
+func (img *Image) remove(p string) {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	if !strings.HasSuffix(p, "/") {
+		delete(img.files, p)
+	}
+	for name := range img.files {
+		if strings.HasPrefix(name, prefix) {
+			delete(img.files, name)
+		}
+	}
+}
+
+// Files returns the entries of the merged filesystem sorted by path.
+func (img *Image) Files() []*File {
+	files := make([]*File, 0, len(img.files))
+	for _, file := range img.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Stat returns the entry of the merged filesystem at the absolute path p.
+func (img *Image) Stat(p string) (*File, bool) {
+	file, ok := img.files[path.Clean("/"+p)]
+	return file, ok
+}
+
+// Size returns the sum of the sizes of the files in the merged filesystem.
+func (img *Image) Size() int64 {
+	var size int64
+	for _, file := range img.files {
+		size += file.Size
+	}
+	return size
+}
+
+// ReadFile returns up to limit bytes of the regular file at path p in the
+// merged filesystem, following hard links.
+func (img *Image) ReadFile(p string, limit int64) ([]byte, error) {
+	file, ok := img.Stat(p)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	if file.Linkname != "" && !file.Mode.IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", p)
+	}
+
+	tr, closer, err := openLayer(img.Layers[file.Layer].archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fs.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Clean("/"+header.Name) != file.Path {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			return img.ReadFile("/"+header.Linkname, limit)
+		}
+		return io.ReadAll(io.LimitReader(tr, limit))
+	}
+}
+
+// Walk calls fn with the content of the regular files of the merged
+// filesystem that match accepts, reading every layer at most once. Hard
+// links are left out.
+func (img *Image) Walk(match func(file *File) bool, fn func(file *File, content io.Reader) error) error {
+	for i, layer := range img.Layers {
+		if !slices.ContainsFunc(layer.Files, func(file *File) bool { return img.files[file.Path] == file && img.walkable(file, match) }) {
+			continue
+		}
+		if err := img.walkLayer(i, match, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (img *Image) walkable(file *File, match func(file *File) bool) bool {
+	return file.Mode.IsRegular() && file.Linkname == "" && match(file)
+}
+
+// walkLayer calls fn for the files of match that layer index wrote last.
+func (img *Image) walkLayer(index int, match func(file *File) bool, fn func(file *File, content io.Reader) error) error {
+	tr, closer, err := openLayer(img.Layers[index].archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		file, ok := img.files[path.Clean("/"+header.Name)]
+		if !ok || file.Layer != index || header.Typeflag != tar.TypeReg || !img.walkable(file, match) {
+			continue
+		}
+		if err := fn(file, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package imagefs
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+// entry is a tar entry of a test layer.
+type entry struct {
+	name     string
+	content  string
+	mode     int64
+	typeflag byte
+	linkname string
+}
+
+func layerTar(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: e.mode, Typeflag: e.typeflag, Linkname: e.linkname}
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if header.Mode == 0 {
+			header.Mode = 0o644
+		}
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// saveArchive builds a docker save archive with the given layers.
+func saveArchive(t *testing.T, layers ...[]entry) []byte {
+	t.Helper()
+	files := map[string][]byte{}
+	manifest := manifestEntry{Config: "blobs/sha256/config", RepoTags: []string{"app:1.0"}}
+	for i, layer := range layers {
+		name := "blobs/sha256/layer" + string(rune('0'+i))
+		files[name] = layerTar(t, layer)
+		manifest.Layers = append(manifest.Layers, name)
+	}
+	files["blobs/sha256/config"] = []byte(`{"architecture":"amd64","os":"linux","config":{"User":"app"},"rootfs":{"type":"layers","diff_ids":[]}}`)
+	data, _ := json.Marshal([]manifestEntry{manifest})
+	files["manifest.json"] = data
+
+	entries := []entry{}
+	for name, content := range files {
+		entries = append(entries, entry{name: name, content: string(content)})
+	}
+	return layerTar(t, entries)
+}
+
+func testArchive(t *testing.T) []byte {
+	return saveArchive(t,
+		[]entry{
+			{name: "bin/", typeflag: tar.TypeDir, mode: 0o755},
+			{name: "bin/sh", content: "#!binary", mode: 0o755},
+			{name: "bin/su", content: "su", mode: 0o4755},
+			{name: "var/cache/apk/", typeflag: tar.TypeDir, mode: 0o755},
+			{name: "var/cache/apk/APKINDEX.tar.gz", content: "index"},
+			{name: "app/", typeflag: tar.TypeDir, mode: 0o755},
+			{name: "app/old.txt", content: "old"},
+		},
+		[]entry{
+			{name: "var/cache/apk/.wh..wh..opq"},
+			{name: "app/.wh.old.txt"},
+			{name: "app/server", content: "server binary", mode: 0o755},
+			{name: "app/sh", typeflag: tar.TypeSymlink, linkname: "/bin/sh"},
+			{name: "app/hard", typeflag: tar.TypeLink, linkname: "app/server"},
+			{name: "bin/su", content: "su2", mode: 0o755},
+		},
+	)
+}
+
+func TestReadArchive(t *testing.T) {
+	img, err := ReadArchive(bytes.NewReader(testArchive(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer img.Close()
+
+	if len(img.Layers) != 2 || len(img.RepoTags) != 1 || img.Config.Config.User != "app" {
+		t.Fatalf("Unexpected image: %+v", img)
+	}
+	if img.Layers[0].Size != int64(len("#!binary")+len("su")+len("index")+len("old")) {
+		t.Errorf("Unexpected layer size %d", img.Layers[0].Size)
+	}
+	if deleted := img.Layers[1].Deleted; len(deleted) != 2 || deleted[0] != "/var/cache/apk/" || deleted[1] != "/app/old.txt" {
+		t.Errorf("Unexpected whiteouts %q", deleted)
+	}
+
+	paths := []string{}
+	for _, file := range img.Files() {
+		paths = append(paths, file.Path)
+	}
+	expected := []string{"/app", "/app/hard", "/app/server", "/app/sh", "/bin", "/bin/sh", "/bin/su", "/var/cache/apk"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("Expected %q, got %q", expected, paths)
+		}
+	}
+
+	if su, _ := img.Stat("/bin/su"); su.Mode&fs.ModeSetuid != 0 || su.Layer != 1 {
+		t.Errorf("Expected /bin/su from layer 1 without setuid, got %+v", su)
+	}
+	if server, _ := img.Stat("app/server"); server.Layer != 1 {
+		t.Errorf("Expected /app/server in layer 1, got %d", server.Layer)
+	}
+
+	content, err := img.ReadFile("/app/hard", 6)
+	if err != nil || string(content) != "server" {
+		t.Errorf("Unexpected content %q, %v", content, err)
+	}
+	if _, err := img.ReadFile("/app/old.txt", 10); err == nil {
+		t.Errorf("Expected deleted file to be missing")
+	}
+}
+
+func TestEfficiency(t *testing.T) {
+	img, err := ReadArchive(bytes.NewReader(testArchive(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer img.Close()
+
+	efficiency := img.Efficiency()
+	// Layer 0 writes 18 bytes, layer 1 writes 16; the 5-byte APKINDEX, the
+	// 3-byte old.txt and the 2-byte su of layer 0 are hidden.
+	if efficiency.TotalBytes != 34 || efficiency.WastedBytes != 10 {
+		t.Fatalf("Unexpected efficiency: %+v", efficiency)
+	}
+	if efficiency.Score < 70.5 || efficiency.Score > 70.6 {
+		t.Errorf("Expected a score of 70.6%%, got %.2f", efficiency.Score)
+	}
+	files := efficiency.Files
+	if len(files) != 3 || files[0].Path != "/var/cache/apk/APKINDEX.tar.gz" || files[1].Path != "/app/old.txt" || files[2].Copies != 1 {
+		t.Errorf("Unexpected wasted files: %+v", files)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	img, err := ReadArchive(bytes.NewReader(testArchive(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer img.Close()
+
+	got := map[string]string{}
+	err = img.Walk(func(file *File) bool { return file.Mode&0o111 != 0 }, func(file *File, content io.Reader) error {
+		data, err := io.ReadAll(content)
+		got[file.Path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The su of layer 0 is overwritten and the hard link is left out.
+	want := map[string]string{"/bin/sh": "#!binary", "/bin/su": "su2", "/app/server": "server binary"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s: got %q, want %q", name, got[name], content)
+		}
+	}
+}
+
+func TestOSRelease(t *testing.T) {
+	archive := saveArchive(t, []entry{
+		{name: "etc/os-release", typeflag: tar.TypeSymlink, linkname: "../usr/lib/os-release"},
+		{name: "usr/lib/os-release", content: "# comment\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nID=debian\nVERSION_ID='12'\n"},
+	})
+	img, err := ReadArchive(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer img.Close()
+
+	fields := img.OSRelease()
+	if fields["ID"] != "debian" || fields["VERSION_ID"] != "12" || fields["PRETTY_NAME"] != "Debian GNU/Linux 12 (bookworm)" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	scratch, err := ReadArchive(bytes.NewReader(saveArchive(t, []entry{{name: "app", content: "binary"}})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer scratch.Close()
+	if fields := scratch.OSRelease(); fields != nil {
+		t.Errorf("got %v for an image without os-release", fields)
+	}
+}
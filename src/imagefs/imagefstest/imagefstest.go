@@ -0,0 +1,95 @@
+// Package imagefstest builds test images in the docker save format, to be
+// read with imagefs.ReadArchive.
+package imagefstest
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// File is an entry of a test layer: a regular file, a directory when Dir is
+// set, or a symbolic link when Link is set.
+type File struct {
+	Name    string
+	Content []byte
+	// Mode defaults to 0644 for files and 0755 for directories.
+	Mode int64
+	Dir  bool
+	Link string
+}
+
+// Layer returns the tarball of a layer holding files, in order.
+func Layer(t testing.TB, files ...File) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		header := &tar.Header{Name: f.Name, Mode: f.Mode, Typeflag: tar.TypeReg, Size: int64(len(f.Content))}
+		switch {
+		case f.Dir:
+			header.Typeflag, header.Size = tar.TypeDir, 0
+			if header.Mode == 0 {
+				header.Mode = 0o755
+			}
+		case f.Link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, f.Link, 0
+		}
+		if header.Mode == 0 {
+			header.Mode = 0o644
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := tw.Write(f.Content[:header.Size]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Archive returns a docker save archive of an image tagged app:1.0 with the
+// layers, from the base up. The digests of the layers are their diff IDs, so
+// that equal layers are shared between images.
+func Archive(t testing.TB, layers ...[]File) []byte {
+	t.Helper()
+	files := []File{}
+	names, diffIDs := []string{}, []string{}
+	for i, layer := range layers {
+		content := Layer(t, layer...)
+		name := fmt.Sprintf("layer%d.tar", i)
+		files = append(files, File{Name: name, Content: content})
+		names = append(names, name)
+		diffIDs = append(diffIDs, fmt.Sprintf("sha256:%x", sha256.Sum256(content)))
+	}
+	manifest, err := json.Marshal([]map[string]any{{"Config": "config.json", "RepoTags": []string{"app:1.0"}, "Layers": names}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config, err := json.Marshal(map[string]any{"architecture": "amd64", "os": "linux", "rootfs": map[string]any{"type": "layers", "diff_ids": diffIDs}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files = append(files, File{Name: "manifest.json", Content: manifest}, File{Name: "config.json", Content: config})
+	return Layer(t, files...)
+}
+
+// New reads the image of Archive and removes its files at the end of the
+// test.
+func New(t testing.TB, layers ...[]File) *imagefs.Image {
+	t.Helper()
+	img, err := imagefs.ReadArchive(bytes.NewReader(Archive(t, layers...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { img.Close() })
+	return img
+}
@@ -0,0 +1,45 @@
+package imagefs
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// Inspect describes the image the way docker image inspect does, so the
+// analyzers work the same on images read without a Docker daemon.
+func (img *Image) Inspect() image.InspectResponse {
+	config := img.Config.Config
+	inspect := image.InspectResponse{
+		ID:           img.ID,
+		RepoTags:     img.RepoTags,
+		RepoDigests:  []string{},
+		Author:       img.Config.Author,
+		Architecture: img.Config.Architecture,
+		Variant:      img.Config.Variant,
+		Os:           img.Config.OS,
+		OsVersion:    img.Config.OSVersion,
+		Config:       &config,
+		RootFS:       image.RootFS{Type: img.Config.RootFS.Type},
+	}
+	if inspect.RepoTags == nil {
+		inspect.RepoTags = []string{}
+	}
+	if img.Repository != "" && img.Digest != "" {
+		inspect.RepoDigests = append(inspect.RepoDigests, img.Repository+"@"+img.Digest)
+	}
+	if img.Config.Created != nil {
+		inspect.Created = img.Config.Created.Format(time.RFC3339Nano)
+	}
+	for _, diffID := range img.Config.RootFS.DiffIDs {
+		inspect.RootFS.Layers = append(inspect.RootFS.Layers, diffID.String())
+	}
+
+	for _, layer := range img.Layers {
+		inspect.Size += layer.Size
+	}
+	if len(img.Layers) == 0 {
+		inspect.Size = img.compressedSize
+	}
+	return inspect
+}
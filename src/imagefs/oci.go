@@ -0,0 +1,148 @@
+package imagefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const ociLayoutFile = "oci-layout"
+
+// Media types of the manifest lists that Docker registries still serve
+// next to the OCI ones.
+const (
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// refNameAnnotation holds the tag of a manifest in an OCI layout index.
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// manifestDocument holds the fields of both image indexes and image
+// manifests, which are told apart by their media type or their content.
+type manifestDocument struct {
+	MediaType string               `json:"mediaType"`
+	Manifests []ocispec.Descriptor `json:"manifests"`
+	Config    ocispec.Descriptor   `json:"config"`
+	Layers    []ocispec.Descriptor `json:"layers"`
+}
+
+func (m manifestDocument) isIndex() bool {
+	return m.MediaType == ocispec.MediaTypeImageIndex || m.MediaType == dockerManifestListMediaType || m.Manifests != nil
+}
+
+// fetchFunc returns the content of the blob of a descriptor.
+type fetchFunc func(desc ocispec.Descriptor) ([]byte, error)
+
+// resolveManifest follows image indexes down to the image manifest for the
+// platform of the running binary, falling back to linux/amd64.
+func resolveManifest(desc ocispec.Descriptor, data []byte, fetch fetchFunc) (ocispec.Descriptor, manifestDocument, error) {
+	for depth := 0; depth < 4; depth++ {
+		var document manifestDocument
+		if err := json.Unmarshal(data, &document); err != nil {
+			return desc, document, fmt.Errorf("manifest %s: %w", desc.Digest, err)
+		}
+		if !document.isIndex() {
+			return desc, document, nil
+		}
+
+		next, ok := selectPlatform(document.Manifests)
+		if !ok {
+			return desc, document, fmt.Errorf("index %s has no linux image", desc.Digest)
+		}
+		content, err := fetch(next)
+		if err != nil {
+			return desc, document, err
+		}
+		desc, data = next, content
+	}
+	return desc, manifestDocument{}, errors.New("image indexes are nested too deeply")
+}
+
+// selectPlatform picks the manifest of an index matching the platform of the
+// running binary, then linux/amd64, then the first one that is not an
+// attestation.
+func selectPlatform(manifests []ocispec.Descriptor) (ocispec.Descriptor, bool) {
+	candidates := []ocispec.Descriptor{}
+	for _, m := range manifests {
+		if m.Platform != nil && (m.Platform.OS == "unknown" || m.Platform.Architecture == "unknown") {
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+	for _, arch := range []string{runtime.GOARCH, "amd64"} {
+		for _, m := range candidates {
+			if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == arch {
+				return m, true
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], true
+	}
+	return ocispec.Descriptor{}, false
+}
+
+// blobPath returns the path of a blob in an OCI layout.
+func blobPath(dir string, d digest.Digest) string {
+	return filepath.Join(dir, "blobs", d.Algorithm().String(), d.Encoded())
+}
+
+// readBlob reads a blob of an OCI layout and checks its digest.
+func readBlob(dir string, desc ocispec.Descriptor) ([]byte, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(blobPath(dir, desc.Digest))
+	if err != nil {
+		return nil, err
+	}
+	if desc.Digest.Algorithm().FromBytes(data) != desc.Digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	return data, nil
+}
+
+// LoadOCILayout reads an OCI image layout directory. When the index lists
+// several images, the first one is used.
+func LoadOCILayout(dir string) (*Image, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("not an OCI image layout: %w", err)
+	}
+	fetch := func(desc ocispec.Descriptor) ([]byte, error) { return readBlob(dir, desc) }
+	desc, manifest, err := resolveManifest(ocispec.Descriptor{Digest: digest.FromBytes(data)}, data, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []string{}
+	for _, layer := range manifest.Layers {
+		if err := layer.Digest.Validate(); err != nil {
+			return nil, err
+		}
+		layers = append(layers, blobPath(dir, layer.Digest))
+	}
+	if err := manifest.Config.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	img, err := newImage(dir, blobPath(dir, manifest.Config.Digest), layers)
+	if err != nil {
+		return nil, err
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err == nil && len(index.Manifests) > 0 {
+		if tag := index.Manifests[0].Annotations[refNameAnnotation]; tag != "" {
+			img.RepoTags = []string{tag}
+		}
+	}
+	img.Digest = desc.Digest.String()
+	return img, nil
+}
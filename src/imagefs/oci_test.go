@@ -0,0 +1,167 @@
+package imagefs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ociImage is a test image as the blobs of an OCI layout or a registry. Its
+// index lists an attestation and a linux/amd64 image manifest.
+type ociImage struct {
+	blobs    map[digest.Digest][]byte
+	index    []byte
+	manifest digest.Digest
+	config   digest.Digest
+	layer    digest.Digest
+}
+
+func newOCIImage(t *testing.T) ociImage {
+	t.Helper()
+	img := ociImage{blobs: map[digest.Digest][]byte{}}
+	add := func(data []byte) ocispec.Descriptor {
+		d := digest.FromBytes(data)
+		img.blobs[d] = data
+		return ocispec.Descriptor{Digest: d, Size: int64(len(data))}
+	}
+	marshal := func(v any) []byte {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return data
+	}
+
+	layerData := layerTar(t, []entry{{name: "etc/os-release", content: "ID=alpine\n"}, {name: "app/server", content: "binary", mode: 0o755}})
+	layer := add(layerData)
+	layer.MediaType = ocispec.MediaTypeImageLayer
+	config := add([]byte(`{"created":"2024-05-01T10:00:00Z","architecture":"amd64","os":"linux",` +
+		`"config":{"User":"app","Env":["PATH=/usr/bin"],"Entrypoint":["/app/server"]},` +
+		`"rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(layerData).String() + `"]},` +
+		`"history":[{"created_by":"COPY server /app/server # buildkit"}]}`))
+	config.MediaType = ocispec.MediaTypeImageConfig
+
+	manifest := add(marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	}))
+	manifest.MediaType = ocispec.MediaTypeImageManifest
+	manifest.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	attestation := add([]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`))
+	attestation.MediaType = ocispec.MediaTypeImageManifest
+	attestation.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+
+	img.index = marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{attestation, manifest},
+	})
+	img.manifest, img.config, img.layer = manifest.Digest, config.Digest, layer.Digest
+	return img
+}
+
+// writeLayout writes the image as an OCI layout whose index points at the
+// image index, tagged with refName.
+func (img ociImage) writeLayout(t *testing.T, refName string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for d, data := range img.blobs {
+		writeFile(t, blobPath(dir, d), data)
+	}
+	nested := digest.FromBytes(img.index)
+	writeFile(t, blobPath(dir, nested), img.index)
+	index, _ := json.Marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{{
+			MediaType:   ocispec.MediaTypeImageIndex,
+			Digest:      nested,
+			Size:        int64(len(img.index)),
+			Annotations: map[string]string{refNameAnnotation: refName},
+		}},
+	})
+	writeFile(t, filepath.Join(dir, "index.json"), index)
+	writeFile(t, filepath.Join(dir, ociLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	return dir
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadOCILayout(t *testing.T) {
+	img := newOCIImage(t)
+	dir := img.writeLayout(t, "app:1.0")
+
+	for _, load := range []func(string) (*Image, error){LoadOCILayout, Load} {
+		fsys, err := load(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fsys.ID != img.config.String() || fsys.Digest != img.manifest.String() {
+			t.Errorf("got ID %s and digest %s, want %s and %s", fsys.ID, fsys.Digest, img.config, img.manifest)
+		}
+		if len(fsys.RepoTags) != 1 || fsys.RepoTags[0] != "app:1.0" {
+			t.Errorf("got tags %v, want [app:1.0]", fsys.RepoTags)
+		}
+		if _, ok := fsys.Stat("/app/server"); !ok {
+			t.Errorf("expected /app/server in the filesystem")
+		}
+		if len(fsys.Layers) != 1 || fsys.Layers[0].CreatedBy != "COPY server /app/server # buildkit" {
+			t.Errorf("unexpected layers %+v", fsys.Layers)
+		}
+		if err := fsys.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Close removed the layout of the user: %v", err)
+		}
+	}
+}
+
+func TestLoadOCILayoutRejectsTamperedBlobs(t *testing.T) {
+	img := newOCIImage(t)
+	dir := img.writeLayout(t, "app:1.0")
+	writeFile(t, blobPath(dir, img.manifest), []byte(`{"layers":[]}`))
+
+	if _, err := LoadOCILayout(dir); err == nil {
+		t.Fatalf("expected an error for a blob that does not match its digest")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	img := newOCIImage(t)
+	fsys, err := LoadOCILayout(img.writeLayout(t, "app:1.0"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inspect := fsys.Inspect()
+
+	if inspect.ID != img.config.String() || inspect.Os != "linux" || inspect.Architecture != "amd64" {
+		t.Errorf("unexpected inspect %+v", inspect)
+	}
+	if inspect.Created != "2024-05-01T10:00:00Z" {
+		t.Errorf("got created %q", inspect.Created)
+	}
+	if inspect.Config == nil || inspect.Config.User != "app" || len(inspect.Config.Entrypoint) != 1 {
+		t.Errorf("unexpected config %+v", inspect.Config)
+	}
+	if len(inspect.RootFS.Layers) != 1 {
+		t.Errorf("got %d layers, want 1", len(inspect.RootFS.Layers))
+	}
+	if inspect.Size != int64(len("ID=alpine\n")+len("binary")) {
+		t.Errorf("got size %d", inspect.Size)
+	}
+	if len(inspect.RepoDigests) != 0 {
+		t.Errorf("got digests %v for an image that was not pulled", inspect.RepoDigests)
+	}
+}
@@ -0,0 +1,37 @@
+package imagefs
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// maxOSReleaseSize bounds the bytes read from os-release.
+const maxOSReleaseSize = 16 << 10
+
+// osReleasePaths are the locations of os-release, the first being a symlink
+// to the second on most distributions.
+var osReleasePaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// OSRelease returns the fields of the os-release file of the image, like ID,
+// VERSION_ID and PRETTY_NAME, or nil when there is none, as in scratch and
+// some distroless images.
+func (img *Image) OSRelease() map[string]string {
+	for _, name := range osReleasePaths {
+		content, err := img.ReadFile(name, maxOSReleaseSize)
+		if err != nil {
+			continue
+		}
+		fields := map[string]string{}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !found || strings.HasPrefix(key, "#") {
+				continue
+			}
+			fields[key] = strings.Trim(value, `"'`)
+		}
+		return fields
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+package imagefs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	dockerHubAuthKey  = "https://index.docker.io/v1/"
+)
+
+// maxManifestSize bounds the manifests and configurations read into memory.
+const maxManifestSize = 4 << 20
+
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// manifestMediaTypes are the manifest formats accepted from registries.
+var manifestMediaTypes = []string{
+	ocispec.MediaTypeImageIndex,
+	ocispec.MediaTypeImageManifest,
+	dockerManifestListMediaType,
+	dockerManifestMediaType,
+}
+
+// Reference is an image reference split into its parts.
+type Reference struct {
+	// Domain is the registry as written, docker.io for Docker Hub.
+	Domain     string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference like ghcr.io/org/app:1.0,
+// localhost:5000/app@sha256:... or node:20. Docker Hub is the default
+// registry, library/ the default namespace and latest the default tag.
+func ParseReference(reference string) (Reference, error) {
+	ref := Reference{Domain: dockerHubDomain}
+	name, dgst, hasDigest := strings.Cut(reference, "@")
+	if hasDigest {
+		if err := digest.Digest(dgst).Validate(); err != nil {
+			return ref, fmt.Errorf("invalid reference %q: %w", reference, err)
+		}
+		ref.Digest = dgst
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if first, rest, found := strings.Cut(name, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Domain, name = first, rest
+	}
+	if name == "" || strings.HasSuffix(name, "/") || name != strings.ToLower(name) {
+		return ref, fmt.Errorf("invalid reference %q", reference)
+	}
+	if ref.Domain == dockerHubDomain && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = name
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Name returns the repository in its familiar form, without the Docker Hub
+// domain and library/ namespace.
+func (r Reference) Name() string {
+	if r.Domain == dockerHubDomain {
+		return strings.TrimPrefix(r.Repository, "library/")
+	}
+	return r.Domain + "/" + r.Repository
+}
+
+// PullOptions are the settings of Pull.
+type PullOptions struct {
+	// ConfigOnly fetches the manifest and the configuration but no layers,
+	// so the image has no filesystem.
+	ConfigOnly bool
+	// Username and Password authenticate to the registry. When empty, the
+	// credentials stored by docker login are used, if any.
+	Username string
+	Password string
+	// PlainHTTP talks to the registry over HTTP, which is always the case
+	// for localhost and 127.0.0.1.
+	PlainHTTP bool
+	// Client is the HTTP client, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// registryClient fetches manifests and blobs of one repository.
+type registryClient struct {
+	ref           Reference
+	base          string
+	client        *http.Client
+	username      string
+	password      string
+	authorization string
+}
+
+// isLoopback reports whether a registry host, with an optional port, is the
+// local machine, which is reached over plain HTTP.
+func isLoopback(host string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Pull reads an image from a registry into a temporary directory. Close
+// removes the directory.
+func Pull(ctx context.Context, reference string, options PullOptions) (*Image, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	c := &registryClient{ref: ref, client: options.Client, username: options.Username, password: options.Password}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	host := ref.Domain
+	if host == dockerHubDomain {
+		host = dockerHubRegistry
+	}
+	scheme := "https"
+	if options.PlainHTTP || isLoopback(host) {
+		scheme = "http"
+	}
+	c.base = scheme + "://" + host + "/v2/" + ref.Repository
+	if c.username == "" {
+		c.username, c.password = dockerConfigCredentials(ref.Domain)
+	}
+
+	manifestRef := ref.Digest
+	if manifestRef == "" {
+		manifestRef = ref.Tag
+	}
+	data, mediaType, err := c.fetch(ctx, "/manifests/"+manifestRef, manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	top := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data)}
+	if ref.Digest != "" && top.Digest.String() != ref.Digest {
+		return nil, fmt.Errorf("manifest of %s does not match its digest", reference)
+	}
+	_, manifest, err := resolveManifest(top, data, func(d ocispec.Descriptor) ([]byte, error) {
+		content, _, err := c.fetch(ctx, "/manifests/"+d.Digest.String(), manifestMediaTypes)
+		if err == nil && digest.FromBytes(content) != d.Digest {
+			err = fmt.Errorf("manifest %s does not match its digest", d.Digest)
+		}
+		return content, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "dockeryzer-image-")
+	if err != nil {
+		return nil, err
+	}
+	img, err := c.download(ctx, dir, manifest, options.ConfigOnly)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	img.temporary = true
+	img.Digest = top.Digest.String()
+	img.Repository = ref.Name()
+	if ref.Tag != "" {
+		img.RepoTags = []string{ref.Name() + ":" + ref.Tag}
+	}
+	return img, nil
+}
+
+// download saves the configuration and, unless configOnly, the layers of
+// manifest below dir and reads them.
+func (c *registryClient) download(ctx context.Context, dir string, manifest manifestDocument, configOnly bool) (*Image, error) {
+	config, err := c.downloadBlob(ctx, dir, manifest.Config)
+	if err != nil {
+		return nil, err
+	}
+	layers := []string{}
+	var compressed int64
+	for _, layer := range manifest.Layers {
+		compressed += layer.Size
+		if configOnly {
+			continue
+		}
+		file, err := c.downloadBlob(ctx, dir, layer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, file)
+	}
+
+	img, err := newImage(dir, config, layers)
+	if err != nil {
+		return nil, err
+	}
+	img.compressedSize = compressed
+	return img, nil
+}
+
+// downloadBlob saves a blob in the layout of an OCI image and checks its
+// digest.
+func (c *registryClient) downloadBlob(ctx context.Context, dir string, desc ocispec.Descriptor) (string, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return "", err
+	}
+	resp, err := c.get(ctx, "/blobs/"+desc.Digest.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	name := blobPath(dir, desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	verifier := desc.Digest.Verifier()
+	if _, err := io.Copy(io.MultiWriter(f, verifier), resp.Body); err != nil {
+		return "", fmt.Errorf("blob %s: %w", desc.Digest, err)
+	}
+	if !verifier.Verified() {
+		return "", fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	return name, nil
+}
+
+// fetch reads a manifest, returning its content and media type.
+func (c *registryClient) fetch(ctx context.Context, path string, accept []string) ([]byte, string, error) {
+	resp, err := c.get(ctx, path, accept)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	return data, resp.Header.Get("Content-Type"), err
+}
+
+// get sends a GET to the repository, answering an authentication challenge
+// once.
+func (c *registryClient) get(ctx context.Context, path string, accept []string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := c.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, fmt.Errorf("%s%s: %s", c.ref.Name(), path, resp.Status)
+	}
+}
+
+// authenticate answers a Basic or Bearer challenge of the registry.
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.username == "" {
+			return fmt.Errorf("%s needs credentials", c.ref.Domain)
+		}
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	values := map[string]string{}
+	for _, match := range challengeParamPattern.FindAllStringSubmatch(params, -1) {
+		values[match[1]] = match[2]
+	}
+	if values["realm"] == "" {
+		return errors.New("authentication challenge without a realm")
+	}
+	query := url.Values{}
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, values["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting a token for %s: %s", c.ref.Name(), resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	c.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// dockerConfigCredentials returns the credentials docker login stored for a
+// registry in the Docker config file. Credential helpers are not supported.
+func dockerConfigCredentials(domain string) (string, string) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &config) != nil {
+		return "", ""
+	}
+
+	key := domain
+	if domain == dockerHubDomain {
+		key = dockerHubAuthKey
+	}
+	for _, candidate := range []string{key, "https://" + key, "http://" + key} {
+		if entry, ok := config.Auths[candidate]; ok {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return "", ""
+			}
+			username, password, _ := strings.Cut(string(decoded), ":")
+			return username, password
+		}
+	}
+	return "", ""
+}
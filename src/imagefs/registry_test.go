@@ -0,0 +1,208 @@
+package imagefs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      Reference
+		name      string
+	}{
+		{"node", Reference{Domain: "docker.io", Repository: "library/node", Tag: "latest"}, "node"},
+		{"node:20-alpine", Reference{Domain: "docker.io", Repository: "library/node", Tag: "20-alpine"}, "node"},
+		{"bitnami/redis:7", Reference{Domain: "docker.io", Repository: "bitnami/redis", Tag: "7"}, "bitnami/redis"},
+		{"ghcr.io/org/app:1.0", Reference{Domain: "ghcr.io", Repository: "org/app", Tag: "1.0"}, "ghcr.io/org/app"},
+		{"localhost:5000/app", Reference{Domain: "localhost:5000", Repository: "app", Tag: "latest"}, "localhost:5000/app"},
+		{
+			"localhost/app:2@sha256:" + strings.Repeat("a", 64),
+			Reference{Domain: "localhost", Repository: "app", Tag: "2", Digest: "sha256:" + strings.Repeat("a", 64)},
+			"localhost/app",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.reference)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.reference, err)
+		}
+		if got != tt.want || got.Name() != tt.name {
+			t.Errorf("%s: got %+v (%s), want %+v (%s)", tt.reference, got, got.Name(), tt.want, tt.name)
+		}
+	}
+
+	for _, reference := range []string{"Node:20", "app@sha256:abc", ""} {
+		if _, err := ParseReference(reference); err == nil {
+			t.Errorf("%q: expected an error", reference)
+		}
+	}
+}
+
+// testRegistry serves an image over the distribution API, behind a token
+// server that wants the credentials user and secret.
+type testRegistry struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestRegistry(t *testing.T, img ociImage) *testRegistry {
+	t.Helper()
+	registry := &testRegistry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" || r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"token":"t0k3n"}`))
+	})
+	mux.HandleFunc("/v2/team/app/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="test",scope="repository:team/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		registry.mu.Lock()
+		registry.requests = append(registry.requests, r.URL.Path)
+		registry.mu.Unlock()
+
+		kind, ref, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/team/app/"), "/")
+		switch {
+		case kind == "manifests" && (ref == "1.0" || ref == digest.FromBytes(img.index).String()):
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			w.Write(img.index)
+		case kind == "manifests" && ref == img.manifest.String():
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Write(img.blobs[img.manifest])
+		case kind == "blobs" && img.blobs[digest.Digest(ref)] != nil:
+			w.Write(img.blobs[digest.Digest(ref)])
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	registry.Server = httptest.NewServer(mux)
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (r *testRegistry) reference(ref string) string {
+	return strings.TrimPrefix(r.URL, "http://") + "/team/app" + ref
+}
+
+func (r *testRegistry) fetched(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, request := range r.requests {
+		if strings.HasSuffix(request, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"localhost":             true,
+		"localhost:5000":        true,
+		"127.0.0.1:5000":        true,
+		"127.0.0.2":             true,
+		"[::1]:5000":            true,
+		"localhost.example.com": false,
+		"127.0.0.1.nip.io:5000": false,
+		"registry.example.com":  false,
+		"10.0.0.1:5000":         false,
+	}
+	for host, expected := range tests {
+		if isLoopback(host) != expected {
+			t.Errorf("Expected isLoopback(%q) = %v", host, expected)
+		}
+	}
+}
+
+func TestPull(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	img := newOCIImage(t)
+	registry := newTestRegistry(t, img)
+
+	fsys, err := Pull(context.Background(), registry.reference(":1.0"), PullOptions{Username: "user", Password: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fsys.Close()
+
+	if fsys.ID != img.config.String() {
+		t.Errorf("got ID %s, want %s", fsys.ID, img.config)
+	}
+	if _, ok := fsys.Stat("/etc/os-release"); !ok {
+		t.Errorf("expected /etc/os-release in the filesystem")
+	}
+	inspect := fsys.Inspect()
+	wantDigest := registry.reference("@" + digest.FromBytes(img.index).String())
+	if len(inspect.RepoDigests) != 1 || inspect.RepoDigests[0] != wantDigest {
+		t.Errorf("got digests %v, want [%s]", inspect.RepoDigests, wantDigest)
+	}
+	if len(inspect.RepoTags) != 1 || inspect.RepoTags[0] != registry.reference(":1.0") {
+		t.Errorf("got tags %v", inspect.RepoTags)
+	}
+}
+
+func TestPullConfigOnly(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	img := newOCIImage(t)
+	registry := newTestRegistry(t, img)
+
+	fsys, err := Pull(context.Background(), registry.reference(":1.0"), PullOptions{ConfigOnly: true, Username: "user", Password: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fsys.Close()
+
+	if registry.fetched("/blobs/" + img.layer.String()) {
+		t.Errorf("the layer was downloaded with ConfigOnly")
+	}
+	if inspect := fsys.Inspect(); inspect.Config.User != "app" || inspect.Size != int64(len(img.blobs[img.layer])) {
+		t.Errorf("got user %q and size %d", inspect.Config.User, inspect.Size)
+	}
+}
+
+func TestPullUsesDockerConfigCredentials(t *testing.T) {
+	img := newOCIImage(t)
+	registry := newTestRegistry(t, img)
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	host := strings.TrimPrefix(registry.URL, "http://")
+	writeFile(t, dir+"/config.json", []byte(`{"auths":{"`+host+`":{"auth":"dXNlcjpzZWNyZXQ="}}}`))
+
+	fsys, err := Pull(context.Background(), registry.reference("@"+digest.FromBytes(img.index).String()), PullOptions{ConfigOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fsys.Close()
+}
+
+func TestPullErrors(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	img := newOCIImage(t)
+	registry := newTestRegistry(t, img)
+
+	if _, err := Pull(context.Background(), registry.reference(":1.0"), PullOptions{}); err == nil {
+		t.Errorf("expected an error without credentials")
+	}
+	if _, err := Pull(context.Background(), registry.reference(":2.0"), PullOptions{Username: "user", Password: "secret"}); err == nil {
+		t.Errorf("expected an error for a missing tag")
+	}
+	wrong := "@" + digest.FromString("other").String()
+	if _, err := Pull(context.Background(), registry.reference(wrong), PullOptions{Username: "user", Password: "secret"}); err == nil {
+		t.Errorf("expected an error for a missing digest")
+	}
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffLine struct {
+	// Kind is ' ' for a line kept, '-' for a line removed and '+' for a line added.
+	Kind byte
+	Text string
+}
+
+// UnifiedDiff returns the changes from oldText to newText in unified diff
+// format, or an empty string when they are equal.
+func UnifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	hunks := [][2]int{}
+	for i, line := range lines {
+		if line.Kind == ' ' {
+			continue
+		}
+		start, end := max(0, i-diffContext), min(len(lines), i+diffContext+1)
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+			continue
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:hunk[0]] {
+			if line.Kind != '+' {
+				oldStart++
+			}
+			if line.Kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			if line.Kind != '+' {
+				oldCount++
+			}
+			if line.Kind != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			b.WriteByte(line.Kind)
+			b.WriteString(line.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// diffLines aligns a and b on their longest common subsequence.
+func diffLines(a []string, b []string) []diffLine {
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// hunkRange formats the start,count pair of a hunk header. An empty range
+// starts at the line before it.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,54 @@
+package report
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "Equal",
+			old:      "FROM alpine\n",
+			new:      "FROM alpine\n",
+			expected: "",
+		},
+		{
+			name: "Replaced line",
+			old:  "FROM alpine\nRUN apk add curl\nCMD [\"sh\"]\n",
+			new:  "FROM alpine\nRUN apk add --no-cache curl\nCMD [\"sh\"]\n",
+			expected: "--- a/Dockerfile\n+++ b/Dockerfile\n@@ -1,3 +1,3 @@\n" +
+				" FROM alpine\n-RUN apk add curl\n+RUN apk add --no-cache curl\n CMD [\"sh\"]\n",
+		},
+		{
+			name:     "Appended line",
+			old:      "FROM alpine\n",
+			new:      "FROM alpine\nUSER app\n",
+			expected: "--- a/Dockerfile\n+++ b/Dockerfile\n@@ -1 +1,2 @@\n FROM alpine\n+USER app\n",
+		},
+		{
+			name:     "Into an empty file",
+			old:      "",
+			new:      "FROM alpine\n",
+			expected: "--- a/Dockerfile\n+++ b/Dockerfile\n@@ -0,0 +1 @@\n+FROM alpine\n",
+		},
+		{
+			name: "Separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			expected: "--- a/Dockerfile\n+++ b/Dockerfile\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := UnifiedDiff("a/Dockerfile", "b/Dockerfile", tt.old, tt.new); diff != tt.expected {
+				t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", diff, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func newJUnitSuite(name string, testCases []junitTestCase) junitTestSuites {
+	suite := junitTestSuite{Name: name, Tests: len(testCases), TestCases: testCases}
+	for _, testCase := range testCases {
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		if testCase.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+
+	return junitTestSuites{
+		Name:     "dockeryzer",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+}
+
+func dockerfileJUnit(report DockerfileReport) junitTestSuites {
+	testCases := []junitTestCase{}
+	for _, r := range report.Results {
+		testCases = append(testCases, cisTestCase(report.Dockerfile, r))
+	}
+
+	return newJUnitSuite("CIS Docker Benchmark: "+report.Dockerfile, testCases)
+}
+
+// cisTestCase turns a CIS result into a test case, file naming the
+// Dockerfile the locations refer to.
+func cisTestCase(file string, r security.CISResult) junitTestCase {
+	name := fmt.Sprintf("%s %s", r.RuleID, r.Description)
+	if r.Stage != "" {
+		name += fmt.Sprintf(" (stage %s)", r.Stage)
+	}
+	testCase := junitTestCase{Name: name, ClassName: "dockeryzer.cis"}
+
+	if r.Suppression != nil {
+		testCase.Skipped = &junitSkipped{Message: fmt.Sprintf("%s (suppressed by %s: %s)", r.Message, r.Suppression.Kind, r.Suppression.Reason)}
+	} else if !r.Passed {
+		details := []string{r.Message}
+		if r.Location != nil && r.Location.StartLine > 0 {
+			details = append(details, fmt.Sprintf("at %s:%d:%d", file, r.Location.StartLine, r.Location.StartColumn))
+		}
+		if r.Remediation != "" {
+			details = append(details, "fix: "+r.Remediation)
+		}
+		testCase.Failure = &junitFailure{
+			Message: r.Message,
+			Type:    r.Severity,
+			Text:    strings.Join(details, "\n"),
+		}
+	}
+	return testCase
+}
+
+func imageJUnit(report ImageReport) junitTestSuites {
+	testCases := []junitTestCase{}
+	for _, id := range imageCheckIDs() {
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("%s %s", id, utils.ImageChecks[id]),
+			ClassName: "dockeryzer.image",
+		}
+
+		messages := []string{}
+		for _, suggestion := range report.Image.Suggestions {
+			if suggestion.CheckID == id {
+				messages = append(messages, suggestion.Message)
+			}
+		}
+		if len(messages) > 0 {
+			testCase.Failure = &junitFailure{
+				Message: messages[0],
+				Type:    "SUGGESTION",
+				Text:    strings.Join(messages, "\n"),
+			}
+		}
+		testCases = append(testCases, testCase)
+	}
+	for _, r := range report.Results {
+		testCases = append(testCases, cisTestCase(report.Image.Name, r))
+	}
+
+	return newJUnitSuite("Image analysis: "+report.Image.Name, testCases)
+}
+
+func writeJUnit(w io.Writer, suites junitTestSuites) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
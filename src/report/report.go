@@ -0,0 +1,111 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+// SchemaVersion is bumped whenever a field of the JSON reports changes in a
+// backwards incompatible way.
+const SchemaVersion = 1
+
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatSARIF Format = "sarif"
+	FormatJUnit Format = "junit"
+)
+
+// ParseFormat validates the value of an --output flag.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatText, FormatJSON, FormatSARIF, FormatJUnit:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q (use text, json, sarif or junit)", value)
+	}
+}
+
+// DockerfileReport is the result of analyzing a Dockerfile.
+type DockerfileReport struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Dockerfile    string `json:"dockerfile"`
+	Target        string `json:"target,omitempty"`
+	Score         int    `json:"score"`
+	Grade         string `json:"grade"`
+	// Categories and Improvements explain the score, see security.ScoreCard.
+	Categories   []security.CategoryScore `json:"categories"`
+	Improvements []security.Improvement   `json:"improvements,omitempty"`
+	Results      []security.CISResult     `json:"results"`
+}
+
+// ImageReport is the result of analyzing an image.
+type ImageReport struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	Image         utils.ImageAnalysis `json:"image"`
+	// Results holds the findings of the image policy rules, if any.
+	Results []security.CISResult `json:"results,omitempty"`
+}
+
+func NewDockerfileReport(path string, target string, results []security.CISResult) DockerfileReport {
+	card := security.Evaluate(results)
+	return DockerfileReport{
+		SchemaVersion: SchemaVersion,
+		Dockerfile:    path,
+		Target:        target,
+		Score:         card.Score,
+		Grade:         card.Grade,
+		Categories:    card.Categories,
+		Improvements:  card.Improvements,
+		Results:       results,
+	}
+}
+
+func NewImageReport(analysis utils.ImageAnalysis, results []security.CISResult) ImageReport {
+	return ImageReport{
+		SchemaVersion: SchemaVersion,
+		Image:         analysis,
+		Results:       results,
+	}
+}
+
+// WriteDockerfileReport serializes report in one of the machine-readable formats.
+func WriteDockerfileReport(w io.Writer, format Format, report DockerfileReport) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, report)
+	case FormatSARIF:
+		return writeJSON(w, dockerfileSARIF(report))
+	case FormatJUnit:
+		return writeJUnit(w, dockerfileJUnit(report))
+	default:
+		return fmt.Errorf("format %q is not a machine-readable format", format)
+	}
+}
+
+// WriteImageReport serializes report in one of the machine-readable formats.
+func WriteImageReport(w io.Writer, format Format, report ImageReport) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, report)
+	case FormatSARIF:
+		return writeJSON(w, imageSARIF(report))
+	case FormatJUnit:
+		return writeJUnit(w, imageJUnit(report))
+	default:
+		return fmt.Errorf("format %q is not a machine-readable format", format)
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
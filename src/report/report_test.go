@@ -0,0 +1,156 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+var sampleResults = []security.CISResult{
+	{RuleID: "CIS-1.1", Description: "Use official base images", Passed: true},
+	{
+		RuleID:      "CIS-1.2",
+		Description: "Use explicit image tag (not latest)",
+		Passed:      false,
+		Severity:    "HIGH",
+		Message:     "Image alpine:latest uses latest or no tag",
+		Stage:       "runtime",
+		Location:    &security.Location{File: "Dockerfile", StartLine: 4, StartColumn: 6, EndLine: 4, EndColumn: 19},
+	},
+	{RuleID: "CIS-6.1", Description: "Expose minimum ports", Passed: false, Severity: "LOW", Message: "Multiple exposed ports detected"},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, value := range []string{"text", "JSON", "sarif", "junit"} {
+		if _, err := ParseFormat(value); err != nil {
+			t.Errorf("Expected %q to be accepted, got %v", value, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Errorf("Expected yaml to be rejected")
+	}
+}
+
+func TestWriteDockerfileReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDockerfileReport(&buf, FormatJSON, NewDockerfileReport("Dockerfile", "", sampleResults)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded DockerfileReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.SchemaVersion != SchemaVersion || len(decoded.Results) != 3 || decoded.Score != 33 {
+		t.Errorf("Unexpected report: %+v", decoded)
+	}
+	if decoded.Results[1].Location.StartLine != 4 {
+		t.Errorf("Expected location to round-trip, got %+v", decoded.Results[1].Location)
+	}
+}
+
+func TestWriteDockerfileReportSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDockerfileReport(&buf, FormatSARIF, NewDockerfileReport("Dockerfile", "", sampleResults)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	run := decoded.Runs[0]
+	if len(run.Tool.Driver.Rules) != 3 {
+		t.Errorf("Expected 3 rules, got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("Expected only failures as results, got %d", len(run.Results))
+	}
+	if run.Results[0].Level != "error" || run.Results[1].Level != "note" {
+		t.Errorf("Unexpected levels: %s, %s", run.Results[0].Level, run.Results[1].Level)
+	}
+	region := run.Results[0].Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine != 4 || region.EndColumn != 19 {
+		t.Errorf("Unexpected region: %+v", region)
+	}
+	if run.Results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("Findings without a line must not have a region")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDockerfileReport(&buf, FormatJUnit, NewDockerfileReport("Dockerfile", "", sampleResults)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if decoded.Tests != 3 || decoded.Failures != 2 {
+		t.Errorf("Expected 3 tests and 2 failures, got %d/%d", decoded.Tests, decoded.Failures)
+	}
+
+	buf.Reset()
+	imageReport := NewImageReport(utils.ImageAnalysis{
+		Name:        "app:1.0",
+		Suggestions: []utils.ImageSuggestion{{CheckID: utils.ImageSizeCheck, Message: "too big"}},
+	}, nil)
+	if err := WriteImageReport(&buf, FormatJUnit, imageReport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if decoded.Tests != 3 || decoded.Failures != 1 {
+		t.Errorf("Expected one test case per image check and 1 failure, got %d/%d", decoded.Tests, decoded.Failures)
+	}
+}
+
+func TestSuppressedResults(t *testing.T) {
+	results := []security.CISResult{
+		{
+			RuleID:      "CIS-6.1",
+			Description: "Expose minimum ports",
+			Severity:    "LOW",
+			Message:     "Multiple exposed ports detected",
+			Fingerprint: "0123456789abcdef",
+			Suppression: &security.Suppression{Kind: security.SuppressedInline, Reason: "metrics port"},
+		},
+	}
+	dockerfileReport := NewDockerfileReport("Dockerfile", "", results)
+	if dockerfileReport.Score != 100 {
+		t.Errorf("Suppressed findings must not lower the score, got %d", dockerfileReport.Score)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDockerfileReport(&buf, FormatSARIF, dockerfileReport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	result := decoded.Runs[0].Results[0]
+	if len(result.Suppressions) != 1 || result.Suppressions[0].Kind != "inSource" || result.PartialFingerprints["dockeryzer/v1"] != "0123456789abcdef" {
+		t.Errorf("Unexpected SARIF result: %+v", result)
+	}
+
+	buf.Reset()
+	if err := WriteDockerfileReport(&buf, FormatJUnit, dockerfileReport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if suites.Failures != 0 || suites.Suites[0].Skipped != 1 {
+		t.Errorf("Expected the suppressed finding to be skipped, got %+v", suites.Suites[0])
+	}
+}
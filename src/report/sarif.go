@@ -0,0 +1,238 @@
+package report
+
+import (
+	"path/filepath"
+
+	"github.com/jorgevvs2/dockeryzer/src/security"
+	"github.com/jorgevvs2/dockeryzer/src/utils"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+const informationURI = "https://github.com/jorgevvs2/dockeryzer"
+
+// Minimal subset of the SARIF 2.1.0 object model used by dockeryzer.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	Help                 *sarifMessage       `json:"help,omitempty"`
+	DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations,omitempty"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints,omitempty"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn,omitempty"`
+	EndLine     int           `json:"endLine,omitempty"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// sarifLevel maps a dockeryzer severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSuppressionKind maps a dockeryzer suppression kind to a SARIF one:
+// pragmas live in the Dockerfile, baselines outside of it.
+func sarifSuppressionKind(kind string) string {
+	if kind == security.SuppressedInline {
+		return "inSource"
+	}
+	return "external"
+}
+
+func newSARIFLog(rules []sarifRule, results []sarifResult) sarifLog {
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "dockeryzer",
+				InformationURI: informationURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+func dockerfileSARIF(report DockerfileReport) sarifLog {
+	rules := []sarifRule{}
+	ruleIndex := map[string]int{}
+	results := []sarifResult{}
+
+	for _, r := range report.Results {
+		results = appendCISResult(&rules, ruleIndex, results, r, dockerfileSARIFLocations(report.Dockerfile, r.Location))
+	}
+
+	return newSARIFLog(rules, results)
+}
+
+// appendCISResult registers the rule of r and, when r failed, appends it to
+// results with the given locations.
+func appendCISResult(rules *[]sarifRule, ruleIndex map[string]int, results []sarifResult, r security.CISResult, locations []sarifLocation) []sarifResult {
+	index, known := ruleIndex[r.RuleID]
+	if !known {
+		index = len(*rules)
+		ruleIndex[r.RuleID] = index
+		*rules = append(*rules, sarifRule{
+			ID:               r.RuleID,
+			ShortDescription: sarifMessage{Text: r.Description},
+		})
+	}
+	if r.Passed {
+		return results
+	}
+
+	rule := &(*rules)[index]
+	if rule.DefaultConfiguration == nil {
+		rule.DefaultConfiguration = &sarifConfiguration{Level: sarifLevel(r.Severity)}
+	}
+	if rule.Help == nil && r.Remediation != "" {
+		rule.Help = &sarifMessage{Text: r.Remediation}
+	}
+
+	result := sarifResult{
+		RuleID:    r.RuleID,
+		RuleIndex: index,
+		Level:     sarifLevel(r.Severity),
+		Message:   sarifMessage{Text: r.Message},
+		Locations: locations,
+	}
+	if r.Fingerprint != "" {
+		result.PartialFingerprints = map[string]string{"dockeryzer/v1": r.Fingerprint}
+	}
+	if r.Suppression != nil {
+		result.Suppressions = []sarifSuppression{{
+			Kind:          sarifSuppressionKind(r.Suppression.Kind),
+			Justification: r.Suppression.Reason,
+		}}
+	}
+	return append(results, result)
+}
+
+func dockerfileSARIFLocations(path string, location *security.Location) []sarifLocation {
+	physical := &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(path)}}
+
+	if location != nil && location.StartLine > 0 {
+		physical.Region = &sarifRegion{
+			StartLine:   location.StartLine,
+			StartColumn: location.StartColumn,
+			EndLine:     location.EndLine,
+			EndColumn:   location.EndColumn,
+		}
+		if location.Instruction != "" {
+			physical.Region.Snippet = &sarifMessage{Text: location.Instruction}
+		}
+	}
+
+	return []sarifLocation{{PhysicalLocation: physical}}
+}
+
+func imageSARIF(report ImageReport) sarifLog {
+	rules := []sarifRule{}
+	ruleIndex := map[string]int{}
+	for _, id := range imageCheckIDs() {
+		ruleIndex[id] = len(rules)
+		rules = append(rules, sarifRule{
+			ID:                   id,
+			ShortDescription:     sarifMessage{Text: utils.ImageChecks[id]},
+			DefaultConfiguration: &sarifConfiguration{Level: "warning"},
+		})
+	}
+
+	imageLocations := []sarifLocation{{
+		LogicalLocations: []sarifLogicalLocation{{Name: report.Image.Name, Kind: "image"}},
+	}}
+
+	results := []sarifResult{}
+	for _, suggestion := range report.Image.Suggestions {
+		results = append(results, sarifResult{
+			RuleID:    suggestion.CheckID,
+			RuleIndex: ruleIndex[suggestion.CheckID],
+			Level:     "warning",
+			Message:   sarifMessage{Text: suggestion.Message},
+			Locations: imageLocations,
+		})
+	}
+	for _, r := range report.Results {
+		results = appendCISResult(&rules, ruleIndex, results, r, imageLocations)
+	}
+
+	return newSARIFLog(rules, results)
+}
+
+// imageCheckIDs returns the image check IDs in a stable order.
+func imageCheckIDs() []string {
+	return []string{utils.ImageSizeCheck, utils.ImageLayersCheck, utils.ImageRuntimeCheck}
+}
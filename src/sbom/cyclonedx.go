@@ -0,0 +1,83 @@
+package sbom
+
+import "time"
+
+const cycloneDXVersion = "1.5"
+
+// Minimal subset of the CycloneDX 1.5 object model used by dockeryzer.
+
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License cycloneDXLicenseName `json:"license"`
+}
+
+type cycloneDXLicenseName struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newCycloneDX(sbom *SBOM) cycloneDXBOM {
+	image := cycloneDXComponent{BOMRef: sbom.ImageID, Type: "container", Name: sbom.Image, Version: sbom.Digest}
+	if sbom.OS != "" {
+		image.Properties = []cycloneDXProperty{{Name: "dockeryzer:os", Value: sbom.OS}}
+	}
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXVersion,
+		SerialNumber: "urn:uuid:" + sbom.Serial,
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: sbom.Created.Format(time.RFC3339),
+			Tools:     cycloneDXTools{Components: []cycloneDXComponent{{Type: "application", Name: toolName}}},
+			Component: image,
+		},
+		Components: []cycloneDXComponent{},
+	}
+	for _, p := range sbom.Packages {
+		component := cycloneDXComponent{
+			BOMRef:     p.PURL,
+			Type:       "library",
+			Name:       p.Name,
+			Version:    p.Version,
+			PURL:       p.PURL,
+			Properties: []cycloneDXProperty{{Name: "dockeryzer:location", Value: p.Location}},
+		}
+		for _, license := range p.Licenses {
+			component.Licenses = append(component.Licenses, cycloneDXLicense{cycloneDXLicenseName{license}})
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return bom
+}
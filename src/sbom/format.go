@@ -0,0 +1,44 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCycloneDX Format = "cyclonedx"
+	FormatSPDX      Format = "spdx"
+)
+
+// toolName identifies dockeryzer as the creator of the documents.
+const toolName = "dockeryzer"
+
+// ParseFormat validates the value of an --output flag.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatCycloneDX, FormatSPDX:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported SBOM format %q (use cyclonedx or spdx)", value)
+	}
+}
+
+// Write encodes the SBOM as a CycloneDX or SPDX JSON document.
+func Write(w io.Writer, format Format, sbom *SBOM) error {
+	var document any
+	switch format {
+	case FormatCycloneDX:
+		document = newCycloneDX(sbom)
+	case FormatSPDX:
+		document = newSPDX(sbom)
+	default:
+		return fmt.Errorf("unsupported SBOM format %q", format)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,110 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testSBOM() *SBOM {
+	return &SBOM{
+		Image:   "ghcr.io/org/app:1.0",
+		ImageID: "sha256:abc",
+		Digest:  "sha256:def",
+		OS:      "Alpine Linux v3.19",
+		Created: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Serial:  "6f1c2a9e-0d7b-4c3e-9a51-2b8f7e4d1c60",
+		Packages: []Package{
+			{Name: "musl", Version: "1.2.4-r4", Type: TypeAPK, PURL: "pkg:apk/alpine/musl@1.2.4-r4?arch=x86_64", Licenses: []string{"MIT"}, Location: "/lib/apk/db/installed"},
+			{Name: "six", Version: "1.16.0", Type: TypePyPI, PURL: "pkg:pypi/six@1.16.0", Licenses: []string{"MIT License"}, Location: "/usr/lib/python3/dist-packages/six-1.16.0.egg-info"},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for value, want := range map[string]Format{"cyclonedx": FormatCycloneDX, "SPDX": FormatSPDX} {
+		if got, err := ParseFormat(value); err != nil || got != want {
+			t.Errorf("%s: got %q, %v", value, got, err)
+		}
+	}
+	if _, err := ParseFormat("syft"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCycloneDX, testSBOM()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var bom cycloneDXBOM
+	if err := json.Unmarshal(buf.Bytes(), &bom); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" || bom.SerialNumber != "urn:uuid:6f1c2a9e-0d7b-4c3e-9a51-2b8f7e4d1c60" {
+		t.Errorf("unexpected header %+v", bom)
+	}
+	if bom.Metadata.Timestamp != "2024-05-01T10:00:00Z" || bom.Metadata.Component.Type != "container" || bom.Metadata.Component.Name != "ghcr.io/org/app:1.0" {
+		t.Errorf("unexpected metadata %+v", bom.Metadata)
+	}
+	if len(bom.Components) != 2 {
+		t.Fatalf("got %d components, want 2", len(bom.Components))
+	}
+	musl := bom.Components[0]
+	if musl.PURL != "pkg:apk/alpine/musl@1.2.4-r4?arch=x86_64" || musl.BOMRef != musl.PURL || musl.Type != "library" {
+		t.Errorf("unexpected component %+v", musl)
+	}
+	if len(musl.Licenses) != 1 || musl.Licenses[0].License.Name != "MIT" {
+		t.Errorf("unexpected licenses %+v", musl.Licenses)
+	}
+}
+
+func TestWriteSPDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatSPDX, testSBOM()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if doc.SPDXVersion != "SPDX-2.3" || doc.DataLicense != "CC0-1.0" || doc.CreationInfo.Created != "2024-05-01T10:00:00Z" {
+		t.Errorf("unexpected header %+v", doc)
+	}
+	if doc.DocumentNamespace != "https://github.com/jorgevvs2/dockeryzer/spdx/ghcr.io-org-app-1.0-6f1c2a9e-0d7b-4c3e-9a51-2b8f7e4d1c60" {
+		t.Errorf("got namespace %s", doc.DocumentNamespace)
+	}
+	if len(doc.Packages) != 3 || len(doc.Relationships) != 3 {
+		t.Fatalf("got %d packages and %d relationships, want 3 and 3", len(doc.Packages), len(doc.Relationships))
+	}
+	musl, six := doc.Packages[1], doc.Packages[2]
+	if musl.LicenseDeclared != "MIT" || six.LicenseDeclared != "NOASSERTION" {
+		t.Errorf("got licenses %q and %q", musl.LicenseDeclared, six.LicenseDeclared)
+	}
+	if musl.ExternalRefs[0].ReferenceLocator != "pkg:apk/alpine/musl@1.2.4-r4?arch=x86_64" {
+		t.Errorf("unexpected external refs %+v", musl.ExternalRefs)
+	}
+	if rel := doc.Relationships[1]; rel.SPDXElementID != "SPDXRef-Image" || rel.RelationshipType != "CONTAINS" || rel.RelatedSPDXElement != musl.SPDXID {
+		t.Errorf("unexpected relationship %+v", rel)
+	}
+}
+
+func TestSPDXLicense(t *testing.T) {
+	tests := []struct {
+		licenses []string
+		expected string
+	}{
+		{nil, "NOASSERTION"},
+		{[]string{"Apache-2.0"}, "Apache-2.0"},
+		{[]string{"MIT", "BSD-3-Clause OR GPL-2.0-or-later"}, "MIT AND (BSD-3-Clause OR GPL-2.0-or-later)"},
+		{[]string{"The MIT License"}, "NOASSERTION"},
+	}
+	for _, tt := range tests {
+		if got := spdxLicense(tt.licenses); got != tt.expected {
+			t.Errorf("%v: got %q, want %q", tt.licenses, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"io"
+	"net/textproto"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// maxManifestSize bounds the package manifests and lock files read into
+// memory.
+const maxManifestSize = 32 << 20
+
+// maxBinarySize bounds the executables searched for Go build information.
+const maxBinarySize = 256 << 20
+
+var nodePackagePattern = regexp.MustCompile(`/node_modules/(@[^/]+/)?[^/@.][^/]*/package\.json$`)
+var pythonMetadataPattern = regexp.MustCompile(`/(site|dist)-packages/[^/]+\.(dist-info/METADATA|egg-info/PKG-INFO|egg-info)$`)
+var gemSpecPattern = regexp.MustCompile(`^ {4}([^ ]+) \(([^)]+)\)$`)
+var tomlStringPattern = regexp.MustCompile(`^(\w+)\s*=\s*"([^"]*)"`)
+
+var elfMagic = []byte("\x7fELF")
+
+func isNodePackage(p string) bool { return nodePackagePattern.MatchString(p) }
+
+func isPackageLock(p string) bool {
+	return path.Base(p) == "package-lock.json" && !strings.Contains(p, "/node_modules/")
+}
+
+func isPythonMetadata(p string) bool { return pythonMetadataPattern.MatchString(p) }
+
+func isCargoLock(p string) bool { return path.Base(p) == "Cargo.lock" }
+
+func isGemfileLock(p string) bool { return path.Base(p) == "Gemfile.lock" }
+
+func isExecutable(file *imagefs.File) bool {
+	return file.Mode&0o111 != 0 && file.Size > int64(len(elfMagic)) && file.Size <= maxBinarySize
+}
+
+// npmLicense is the license of a package.json, either an SPDX expression or
+// the legacy {"type": ...} object.
+type npmLicense string
+
+func (l *npmLicense) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		*l = npmLicense(v)
+	case map[string]any:
+		if kind, ok := v["type"].(string); ok {
+			*l = npmLicense(kind)
+		}
+	}
+	return nil
+}
+
+// npmPackage holds the fields read from package.json and package-lock.json
+// entries.
+type npmPackage struct {
+	Name         string                `json:"name"`
+	Version      string                `json:"version"`
+	License      npmLicense            `json:"license"`
+	Dev          bool                  `json:"dev"`
+	Link         bool                  `json:"link"`
+	Packages     map[string]npmPackage `json:"packages"`
+	Dependencies map[string]npmPackage `json:"dependencies"`
+}
+
+func (c *catalog) addNPM(name, version, location string, license npmLicense) {
+	namespace, base := "", name
+	if strings.HasPrefix(name, "@") {
+		namespace, base, _ = strings.Cut(name, "/")
+	}
+	c.add(languagePackage(TypeNPM, namespace, base, version, location, licenseList(string(license))))
+}
+
+// readNodePackage reads the package.json of an installed node module.
+func readNodePackage(c *catalog, file *imagefs.File, content io.Reader) error {
+	var pkg npmPackage
+	if err := json.NewDecoder(io.LimitReader(content, maxManifestSize)).Decode(&pkg); err != nil {
+		return err
+	}
+	c.addNPM(pkg.Name, pkg.Version, file.Path, pkg.License)
+	return nil
+}
+
+// readPackageLock reads the packages of a package-lock.json, from the
+// packages map of lockfile versions 2 and 3 or the dependencies tree of
+// version 1. Development dependencies are left out.
+func readPackageLock(c *catalog, file *imagefs.File, content io.Reader) error {
+	var lock npmPackage
+	if err := json.NewDecoder(io.LimitReader(content, maxManifestSize)).Decode(&lock); err != nil {
+		return err
+	}
+	if len(lock.Packages) > 0 {
+		for key, pkg := range lock.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 || pkg.Dev || pkg.Link {
+				continue
+			}
+			name := pkg.Name
+			if name == "" {
+				name = key[i+len("node_modules/"):]
+			}
+			c.addNPM(name, pkg.Version, file.Path, pkg.License)
+		}
+		return nil
+	}
+
+	var walk func(dependencies map[string]npmPackage)
+	walk = func(dependencies map[string]npmPackage) {
+		for name, pkg := range dependencies {
+			if !pkg.Dev {
+				c.addNPM(name, pkg.Version, file.Path, "")
+			}
+			walk(pkg.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+	return nil
+}
+
+// readPythonMetadata reads the metadata of an installed distribution, from
+// its dist-info METADATA or egg-info PKG-INFO.
+func readPythonMetadata(c *catalog, file *imagefs.File, content io.Reader) error {
+	header, err := textproto.NewReader(bufio.NewReader(io.LimitReader(content, maxManifestSize))).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return err
+	}
+	name := header.Get("Name")
+	normalized := strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+	p := languagePackage(TypePyPI, "", normalized, header.Get("Version"), file.Path, pythonLicenses(header))
+	p.Name = name
+	c.add(p)
+	return nil
+}
+
+// pythonLicenses returns the license expression of a distribution, or its
+// license field when it is a short name, or its license classifiers.
+func pythonLicenses(header textproto.MIMEHeader) []string {
+	if expression := header.Get("License-Expression"); expression != "" {
+		return []string{expression}
+	}
+	if license := strings.TrimSpace(header.Get("License")); len(license) <= 64 && !strings.Contains(license, "\n") {
+		if licenses := licenseList(license); licenses != nil {
+			return licenses
+		}
+	}
+	licenses := []string{}
+	for _, classifier := range header.Values("Classifier") {
+		if strings.HasPrefix(classifier, "License ::") {
+			parts := strings.Split(classifier, " :: ")
+			licenses = append(licenses, parts[len(parts)-1])
+		}
+	}
+	if len(licenses) == 0 {
+		return nil
+	}
+	return licenses
+}
+
+// readGoBinary reads the module versions Go embeds in the binaries it
+// builds. Other executables are skipped.
+func readGoBinary(c *catalog, file *imagefs.File, content io.Reader) error {
+	magic := make([]byte, len(elfMagic))
+	if _, err := io.ReadFull(content, magic); err != nil || !bytes.Equal(magic, elfMagic) {
+		return err
+	}
+	rest, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	info, err := buildinfo.Read(bytes.NewReader(append(magic, rest...)))
+	if err != nil {
+		// Not a Go binary.
+		return nil
+	}
+
+	addModule := func(modulePath, version string) {
+		if version == "" || version == "(devel)" {
+			return
+		}
+		namespace, name := path.Split(modulePath)
+		c.add(languagePackage(TypeGo, strings.TrimSuffix(namespace, "/"), name, version, file.Path, nil))
+	}
+	addModule("stdlib", strings.TrimPrefix(info.GoVersion, "go"))
+	addModule(info.Main.Path, info.Main.Version)
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		addModule(dep.Path, dep.Version)
+	}
+	return nil
+}
+
+// readCargoLock reads the [[package]] tables of a Cargo.lock.
+func readCargoLock(c *catalog, file *imagefs.File, content io.Reader) error {
+	scanner := bufio.NewScanner(io.LimitReader(content, maxManifestSize))
+	name, version := "", ""
+	flush := func() {
+		c.add(languagePackage(TypeCargo, "", name, version, file.Path, nil))
+		name, version = "", ""
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			flush()
+			continue
+		}
+		if match := tomlStringPattern.FindStringSubmatch(line); match != nil {
+			switch match[1] {
+			case "name":
+				name = match[2]
+			case "version":
+				version = match[2]
+			}
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+// readGemfileLock reads the gems listed under the specs of a Gemfile.lock,
+// which are indented by four spaces; their dependencies are indented by six.
+func readGemfileLock(c *catalog, file *imagefs.File, content io.Reader) error {
+	scanner := bufio.NewScanner(io.LimitReader(content, maxManifestSize))
+	inSpecs := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "  specs:":
+			inSpecs = true
+		case !strings.HasPrefix(line, "    "):
+			inSpecs = false
+		case inSpecs:
+			if match := gemSpecPattern.FindStringSubmatch(line); match != nil {
+				c.add(languagePackage(TypeGem, "", match[1], match[2], file.Path, nil))
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,226 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// maxDatabaseSize bounds the package databases read into memory.
+const maxDatabaseSize = 512 << 20
+
+// rpmDatabases are the rpm databases by file name: SQLite since RHEL 9 and
+// Fedora 33, Berkeley DB before.
+var rpmDatabases = map[string]func(data []byte) ([][]byte, error){
+	"rpmdb.sqlite": sqliteBlobs,
+	"Packages":     berkeleyDBBlobs,
+}
+
+func isAPKDatabase(p string) bool { return p == "/lib/apk/db/installed" }
+
+func isDpkgStatus(p string) bool {
+	return p == "/var/lib/dpkg/status" || path.Dir(p) == "/var/lib/dpkg/status.d" && !strings.Contains(path.Base(p), ".")
+}
+
+func isRPMDatabase(p string) bool {
+	dir, name := path.Split(p)
+	return (dir == "/var/lib/rpm/" || dir == "/usr/lib/sysimage/rpm/") && rpmDatabases[name] != nil
+}
+
+// readParagraphs calls fn with the fields of each blank-line separated
+// paragraph of a database like the dpkg status file. Fields are keyed by
+// their name and continuation lines are dropped.
+func readParagraphs(content io.Reader, separator string, fn func(fields map[string]string)) error {
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	fields := map[string]string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				fn(fields)
+				fields = map[string]string{}
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if key, value, found := strings.Cut(line, separator); found {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) > 0 {
+		fn(fields)
+	}
+	return scanner.Err()
+}
+
+// readAPKDatabase reads the installed database of apk, where P is the
+// package, V the version, A the architecture, L the license and o the
+// origin package.
+func readAPKDatabase(c *catalog, file *imagefs.File, content io.Reader) error {
+	return readParagraphs(content, ":", func(fields map[string]string) {
+		p := c.osPackage(TypeAPK, fields["P"], fields["V"], fields["A"], file.Path, licenseList(fields["L"]))
+		p.Source = fields["o"]
+		c.add(p)
+	})
+}
+
+// readDpkgStatus reads the status file of dpkg, or one of the per-package
+// files distroless images keep in status.d.
+func readDpkgStatus(c *catalog, file *imagefs.File, content io.Reader) error {
+	return readParagraphs(content, ":", func(fields map[string]string) {
+		if status := fields["Status"]; status != "" && !strings.HasSuffix(status, " installed") {
+			return
+		}
+		p := c.osPackage(TypeDeb, fields["Package"], fields["Version"], fields["Architecture"], file.Path, nil)
+		// Source may carry the source version in parentheses when it differs.
+		p.Source, _, _ = strings.Cut(fields["Source"], " ")
+		c.add(p)
+	})
+}
+
+// readRPMDatabase reads the package headers stored in an rpm database.
+func readRPMDatabase(c *catalog, file *imagefs.File, content io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(content, maxDatabaseSize))
+	if err != nil {
+		return err
+	}
+	blobs, err := rpmDatabases[path.Base(file.Path)](data)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		header, err := parseRPMHeader(blob)
+		if err != nil || header.name == "" || header.name == "gpg-pubkey" {
+			continue
+		}
+		version := header.version + "-" + header.release
+		var p Package
+		if header.epoch == 0 {
+			p = c.osPackage(TypeRPM, header.name, version, header.arch, file.Path, licenseList(header.license))
+		} else {
+			epoch := strconv.Itoa(header.epoch)
+			p = c.osPackage(TypeRPM, header.name, version, header.arch, file.Path, licenseList(header.license), [2]string{"epoch", epoch})
+			p.Version = epoch + ":" + version
+		}
+		p.Source = rpmSourceName(header.sourceRPM)
+		c.add(p)
+	}
+	return nil
+}
+
+// Tags of the rpm header entries read into an rpmHeader.
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagLicense = 1014
+	rpmTagArch    = 1022
+	rpmTagSource  = 1044
+)
+
+// Types of rpm header entries.
+const (
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+)
+
+// rpmHeader holds the fields of an rpm package header.
+type rpmHeader struct {
+	name, version, release, arch, license string
+	// sourceRPM is the file name of the source package, e.g.
+	// openssl-3.0.7-24.el9.src.rpm.
+	sourceRPM string
+	epoch     int
+}
+
+// parseRPMHeader decodes a header blob as stored in the rpm database: the
+// entry count, the data length, the index entries and the data.
+func parseRPMHeader(blob []byte) (rpmHeader, error) {
+	var header rpmHeader
+	if len(blob) < 8 {
+		return header, errors.New("short rpm header")
+	}
+	entries, length := int(binary.BigEndian.Uint32(blob[0:])), int(binary.BigEndian.Uint32(blob[4:]))
+	dataStart := 8 + 16*entries
+	if entries <= 0 || entries > 1<<16 || length < 0 || dataStart+length > len(blob) {
+		return header, errors.New("malformed rpm header")
+	}
+	data := blob[dataStart : dataStart+length]
+
+	for i := 0; i < entries; i++ {
+		entry := blob[8+16*i:]
+		tag, kind, offset := binary.BigEndian.Uint32(entry[0:]), binary.BigEndian.Uint32(entry[4:]), int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || offset >= len(data) {
+			continue
+		}
+		var value string
+		switch kind {
+		case rpmTypeString, rpmTypeStringArray, rpmTypeI18NString:
+			raw := data[offset:]
+			if end := bytes.IndexByte(raw, 0); end >= 0 {
+				raw = raw[:end]
+			}
+			value = string(raw)
+		case rpmTypeInt32:
+			if tag == rpmTagEpoch && offset+4 <= len(data) {
+				header.epoch = int(binary.BigEndian.Uint32(data[offset:]))
+			}
+			continue
+		default:
+			continue
+		}
+		switch tag {
+		case rpmTagName:
+			header.name = value
+		case rpmTagVersion:
+			header.version = value
+		case rpmTagRelease:
+			header.release = value
+		case rpmTagLicense:
+			header.license = value
+		case rpmTagArch:
+			header.arch = value
+		case rpmTagSource:
+			header.sourceRPM = value
+		}
+	}
+	return header, nil
+}
+
+// rpmSourceName returns the package name of a source rpm file name, which
+// ends with the version and release.
+func rpmSourceName(file string) string {
+	name := strings.TrimSuffix(file, ".src.rpm")
+	for range 2 {
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			name = name[:i]
+		}
+	}
+	if name == file {
+		return ""
+	}
+	return name
+}
+
+// licenseList returns the license of a package as a list, empty when it is
+// unknown.
+func licenseList(license string) []string {
+	license = strings.TrimSpace(license)
+	if license == "" || strings.EqualFold(license, "unknown") {
+		return nil
+	}
+	return []string{license}
+}
@@ -0,0 +1,324 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The rpm database keeps every package header as a blob, in SQLite since
+// rpm 4.16 and in a Berkeley DB hash before. Only the parts of both file
+// formats rpm uses are read here.
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// SQLite b-tree page types.
+const (
+	sqliteInteriorTable = 0x05
+	sqliteLeafTable     = 0x0d
+)
+
+// maxTreeDepth bounds the b-tree levels followed in a damaged file.
+const maxTreeDepth = 32
+
+// sqliteFile is a SQLite database read into memory.
+type sqliteFile struct {
+	data     []byte
+	pageSize int
+	usable   int
+}
+
+// sqliteBlobs returns the headers stored in the Packages table of an rpm
+// SQLite database.
+func sqliteBlobs(data []byte) ([][]byte, error) {
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return nil, errors.New("not a SQLite database")
+	}
+	f := &sqliteFile{data: data, pageSize: int(binary.BigEndian.Uint16(data[16:]))}
+	if f.pageSize == 1 {
+		f.pageSize = 65536
+	}
+	f.usable = f.pageSize - int(data[20])
+	if f.pageSize < 512 || f.usable < 480 {
+		return nil, fmt.Errorf("invalid SQLite page size %d", f.pageSize)
+	}
+
+	// The schema table on page 1 holds the root page of every table.
+	root := 0
+	err := f.rows(1, 0, func(record []any) {
+		if len(record) >= 4 && record[0] == "table" && record[1] == "Packages" {
+			if page, ok := record[3].(int64); ok {
+				root = int(page)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, errors.New("no Packages table in the rpm database")
+	}
+
+	blobs := [][]byte{}
+	err = f.rows(root, 0, func(record []any) {
+		for _, value := range record {
+			if blob, ok := value.([]byte); ok {
+				blobs = append(blobs, blob)
+			}
+		}
+	})
+	return blobs, err
+}
+
+// page returns page n, counting from 1.
+func (f *sqliteFile) page(n int) ([]byte, error) {
+	start := (n - 1) * f.pageSize
+	if n < 1 || start+f.pageSize > len(f.data) {
+		return nil, fmt.Errorf("SQLite page %d is out of range", n)
+	}
+	return f.data[start : start+f.pageSize], nil
+}
+
+// rows calls fn with the records of the table b-tree rooted at page n.
+func (f *sqliteFile) rows(n, depth int, fn func(record []any)) error {
+	if depth > maxTreeDepth {
+		return errors.New("SQLite b-tree is too deep")
+	}
+	page, err := f.page(n)
+	if err != nil {
+		return err
+	}
+	header := 0
+	if n == 1 {
+		header = 100
+	}
+	kind := page[header]
+	cells := int(binary.BigEndian.Uint16(page[header+3:]))
+	pointers := header + 8
+	if kind == sqliteInteriorTable {
+		pointers = header + 12
+	}
+	if pointers+2*cells > len(page) {
+		return fmt.Errorf("SQLite page %d is malformed", n)
+	}
+
+	for i := 0; i < cells; i++ {
+		cell := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+		if cell+4 > len(page) {
+			return fmt.Errorf("SQLite page %d is malformed", n)
+		}
+		switch kind {
+		case sqliteInteriorTable:
+			if err := f.rows(int(binary.BigEndian.Uint32(page[cell:])), depth+1, fn); err != nil {
+				return err
+			}
+		case sqliteLeafTable:
+			payload, err := f.payload(page, cell)
+			if err != nil {
+				return err
+			}
+			record, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+			fn(record)
+		default:
+			return fmt.Errorf("SQLite page %d is not a table page", n)
+		}
+	}
+	if kind == sqliteInteriorTable {
+		return f.rows(int(binary.BigEndian.Uint32(page[header+8:])), depth+1, fn)
+	}
+	return nil
+}
+
+// payload returns the payload of a leaf table cell, following its chain of
+// overflow pages.
+func (f *sqliteFile) payload(page []byte, cell int) ([]byte, error) {
+	size, n := sqliteVarint(page[cell:])
+	cell += n
+	_, n = sqliteVarint(page[cell:])
+	cell += n
+
+	local := f.localPayload(int(size))
+	if size > uint64(len(f.data)) || cell+local > len(page) {
+		return nil, errors.New("SQLite cell is malformed")
+	}
+	payload := append([]byte{}, page[cell:cell+local]...)
+	if local == int(size) {
+		return payload, nil
+	}
+	if cell+local+4 > len(page) {
+		return nil, errors.New("SQLite cell is malformed")
+	}
+	next := int(binary.BigEndian.Uint32(page[cell+local:]))
+	for pages := 0; len(payload) < int(size); pages++ {
+		if next == 0 || pages > len(f.data)/f.pageSize {
+			return nil, errors.New("SQLite overflow chain is broken")
+		}
+		overflow, err := f.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := overflow[4:f.usable]
+		if remaining := int(size) - len(payload); remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+		next = int(binary.BigEndian.Uint32(overflow))
+	}
+	return payload, nil
+}
+
+// localPayload returns how many bytes of a payload of size bytes are stored
+// in the cell itself, the rest going to overflow pages.
+func (f *sqliteFile) localPayload(size int) int {
+	maxLocal := f.usable - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (f.usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(f.usable-4)
+	if local <= maxLocal {
+		return local
+	}
+	return minLocal
+}
+
+// sqliteVarint decodes a SQLite variable-length integer, returning it and
+// the number of bytes read.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// sqliteRecord decodes a record into nil, int64, string and []byte values.
+// Floating point values are returned as nil.
+func sqliteRecord(payload []byte) ([]any, error) {
+	headerSize, n := sqliteVarint(payload)
+	if headerSize > uint64(len(payload)) {
+		return nil, errors.New("SQLite record is malformed")
+	}
+	serialTypes := []uint64{}
+	for pos := n; pos < int(headerSize); {
+		serialType, n := sqliteVarint(payload[pos:headerSize])
+		serialTypes = append(serialTypes, serialType)
+		pos += n
+	}
+
+	record := []any{}
+	body := payload[headerSize:]
+	for _, serialType := range serialTypes {
+		size := 0
+		switch {
+		case serialType >= 12:
+			size = int((serialType - 12) / 2)
+		case serialType >= 1 && serialType <= 7:
+			size = []int{0, 1, 2, 3, 4, 6, 8, 8}[serialType]
+		}
+		if size > len(body) {
+			return nil, errors.New("SQLite record is malformed")
+		}
+		value := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0 || serialType == 7:
+			record = append(record, nil)
+		case serialType <= 6:
+			var v int64
+			for i, b := range value {
+				if i == 0 {
+					v = int64(int8(b))
+				} else {
+					v = v<<8 | int64(b)
+				}
+			}
+			record = append(record, v)
+		case serialType == 8 || serialType == 9:
+			record = append(record, int64(serialType-8))
+		case serialType >= 12 && serialType%2 == 0:
+			record = append(record, value)
+		case serialType >= 13:
+			record = append(record, string(value))
+		default:
+			return nil, fmt.Errorf("SQLite serial type %d is reserved", serialType)
+		}
+	}
+	return record, nil
+}
+
+// Berkeley DB constants of hash databases.
+const (
+	bdbHashMagic        = 0x061561
+	bdbPageHeaderSize   = 26
+	bdbHashUnsortedPage = 2
+	bdbOverflowPage     = 7
+	bdbHashPage         = 13
+	bdbOffPageItem      = 3
+)
+
+// berkeleyDBBlobs returns the values of an rpm Packages database in the
+// Berkeley DB hash format. rpm headers are large enough to always live on
+// overflow pages, so the values stored inline are skipped.
+func berkeleyDBBlobs(data []byte) ([][]byte, error) {
+	if len(data) < 512 {
+		return nil, errors.New("not a Berkeley DB database")
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(data[12:]) == bdbHashMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data[12:]) == bdbHashMagic:
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("not a Berkeley DB hash database")
+	}
+	pageSize := int(order.Uint32(data[20:]))
+	if pageSize < 512 || pageSize > 65536 {
+		return nil, fmt.Errorf("invalid Berkeley DB page size %d", pageSize)
+	}
+	pages := len(data) / pageSize
+	page := func(n int) []byte { return data[n*pageSize : (n+1)*pageSize] }
+
+	blobs := [][]byte{}
+	for n := 1; n < pages; n++ {
+		p := page(n)
+		if p[25] != bdbHashPage && p[25] != bdbHashUnsortedPage {
+			continue
+		}
+		entries := int(order.Uint16(p[20:]))
+		// Items come in key and value pairs; the values are the odd ones.
+		for i := 1; i < entries && bdbPageHeaderSize+2*i+2 <= pageSize; i += 2 {
+			item := int(order.Uint16(p[bdbPageHeaderSize+2*i:]))
+			if item+12 > pageSize || p[item] != bdbOffPageItem {
+				continue
+			}
+			next, length := int(order.Uint32(p[item+4:])), int(order.Uint32(p[item+8:]))
+			blob := make([]byte, 0, min(length, len(data)))
+			for chain := 0; next != 0 && len(blob) < length; chain++ {
+				if next >= pages || chain >= pages {
+					return nil, errors.New("Berkeley DB overflow chain is broken")
+				}
+				overflow := page(next)
+				used := int(order.Uint16(overflow[22:]))
+				if overflow[25] != bdbOverflowPage || bdbPageHeaderSize+used > pageSize {
+					return nil, errors.New("Berkeley DB overflow page is malformed")
+				}
+				blob = append(blob, overflow[bdbPageHeaderSize:bdbPageHeaderSize+used]...)
+				next = int(order.Uint32(overflow[16:]))
+			}
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs, nil
+}
@@ -0,0 +1,239 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// rpmHeaderBlob encodes string tags as an rpm database header blob.
+func rpmHeaderBlob(tags map[uint32]string, epoch uint32) []byte {
+	index, data := []byte{}, []byte{}
+	for tag, value := range tags {
+		index = binary.BigEndian.AppendUint32(index, tag)
+		index = binary.BigEndian.AppendUint32(index, rpmTypeString)
+		index = binary.BigEndian.AppendUint32(index, uint32(len(data)))
+		index = binary.BigEndian.AppendUint32(index, 1)
+		data = append(data, value+"\x00"...)
+	}
+	if epoch > 0 {
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+		index = binary.BigEndian.AppendUint32(index, rpmTagEpoch)
+		index = binary.BigEndian.AppendUint32(index, rpmTypeInt32)
+		index = binary.BigEndian.AppendUint32(index, uint32(len(data)))
+		index = binary.BigEndian.AppendUint32(index, 1)
+		data = binary.BigEndian.AppendUint32(data, epoch)
+	}
+	blob := binary.BigEndian.AppendUint32(nil, uint32(len(index)/16))
+	blob = binary.BigEndian.AppendUint32(blob, uint32(len(data)))
+	return append(append(blob, index...), data...)
+}
+
+func rpmBlobs() [][]byte {
+	// The padding tag pushes the headers onto overflow pages.
+	padding := strings.Repeat("x", 1500)
+	return [][]byte{
+		rpmHeaderBlob(map[uint32]string{rpmTagName: "bash", rpmTagVersion: "5.1.8", rpmTagRelease: "9.el9", rpmTagArch: "x86_64", rpmTagLicense: "GPLv3+", 5000: padding}, 0),
+		rpmHeaderBlob(map[uint32]string{rpmTagName: "openssl-libs", rpmTagVersion: "3.0.7", rpmTagRelease: "27.el9", rpmTagArch: "x86_64", rpmTagSource: "openssl-3.0.7-27.el9.src.rpm", 5000: padding}, 1),
+		rpmHeaderBlob(map[uint32]string{rpmTagName: "gpg-pubkey", rpmTagVersion: "fd431d51", rpmTagRelease: "4ae0493b", 5000: padding}, 0),
+	}
+}
+
+func TestParseRPMHeader(t *testing.T) {
+	header, err := parseRPMHeader(rpmBlobs()[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := rpmHeader{name: "openssl-libs", version: "3.0.7", release: "27.el9", arch: "x86_64", sourceRPM: "openssl-3.0.7-27.el9.src.rpm", epoch: 1}
+	if header != want {
+		t.Errorf("got %+v, want %+v", header, want)
+	}
+
+	for _, blob := range [][]byte{nil, {0, 0, 0, 1}, {0, 0, 0, 9, 0, 0, 0, 1}} {
+		if _, err := parseRPMHeader(blob); err == nil {
+			t.Errorf("expected an error for %v", blob)
+		}
+	}
+}
+
+const testPageSize = 512
+
+// sqliteRecordBytes encodes a record of text, int64 (one byte), blob and
+// nil values.
+func sqliteRecordBytes(values ...any) []byte {
+	header, body := []byte{}, []byte{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = appendSQLiteVarint(header, 0)
+		case int64:
+			header = appendSQLiteVarint(header, 1)
+			body = append(body, byte(v))
+		case string:
+			header = appendSQLiteVarint(header, uint64(13+2*len(v)))
+			body = append(body, v...)
+		case []byte:
+			header = appendSQLiteVarint(header, uint64(12+2*len(v)))
+			body = append(body, v...)
+		}
+	}
+	// Headers of the test records are shorter than 127 bytes.
+	return append(append([]byte{byte(len(header) + 1)}, header...), body...)
+}
+
+func appendSQLiteVarint(b []byte, v uint64) []byte {
+	groups := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		groups = append([]byte{byte(v&0x7f) | 0x80}, groups...)
+	}
+	return append(b, groups...)
+}
+
+// testSQLite builds a SQLite file whose Packages table is an interior page
+// over one leaf page per blob, with the blobs spilling onto overflow pages.
+type testSQLite struct {
+	pages [][]byte
+}
+
+func (db *testSQLite) newPage() (int, []byte) {
+	page := make([]byte, testPageSize)
+	db.pages = append(db.pages, page)
+	return len(db.pages), page
+}
+
+// leaf writes a one-cell leaf table page holding payload.
+func (db *testSQLite) leaf(page []byte, header, rowid int, payload []byte) {
+	f := &sqliteFile{pageSize: testPageSize, usable: testPageSize}
+	local := f.localPayload(len(payload))
+	cell := appendSQLiteVarint(nil, uint64(len(payload)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	cell = append(cell, payload[:local]...)
+	if local < len(payload) {
+		next, _ := db.newPage()
+		cell = binary.BigEndian.AppendUint32(cell, uint32(next))
+		for rest := payload[local:]; len(rest) > 0; {
+			overflow := db.pages[next-1]
+			chunk := rest[:min(len(rest), testPageSize-4)]
+			rest = rest[len(chunk):]
+			copy(overflow[4:], chunk)
+			if len(rest) > 0 {
+				next, _ = db.newPage()
+				binary.BigEndian.PutUint32(overflow, uint32(next))
+			}
+		}
+	}
+	start := testPageSize - len(cell)
+	copy(page[start:], cell)
+	page[header] = sqliteLeafTable
+	binary.BigEndian.PutUint16(page[header+3:], 1)
+	binary.BigEndian.PutUint16(page[header+5:], uint16(start))
+	binary.BigEndian.PutUint16(page[header+8:], uint16(start))
+}
+
+func newTestSQLite(blobs [][]byte) []byte {
+	db := &testSQLite{}
+	_, schema := db.newPage()
+	root, interior := db.newPage()
+	db.leaf(schema, 100, 1, sqliteRecordBytes("table", "Packages", "Packages", int64(root), "CREATE TABLE Packages (hnum INTEGER PRIMARY KEY, blob BLOB)"))
+	copy(schema, sqliteMagic)
+	binary.BigEndian.PutUint16(schema[16:], testPageSize)
+
+	children := []int{}
+	for i, blob := range blobs {
+		n, page := db.newPage()
+		children = append(children, n)
+		db.leaf(page, 0, i+1, sqliteRecordBytes(nil, blob))
+	}
+	interior[0] = sqliteInteriorTable
+	binary.BigEndian.PutUint16(interior[3:], uint16(len(children)-1))
+	binary.BigEndian.PutUint32(interior[8:], uint32(children[len(children)-1]))
+	for i, child := range children[:len(children)-1] {
+		cell := testPageSize - 8*(i+1)
+		binary.BigEndian.PutUint16(interior[12+2*i:], uint16(cell))
+		binary.BigEndian.PutUint32(interior[cell:], uint32(child))
+		interior[cell+4] = byte(i + 1)
+	}
+
+	data := []byte{}
+	for _, page := range db.pages {
+		data = append(data, page...)
+	}
+	return data
+}
+
+// newTestBerkeleyDB builds a Berkeley DB hash file with one hash page whose
+// values point at chains of overflow pages.
+func newTestBerkeleyDB(blobs [][]byte) []byte {
+	pages := [][]byte{make([]byte, testPageSize), make([]byte, testPageSize)}
+	meta, hash := pages[0], pages[1]
+	binary.LittleEndian.PutUint32(meta[12:], bdbHashMagic)
+	binary.LittleEndian.PutUint32(meta[20:], testPageSize)
+	hash[25] = bdbHashPage
+	binary.LittleEndian.PutUint16(hash[20:], uint16(2*len(blobs)))
+
+	offset := testPageSize
+	for i, blob := range blobs {
+		// The key is an inline item, the value an off-page item.
+		offset -= 5
+		hash[offset] = 1
+		binary.LittleEndian.PutUint16(hash[bdbPageHeaderSize+4*i:], uint16(offset))
+		offset -= 12
+		hash[offset] = bdbOffPageItem
+		binary.LittleEndian.PutUint32(hash[offset+4:], uint32(len(pages)))
+		binary.LittleEndian.PutUint32(hash[offset+8:], uint32(len(blob)))
+		binary.LittleEndian.PutUint16(hash[bdbPageHeaderSize+4*i+2:], uint16(offset))
+
+		for rest := blob; len(rest) > 0; {
+			page := make([]byte, testPageSize)
+			chunk := rest[:min(len(rest), testPageSize-bdbPageHeaderSize)]
+			rest = rest[len(chunk):]
+			page[25] = bdbOverflowPage
+			binary.LittleEndian.PutUint16(page[22:], uint16(len(chunk)))
+			copy(page[bdbPageHeaderSize:], chunk)
+			if len(rest) > 0 {
+				binary.LittleEndian.PutUint32(page[16:], uint32(len(pages)+1))
+			}
+			pages = append(pages, page)
+		}
+	}
+
+	data := []byte{}
+	for _, page := range pages {
+		data = append(data, page...)
+	}
+	return data
+}
+
+func TestRPMDatabaseBlobs(t *testing.T) {
+	tests := []struct {
+		name  string
+		read  func([]byte) ([][]byte, error)
+		build func([][]byte) []byte
+	}{
+		{"SQLite", sqliteBlobs, newTestSQLite},
+		{"BerkeleyDB", berkeleyDBBlobs, newTestBerkeleyDB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := rpmBlobs()
+			got, err := tt.read(tt.build(want))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d blobs, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if string(got[i]) != string(want[i]) {
+					t.Errorf("blob %d differs", i)
+				}
+			}
+
+			if _, err := tt.read([]byte("not a database")); err == nil {
+				t.Errorf("expected an error for a file of another format")
+			}
+		})
+	}
+}
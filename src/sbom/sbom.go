@@ -0,0 +1,214 @@
+// Package sbom lists the OS and language packages installed in an image and
+// writes them as CycloneDX or SPDX documents.
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// Types of packages, which are also the package URL types.
+const (
+	TypeAPK   = "apk"
+	TypeDeb   = "deb"
+	TypeRPM   = "rpm"
+	TypeNPM   = "npm"
+	TypePyPI  = "pypi"
+	TypeGo    = "golang"
+	TypeCargo = "cargo"
+	TypeGem   = "gem"
+)
+
+// typeOrder sorts OS packages before language packages.
+var typeOrder = map[string]int{TypeAPK: 0, TypeDeb: 0, TypeRPM: 0, TypeNPM: 1, TypePyPI: 2, TypeGo: 3, TypeCargo: 4, TypeGem: 5}
+
+// Package is a package installed in the image.
+type Package struct {
+	Name    string
+	Version string
+	Type    string
+	// PURL is the package URL, e.g. pkg:npm/express@4.19.2.
+	PURL     string
+	Licenses []string
+	// Location is the file the package was found in.
+	Location string
+	// Source is the source package an OS package was built from, which
+	// distributions publish their advisories for. It is empty when unknown.
+	Source string
+}
+
+// SBOM is the software bill of materials of an image.
+type SBOM struct {
+	// Image is the image reference the SBOM was generated for.
+	Image   string
+	ImageID string
+	// Digest is the digest of the image manifest, empty when unknown.
+	Digest string
+	// OS is the PRETTY_NAME of the os-release file, empty when there is none.
+	OS       string
+	Packages []Package
+	Created  time.Time
+	// Serial is a random UUID identifying the document.
+	Serial string
+}
+
+// cataloger reads the packages described by the files it matches.
+type cataloger struct {
+	match func(path string) bool
+	read  func(c *catalog, file *imagefs.File, content io.Reader) error
+}
+
+// catalog collects the packages of an image.
+type catalog struct {
+	// distro is the os-release ID, e.g. debian, and release its VERSION_ID.
+	distro   string
+	release  string
+	packages []Package
+	seen     map[string]bool
+}
+
+// catalogers are the package formats read from the image filesystem.
+var catalogers = []cataloger{
+	{isAPKDatabase, readAPKDatabase},
+	{isDpkgStatus, readDpkgStatus},
+	{isRPMDatabase, readRPMDatabase},
+	{isNodePackage, readNodePackage},
+	{isPackageLock, readPackageLock},
+	{isPythonMetadata, readPythonMetadata},
+	{isCargoLock, readCargoLock},
+	{isGemfileLock, readGemfileLock},
+}
+
+// Generate walks the filesystem of an image and lists its packages.
+func Generate(name string, img *imagefs.Image) (*SBOM, error) {
+	osRelease := img.OSRelease()
+	c := &catalog{distro: osRelease["ID"], release: osRelease["VERSION_ID"], seen: map[string]bool{}}
+
+	match := func(file *imagefs.File) bool {
+		if isExecutable(file) {
+			return true
+		}
+		for _, cat := range catalogers {
+			if cat.match(file.Path) {
+				return true
+			}
+		}
+		return false
+	}
+	err := img.Walk(match, func(file *imagefs.File, content io.Reader) error {
+		// A malformed file leaves its packages out rather than failing the
+		// whole SBOM.
+		if isExecutable(file) {
+			readGoBinary(c, file, content)
+			return nil
+		}
+		for _, cat := range catalogers {
+			if cat.match(file.Path) {
+				cat.read(c, file, content)
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(c.packages, func(i, j int) bool {
+		a, b := c.packages[i], c.packages[j]
+		if typeOrder[a.Type] != typeOrder[b.Type] {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return &SBOM{
+		Image:    name,
+		ImageID:  img.ID,
+		Digest:   img.Digest,
+		OS:       osRelease["PRETTY_NAME"],
+		Packages: c.packages,
+		Created:  time.Now().UTC(),
+		Serial:   newUUID(),
+	}, nil
+}
+
+// add records a package once per package URL.
+func (c *catalog) add(p Package) {
+	if p.Name == "" || p.Version == "" || c.seen[p.PURL] {
+		return
+	}
+	c.seen[p.PURL] = true
+	c.packages = append(c.packages, p)
+}
+
+// osPackage builds a package of the distribution's package manager. The
+// extra qualifiers go between the architecture and the distribution.
+func (c *catalog) osPackage(kind, name, version, arch, location string, licenses []string, extra ...[2]string) Package {
+	qualifiers := append([][2]string{{"arch", arch}}, extra...)
+	if c.distro != "" {
+		qualifiers = append(qualifiers, [2]string{"distro", strings.Trim(c.distro+"-"+c.release, "-")})
+	}
+	return Package{
+		Name:     name,
+		Version:  version,
+		Type:     kind,
+		PURL:     purl(kind, c.distro, name, version, qualifiers...),
+		Licenses: licenses,
+		Location: location,
+	}
+}
+
+// languagePackage builds a package of a language ecosystem.
+func languagePackage(kind, namespace, name, version, location string, licenses []string) Package {
+	return Package{
+		Name:     strings.Trim(namespace+"/"+name, "/"),
+		Version:  version,
+		Type:     kind,
+		PURL:     purl(kind, namespace, name, version),
+		Licenses: licenses,
+		Location: location,
+	}
+}
+
+// purl formats a package URL. Empty qualifiers are left out.
+func purl(kind, namespace, name, version string, qualifiers ...[2]string) string {
+	var b strings.Builder
+	b.WriteString("pkg:" + kind + "/")
+	for _, segment := range strings.Split(namespace, "/") {
+		if segment != "" {
+			b.WriteString(purlEscape(segment) + "/")
+		}
+	}
+	b.WriteString(purlEscape(name) + "@" + purlEscape(version))
+	separator := "?"
+	for _, q := range qualifiers {
+		if q[1] != "" {
+			b.WriteString(separator + q[0] + "=" + purlEscape(q[1]))
+			separator = "&"
+		}
+	}
+	return b.String()
+}
+
+func purlEscape(s string) string {
+	return strings.NewReplacer("+", "%2B", "@", "%40").Replace(url.PathEscape(s))
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,175 @@
+package sbom
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs/imagefstest"
+)
+
+func generate(t *testing.T, files ...imagefstest.File) map[string]Package {
+	t.Helper()
+	document, err := Generate("app:1.0", imagefstest.New(t, files))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packages := map[string]Package{}
+	for _, p := range document.Packages {
+		packages[p.PURL] = p
+	}
+	return packages
+}
+
+func TestGenerateOSPackages(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []imagefstest.File
+		expected []string
+		// sources are the expected source packages by package URL.
+		sources map[string]string
+	}{
+		{
+			name: "Alpine",
+			files: []imagefstest.File{
+				{Name: "etc/os-release", Content: []byte("ID=alpine\nVERSION_ID=3.19.1\nPRETTY_NAME=\"Alpine Linux v3.19\"\n")},
+				{Name: "lib/apk/db/installed", Content: []byte("C:Q1abc=\nP:musl\nV:1.2.4_git20230717-r4\nA:x86_64\nL:MIT\no:musl\n\nP:ssl_client\nV:1.36.1-r15\nA:x86_64\nL:GPL-2.0-only\no:busybox\n")},
+			},
+			expected: []string{
+				"pkg:apk/alpine/musl@1.2.4_git20230717-r4?arch=x86_64&distro=alpine-3.19.1",
+				"pkg:apk/alpine/ssl_client@1.36.1-r15?arch=x86_64&distro=alpine-3.19.1",
+			},
+			sources: map[string]string{"pkg:apk/alpine/ssl_client@1.36.1-r15?arch=x86_64&distro=alpine-3.19.1": "busybox"},
+		},
+		{
+			name: "Debian",
+			files: []imagefstest.File{
+				{Name: "etc/os-release", Content: []byte("ID=debian\nVERSION_ID=\"12\"\n")},
+				{Name: "var/lib/dpkg/status", Content: []byte("Package: libc6\nStatus: install ok installed\nArchitecture: amd64\nSource: glibc\nVersion: 2.36-9+deb12u4\nDescription: GNU C Library\n multi-line description\n\nPackage: removed\nStatus: deinstall ok config-files\nVersion: 1.0\n")},
+			},
+			expected: []string{"pkg:deb/debian/libc6@2.36-9%2Bdeb12u4?arch=amd64&distro=debian-12"},
+			sources:  map[string]string{"pkg:deb/debian/libc6@2.36-9%2Bdeb12u4?arch=amd64&distro=debian-12": "glibc"},
+		},
+		{
+			name: "Distroless",
+			files: []imagefstest.File{
+				{Name: "var/lib/dpkg/status.d/base-files", Content: []byte("Package: base-files\nVersion: 12.4+deb12u5\nArchitecture: amd64\n")},
+				{Name: "var/lib/dpkg/status.d/base-files.md5sums", Content: []byte("abc  etc/debian_version\n")},
+			},
+			expected: []string{"pkg:deb/base-files@12.4%2Bdeb12u5?arch=amd64"},
+		},
+		{
+			name: "RHEL",
+			files: []imagefstest.File{
+				{Name: "etc/os-release", Content: []byte("ID=\"rhel\"\nVERSION_ID=\"9.3\"\n")},
+				{Name: "var/lib/rpm/rpmdb.sqlite", Content: newTestSQLite(rpmBlobs())},
+			},
+			expected: []string{
+				"pkg:rpm/rhel/bash@5.1.8-9.el9?arch=x86_64&distro=rhel-9.3",
+				"pkg:rpm/rhel/openssl-libs@3.0.7-27.el9?arch=x86_64&epoch=1&distro=rhel-9.3",
+			},
+			sources: map[string]string{"pkg:rpm/rhel/openssl-libs@3.0.7-27.el9?arch=x86_64&epoch=1&distro=rhel-9.3": "openssl"},
+		},
+		{
+			name: "CentOS",
+			files: []imagefstest.File{
+				{Name: "var/lib/rpm/Packages", Content: newTestBerkeleyDB(rpmBlobs())},
+			},
+			expected: []string{"pkg:rpm/bash@5.1.8-9.el9?arch=x86_64", "pkg:rpm/openssl-libs@3.0.7-27.el9?arch=x86_64&epoch=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages := generate(t, tt.files...)
+			for purl, source := range tt.sources {
+				if packages[purl].Source != source {
+					t.Errorf("%s: got source %q, want %q", purl, packages[purl].Source, source)
+				}
+			}
+			if len(packages) != len(tt.expected) {
+				t.Errorf("got %d packages, want %d: %v", len(packages), len(tt.expected), packages)
+			}
+			for _, purl := range tt.expected {
+				if _, ok := packages[purl]; !ok {
+					t.Errorf("missing %s in %v", purl, packages)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateLanguagePackages(t *testing.T) {
+	packages := generate(t,
+		imagefstest.File{Name: "app/node_modules/express/package.json", Content: []byte(`{"name":"express","version":"4.19.2","license":"MIT"}`)},
+		imagefstest.File{Name: "app/node_modules/@babel/core/package.json", Content: []byte(`{"name":"@babel/core","version":"7.24.0","license":{"type":"MIT"}}`)},
+		imagefstest.File{Name: "app/node_modules/express/lib/package.json", Content: []byte(`{"name":"not-a-package","version":"1.0.0"}`)},
+		imagefstest.File{Name: "app/package-lock.json", Content: []byte(`{"lockfileVersion":3,"packages":{"":{"name":"app"},` +
+			`"node_modules/express":{"version":"4.19.2"},"node_modules/body-parser":{"version":"1.20.2","license":"MIT"},` +
+			`"node_modules/jest":{"version":"29.7.0","dev":true}}}`)},
+		imagefstest.File{Name: "usr/local/lib/python3.12/site-packages/Flask_Login-0.6.3.dist-info/METADATA", Content: []byte("Metadata-Version: 2.1\nName: Flask_Login\nVersion: 0.6.3\nLicense: MIT\n\nName: not-the-name\n")},
+		imagefstest.File{Name: "usr/lib/python3/dist-packages/six-1.16.0.egg-info", Content: []byte("Name: six\nVersion: 1.16.0\nClassifier: License :: OSI Approved :: MIT License\n")},
+		imagefstest.File{Name: "src/Cargo.lock", Content: []byte("version = 3\n\n[[package]]\nname = \"serde\"\nversion = \"1.0.197\"\nsource = \"registry+https://github.com/rust-lang/crates.io-index\"\ndependencies = [\n \"serde_derive\",\n]\n\n[[package]]\nname = \"app\"\nversion = \"0.1.0\"\n")},
+		imagefstest.File{Name: "app/Gemfile.lock", Content: []byte("GEM\n  remote: https://rubygems.org/\n  specs:\n    rack (3.0.9)\n    rails (7.1.3)\n      rack (>= 2.2.4)\n\nPLATFORMS\n  x86_64-linux\n")},
+	)
+
+	expected := map[string]string{
+		"pkg:npm/express@4.19.2":       "MIT",
+		"pkg:npm/%40babel/core@7.24.0": "MIT",
+		"pkg:npm/body-parser@1.20.2":   "MIT",
+		"pkg:pypi/flask-login@0.6.3":   "MIT",
+		"pkg:pypi/six@1.16.0":          "MIT License",
+		"pkg:cargo/serde@1.0.197":      "",
+		"pkg:cargo/app@0.1.0":          "",
+		"pkg:gem/rack@3.0.9":           "",
+		"pkg:gem/rails@7.1.3":          "",
+	}
+	if len(packages) != len(expected) {
+		t.Errorf("got %d packages, want %d: %v", len(packages), len(expected), packages)
+	}
+	for purl, license := range expected {
+		p, ok := packages[purl]
+		if !ok {
+			t.Errorf("missing %s", purl)
+			continue
+		}
+		if got := strings.Join(p.Licenses, " "); got != license {
+			t.Errorf("%s: got license %q, want %q", purl, got, license)
+		}
+	}
+	if p := packages["pkg:npm/express@4.19.2"]; p.Location != "/app/node_modules/express/package.json" {
+		t.Errorf("express should be reported where it is installed, got %s", p.Location)
+	}
+	if p := packages["pkg:npm/%40babel/core@7.24.0"]; p.Name != "@babel/core" {
+		t.Errorf("got name %q, want @babel/core", p.Name)
+	}
+}
+
+func TestGenerateGoBinaries(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skip("test binary not found:", err)
+	}
+	binary, err := os.ReadFile(executable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packages := generate(t,
+		imagefstest.File{Name: "usr/local/bin/app", Content: binary, Mode: 0o755},
+		imagefstest.File{Name: "usr/local/bin/script", Content: []byte("#!/bin/sh\necho hi\n"), Mode: 0o755},
+	)
+
+	stdlib := "pkg:golang/stdlib@" + strings.TrimPrefix(runtime.Version(), "go")
+	if _, ok := packages[stdlib]; !ok && !strings.HasPrefix(runtime.Version(), "devel") {
+		t.Errorf("missing %s in %v", stdlib, packages)
+	}
+	if _, ok := packages["pkg:golang/github.com/opencontainers/go-digest@v1.0.0"]; !ok {
+		t.Errorf("missing the go-digest module in %v", packages)
+	}
+	for _, p := range packages {
+		if p.Type != TypeGo || p.Location != "/usr/local/bin/app" {
+			t.Errorf("unexpected package %+v", p)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sbom
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const spdxVersion = "SPDX-2.3"
+
+// spdxNoAssertion stands for a value that was not determined.
+const spdxNoAssertion = "NOASSERTION"
+
+// spdxExpressionPattern matches license expressions made of SPDX license
+// identifiers, like MIT or (Apache-2.0 OR GPL-2.0-or-later). Free-form
+// license names are not valid in licenseDeclared.
+var spdxExpressionPattern = regexp.MustCompile(`^\(?[A-Za-z0-9.+-]+\)?( (AND|OR|WITH) \(?[A-Za-z0-9.+-]+\)?)*$`)
+
+var spdxIDPattern = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// Minimal subset of the SPDX 2.3 object model used by dockeryzer.
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string           `json:"documentDescribes"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func newSPDX(sbom *SBOM) spdxDocument {
+	const imageID = "SPDXRef-Image"
+	doc := spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              sbom.Image,
+		DocumentNamespace: "https://github.com/jorgevvs2/dockeryzer/spdx/" + spdxIDPattern.ReplaceAllString(sbom.Image, "-") + "-" + sbom.Serial,
+		CreationInfo: spdxCreationInfo{
+			Created:  sbom.Created.Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName},
+		},
+		DocumentDescribes: []string{imageID},
+		Packages: []spdxPackage{{
+			Name:             sbom.Image,
+			SPDXID:           imageID,
+			VersionInfo:      sbom.Digest,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			PrimaryPurpose:   "CONTAINER",
+		}},
+		Relationships: []spdxRelationship{{"SPDXRef-DOCUMENT", "DESCRIBES", imageID}},
+	}
+
+	for i, p := range sbom.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%s-%d", p.Type, i+1)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             p.Name,
+			SPDXID:           id,
+			VersionInfo:      p.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxLicense(p.Licenses),
+			SourceInfo:       "found in " + p.Location,
+			PrimaryPurpose:   "LIBRARY",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  p.PURL,
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{imageID, "CONTAINS", id})
+	}
+	return doc
+}
+
+// spdxLicense joins the licenses of a package into an SPDX expression, or
+// NOASSERTION when one of them is not made of SPDX identifiers.
+func spdxLicense(licenses []string) string {
+	if len(licenses) == 0 {
+		return spdxNoAssertion
+	}
+	expressions := []string{}
+	for _, license := range licenses {
+		if !spdxExpressionPattern.MatchString(license) {
+			return spdxNoAssertion
+		}
+		if len(licenses) > 1 && strings.Contains(license, " ") {
+			license = "(" + license + ")"
+		}
+		expressions = append(expressions, license)
+	}
+	return strings.Join(expressions, " AND ")
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// BaselineVersion is the version of the baseline file format.
+const BaselineVersion = 1
+
+// Baseline records accepted findings so that only new ones are reported.
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// BaselineEntry is a finding recorded in a baseline. Message is informative
+// only, findings are matched by rule and fingerprint.
+type BaselineEntry struct {
+	RuleID      string `json:"ruleId"`
+	Fingerprint string `json:"fingerprint"`
+	Message     string `json:"message,omitempty"`
+}
+
+// NewBaseline records every failure of results that is not already suppressed.
+func NewBaseline(results []CISResult) *Baseline {
+	baseline := &Baseline{Version: BaselineVersion, Findings: []BaselineEntry{}}
+	for _, r := range results {
+		if !r.Failed() {
+			continue
+		}
+		baseline.Findings = append(baseline.Findings, BaselineEntry{
+			RuleID:      r.RuleID,
+			Fingerprint: r.Fingerprint,
+			Message:     r.Message,
+		})
+	}
+	return baseline
+}
+
+// LoadBaseline reads a baseline file written by Save.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := &Baseline{}
+	if err := json.Unmarshal(data, baseline); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if baseline.Version != BaselineVersion {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d", path, baseline.Version)
+	}
+	return baseline, nil
+}
+
+// Save writes the baseline as indented JSON.
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Apply marks the failed results recorded in the baseline as suppressed.
+func (b *Baseline) Apply(results []CISResult) {
+	known := map[string]bool{}
+	for _, entry := range b.Findings {
+		known[entry.RuleID+"\x00"+entry.Fingerprint] = true
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Failed() && known[r.RuleID+"\x00"+r.Fingerprint] {
+			r.Suppression = &Suppression{Kind: SuppressedBaseline, Reason: "Recorded in the baseline"}
+		}
+	}
+}
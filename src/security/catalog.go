@@ -0,0 +1,80 @@
+package security
+
+// Rule categories of the score breakdown.
+const (
+	CategoryBaseImage       = "base-image"
+	CategoryPrivileges      = "user-privileges"
+	CategoryBuildHygiene    = "build-hygiene"
+	CategoryRuntime         = "runtime"
+	CategorySecrets         = "secrets"
+	CategoryVulnerabilities = "vulnerabilities"
+)
+
+// Categories lists the rule categories in display order.
+var Categories = []string{CategoryBaseImage, CategoryPrivileges, CategorySecrets, CategoryVulnerabilities, CategoryBuildHygiene, CategoryRuntime}
+
+var categoryTitles = map[string]string{
+	CategoryBaseImage:       "Base image",
+	CategoryPrivileges:      "User and privileges",
+	CategoryBuildHygiene:    "Build hygiene",
+	CategoryRuntime:         "Runtime",
+	CategorySecrets:         "Secrets",
+	CategoryVulnerabilities: "Vulnerabilities",
+}
+
+// CategoryTitle returns the display name of a rule category.
+func CategoryTitle(category string) string {
+	if title, ok := categoryTitles[category]; ok {
+		return title
+	}
+	return category
+}
+
+// RuleInfo describes a built-in rule independently of its results.
+type RuleInfo struct {
+	Description string
+	// Severity is the severity of the rule's typical finding, which sets its
+	// weight in the score while it passes.
+	Severity string
+	Category string
+}
+
+// ruleCatalog describes the built-in rules, keyed by rule ID.
+var ruleCatalog = map[string]RuleInfo{
+	"CIS-1.1":                {Description: "Use official base images", Severity: SeverityMedium, Category: CategoryBaseImage},
+	"CIS-1.2":                {Description: "Use explicit image tag (not latest)", Severity: SeverityHigh, Category: CategoryBaseImage},
+	"CIS-4.1":                {Description: "Container should not run as root", Severity: SeverityHigh, Category: CategoryPrivileges},
+	"CIS-4.6":                {Description: "Container must define HEALTHCHECK", Severity: SeverityLow, Category: CategoryRuntime},
+	"CIS-4.9":                {Description: "Use COPY instead of ADD", Severity: SeverityMedium, Category: CategoryBuildHygiene},
+	"CIS-5.1":                {Description: "Remove cache and temporary files", Severity: SeverityMedium, Category: CategoryBuildHygiene},
+	"CIS-5.2":                {Description: "Use .dockerignore", Severity: SeverityLow, Category: CategoryBuildHygiene},
+	"CIS-6.1":                {Description: "Expose minimum ports", Severity: SeverityLow, Category: CategoryRuntime},
+	"CIS-7.1":                {Description: "Use multi-stage builds when appropriate", Severity: SeverityMedium, Category: CategoryBuildHygiene},
+	"CIS-8.1":                {Description: "Combine RUN instructions", Severity: SeverityLow, Category: CategoryBuildHygiene},
+	"CIS-9.1":                {Description: "Optimize instruction order", Severity: SeverityLow, Category: CategoryBuildHygiene},
+	"DKZ-SECRET-ENV":         {Description: "Do not store secrets in ENV or ARG", Severity: SeverityHigh, Category: CategorySecrets},
+	"DKZ-SECRET-COPY":        {Description: "Do not copy credential files into the image", Severity: SeverityHigh, Category: CategorySecrets},
+	"DKZ-SECRET-CONTEXT":     {Description: "Keep secrets out of the build context", Severity: SeverityMedium, Category: CategorySecrets},
+	"DKZ-ADD-CHECKSUM":       {Description: "Verify remote downloads", Severity: SeverityMedium, Category: CategoryBuildHygiene},
+	"DKZ-PIPE-SHELL":         {Description: "Do not pipe downloaded scripts into a shell", Severity: SeverityHigh, Category: CategoryBuildHygiene},
+	"DKZ-PIN-PACKAGES":       {Description: "Pin package versions", Severity: SeverityLow, Category: CategoryBuildHygiene},
+	"DKZ-SUDO":               {Description: "Do not install sudo", Severity: SeverityMedium, Category: CategoryPrivileges},
+	"DKZ-CHMOD-777":          {Description: "Do not make files world-writable", Severity: SeverityMedium, Category: CategoryPrivileges},
+	"DKZ-SETUID":             {Description: "Do not add setuid or setgid bits", Severity: SeverityHigh, Category: CategoryPrivileges},
+	"DKZ-COPY-CHOWN":         {Description: "Copy files with --chown for the runtime user", Severity: SeverityLow, Category: CategoryPrivileges},
+	"DKZ-IMG-SHELL":          {Description: "Runtime images should not ship a shell", Severity: SeverityLow, Category: CategoryRuntime},
+	"DKZ-IMG-PKG-MANAGER":    {Description: "Runtime images should not ship a package manager", Severity: SeverityLow, Category: CategoryRuntime},
+	"DKZ-IMG-SETUID":         {Description: "Images should not contain setuid or setgid binaries", Severity: SeverityMedium, Category: CategoryPrivileges},
+	"DKZ-IMG-WORLD-WRITABLE": {Description: "Images should not contain world-writable paths", Severity: SeverityMedium, Category: CategoryPrivileges},
+	"DKZ-IMG-CACHE":          {Description: "Images should not keep package caches", Severity: SeverityLow, Category: CategoryBuildHygiene},
+	"DKZ-IMG-SECRET":         {Description: "Images should not contain credential files", Severity: SeverityHigh, Category: CategorySecrets},
+	"DKZ-VULN":               {Description: "Packages should not have known vulnerabilities", Severity: SeverityHigh, Category: CategoryVulnerabilities},
+	"DKZ-LABELS":             {Description: "Define the required labels", Severity: SeverityLow, Category: CategoryRuntime},
+	PragmaRuleID:             {Description: "Ignore pragmas must name rules and give a reason", Severity: SeverityLow, Category: CategoryBuildHygiene},
+}
+
+// LookupRule returns the description of a built-in rule.
+func LookupRule(id string) (RuleInfo, bool) {
+	info, ok := ruleCatalog[id]
+	return info, ok
+}
@@ -1,42 +1,271 @@
 package security
 
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/config"
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+	"github.com/jorgevvs2/dockeryzer/src/vuln"
+)
+
 type CISResult struct {
-	RuleID      string
-	Description string
-	Passed      bool
-	Severity    string
-	Message     string
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	Passed      bool   `json:"passed"`
+	Severity    string `json:"severity,omitempty"`
+	Message     string `json:"message,omitempty"`
+	// Stage is the label of the build stage the result refers to, empty for
+	// results about the Dockerfile as a whole.
+	Stage string `json:"stage,omitempty"`
+	// Location points at the offending instruction. It is nil for passed
+	// results and has no line when the finding concerns the whole file.
+	Location *Location `json:"location,omitempty"`
+	// Category groups the rule in the score breakdown, see Categories.
+	Category string `json:"category,omitempty"`
+	// Remediation is an optional hint on how to fix the finding.
+	Remediation string `json:"remediation,omitempty"`
+	// Fingerprint identifies a failed result across edits, see Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Suppression is set when a failed result was acknowledged by an ignore
+	// pragma or a baseline. Suppressed results do not count as failures.
+	Suppression *Suppression `json:"suppression,omitempty"`
+}
+
+// Failed reports whether r is a failure that has not been suppressed.
+func (r CISResult) Failed() bool {
+	return !r.Passed && r.Suppression == nil
+}
+
+// Location is a range inside a Dockerfile. Lines and columns are 1-based and
+// EndColumn points just past the last highlighted character.
+type Location struct {
+	File        string `json:"file"`
+	StartLine   int    `json:"startLine,omitempty"`
+	StartColumn int    `json:"startColumn,omitempty"`
+	EndLine     int    `json:"endLine,omitempty"`
+	EndColumn   int    `json:"endColumn,omitempty"`
+	// Instruction is the full text of the offending instruction.
+	Instruction string `json:"instruction,omitempty"`
+	// Source holds the source lines from StartLine to EndLine.
+	Source []string `json:"-"`
+}
+
+// Target is the Dockerfile under analysis together with the stage that is
+// shipped, which is the --target stage or the last one.
+type Target struct {
+	Dockerfile *dockerfile.Dockerfile
+	Final      *dockerfile.Stage
 }
 
 type CISRule interface {
-	Check(dockerfile string) CISResult
+	// ID is the rule ID carried by every result of the rule.
+	ID() string
+	Check(target *Target) []CISResult
+}
+
+// ImageTarget is the image under analysis: its inspect data and, unless
+// only the metadata is analyzed, its exported filesystem.
+type ImageTarget struct {
+	Name       string
+	Inspect    image.InspectResponse
+	Filesystem *imagefs.Image
+}
+
+// ImageRule is a check evaluated against an image.
+type ImageRule interface {
+	ID() string
+	CheckImage(target *ImageTarget) []CISResult
 }
 
 type CISAnalyzer struct {
-	rules []CISRule
+	rules      []CISRule
+	imageRules []ImageRule
+	// severities overrides the severity of failed results, keyed by rule ID.
+	severities map[string]string
+	// checkPragmas reports malformed ignore pragmas as PragmaRuleID findings.
+	checkPragmas bool
 }
 
 func NewCISAnalyzer() *CISAnalyzer {
-	return &CISAnalyzer{
-		rules: []CISRule{
-			OfficialBaseImageRule{},
-			ExplicitTagRule{},
-			NoRootUserRule{},
-			CleanCacheRule{},
-			HealthcheckRule{},
-			DockerIgnoreRule{},
-			MinimalPortExposureRule{},
-			MultiStageBuildRule{},
-			CombinedRunCommandRule{},
-			OptimizedOrderRule{},
-		},
-	}
-}
-
-func (a *CISAnalyzer) Analyze(content string) []CISResult {
+	analyzer, _ := NewConfiguredCISAnalyzer(&config.Project{})
+	return analyzer
+}
+
+// NewConfiguredCISAnalyzer builds an analyzer that applies the rule
+// switches, severities and parameters of a project configuration.
+func NewConfiguredCISAnalyzer(project *config.Project) (*CISAnalyzer, error) {
+	rules := []CISRule{
+		OfficialBaseImageRule{AllowedRegistries: project.AllowedRegistries},
+		ExplicitTagRule{},
+		NoRootUserRule{},
+		CleanCacheRule{},
+		HealthcheckRule{},
+		DockerIgnoreRule{},
+		MinimalPortExposureRule{MaxPorts: project.MaxExposedPorts},
+		MultiStageBuildRule{},
+		CombinedRunCommandRule{},
+		OptimizedOrderRule{},
+		RequiredLabelsRule{Labels: project.RequiredLabels},
+		SecretEnvRule{},
+		SecretFileCopyRule{},
+		SecretContextRule{},
+		CopyInsteadOfAddRule{},
+		RemoteAddChecksumRule{},
+		PipeToShellRule{},
+		PinnedPackagesRule{},
+		SudoInstallRule{},
+		WorldWritableRule{},
+		SetuidRule{},
+		CopyChownRule{},
+	}
+
+	var database *vuln.Database
+	if project.VulnerabilityDatabase != "" {
+		var err error
+		database, err = vuln.Open(project.VulnerabilityDatabase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	known := map[string]bool{PragmaRuleID: true}
+	for _, rule := range rules {
+		known[rule.ID()] = true
+	}
+
+	imageRules := []ImageRule{
+		ImageShellRule{},
+		ImagePackageManagerRule{},
+		ImageSetuidRule{},
+		ImageWorldWritableRule{},
+		ImageCacheRule{},
+		ImageSecretFileRule{},
+		VulnerabilityRule{Database: database},
+	}
+	for _, rule := range imageRules {
+		known[rule.ID()] = true
+	}
+
+	for _, path := range project.Policies {
+		policies, err := LoadPolicyRules(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range policies {
+			if known[policy.ID()] {
+				return nil, fmt.Errorf("%s: duplicate rule id %s", policy.Source, policy.ID())
+			}
+			known[policy.ID()] = true
+			if policy.Definition.Target == PolicyTargetImage {
+				imageRules = append(imageRules, policy)
+			} else {
+				rules = append(rules, policy)
+			}
+		}
+	}
+
+	analyzer := &CISAnalyzer{
+		severities:   map[string]string{},
+		checkPragmas: project.RuleEnabled(PragmaRuleID),
+	}
+	for id, rule := range project.Rules {
+		if !known[id] {
+			return nil, fmt.Errorf("%s: unknown rule %q", project.Path, id)
+		}
+		if rule.Severity != "" {
+			severity, err := ParseSeverity(rule.Severity)
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %s: %w", project.Path, id, err)
+			}
+			analyzer.severities[id] = severity
+		}
+	}
+
+	for _, rule := range rules {
+		if project.RuleEnabled(rule.ID()) {
+			analyzer.rules = append(analyzer.rules, rule)
+		}
+	}
+	for _, rule := range imageRules {
+		if project.RuleEnabled(rule.ID()) {
+			analyzer.imageRules = append(analyzer.imageRules, rule)
+		}
+	}
+	return analyzer, nil
+}
+
+// AnalyzeImage runs the image rules against an image. The filesystem rules
+// return no results when the filesystem was not exported.
+func (a *CISAnalyzer) AnalyzeImage(target *ImageTarget) []CISResult {
 	results := []CISResult{}
-	for _, rule := range a.rules {
-		results = append(results, rule.Check(content))
+	for _, rule := range a.imageRules {
+		results = append(results, rule.CheckImage(target)...)
 	}
+	a.finish(results)
 	return results
 }
+
+// Analyze runs every rule against df. Runtime rules look at the stage
+// selected by stageTarget (the last stage when empty), base image rules look
+// at every FROM. Failures matched by an ignore pragma come back suppressed.
+func (a *CISAnalyzer) Analyze(df *dockerfile.Dockerfile, stageTarget string) ([]CISResult, error) {
+	target, err := newAnalysisTarget(df, stageTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []CISResult{}
+	for _, rule := range a.rules {
+		results = append(results, rule.Check(target)...)
+	}
+
+	pragmas, invalid := parsePragmas(df)
+	applyPragmas(pragmas, results)
+	if a.checkPragmas {
+		results = append(results, invalid...)
+	}
+
+	a.finish(results)
+	return results, nil
+}
+
+// newAnalysisTarget selects the stage of df built for stageTarget.
+func newAnalysisTarget(df *dockerfile.Dockerfile, stageTarget string) (*Target, error) {
+	target := &Target{Dockerfile: df}
+	if len(df.Stages) > 0 {
+		final, err := df.Target(stageTarget)
+		if err != nil {
+			return nil, err
+		}
+		target.Final = final
+	}
+	return target, nil
+}
+
+// finish completes the results with the description, severity and
+// category of their rule, applies the severity overrides and fingerprints
+// the failures.
+func (a *CISAnalyzer) finish(results []CISResult) {
+	for i := range results {
+		r := &results[i]
+		if info, ok := ruleCatalog[r.RuleID]; ok {
+			if r.Description == "" {
+				r.Description = info.Description
+			}
+			if r.Severity == "" {
+				r.Severity = info.Severity
+			}
+			if r.Category == "" {
+				r.Category = info.Category
+			}
+		}
+		if severity, ok := a.severities[r.RuleID]; ok {
+			r.Severity = severity
+		}
+		if !r.Passed {
+			r.Fingerprint = Fingerprint(*r)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package security
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// maxContextFiles bounds the walk of a build context. It is a variable so
+// that tests can lower it.
+var maxContextFiles = 20000
+
+// dockerIgnore holds the patterns of a .dockerignore file, matched with the
+// same semantics as the Docker builder.
+type dockerIgnore struct {
+	// matcher is nil when there is no .dockerignore.
+	matcher *patternmatcher.PatternMatcher
+}
+
+// loadDockerIgnore reads the .dockerignore of a build context. A missing
+// file ignores nothing.
+func loadDockerIgnore(dir string) (*dockerIgnore, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &dockerIgnore{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerIgnore{matcher: matcher}, nil
+}
+
+// Ignored reports whether the slash-separated path, relative to the build
+// context, is left out of the context.
+func (d *dockerIgnore) Ignored(path string) bool {
+	ignored, _, _ := d.match(path, patternmatcher.MatchInfo{})
+	return ignored
+}
+
+// match is Ignored reusing the result of the parent directory, as returned
+// for it by match.
+func (d *dockerIgnore) match(path string, parent patternmatcher.MatchInfo) (bool, patternmatcher.MatchInfo, error) {
+	if d.matcher == nil {
+		return false, patternmatcher.MatchInfo{}, nil
+	}
+	return d.matcher.MatchesUsingParentResults(path, parent)
+}
+
+// canSkipDir reports whether an ignored directory can be left out of the
+// walk, which is when no "!" pattern may add back something below it.
+func (d *dockerIgnore) canSkipDir(dir string) bool {
+	if !d.matcher.Exclusions() {
+		return true
+	}
+	for _, pattern := range d.matcher.Patterns() {
+		if pattern.Exclusion() && strings.HasPrefix(pattern.String()+"/", dir+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// contextFiles returns the regular files of the build context in dir that
+// are not excluded by its .dockerignore, as slash-separated relative paths.
+// .git directories and the ignored directories are skipped. truncated
+// reports that the walk stopped at maxContextFiles files.
+func contextFiles(dir string) (files []string, truncated bool, err error) {
+	ignore, err := loadDockerIgnore(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	files = []string{}
+	parents := map[string]patternmatcher.MatchInfo{}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		ignored, info, err := ignore.match(rel, parents[filepath.ToSlash(filepath.Dir(rel))])
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if ignored && ignore.canSkipDir(rel) {
+				return filepath.SkipDir
+			}
+			parents[rel] = info
+			return nil
+		}
+		if !entry.Type().IsRegular() || ignored {
+			return nil
+		}
+		if len(files) == maxContextFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, truncated, err
+}
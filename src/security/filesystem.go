@@ -0,0 +1,257 @@
+package security
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// maxListedFiles bounds the paths named in the message of an image finding.
+const maxListedFiles = 5
+
+// maxKeyFileSize bounds the bytes of a key file read to tell private keys
+// from certificates.
+const maxKeyFileSize = 64 << 10
+
+var binaryDirs = []string{"/bin", "/usr/bin", "/sbin", "/usr/sbin", "/usr/local/bin"}
+var shells = []string{"sh", "bash", "ash", "dash", "zsh", "ksh", "busybox"}
+var packageManagers = []string{"apt", "apt-get", "dpkg", "apk", "yum", "dnf", "microdnf", "rpm", "zypper"}
+
+// packageCaches are the locations package managers leave downloads and
+// indexes in.
+var packageCaches = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"apk cache (/var/cache/apk)", regexp.MustCompile(`^/var/cache/apk/`)},
+	{"apt lists (/var/lib/apt/lists)", regexp.MustCompile(`^/var/lib/apt/lists/`)},
+	{"apt archives (/var/cache/apt)", regexp.MustCompile(`^/var/cache/apt/`)},
+	{"yum/dnf cache (/var/cache/yum, /var/cache/dnf)", regexp.MustCompile(`^/var/cache/(yum|dnf)/`)},
+	{"npm cache (~/.npm)", regexp.MustCompile(`^/(root|home/[^/]+)/\.npm/`)},
+	{"pip cache (~/.cache/pip)", regexp.MustCompile(`^/(root|home/[^/]+)/\.cache/pip/`)},
+	{"yarn cache", regexp.MustCompile(`^/(root|home/[^/]+|usr/local/share)/\.cache/yarn/`)},
+}
+
+// bundledFilePattern matches the places where images carry key material
+// that is public or belongs to packages: CA bundles, docs and test data.
+var bundledFilePattern = regexp.MustCompile(`^/(etc/ssl|etc/pki|etc/ca-certificates|usr/share|usr/lib/ssl)/|/(node_modules|site-packages|dist-packages|testdata|test|tests)/`)
+
+var keyFilePattern = regexp.MustCompile(`\.(pem|key)$`)
+
+// findBinaries returns the paths of names found in the binary directories.
+func findBinaries(fsys *imagefs.Image, names []string) []string {
+	found := []string{}
+	for _, name := range names {
+		for _, dir := range binaryDirs {
+			if file, ok := fsys.Stat(path.Join(dir, name)); ok && !file.Mode.IsDir() {
+				found = append(found, file.Path)
+			}
+		}
+	}
+	return found
+}
+
+// listFiles joins the first paths for a message, noting how many are left.
+func listFiles(paths []string) string {
+	if len(paths) <= maxListedFiles {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:maxListedFiles], ", "), len(paths)-maxListedFiles)
+}
+
+// DKZ-IMG-SHELL Shells na imagem de runtime
+type ImageShellRule struct{}
+
+func (r ImageShellRule) ID() string { return "DKZ-IMG-SHELL" }
+
+func (r ImageShellRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	found := findBinaries(target.Filesystem, shells)
+	if len(found) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Runtime images should not ship a shell", Passed: true}}
+	}
+	return []CISResult{{
+		RuleID:      r.ID(),
+		Description: "Runtime images should not ship a shell",
+		Passed:      false,
+		Severity:    SeverityLow,
+		Message:     "The image contains shells: " + listFiles(found),
+		Remediation: "Build the runtime stage FROM a distroless or scratch image and debug with docker debug or an ephemeral container",
+	}}
+}
+
+// DKZ-IMG-PKG-MANAGER Gerenciadores de pacotes na imagem de runtime
+type ImagePackageManagerRule struct{}
+
+func (r ImagePackageManagerRule) ID() string { return "DKZ-IMG-PKG-MANAGER" }
+
+func (r ImagePackageManagerRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	found := findBinaries(target.Filesystem, packageManagers)
+	if len(found) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Runtime images should not ship a package manager", Passed: true}}
+	}
+	return []CISResult{{
+		RuleID:      r.ID(),
+		Description: "Runtime images should not ship a package manager",
+		Passed:      false,
+		Severity:    SeverityLow,
+		Message:     "The image contains package managers: " + listFiles(found),
+		Remediation: "Install packages in a build stage and copy the results into a runtime image without a package manager",
+	}}
+}
+
+// DKZ-IMG-SETUID Binários setuid/setgid na imagem
+type ImageSetuidRule struct{}
+
+func (r ImageSetuidRule) ID() string { return "DKZ-IMG-SETUID" }
+
+func (r ImageSetuidRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	found := []string{}
+	for _, file := range target.Filesystem.Files() {
+		if file.Mode.IsRegular() && file.Mode&(fs.ModeSetuid|fs.ModeSetgid) != 0 {
+			found = append(found, file.Path)
+		}
+	}
+	if len(found) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Images should not contain setuid or setgid binaries", Passed: true}}
+	}
+	return []CISResult{{
+		RuleID:      r.ID(),
+		Description: "Images should not contain setuid or setgid binaries",
+		Passed:      false,
+		Severity:    SeverityMedium,
+		Message:     fmt.Sprintf("%d setuid or setgid binaries: %s", len(found), listFiles(found)),
+		Remediation: "Strip the bits in the final stage, e.g. RUN find / -xdev -perm /6000 -type f -exec chmod a-s {} +",
+	}}
+}
+
+// DKZ-IMG-WORLD-WRITABLE Diretórios graváveis por todos na imagem
+type ImageWorldWritableRule struct{}
+
+func (r ImageWorldWritableRule) ID() string { return "DKZ-IMG-WORLD-WRITABLE" }
+
+// CheckImage flags world-writable files and directories. Directories with
+// the sticky bit, like /tmp, are fine.
+func (r ImageWorldWritableRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	found := []string{}
+	for _, file := range target.Filesystem.Files() {
+		writable := file.Mode.Perm()&0o002 != 0
+		if writable && (file.Mode.IsRegular() || file.Mode.IsDir() && file.Mode&fs.ModeSticky == 0) {
+			found = append(found, file.Path)
+		}
+	}
+	if len(found) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Images should not contain world-writable paths", Passed: true}}
+	}
+	return []CISResult{{
+		RuleID:      r.ID(),
+		Description: "Images should not contain world-writable paths",
+		Passed:      false,
+		Severity:    SeverityMedium,
+		Message:     fmt.Sprintf("%d world-writable paths: %s", len(found), listFiles(found)),
+		Remediation: "Give the paths to the runtime user with chown and remove the write bit for others, or add the sticky bit to shared directories",
+	}}
+}
+
+// DKZ-IMG-CACHE Caches de pacotes esquecidos na imagem
+type ImageCacheRule struct{}
+
+func (r ImageCacheRule) ID() string { return "DKZ-IMG-CACHE" }
+
+func (r ImageCacheRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	sizes := make([]int64, len(packageCaches))
+	for _, file := range target.Filesystem.Files() {
+		if !file.Mode.IsRegular() || file.Size == 0 {
+			continue
+		}
+		for i, cache := range packageCaches {
+			if cache.Pattern.MatchString(file.Path) {
+				sizes[i] += file.Size
+				break
+			}
+		}
+	}
+
+	found := []string{}
+	for i, cache := range packageCaches {
+		if sizes[i] > 0 {
+			found = append(found, cache.Name+" "+units.HumanSize(float64(sizes[i])))
+		}
+	}
+	if len(found) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Images should not keep package caches", Passed: true}}
+	}
+	return []CISResult{{
+		RuleID:      r.ID(),
+		Description: "Images should not keep package caches",
+		Passed:      false,
+		Severity:    SeverityLow,
+		Message:     "Leftover package caches: " + strings.Join(found, ", "),
+		Remediation: "Clean the caches in the RUN that fills them (apk add --no-cache, rm -rf /var/lib/apt/lists/*, npm cache clean --force, pip install --no-cache-dir) or use a cache mount",
+	}}
+}
+
+// DKZ-IMG-SECRET Arquivos de credenciais na imagem
+type ImageSecretFileRule struct{}
+
+func (r ImageSecretFileRule) ID() string { return "DKZ-IMG-SECRET" }
+
+func (r ImageSecretFileRule) CheckImage(target *ImageTarget) []CISResult {
+	if target.Filesystem == nil {
+		return nil
+	}
+	results := []CISResult{}
+	for _, file := range target.Filesystem.Files() {
+		if !file.Mode.IsRegular() || !isImageSecretFile(target.Filesystem, file) {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Images should not contain credential files",
+			Passed:      false,
+			Severity:    SeverityHigh,
+			Message:     "Credential file " + file.Path + " is in the image",
+			Remediation: "Remove the file from the layer that adds it, and pass credentials at build time with RUN --mount=type=secret or at run time",
+		})
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Images should not contain credential files", Passed: true}}
+	}
+	return results
+}
+
+// isImageSecretFile reports whether file looks like credentials. Public
+// keys, CA bundles and files shipped by packages are left out, and .pem and
+// .key files must hold a private key.
+func isImageSecretFile(fsys *imagefs.Image, file *imagefs.File) bool {
+	base := path.Base(file.Path)
+	if strings.HasSuffix(base, ".pub") || base == "known_hosts" || base == "authorized_keys" ||
+		bundledFilePattern.MatchString(file.Path) || !isCredentialFile(file.Path) {
+		return false
+	}
+	if !keyFilePattern.MatchString(base) {
+		return true
+	}
+	content, err := fsys.ReadFile(file.Path, maxKeyFileSize)
+	return err == nil && strings.Contains(string(content), "PRIVATE KEY-----")
+}
@@ -0,0 +1,384 @@
+package security
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+// maxFixPasses bounds the analyze and fix rounds of CISAnalyzer.Fix.
+const maxFixPasses = 20
+
+// fallbackUser is the user set by the NoRootUserRule fix when the stage does
+// not create one: nobody, which exists in most base images and is accepted
+// by Docker as a numeric ID when it does not.
+const fallbackUser = "65534:65534"
+
+var userCreatePattern = regexp.MustCompile(`^(useradd|adduser)$`)
+var commandSeparatorPattern = regexp.MustCompile(`&&|\|\||[;|\n]`)
+var shellStatePattern = regexp.MustCompile(`(^|[\s;&|(])(cd|export|set|unset|source|alias|shopt|\.)\s`)
+
+// Edit replaces the source lines StartLine to EndLine (1-based, inclusive)
+// of a Dockerfile with Lines. An insertion before StartLine has EndLine set
+// to StartLine-1.
+type Edit struct {
+	RuleID string
+	// Summary describes the change for the user.
+	Summary   string
+	StartLine int
+	EndLine   int
+	Lines     []string
+}
+
+// FixOptions are the settings of CISAnalyzer.Fix.
+type FixOptions struct {
+	// Target is the stage analyzed as the shipped image, empty for the last one.
+	Target string
+	// PinImage returns image pinned to a version tag or digest, or an empty
+	// string when it cannot tell. Base images are left alone when nil.
+	PinImage func(image string) string
+	// Baseline holds accepted findings, which are not fixed.
+	Baseline *Baseline
+}
+
+// Fixer is implemented by rules that can rewrite a Dockerfile to resolve
+// their own failures. Fix returns nil when result has no safe rewrite.
+type Fixer interface {
+	Fix(target *Target, result CISResult, options FixOptions) *Edit
+}
+
+// Fix rewrites df to resolve the failures of the rules that implement
+// Fixer and returns the fixed source with the edits applied, in order.
+// Suppressed failures are left alone. df is analyzed again after every
+// round, so edits that touch the same lines are applied one at a time.
+func (a *CISAnalyzer) Fix(df *dockerfile.Dockerfile, options FixOptions) (string, []Edit, error) {
+	applied := []Edit{}
+	for pass := 0; pass < maxFixPasses; pass++ {
+		results, err := a.Analyze(df, options.Target)
+		if err != nil {
+			return "", nil, err
+		}
+		if options.Baseline != nil {
+			options.Baseline.Apply(results)
+		}
+
+		target, err := newAnalysisTarget(df, options.Target)
+		if err != nil {
+			return "", nil, err
+		}
+		edits := disjointEdits(a.fixes(target, results, options))
+		if len(edits) == 0 {
+			break
+		}
+		applied = append(applied, edits...)
+
+		path := df.Path
+		df, err = dockerfile.ParseString(strings.Join(applyEdits(df.Lines, edits), "\n"))
+		if err != nil {
+			return "", nil, err
+		}
+		df.Path = path
+	}
+	return strings.Join(df.Lines, "\n"), applied, nil
+}
+
+// fixes collects the edits offered for the failures of results.
+func (a *CISAnalyzer) fixes(target *Target, results []CISResult, options FixOptions) []Edit {
+	fixers := map[string]Fixer{}
+	for _, rule := range a.rules {
+		if fixer, ok := rule.(Fixer); ok {
+			fixers[rule.ID()] = fixer
+		}
+	}
+
+	edits := []Edit{}
+	for _, r := range results {
+		fixer, ok := fixers[r.RuleID]
+		if !ok || !r.Failed() {
+			continue
+		}
+		if edit := fixer.Fix(target, r, options); edit != nil {
+			edits = append(edits, *edit)
+		}
+	}
+	return edits
+}
+
+// disjointEdits sorts edits by line and drops those overlapping an earlier
+// one. The dropped ones are offered again on the next round.
+func disjointEdits(edits []Edit) []Edit {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].StartLine < edits[j].StartLine })
+
+	kept := []Edit{}
+	last := 0
+	for _, edit := range edits {
+		if edit.StartLine <= last {
+			continue
+		}
+		kept = append(kept, edit)
+		last = max(edit.StartLine, edit.EndLine)
+	}
+	return kept
+}
+
+// applyEdits applies disjoint edits sorted by line to lines.
+func applyEdits(lines []string, edits []Edit) []string {
+	result := append([]string{}, lines...)
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		tail := append([]string{}, result[edit.EndLine:]...)
+		result = append(append(result[:edit.StartLine-1], edit.Lines...), tail...)
+	}
+	return result
+}
+
+// Fix pins the base image with options.PinImage. Images given through ARG
+// are left alone.
+func (r ExplicitTagRule) Fix(target *Target, result CISResult, options FixOptions) *Edit {
+	stage := target.Dockerfile.Stage(result.Stage)
+	if options.PinImage == nil || stage == nil || result.Location == nil || fromImage(stage) != stage.BaseImage {
+		return nil
+	}
+
+	pinned := options.PinImage(stage.BaseImage)
+	if pinned == "" || !hasExplicitTag(pinned) {
+		return nil
+	}
+
+	loc := result.Location
+	line := target.Dockerfile.Lines[loc.StartLine-1]
+	return &Edit{
+		RuleID:    r.ID(),
+		Summary:   "Pinned " + stage.BaseImage + " to " + pinned,
+		StartLine: loc.StartLine,
+		EndLine:   loc.StartLine,
+		Lines:     []string{line[:loc.StartColumn-1] + pinned + line[loc.EndColumn-1:]},
+	}
+}
+
+// Fix appends a USER instruction to the final stage, switching to a user
+// created by the stage or to nobody.
+func (r NoRootUserRule) Fix(target *Target, result CISResult, options FixOptions) *Edit {
+	if target.Final == nil {
+		return nil
+	}
+
+	user := fallbackUser
+	for _, stage := range target.Dockerfile.Lineage(target.Final) {
+		if created := createdUser(stage); created != "" {
+			user = created
+			break
+		}
+	}
+
+	last := target.Final.From
+	if len(target.Final.Instructions) > 0 {
+		last = target.Final.Instructions[len(target.Final.Instructions)-1]
+	}
+	return &Edit{
+		RuleID:    r.ID(),
+		Summary:   "Switched the final stage to the non-root user " + user,
+		StartLine: last.EndLine + 1,
+		EndLine:   last.EndLine,
+		Lines:     []string{"USER " + user},
+	}
+}
+
+// Fix adds --no-cache to apk add, or removes the apt lists at the end of
+// the RUN that installs packages.
+func (r CleanCacheRule) Fix(target *Target, result CISResult, options FixOptions) *Edit {
+	df := target.Dockerfile
+	run := instructionAt(df, result.Location)
+	if run == nil || run.JSONForm || len(run.Heredocs) > 0 {
+		return nil
+	}
+
+	lines := append([]string{}, df.Lines[run.StartLine-1:run.EndLine]...)
+	script := strings.ToLower(runScript(run))
+	switch {
+	case strings.Contains(script, "apk add"):
+		for i, line := range lines {
+			if col := strings.Index(line, "apk add"); col >= 0 {
+				lines[i] = line[:col] + "apk add --no-cache" + line[col+len("apk add"):]
+				return &Edit{RuleID: r.ID(), Summary: "Added --no-cache to apk add", StartLine: run.StartLine, EndLine: run.EndLine, Lines: lines}
+			}
+		}
+		return nil
+	case strings.Contains(script, "apt-get install") || strings.Contains(script, "apt install"):
+		if endsInComment(df, run) {
+			return nil
+		}
+		lines = continueLine(df, lines)
+		lines = append(lines, "    && rm -rf /var/lib/apt/lists/*")
+		return &Edit{RuleID: r.ID(), Summary: "Removed the apt package lists after installing", StartLine: run.StartLine, EndLine: run.EndLine, Lines: lines}
+	}
+	return nil
+}
+
+// Fix merges the RUN instructions that follow each other from the one
+// before the reported RUN onwards. RUNs with flags, exec form,
+// here-documents or commands that change the shell state (cd, export...)
+// are not merged, as that would change what the later commands see, and
+// neither is a RUN ending in a shell comment, which would swallow the
+// commands appended to it.
+func (r CombinedRunCommandRule) Fix(target *Target, result CISResult, options FixOptions) *Edit {
+	df := target.Dockerfile
+	stage := df.Stage(result.Stage)
+	reported := instructionAt(df, result.Location)
+	if stage == nil || reported == nil {
+		return nil
+	}
+
+	start := -1
+	for i, inst := range stage.Instructions {
+		if inst == reported && i > 0 {
+			start = i - 1
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	chain := []*dockerfile.Instruction{stage.Instructions[start]}
+	for _, next := range stage.Instructions[start+1:] {
+		if !canMergeRuns(df, chain[len(chain)-1], next) {
+			break
+		}
+		chain = append(chain, next)
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+
+	first, last := chain[0], chain[len(chain)-1]
+	lines := append([]string{}, df.Lines[first.StartLine-1:first.EndLine]...)
+	for _, next := range chain[1:] {
+		lines = continueLine(df, lines)
+		_, command := splitKeyword(df.Lines[next.StartLine-1])
+		lines = append(lines, "    && "+command)
+		lines = append(lines, df.Lines[next.StartLine:next.EndLine]...)
+	}
+	return &Edit{
+		RuleID:    r.ID(),
+		Summary:   fmt.Sprintf("Merged %d RUN instructions in stage %s", len(chain), stage.Label()),
+		StartLine: first.StartLine,
+		EndLine:   last.EndLine,
+		Lines:     lines,
+	}
+}
+
+// canMergeRuns reports whether next can be appended to prev with &&.
+func canMergeRuns(df *dockerfile.Dockerfile, prev, next *dockerfile.Instruction) bool {
+	for _, inst := range []*dockerfile.Instruction{prev, next} {
+		if !inst.Is("RUN") || inst.JSONForm || len(inst.Flags) > 0 || len(inst.Heredocs) > 0 {
+			return false
+		}
+	}
+	if next.StartLine != prev.EndLine+1 || endsInComment(df, prev) {
+		return false
+	}
+	return !shellStatePattern.MatchString(runScript(prev))
+}
+
+// Fix turns an ADD of local files into a COPY. ADDs that also fetch URLs or
+// extract archives are left alone.
+func (r CopyInsteadOfAddRule) Fix(target *Target, result CISResult, options FixOptions) *Edit {
+	df := target.Dockerfile
+	add := instructionAt(df, result.Location)
+	if add == nil || !add.Is("ADD") || len(localAddSources(add)) != len(add.Args)-1 {
+		return nil
+	}
+	for _, flag := range []string{"checksum", "keep-git-dir", "unpack"} {
+		if _, ok := add.Flag(flag); ok {
+			return nil
+		}
+	}
+
+	line := df.Lines[add.StartLine-1]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	_, rest := splitKeyword(line)
+	return &Edit{
+		RuleID:    r.ID(),
+		Summary:   fmt.Sprintf("Replaced ADD with COPY on line %d", add.StartLine),
+		StartLine: add.StartLine,
+		EndLine:   add.StartLine,
+		Lines:     []string{indent + "COPY " + rest},
+	}
+}
+
+// instructionAt returns the instruction covering the first line of loc.
+func instructionAt(df *dockerfile.Dockerfile, loc *Location) *dockerfile.Instruction {
+	if loc == nil || loc.StartLine == 0 {
+		return nil
+	}
+	for _, inst := range df.Instructions {
+		if inst.StartLine <= loc.StartLine && loc.StartLine <= inst.EndLine {
+			return inst
+		}
+	}
+	return nil
+}
+
+// continueLine ends the last of lines with the escape token so that another
+// line can follow.
+func continueLine(df *dockerfile.Dockerfile, lines []string) []string {
+	last := len(lines) - 1
+	lines[last] = strings.TrimRight(lines[last], " \t") + " " + string(df.EscapeToken)
+	return lines
+}
+
+// endsInComment reports whether the last line of inst ends in a shell
+// comment: a # outside quotes that starts a word.
+func endsInComment(df *dockerfile.Dockerfile, inst *dockerfile.Instruction) bool {
+	line := df.Lines[inst.EndLine-1]
+	var quote rune
+	previous := ' '
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (previous == ' ' || previous == '\t'):
+			return true
+		}
+		previous = c
+	}
+	return false
+}
+
+// splitKeyword splits an instruction line into its keyword and the rest.
+func splitKeyword(line string) (string, string) {
+	fields := strings.TrimLeft(line, " \t")
+	keyword, rest, _ := strings.Cut(fields, " ")
+	return keyword, strings.TrimLeft(rest, " \t")
+}
+
+// createdUser returns the user added by useradd or adduser in a RUN of
+// stage, or an empty string.
+func createdUser(stage *dockerfile.Stage) string {
+	user := ""
+	for _, run := range stage.Find("RUN") {
+		for _, command := range shellCommands(runScript(run)) {
+			fields := strings.Fields(command)
+			if len(fields) < 2 || !userCreatePattern.MatchString(fields[0]) {
+				continue
+			}
+			name := fields[len(fields)-1]
+			if !strings.HasPrefix(name, "-") && name != "root" {
+				user = name
+			}
+		}
+	}
+	return user
+}
+
+// shellCommands splits a shell script on its command separators.
+func shellCommands(script string) []string {
+	return commandSeparatorPattern.Split(script, -1)
+}
@@ -0,0 +1,139 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+func fix(t *testing.T, content string, options FixOptions) (string, []Edit) {
+	t.Helper()
+	df, err := dockerfile.ParseString(content)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	fixed, edits, err := NewCISAnalyzer().Fix(df, options)
+	if err != nil {
+		t.Fatalf("unexpected fix error: %v", err)
+	}
+	return fixed, edits
+}
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		options  FixOptions
+		expected string
+	}{
+		{
+			name:     "Pin base image",
+			content:  "FROM node AS build\nFROM alpine:3.20\nUSER app\n",
+			options:  FixOptions{PinImage: func(image string) string { return image + ":20.11.1" }},
+			expected: "FROM node:20.11.1 AS build\nFROM alpine:3.20\nUSER app\n",
+		},
+		{
+			name:     "Base image left alone without a resolver",
+			content:  "FROM node:latest\nUSER node\n",
+			expected: "FROM node:latest\nUSER node\n",
+		},
+		{
+			name:     "Append USER with the created user",
+			content:  "FROM alpine:3.20\nRUN adduser -S -G app app\nCMD [\"app\"]\n",
+			expected: "FROM alpine:3.20\nRUN adduser -S -G app app\nCMD [\"app\"]\nUSER app\n",
+		},
+		{
+			name:     "Append USER nobody to the target stage",
+			content:  "FROM alpine:3.20 AS runtime\nCMD [\"app\"]\nFROM alpine:3.20 AS debug\nUSER app\n",
+			options:  FixOptions{Target: "runtime"},
+			expected: "FROM alpine:3.20 AS runtime\nCMD [\"app\"]\nUSER 65534:65534\nFROM alpine:3.20 AS debug\nUSER app\n",
+		},
+		{
+			name:     "Add --no-cache to apk",
+			content:  "FROM alpine:3.20\nRUN apk add curl\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN apk add --no-cache curl\nUSER app\n",
+		},
+		{
+			name:     "Remove apt lists",
+			content:  "FROM debian:12\nRUN apt-get update && \\\n    apt-get install -y curl\nUSER app\n",
+			expected: "FROM debian:12\nRUN apt-get update && \\\n    apt-get install -y curl \\\n    && rm -rf /var/lib/apt/lists/*\nUSER app\n",
+		},
+		{
+			name:     "Merge consecutive RUNs",
+			content:  "FROM alpine:3.20\nRUN echo one\nRUN echo two\nRUN echo three\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN echo one \\\n    && echo two \\\n    && echo three\nUSER app\n",
+		},
+		{
+			name:     "Do not merge after cd",
+			content:  "FROM alpine:3.20\nRUN cd /tmp\nRUN make\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN cd /tmp\nRUN make\nUSER app\n",
+		},
+		{
+			name:     "Do not merge RUNs with flags",
+			content:  "FROM alpine:3.20\nRUN echo one\nRUN --mount=type=cache,target=/root/.cache make\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN echo one\nRUN --mount=type=cache,target=/root/.cache make\nUSER app\n",
+		},
+		{
+			name:     "Do not merge after a trailing comment",
+			content:  "FROM alpine:3.20\nRUN apk update # refresh the index\nRUN apk add --no-cache curl\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN apk update # refresh the index\nRUN apk add --no-cache curl\nUSER app\n",
+		},
+		{
+			name:     "Merge when # is quoted or inside a word",
+			content:  "FROM alpine:3.20\nRUN echo '# not a comment' issue#4\nRUN echo two\nUSER app\n",
+			expected: "FROM alpine:3.20\nRUN echo '# not a comment' issue#4 \\\n    && echo two\nUSER app\n",
+		},
+		{
+			name:     "Keep apt lists after a trailing comment",
+			content:  "FROM debian:12\nRUN apt-get update && apt-get install -y curl # tools\nUSER app\n",
+			expected: "FROM debian:12\nRUN apt-get update && apt-get install -y curl # tools\nUSER app\n",
+		},
+		{
+			name:     "Custom escape token",
+			content:  "# escape=`\nFROM alpine:3.20\nRUN echo one\nRUN echo two\nUSER app\n",
+			expected: "# escape=`\nFROM alpine:3.20\nRUN echo one `\n    && echo two\nUSER app\n",
+		},
+		{
+			name:     "Overlapping fixes are applied in turn",
+			content:  "FROM alpine:3.20\nRUN apk update\nRUN apk add curl\n",
+			expected: "FROM alpine:3.20\nRUN apk update \\\n    && apk add --no-cache curl\nUSER 65534:65534\n",
+		},
+		{
+			name:     "Suppressed findings are not fixed",
+			content:  "FROM alpine:3.20\n# dockeryzer:ignore-file CIS-8.1 -- separate layers for caching\nRUN echo one\nRUN echo two\nUSER app\n",
+			expected: "FROM alpine:3.20\n# dockeryzer:ignore-file CIS-8.1 -- separate layers for caching\nRUN echo one\nRUN echo two\nUSER app\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, _ := fix(t, tt.content, tt.options)
+			if fixed != tt.expected {
+				t.Errorf("Unexpected result:\n%s\nexpected:\n%s", fixed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFixResolvesFindings(t *testing.T) {
+	content := "FROM debian\nRUN apt-get update\nRUN apt-get install -y curl\n"
+	fixed, edits := fix(t, content, FixOptions{PinImage: func(string) string { return "debian:12@sha256:abc" }})
+	if len(edits) == 0 {
+		t.Fatalf("Expected edits for %q", content)
+	}
+
+	df, err := dockerfile.ParseString(fixed)
+	if err != nil {
+		t.Fatalf("fixed Dockerfile does not parse: %v\n%s", err, fixed)
+	}
+	results, err := NewCISAnalyzer().Analyze(df, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range results {
+		if !r.Passed && strings.Contains("CIS-1.2 CIS-4.1 CIS-5.1 CIS-8.1", r.RuleID) {
+			t.Errorf("%s still fails after fixing:\n%s", r.RuleID, fixed)
+		}
+	}
+}
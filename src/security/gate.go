@@ -0,0 +1,69 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severities of failed results, from least to most severe.
+const (
+	SeverityLow    = "LOW"
+	SeverityMedium = "MEDIUM"
+	SeverityHigh   = "HIGH"
+)
+
+var severityRanks = map[string]int{
+	SeverityLow:    1,
+	SeverityMedium: 2,
+	SeverityHigh:   3,
+}
+
+// ParseSeverity validates a severity given on the command line and returns
+// it upper-cased.
+func ParseSeverity(value string) (string, error) {
+	severity := strings.ToUpper(value)
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("unsupported severity %q (use low, medium or high)", value)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether severity is as severe as threshold or more.
+func AtLeast(severity string, threshold string) bool {
+	return severityRanks[strings.ToUpper(severity)] >= severityRanks[strings.ToUpper(threshold)]
+}
+
+// Gate holds the thresholds used to fail a CI pipeline on analysis results.
+type Gate struct {
+	// FailOn is the lowest severity that breaks the gate, empty to ignore severities.
+	FailOn string
+	// MinScore is the lowest acceptable Score, 0 to ignore the score.
+	MinScore int
+}
+
+// Check returns one reason per threshold broken by results, or nil when the
+// gate passes.
+func (g Gate) Check(results []CISResult) []string {
+	reasons := []string{}
+
+	if g.FailOn != "" {
+		failing := 0
+		for _, r := range results {
+			if r.Failed() && AtLeast(r.Severity, g.FailOn) {
+				failing++
+			}
+		}
+		if failing > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d finding(s) with severity %s or higher", failing, g.FailOn))
+		}
+	}
+
+	if score := Score(results); score < g.MinScore {
+		reasons = append(reasons, fmt.Sprintf("security score %d%% is below the minimum of %d%%", score, g.MinScore))
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	return reasons
+}
@@ -0,0 +1,42 @@
+package security
+
+import "testing"
+
+var gateResults = []CISResult{
+	{RuleID: "CIS-1.1", Passed: true},
+	{RuleID: "CIS-1.2", Passed: false, Severity: "MEDIUM"},
+	{RuleID: "CIS-4.6", Passed: false, Severity: "LOW"},
+	{RuleID: "CIS-6.1", Passed: true},
+}
+
+func TestParseSeverity(t *testing.T) {
+	if severity, err := ParseSeverity("high"); err != nil || severity != SeverityHigh {
+		t.Errorf("Expected HIGH, got %q (%v)", severity, err)
+	}
+	if _, err := ParseSeverity("critical"); err == nil {
+		t.Errorf("Expected critical to be rejected")
+	}
+}
+
+func TestGateCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		gate    Gate
+		failing int
+	}{
+		{name: "Empty gate", gate: Gate{}, failing: 0},
+		{name: "No finding reaches HIGH", gate: Gate{FailOn: SeverityHigh}, failing: 0},
+		{name: "MEDIUM finding breaks medium", gate: Gate{FailOn: SeverityMedium}, failing: 1},
+		{name: "Score equal to minimum", gate: Gate{MinScore: 50}, failing: 0},
+		{name: "Score below minimum", gate: Gate{MinScore: 51}, failing: 1},
+		{name: "Both thresholds broken", gate: Gate{FailOn: SeverityLow, MinScore: 80}, failing: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := tt.gate.Check(gateResults); len(reasons) != tt.failing {
+				t.Errorf("Expected %d reasons, got %v", tt.failing, reasons)
+			}
+		})
+	}
+}
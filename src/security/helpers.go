@@ -0,0 +1,186 @@
+package security
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+var packageInstallPattern = regexp.MustCompile(`\b(apt-get install|apt install|apk add|yum install|dnf install|microdnf install|zypper (install|in))\b`)
+var dependencyInstallPattern = regexp.MustCompile(`\b(npm (ci|install|i)|yarn( install)?|pnpm (install|i)|pip3? install|poetry install|bundle install|composer install|go mod download|cargo (build|fetch)|mvn|gradle)\b`)
+
+// imageReference is a FROM image split into its parts.
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func parseImageReference(image string) imageReference {
+	ref := imageReference{}
+
+	if name, digest, found := strings.Cut(image, "@"); found {
+		image, ref.Digest = name, digest
+	}
+
+	lastSlash := strings.LastIndex(image, "/")
+	if idx := strings.LastIndex(image, ":"); idx > lastSlash {
+		image, ref.Tag = image[:idx], image[idx+1:]
+	}
+
+	if first, rest, found := strings.Cut(image, "/"); found &&
+		(strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, image = first, rest
+	}
+	ref.Repository = image
+	return ref
+}
+
+// isOfficialImage reports whether image is a Docker Hub official image.
+func isOfficialImage(image string) bool {
+	if image == "scratch" {
+		return true
+	}
+	ref := parseImageReference(image)
+	if ref.Registry != "" && ref.Registry != "docker.io" && ref.Registry != "index.docker.io" {
+		return false
+	}
+	return !strings.Contains(ref.Repository, "/") || strings.HasPrefix(ref.Repository, "library/")
+}
+
+// isAllowedImage reports whether image comes from one of the allowed
+// registries or repository prefixes, e.g. "ghcr.io/acme" allows
+// "ghcr.io/acme/base:1.0" but not "ghcr.io/acme-fork/base:1.0".
+func isAllowedImage(image string, allowed []string) bool {
+	ref := parseImageReference(image)
+	name := ref.Repository
+	if ref.Registry != "" {
+		name = ref.Registry + "/" + ref.Repository
+	}
+
+	for _, prefix := range allowed {
+		prefix = strings.TrimSuffix(strings.ToLower(prefix), "/")
+		if prefix == "" {
+			continue
+		}
+		lower := strings.ToLower(name)
+		if lower == prefix || strings.HasPrefix(lower, prefix+"/") {
+			return true
+		}
+		if ref.Registry == "" && strings.HasPrefix("docker.io/"+lower, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExplicitTag reports whether image is pinned to a tag other than latest or to a digest.
+func hasExplicitTag(image string) bool {
+	ref := parseImageReference(image)
+	if ref.Digest != "" {
+		return true
+	}
+	return ref.Tag != "" && ref.Tag != "latest"
+}
+
+// runScript returns the shell command of a RUN instruction, here-documents included.
+func runScript(run *dockerfile.Instruction) string {
+	script := run.Value
+	if run.JSONForm {
+		script = strings.Join(run.Args, " ")
+	}
+	for _, heredoc := range run.Heredocs {
+		script += "\n" + heredoc.Content
+	}
+	return script
+}
+
+// contextDir guesses the build context as the directory holding the Dockerfile.
+func contextDir(df *dockerfile.Dockerfile) string {
+	if df.Path == "" {
+		return "."
+	}
+	return filepath.Dir(df.Path)
+}
+
+// copiesWholeContext reports whether a COPY/ADD copies the entire build context.
+func copiesWholeContext(inst *dockerfile.Instruction) bool {
+	if _, fromStage := inst.Flag("from"); fromStage {
+		return false
+	}
+	if len(inst.Args) < 2 {
+		return false
+	}
+	for _, src := range inst.Args[:len(inst.Args)-1] {
+		if src == "." || src == "./" || src == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// locate builds the Location of inst, highlighting the first occurrence of
+// needle or, when needle is empty or not found, the instruction's first line.
+func locate(df *dockerfile.Dockerfile, inst *dockerfile.Instruction, needle string) *Location {
+	if inst == nil {
+		return nil
+	}
+
+	location := &Location{File: df.Path, Instruction: inst.Original}
+
+	if needle != "" {
+		for line := inst.StartLine; line <= inst.EndLine && line <= len(df.Lines); line++ {
+			text := df.Lines[line-1]
+			col := strings.Index(text, needle)
+			if col < 0 {
+				col = strings.Index(strings.ToLower(text), strings.ToLower(needle))
+			}
+			if col >= 0 {
+				location.StartLine, location.EndLine = line, line
+				location.StartColumn = col + 1
+				location.EndColumn = col + 1 + len(needle)
+				location.Source = []string{text}
+				return location
+			}
+		}
+	}
+
+	text := df.Lines[inst.StartLine-1]
+	trimmed := strings.TrimRight(text, " \t\\`")
+	location.StartLine, location.EndLine = inst.StartLine, inst.StartLine
+	location.StartColumn = len(text) - len(strings.TrimLeft(text, " \t")) + 1
+	location.EndColumn = len(trimmed) + 1
+	location.Source = []string{text}
+	return location
+}
+
+// fromImage returns the image reference as written in the FROM instruction.
+func fromImage(stage *dockerfile.Stage) string {
+	if len(stage.From.Args) == 0 {
+		return ""
+	}
+	return stage.From.Args[0]
+}
+
+// imageStages returns the stages that start from an image rather than from
+// an earlier stage.
+func imageStages(df *dockerfile.Dockerfile) []*dockerfile.Stage {
+	stages := []*dockerfile.Stage{}
+	for _, stage := range df.Stages {
+		if df.Parent(stage) == nil {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+func isPackageInstall(script string) bool {
+	return packageInstallPattern.MatchString(strings.ToLower(script))
+}
+
+func isDependencyInstall(script string) bool {
+	return dependencyInstallPattern.MatchString(strings.ToLower(script))
+}
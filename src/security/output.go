@@ -1,26 +1,103 @@
 package security
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 func PrintCISResults(results []CISResult) {
-	fmt.Println("\nSecurity Analysis based on CIS Docker Benchmark:\n")
-
-	score := 0
+	fmt.Print("\nSecurity Analysis based on CIS Docker Benchmark:\n\n")
 
 	for _, r := range results {
 		status := "PASS"
-		if !r.Passed {
+		if r.Failed() {
 			status = "FAIL"
-		} else {
-			score++
+		} else if r.Suppression != nil {
+			status = "SKIP"
+		}
+
+		fmt.Printf("[%s] %s - %s", status, r.RuleID, r.Description)
+		if r.Stage != "" {
+			fmt.Printf(" (stage %s)", r.Stage)
 		}
+		fmt.Println()
+		if r.Suppression != nil {
+			fmt.Printf("  Suppressed (%s): %s\n", r.Suppression.Kind, r.Suppression.Reason)
+		}
+		if r.Failed() {
+			fmt.Printf("  Severity: %s\n  Issue: %s\n", r.Severity, r.Message)
+			printExcerpt(r.Location)
+			if r.Remediation != "" {
+				fmt.Printf("  Fix: %s\n", r.Remediation)
+			}
+			fmt.Println()
+		}
+	}
+
+	printScoreCard(Evaluate(results))
+}
+
+// printScoreCard prints the score with its breakdown by category and the
+// three fixes that would raise it the most.
+func printScoreCard(card ScoreCard) {
+	fmt.Printf("Security Score: %d%% (grade %s)\n", card.Score, card.Grade)
+	for _, category := range card.Categories {
+		fmt.Printf("  %-20s %3d%%", CategoryTitle(category.Category), category.Score)
+		if category.Failing > 0 {
+			fmt.Printf("  (%d of %d rules failing)", category.Failing, category.Rules)
+		}
+		fmt.Println()
+	}
 
-		fmt.Printf("[%s] %s - %s\n", status, r.RuleID, r.Description)
-		if !r.Passed {
-			fmt.Printf("  Severity: %s\n  Issue: %s\n\n", r.Severity, r.Message)
+	if len(card.Improvements) == 0 {
+		return
+	}
+	fmt.Println("Biggest improvements:")
+	for _, improvement := range card.Improvements[:min(3, len(card.Improvements))] {
+		fmt.Printf("  +%d points: fix %s - %s\n", improvement.Points, improvement.RuleID, improvement.Description)
+	}
+}
+
+// printExcerpt prints the location compiler-style, with a caret under the
+// highlighted range:
+//
+//	--> Dockerfile:5:6
+//	  |
+//	5 | FROM alpine:latest
+//	  |      ^^^^^^^^^^^^^
+func printExcerpt(location *Location) {
+	if location == nil || location.File == "" && location.StartLine == 0 {
+		return
+	}
+
+	if location.StartLine == 0 {
+		fmt.Printf("  --> %s\n", location.File)
+		return
+	}
+
+	file := location.File
+	if file == "" {
+		file = "Dockerfile"
+	}
+	fmt.Printf("  --> %s:%d:%d\n", file, location.StartLine, location.StartColumn)
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(location.EndLine)))
+	fmt.Printf("  %s |\n", gutter)
+	for i, line := range location.Source {
+		number := location.StartLine + i
+		fmt.Printf("  %*d | %s\n", len(gutter), number, expandTabs(line))
+		if number == location.StartLine {
+			width := location.EndColumn - location.StartColumn
+			if width < 1 {
+				width = 1
+			}
+			padding := expandTabs(line[:min(location.StartColumn-1, len(line))])
+			fmt.Printf("  %s | %s%s\n", gutter, strings.Repeat(" ", len(padding)), strings.Repeat("^", width))
 		}
 	}
+}
 
-	percent := (score * 100) / len(results)
-	fmt.Printf("Security Score: %d%%\n", percent)
+func expandTabs(line string) string {
+	return strings.ReplaceAll(line, "\t", "    ")
 }
@@ -0,0 +1,313 @@
+package security
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy rule targets.
+const (
+	PolicyTargetDockerfile = "dockerfile"
+	PolicyTargetImage      = "image"
+)
+
+var policyIDPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// PolicyFile is a file of user-defined rules:
+//
+//	rules:
+//	  - id: ACME-1
+//	    description: Base images must come from the internal mirror
+//	    severity: high
+//	    forEach: stages
+//	    deny: '!item.baseImage.startsWith("registry.acme.com/")'
+//	    message: Base image is not mirrored internally
+//
+// deny is a CEL expression that evaluates to true when the rule is broken.
+// With forEach, which must evaluate to a list, deny is evaluated once per
+// element, bound to item.
+type PolicyFile struct {
+	Rules []PolicyDefinition `yaml:"rules"`
+}
+
+// PolicyDefinition is a single user-defined rule as written in a policy file.
+type PolicyDefinition struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	// Target is what the rule inspects: dockerfile (the default) or image.
+	Target   string `yaml:"target"`
+	Severity string `yaml:"severity"`
+	// Category groups the rule in the score breakdown: base-image,
+	// user-privileges, secrets, build-hygiene or runtime. Dockerfile rules default to
+	// build-hygiene and image rules to runtime.
+	Category    string `yaml:"category"`
+	ForEach     string `yaml:"forEach"`
+	Deny        string `yaml:"deny"`
+	Message     string `yaml:"message"`
+	Remediation string `yaml:"remediation"`
+}
+
+// PolicyRule is a compiled user-defined rule. It runs as a CISRule against
+// Dockerfiles or as an ImageRule against image inspect data, depending on
+// its target.
+type PolicyRule struct {
+	Definition PolicyDefinition
+	// Source is the file the rule was loaded from.
+	Source  string
+	forEach cel.Program
+	deny    cel.Program
+}
+
+var policyEnv = mustPolicyEnv()
+
+func mustPolicyEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("dockerfile", cel.DynType),
+		cel.Variable("stages", cel.ListType(cel.DynType)),
+		cel.Variable("final", cel.DynType),
+		cel.Variable("image", cel.DynType),
+		cel.Variable("item", cel.DynType),
+		ext.Strings(),
+		ext.Lists(),
+		ext.Sets(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+// LoadPolicyRules compiles the rules of a policy file, or of every .yaml and
+// .yml file in a directory.
+func LoadPolicyRules(path string) ([]*PolicyRule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = []string{}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	rules := []*PolicyRule{}
+	for _, file := range files {
+		loaded, err := loadPolicyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, loaded...)
+	}
+	return rules, nil
+}
+
+func loadPolicyFile(path string) ([]*PolicyRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := PolicyFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	rules := []*PolicyRule{}
+	for _, definition := range policy.Rules {
+		rule, err := CompilePolicyRule(definition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rule.Source = path
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CompilePolicyRule validates a rule definition and compiles its expressions.
+func CompilePolicyRule(definition PolicyDefinition) (*PolicyRule, error) {
+	if !policyIDPattern.MatchString(definition.ID) {
+		return nil, fmt.Errorf("invalid rule id %q", definition.ID)
+	}
+	if strings.HasPrefix(strings.ToUpper(definition.ID), "CIS-") || strings.HasPrefix(strings.ToUpper(definition.ID), "DKZ-") {
+		return nil, fmt.Errorf("rule %s: the CIS- and DKZ- prefixes are reserved for built-in rules", definition.ID)
+	}
+
+	if definition.Target == "" {
+		definition.Target = PolicyTargetDockerfile
+	}
+	if definition.Target != PolicyTargetDockerfile && definition.Target != PolicyTargetImage {
+		return nil, fmt.Errorf("rule %s: unsupported target %q (use dockerfile or image)", definition.ID, definition.Target)
+	}
+	if definition.Severity == "" {
+		definition.Severity = SeverityMedium
+	}
+	severity, err := ParseSeverity(definition.Severity)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", definition.ID, err)
+	}
+	definition.Severity = severity
+	if definition.Category == "" {
+		definition.Category = CategoryBuildHygiene
+		if definition.Target == PolicyTargetImage {
+			definition.Category = CategoryRuntime
+		}
+	}
+	if _, ok := categoryTitles[definition.Category]; !ok {
+		categories := slices.Sorted(maps.Keys(categoryTitles))
+		return nil, fmt.Errorf("rule %s: unsupported category %q (use %s or %s)", definition.ID, definition.Category,
+			strings.Join(categories[:len(categories)-1], ", "), categories[len(categories)-1])
+	}
+	if definition.Description == "" {
+		definition.Description = definition.ID
+	}
+	if definition.Message == "" {
+		definition.Message = definition.Description
+	}
+
+	if definition.Deny == "" {
+		return nil, fmt.Errorf("rule %s: deny expression is required", definition.ID)
+	}
+
+	rule := &PolicyRule{Definition: definition}
+	if rule.deny, err = compileExpression(definition.Deny, cel.BoolType); err != nil {
+		return nil, fmt.Errorf("rule %s: deny: %w", definition.ID, err)
+	}
+	if definition.ForEach != "" {
+		if rule.forEach, err = compileExpression(definition.ForEach, cel.ListType(cel.DynType)); err != nil {
+			return nil, fmt.Errorf("rule %s: forEach: %w", definition.ID, err)
+		}
+	}
+	return rule, nil
+}
+
+func compileExpression(expression string, expected *cel.Type) (cel.Program, error) {
+	ast, issues := policyEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	output := ast.OutputType()
+	if !output.IsAssignableType(expected) && !output.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression returns %s, expected %s", output, expected)
+	}
+	return policyEnv.Program(ast)
+}
+
+func (r *PolicyRule) ID() string { return r.Definition.ID }
+
+// Check evaluates a dockerfile rule. Image rules return no results.
+func (r *PolicyRule) Check(target *Target) []CISResult {
+	if r.Definition.Target != PolicyTargetDockerfile {
+		return nil
+	}
+	return r.evaluate(dockerfileInput(target), func(item interface{}) (string, *Location) {
+		return locateItem(target, item)
+	})
+}
+
+// CheckImage evaluates an image rule. Dockerfile rules return no results.
+func (r *PolicyRule) CheckImage(target *ImageTarget) []CISResult {
+	if r.Definition.Target != PolicyTargetImage {
+		return nil
+	}
+	return r.evaluate(imageInput(target.Name, target.Inspect), func(interface{}) (string, *Location) {
+		return "", nil
+	})
+}
+
+// evaluate runs deny once, or once per forEach element, and turns every
+// true into a failed result. locateItem maps an element to its stage and
+// location.
+func (r *PolicyRule) evaluate(input map[string]interface{}, locateItem func(interface{}) (string, *Location)) []CISResult {
+	items := []ref.Val{types.NullValue}
+	if r.forEach != nil {
+		value, _, err := r.forEach.Eval(input)
+		if err != nil {
+			return []CISResult{r.evaluationError(err)}
+		}
+		list, ok := value.(traits.Lister)
+		if !ok {
+			return []CISResult{r.evaluationError(fmt.Errorf("forEach returned %s, expected a list", value.Type()))}
+		}
+		items = []ref.Val{}
+		for it := list.Iterator(); it.HasNext() == types.True; {
+			items = append(items, it.Next())
+		}
+	}
+
+	results := []CISResult{}
+	for _, item := range items {
+		input["item"] = item
+		value, _, err := r.deny.Eval(input)
+		if err != nil {
+			results = append(results, r.evaluationError(err))
+			continue
+		}
+		if denied, ok := value.Value().(bool); !ok || !denied {
+			continue
+		}
+
+		stage, location := locateItem(item.Value())
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: r.Definition.Description,
+			Passed:      false,
+			Severity:    r.Definition.Severity,
+			Message:     r.Definition.Message,
+			Stage:       stage,
+			Location:    location,
+			Category:    r.Definition.Category,
+			Remediation: r.Definition.Remediation,
+		})
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{
+			RuleID:      r.ID(),
+			Description: r.Definition.Description,
+			Passed:      true,
+			Severity:    r.Definition.Severity,
+			Category:    r.Definition.Category,
+		}}
+	}
+	return results
+}
+
+// evaluationError reports a rule that could not be evaluated as a failure,
+// so a broken policy never passes silently.
+func (r *PolicyRule) evaluationError(err error) CISResult {
+	return CISResult{
+		RuleID:      r.ID(),
+		Description: r.Definition.Description,
+		Passed:      false,
+		Severity:    r.Definition.Severity,
+		Message:     "Policy rule could not be evaluated: " + err.Error(),
+		Location:    &Location{File: r.Source},
+		Category:    r.Definition.Category,
+		Remediation: "Fix the expressions of rule " + r.ID() + " in " + r.Source,
+	}
+}
@@ -0,0 +1,306 @@
+package security
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+// Docker defaults for the HEALTHCHECK options that are not given.
+const (
+	defaultHealthcheckInterval = 30 * time.Second
+	defaultHealthcheckTimeout  = 30 * time.Second
+	defaultHealthcheckRetries  = 3
+)
+
+// dockerfileInput builds the variables visible to dockerfile policy rules:
+//
+//	dockerfile  {path, directives, instructions, metaArgs, stages}
+//	stages      the list of stages, see stageInput
+//	final       the stage analyzed as the shipped image
+func dockerfileInput(target *Target) map[string]interface{} {
+	df := target.Dockerfile
+
+	stages := []interface{}{}
+	for _, stage := range df.Stages {
+		stages = append(stages, stageInput(df, stage))
+	}
+
+	var final interface{}
+	if target.Final != nil {
+		final = stages[target.Final.Index]
+	}
+
+	directives := map[string]interface{}{}
+	for key, value := range df.Directives {
+		directives[key] = value
+	}
+
+	return map[string]interface{}{
+		"dockerfile": map[string]interface{}{
+			"path":         df.Path,
+			"directives":   directives,
+			"instructions": instructionsInput(df.Instructions),
+			"metaArgs":     instructionsInput(df.MetaArgs),
+			"stages":       stages,
+		},
+		"stages": stages,
+		"final":  final,
+		"image":  nil,
+	}
+}
+
+// stageInput describes a stage. labels, env, user, exposedPorts and
+// healthcheck include what the stage inherits from earlier stages.
+func stageInput(df *dockerfile.Dockerfile, stage *dockerfile.Stage) map[string]interface{} {
+	lineage := df.Lineage(stage)
+
+	labels := map[string]interface{}{}
+	env := map[string]interface{}{}
+	ports := []interface{}{}
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for _, inst := range lineage[i].Instructions {
+			switch {
+			case inst.Is("LABEL"):
+				for _, pair := range inst.KeyValues() {
+					labels[pair.Key] = pair.Value
+				}
+			case inst.Is("ENV"):
+				for _, pair := range inst.KeyValues() {
+					env[pair.Key] = pair.Value
+				}
+			case inst.Is("EXPOSE"):
+				for _, port := range inst.Args {
+					ports = append(ports, port)
+				}
+			}
+		}
+	}
+
+	user := ""
+	if inst := df.LastInLineage(stage, "USER"); inst != nil && len(inst.Args) > 0 {
+		user = inst.Args[0]
+	}
+
+	var healthcheck interface{}
+	if inst := df.LastInLineage(stage, "HEALTHCHECK"); inst != nil {
+		healthcheck = dockerfileHealthcheck(inst)
+	}
+
+	parent := ""
+	if p := df.Parent(stage); p != nil {
+		parent = p.Label()
+	}
+
+	return map[string]interface{}{
+		"index":        int64(stage.Index),
+		"name":         stage.Name,
+		"label":        stage.Label(),
+		"baseImage":    stage.BaseImage,
+		"parent":       parent,
+		"platform":     stage.Platform,
+		"from":         instructionInput(stage.From),
+		"instructions": instructionsInput(stage.Instructions),
+		"labels":       labels,
+		"env":          env,
+		"user":         user,
+		"exposedPorts": ports,
+		"healthcheck":  healthcheck,
+	}
+}
+
+func instructionsInput(instructions []*dockerfile.Instruction) []interface{} {
+	list := []interface{}{}
+	for _, inst := range instructions {
+		list = append(list, instructionInput(inst))
+	}
+	return list
+}
+
+func instructionInput(inst *dockerfile.Instruction) map[string]interface{} {
+	flags := map[string]interface{}{}
+	for _, flag := range inst.Flags {
+		name, value, _ := strings.Cut(strings.TrimPrefix(flag, "--"), "=")
+		flags[name] = value
+	}
+
+	args := []interface{}{}
+	for _, arg := range inst.Args {
+		args = append(args, arg)
+	}
+
+	return map[string]interface{}{
+		"cmd":      inst.Cmd,
+		"flags":    flags,
+		"args":     args,
+		"value":    inst.Value,
+		"original": inst.Original,
+		"jsonForm": inst.JSONForm,
+		"stage":    int64(inst.Stage),
+		"line":     int64(inst.StartLine),
+		"endLine":  int64(inst.EndLine),
+	}
+}
+
+// dockerfileHealthcheck gives a HEALTHCHECK instruction the same shape as
+// the healthcheck of an image, with Docker's defaults filled in.
+func dockerfileHealthcheck(inst *dockerfile.Instruction) map[string]interface{} {
+	duration := func(flag string, fallback time.Duration) time.Duration {
+		value, ok := inst.Flag(flag)
+		if !ok {
+			return fallback
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fallback
+		}
+		return parsed
+	}
+
+	retries := int64(defaultHealthcheckRetries)
+	if value, ok := inst.Flag("retries"); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			retries = int64(n)
+		}
+	}
+
+	test := []interface{}{}
+	for _, arg := range inst.Args {
+		test = append(test, arg)
+	}
+
+	return map[string]interface{}{
+		"test":        test,
+		"disabled":    len(inst.Args) > 0 && strings.EqualFold(inst.Args[0], "NONE"),
+		"interval":    duration("interval", defaultHealthcheckInterval),
+		"timeout":     duration("timeout", defaultHealthcheckTimeout),
+		"startPeriod": duration("start-period", 0),
+		"retries":     retries,
+	}
+}
+
+// imageInput builds the variables visible to image policy rules:
+//
+//	image  {name, id, tags, size, layers, os, architecture, author, created,
+//	        user, env, labels, cmd, entrypoint, workingDir, exposedPorts,
+//	        volumes, healthcheck}
+func imageInput(name string, inspect image.InspectResponse) map[string]interface{} {
+	input := map[string]interface{}{
+		"name":         name,
+		"id":           inspect.ID,
+		"tags":         stringsInput(inspect.RepoTags),
+		"size":         inspect.Size,
+		"layers":       int64(len(inspect.RootFS.Layers)),
+		"os":           inspect.Os,
+		"architecture": inspect.Architecture,
+		"author":       inspect.Author,
+		"created":      inspect.Created,
+		"user":         "",
+		"env":          map[string]interface{}{},
+		"labels":       map[string]interface{}{},
+		"cmd":          []interface{}{},
+		"entrypoint":   []interface{}{},
+		"workingDir":   "",
+		"exposedPorts": []interface{}{},
+		"volumes":      []interface{}{},
+		"healthcheck":  nil,
+	}
+
+	variables := map[string]interface{}{"image": input, "dockerfile": nil, "stages": []interface{}{}, "final": nil}
+	config := inspect.Config
+	if config == nil {
+		return variables
+	}
+
+	env := map[string]interface{}{}
+	for _, pair := range config.Env {
+		key, value, _ := strings.Cut(pair, "=")
+		env[key] = value
+	}
+	labels := map[string]interface{}{}
+	for key, value := range config.Labels {
+		labels[key] = value
+	}
+
+	input["user"] = config.User
+	input["env"] = env
+	input["labels"] = labels
+	input["cmd"] = stringsInput(config.Cmd)
+	input["entrypoint"] = stringsInput(config.Entrypoint)
+	input["workingDir"] = config.WorkingDir
+	input["exposedPorts"] = sortedKeys(config.ExposedPorts)
+	input["volumes"] = sortedKeys(config.Volumes)
+
+	if hc := config.Healthcheck; hc != nil && len(hc.Test) > 0 {
+		interval, timeout, retries := hc.Interval, hc.Timeout, int64(hc.Retries)
+		if interval == 0 {
+			interval = defaultHealthcheckInterval
+		}
+		if timeout == 0 {
+			timeout = defaultHealthcheckTimeout
+		}
+		if retries == 0 {
+			retries = defaultHealthcheckRetries
+		}
+		input["healthcheck"] = map[string]interface{}{
+			"test":        stringsInput(hc.Test),
+			"disabled":    hc.Test[0] == "NONE",
+			"interval":    interval,
+			"timeout":     timeout,
+			"startPeriod": hc.StartPeriod,
+			"retries":     retries,
+		}
+	}
+	return variables
+}
+
+func stringsInput(values []string) []interface{} {
+	list := []interface{}{}
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+func sortedKeys[V any](set map[string]V) []interface{} {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return stringsInput(keys)
+}
+
+// locateItem maps a forEach element back to its stage and instruction.
+// Elements that are neither give a result about the file as a whole.
+func locateItem(target *Target, item interface{}) (string, *Location) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		if target.Final != nil {
+			return target.Final.Label(), locate(target.Dockerfile, target.Final.From, "")
+		}
+		return "", &Location{File: target.Dockerfile.Path}
+	}
+
+	df := target.Dockerfile
+	if line, ok := fields["line"].(int64); ok {
+		for _, inst := range df.Instructions {
+			if int64(inst.StartLine) == line {
+				stage := ""
+				if inst.Stage >= 0 {
+					stage = df.Stages[inst.Stage].Label()
+				}
+				return stage, locate(df, inst, "")
+			}
+		}
+	}
+	if index, ok := fields["index"].(int64); ok && int(index) < len(df.Stages) {
+		stage := df.Stages[index]
+		return stage.Label(), locate(df, stage.From, fromImage(stage))
+	}
+	return "", &Location{File: df.Path}
+}
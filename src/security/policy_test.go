@@ -0,0 +1,180 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	specs "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const policyDockerfile = `FROM centos:7 AS builder
+RUN make
+FROM alpine:3.19
+LABEL com.acme.team=payments
+HEALTHCHECK --interval=5m CMD wget -qO- http://localhost/health
+`
+
+func mustCompile(t *testing.T, definition PolicyDefinition) *PolicyRule {
+	t.Helper()
+	rule, err := CompilePolicyRule(definition)
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	return rule
+}
+
+func TestPolicyRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition PolicyDefinition
+		expected   bool
+	}{
+		{
+			name:       "Mandatory label present",
+			definition: PolicyDefinition{ID: "ACME-1", Deny: `!("com.acme.team" in final.labels)`},
+			expected:   true,
+		},
+		{
+			name:       "Mandatory label missing",
+			definition: PolicyDefinition{ID: "ACME-2", Deny: `!("com.acme.owner" in final.labels)`},
+			expected:   false,
+		},
+		{
+			name:       "Banned base image",
+			definition: PolicyDefinition{ID: "ACME-3", ForEach: "stages", Deny: `item.baseImage.startsWith("centos")`},
+			expected:   false,
+		},
+		{
+			name:       "Healthcheck interval too long",
+			definition: PolicyDefinition{ID: "ACME-4", Deny: `final.healthcheck == null || final.healthcheck.interval > duration("1m")`},
+			expected:   false,
+		},
+		{
+			name:       "Instructions filtered with forEach",
+			definition: PolicyDefinition{ID: "ACME-5", ForEach: `dockerfile.instructions.filter(i, i.cmd == "ADD")`, Deny: "true"},
+			expected:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := mustCompile(t, tt.definition).Check(newTarget(t, policyDockerfile, ""))
+			if passed(results) != tt.expected {
+				t.Errorf("Expected passed=%v, got %+v", tt.expected, results)
+			}
+		})
+	}
+}
+
+func TestPolicyRuleLocatesForEachItems(t *testing.T) {
+	rule := mustCompile(t, PolicyDefinition{
+		ID:       "ACME-BASE",
+		Severity: "high",
+		ForEach:  "stages",
+		Deny:     `item.baseImage.startsWith("centos")`,
+		Message:  "CentOS is end of life",
+	})
+
+	results := rule.Check(newTarget(t, policyDockerfile, ""))
+	if len(results) != 1 {
+		t.Fatalf("Expected one finding, got %+v", results)
+	}
+	r := results[0]
+	if r.Severity != SeverityHigh || r.Stage != "builder" || r.Location.StartLine != 1 || r.Message != "CentOS is end of life" {
+		t.Errorf("Unexpected finding: %+v (location %+v)", r, r.Location)
+	}
+}
+
+func TestPolicyRuleEvaluationErrorFails(t *testing.T) {
+	rule := mustCompile(t, PolicyDefinition{ID: "ACME-BROKEN", Deny: `final.labels["missing"] == "x"`})
+
+	results := rule.Check(newTarget(t, policyDockerfile, ""))
+	if passed(results) {
+		t.Errorf("A rule that cannot be evaluated must fail, got %+v", results)
+	}
+}
+
+func TestCompilePolicyRuleErrors(t *testing.T) {
+	definitions := map[string]PolicyDefinition{
+		"syntax error":       {ID: "ACME-1", Deny: "final.labels["},
+		"not a boolean":      {ID: "ACME-1", Deny: `"yes"`},
+		"missing deny":       {ID: "ACME-1"},
+		"reserved prefix":    {ID: "CIS-4.1", Deny: "false"},
+		"unknown target":     {ID: "ACME-1", Target: "registry", Deny: "false"},
+		"unknown severity":   {ID: "ACME-1", Severity: "blocker", Deny: "false"},
+		"unknown variable":   {ID: "ACME-1", Deny: "container.privileged"},
+		"forEach not a list": {ID: "ACME-1", ForEach: `"stages"`, Deny: "false"},
+	}
+
+	for name, definition := range definitions {
+		if _, err := CompilePolicyRule(definition); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+
+	_, err := CompilePolicyRule(PolicyDefinition{ID: "ACME-1", Category: "network", Deny: "false"})
+	expected := `rule ACME-1: unsupported category "network" (use base-image, build-hygiene, runtime, secrets, user-privileges or vulnerabilities)`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
+func TestImagePolicyRule(t *testing.T) {
+	rule := mustCompile(t, PolicyDefinition{
+		ID:     "ACME-HEALTH",
+		Target: PolicyTargetImage,
+		Deny:   `image.healthcheck == null || image.healthcheck.interval > duration("1m") || image.user == ""`,
+	})
+
+	inspect := image.InspectResponse{
+		Config: &specs.DockerOCIImageConfig{
+			ImageConfig: ocispec.ImageConfig{User: "app", Labels: map[string]string{"team": "payments"}},
+			DockerOCIImageConfigExt: specs.DockerOCIImageConfigExt{
+				Healthcheck: &specs.HealthcheckConfig{Test: []string{"CMD", "true"}, Interval: 10 * time.Second},
+			},
+		},
+	}
+	if results := rule.CheckImage(&ImageTarget{Name: "app:1.0", Inspect: inspect}); !passed(results) {
+		t.Errorf("Expected the image to pass, got %+v", results)
+	}
+
+	inspect.Config.Healthcheck = nil
+	if results := rule.CheckImage(&ImageTarget{Name: "app:1.0", Inspect: inspect}); passed(results) {
+		t.Errorf("Expected an image without HEALTHCHECK to fail, got %+v", results)
+	}
+
+	if results := rule.Check(newTarget(t, policyDockerfile, "")); results != nil {
+		t.Errorf("Image rules must not run against Dockerfiles, got %+v", results)
+	}
+}
+
+func TestLoadPolicyRulesFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	content := `rules:
+  - id: ACME-LABEL
+    description: Images must name their team
+    severity: low
+    deny: '!("com.acme.team" in final.labels)'
+  - id: ACME-IMAGE
+    target: image
+    deny: image.size > 500000000
+`
+	if err := os.WriteFile(filepath.Join(dir, "acme.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a policy"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rules, err := LoadPolicyRules(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Definition.Severity != SeverityLow || rules[1].Definition.Target != PolicyTargetImage {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+}
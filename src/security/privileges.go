@@ -0,0 +1,240 @@
+package security
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+var sudoInstallPattern = regexp.MustCompile(`\b(apt-get install|apt install|apk add|yum install|dnf install|microdnf install|zypper (install|in))\b[^;&|\n]*\s(sudo|doas)(\s|$)`)
+var worldWritablePattern = regexp.MustCompile(`\bchmod\s+(-\S+\s+)*(0?777|a\+rwx|ugo\+rwx|o\+w)\b`)
+var setuidPattern = regexp.MustCompile(`\bchmod\s+(-\S+\s+)*([ugoa]*\+[rwxt]*s[rwxt]*|0?[2467][0-7]{3})\b`)
+var setuidModePattern = regexp.MustCompile(`^0?[2467][0-7]{3}$|[ugoa]*\+[rwxt]*s`)
+
+// isRootUser reports whether the user of a USER instruction, with or without
+// a group, is root.
+func isRootUser(value string) bool {
+	user, _, _ := strings.Cut(value, ":")
+	return user == "root" || user == "0"
+}
+
+// finalInstructions returns the instructions with one of the commands that
+// end up in the final stage, including those of the stages it is built FROM.
+func finalInstructions(df *dockerfile.Dockerfile, final *dockerfile.Stage, cmds ...string) []*dockerfile.Instruction {
+	instructions := []*dockerfile.Instruction{}
+	lineage := df.Lineage(final)
+	for i := len(lineage) - 1; i >= 0; i-- {
+		instructions = append(instructions, lineage[i].Find(cmds...)...)
+	}
+	return instructions
+}
+
+// DKZ-SUDO Instalação de sudo
+type SudoInstallRule struct{}
+
+func (r SudoInstallRule) ID() string { return "DKZ-SUDO" }
+
+func (r SudoInstallRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not install sudo", Passed: true}}
+	}
+
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, run := range finalInstructions(df, target.Final, "RUN") {
+		match := sudoInstallPattern.FindStringSubmatch(runScript(run))
+		if match == nil {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Do not install sudo",
+			Passed:      false,
+			Severity:    SeverityMedium,
+			Message:     match[3] + " is installed in the final image",
+			Stage:       instructionStage(df, run),
+			Location:    locate(df, run, " "+match[3]),
+			Remediation: "Run the steps that need root before the USER instruction instead of granting sudo, or use gosu/su-exec in the entrypoint",
+		})
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not install sudo", Passed: true, Stage: target.Final.Label()}}
+	}
+	return results
+}
+
+// DKZ-CHMOD-777 Permissões de escrita para todos
+type WorldWritableRule struct{}
+
+func (r WorldWritableRule) ID() string { return "DKZ-CHMOD-777" }
+
+func (r WorldWritableRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not make files world-writable", Passed: true}}
+	}
+
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, run := range finalInstructions(df, target.Final, "RUN") {
+		match := worldWritablePattern.FindString(runScript(run))
+		if match == "" {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Do not make files world-writable",
+			Passed:      false,
+			Severity:    SeverityMedium,
+			Message:     "`" + match + "` lets any user in the container modify the files",
+			Stage:       instructionStage(df, run),
+			Location:    locate(df, run, match),
+			Remediation: "Give the files to the runtime user with chown (or COPY --chown) and keep the mode at 755/644",
+		})
+	}
+	for _, inst := range finalInstructions(df, target.Final, "COPY", "ADD") {
+		if mode, ok := inst.Flag("chmod"); ok && (mode == "777" || mode == "0777") {
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Do not make files world-writable",
+				Passed:      false,
+				Severity:    SeverityMedium,
+				Message:     inst.Cmd + " --chmod=" + mode + " lets any user in the container modify the files",
+				Stage:       instructionStage(df, inst),
+				Location:    locate(df, inst, "--chmod"),
+				Remediation: "Use --chown with the runtime user and a 755/644 mode",
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not make files world-writable", Passed: true, Stage: target.Final.Label()}}
+	}
+	return results
+}
+
+// DKZ-SETUID Bits setuid/setgid
+type SetuidRule struct{}
+
+func (r SetuidRule) ID() string { return "DKZ-SETUID" }
+
+func (r SetuidRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not add setuid or setgid bits", Passed: true}}
+	}
+
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, run := range finalInstructions(df, target.Final, "RUN") {
+		match := setuidPattern.FindString(runScript(run))
+		if match == "" {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Do not add setuid or setgid bits",
+			Passed:      false,
+			Severity:    SeverityHigh,
+			Message:     "`" + match + "` sets the setuid or setgid bit, which lets a user gain the file owner's privileges",
+			Stage:       instructionStage(df, run),
+			Location:    locate(df, run, match),
+			Remediation: "Drop the setuid/setgid bit and grant only the capability the program needs at run time (docker run --cap-add)",
+		})
+	}
+	for _, inst := range finalInstructions(df, target.Final, "COPY", "ADD") {
+		if mode, ok := inst.Flag("chmod"); ok && setuidModePattern.MatchString(mode) {
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Do not add setuid or setgid bits",
+				Passed:      false,
+				Severity:    SeverityHigh,
+				Message:     inst.Cmd + " --chmod=" + mode + " sets the setuid or setgid bit",
+				Stage:       instructionStage(df, inst),
+				Location:    locate(df, inst, "--chmod"),
+				Remediation: "Copy the file with a mode without the setuid/setgid bit, e.g. 755",
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not add setuid or setgid bits", Passed: true, Stage: target.Final.Label()}}
+	}
+	return results
+}
+
+// DKZ-COPY-CHOWN Arquivos copiados para o diretório de trabalho sem --chown
+type CopyChownRule struct{}
+
+func (r CopyChownRule) ID() string { return "DKZ-COPY-CHOWN" }
+
+// Check flags COPY and ADD into the WORKDIR of a final stage that runs as a
+// non-root user: without --chown the files stay owned by root, which usually
+// ends in a chmod 777 or a container that cannot write its own files.
+func (r CopyChownRule) Check(target *Target) []CISResult {
+	passed := []CISResult{{RuleID: r.ID(), Description: "Copy files with --chown for the runtime user", Passed: true}}
+	if target.Final == nil {
+		return passed
+	}
+	df := target.Dockerfile
+	user := df.LastInLineage(target.Final, "USER")
+	if user == nil || len(user.Args) == 0 || isRootUser(user.Args[0]) {
+		return passed
+	}
+
+	results := []CISResult{}
+	workdir := ""
+	if parent := df.Parent(target.Final); parent != nil {
+		if inherited := df.LastInLineage(parent, "WORKDIR"); inherited != nil {
+			workdir = workdirPath("", inherited)
+		}
+	}
+	for _, inst := range target.Final.Instructions {
+		if inst.Is("WORKDIR") {
+			workdir = workdirPath(workdir, inst)
+			continue
+		}
+		if !inst.Is("COPY", "ADD") || workdir == "" || workdir == "/" || len(inst.Args) < 2 {
+			continue
+		}
+		if _, ok := inst.Flag("chown"); ok {
+			continue
+		}
+		dest := inst.Args[len(inst.Args)-1]
+		if !path.IsAbs(dest) {
+			dest = path.Join(workdir, dest)
+		}
+		if dest != workdir && !strings.HasPrefix(dest, strings.TrimSuffix(workdir, "/")+"/") {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Copy files with --chown for the runtime user",
+			Passed:      false,
+			Severity:    SeverityLow,
+			Message:     inst.Cmd + " into " + workdir + " leaves the files owned by root while the container runs as " + user.Args[0],
+			Stage:       target.Final.Label(),
+			Location:    locate(df, inst, inst.Cmd),
+			Remediation: "Add --chown=" + user.Args[0] + " to the " + inst.Cmd + " if the application writes to these files",
+		})
+	}
+
+	if len(results) == 0 {
+		passed[0].Stage = target.Final.Label()
+		return passed
+	}
+	return results
+}
+
+// workdirPath resolves a WORKDIR instruction against the current directory.
+func workdirPath(current string, workdir *dockerfile.Instruction) string {
+	if len(workdir.Args) == 0 {
+		return current
+	}
+	dir := workdir.Args[0]
+	if !path.IsAbs(dir) {
+		dir = path.Join("/", current, dir)
+	}
+	return path.Clean(dir)
+}
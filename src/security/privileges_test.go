@@ -0,0 +1,149 @@
+package security
+
+import "testing"
+
+func TestPrivilegeRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     CISRule
+		content  string
+		expected bool
+	}{
+		{
+			name:     "USER root",
+			rule:     NoRootUserRule{},
+			content:  "FROM alpine:3.20\nUSER app\nRUN id\nUSER root\n",
+			expected: false,
+		},
+		{
+			name:     "USER 0 with a group",
+			rule:     NoRootUserRule{},
+			content:  "FROM alpine:3.20\nUSER 0:0\n",
+			expected: false,
+		},
+		{
+			name:     "Root only while building",
+			rule:     NoRootUserRule{},
+			content:  "FROM alpine:3.20\nUSER root\nRUN apk add --no-cache curl\nUSER 10001\n",
+			expected: true,
+		},
+		{
+			name:     "sudo installed",
+			rule:     SudoInstallRule{},
+			content:  "FROM debian:12\nRUN apt-get update && apt-get install -y curl sudo && rm -rf /var/lib/apt/lists/*\n",
+			expected: false,
+		},
+		{
+			name:     "sudo in a builder stage",
+			rule:     SudoInstallRule{},
+			content:  "FROM debian:12 AS build\nRUN apt-get install -y sudo\nFROM debian:12\nUSER app\n",
+			expected: true,
+		},
+		{
+			name:     "Recursive chmod 777",
+			rule:     WorldWritableRule{},
+			content:  "FROM alpine:3.20\nRUN mkdir /data && chmod -R 777 /data\n",
+			expected: false,
+		},
+		{
+			name:     "COPY --chmod=777",
+			rule:     WorldWritableRule{},
+			content:  "FROM alpine:3.20\nCOPY --chmod=777 run.sh /run.sh\n",
+			expected: false,
+		},
+		{
+			name:     "COPY --chmod=777 in a parent stage",
+			rule:     WorldWritableRule{},
+			content:  "FROM alpine:3.20 AS base\nCOPY --chmod=777 run.sh /run.sh\nFROM base\nUSER app\n",
+			expected: false,
+		},
+		{
+			name:     "COPY --chmod=777 in a stage copied from",
+			rule:     WorldWritableRule{},
+			content:  "FROM alpine:3.20 AS build\nCOPY --chmod=777 run.sh /run.sh\nFROM alpine:3.20\nCOPY --from=build /run.sh /run.sh\n",
+			expected: true,
+		},
+		{
+			name:     "COPY --chmod=4755 in a parent stage",
+			rule:     SetuidRule{},
+			content:  "FROM alpine:3.20 AS base\nCOPY --chmod=4755 helper /usr/local/bin/helper\nFROM base\n",
+			expected: false,
+		},
+		{
+			name:     "chmod 755",
+			rule:     WorldWritableRule{},
+			content:  "FROM alpine:3.20\nRUN chmod 755 /run.sh\n",
+			expected: true,
+		},
+		{
+			name:     "chmod u+s",
+			rule:     SetuidRule{},
+			content:  "FROM alpine:3.20\nRUN chmod u+s /usr/bin/ping\n",
+			expected: false,
+		},
+		{
+			name:     "Numeric setuid mode",
+			rule:     SetuidRule{},
+			content:  "FROM alpine:3.20\nRUN chmod 4755 /usr/local/bin/helper\n",
+			expected: false,
+		},
+		{
+			name:     "Numeric mode without special bits",
+			rule:     SetuidRule{},
+			content:  "FROM alpine:3.20\nRUN chmod 0755 /usr/local/bin/helper\n",
+			expected: true,
+		},
+		{
+			name:     "COPY into the workdir without --chown",
+			rule:     CopyChownRule{},
+			content:  "FROM node:20\nWORKDIR /app\nCOPY . .\nUSER node\n",
+			expected: false,
+		},
+		{
+			name:     "COPY with --chown",
+			rule:     CopyChownRule{},
+			content:  "FROM node:20\nWORKDIR /app\nCOPY --chown=node:node . .\nUSER node\n",
+			expected: true,
+		},
+		{
+			name:     "COPY outside the workdir",
+			rule:     CopyChownRule{},
+			content:  "FROM node:20\nWORKDIR /app\nCOPY entrypoint.sh /usr/local/bin/\nUSER node\n",
+			expected: true,
+		},
+		{
+			name:     "Container running as root",
+			rule:     CopyChownRule{},
+			content:  "FROM node:20\nWORKDIR /app\nCOPY . .\n",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := tt.rule.Check(newTarget(t, tt.content, ""))
+			if passed(results) != tt.expected {
+				t.Errorf("Expected passed=%v, got %+v", tt.expected, results)
+			}
+		})
+	}
+}
+
+func TestPrivilegeRulesPointAtTheInstruction(t *testing.T) {
+	content := "FROM alpine:3.20 AS build\nRUN chmod 777 /tmp/out\nFROM alpine:3.20\nWORKDIR /srv\nRUN chmod 777 /srv\nUSER root\n"
+
+	results := NoRootUserRule{}.Check(newTarget(t, content, ""))
+	if len(results) != 1 || results[0].Location.StartLine != 6 || results[0].Message != "The final stage runs as root" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	results = WorldWritableRule{}.Check(newTarget(t, content, ""))
+	if len(results) != 1 || results[0].Location.StartLine != 5 || results[0].Location.StartColumn != 5 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	results = SetuidRule{}.Check(newTarget(t, "FROM alpine:3.20 AS base\nCOPY --chmod=4755 helper /helper\nFROM base\n", ""))
+	if len(results) != 1 || results[0].Stage != "base" || results[0].Location.StartLine != 2 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
@@ -1,226 +1,426 @@
 package security
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
 )
 
 // CIS-1.1 Uso de imagem base oficial
-type OfficialBaseImageRule struct{}
-
-func (r OfficialBaseImageRule) Check(df string) CISResult {
-	lines := strings.Split(df, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "FROM") {
-			image := strings.Fields(line)[1]
-			if strings.Contains(image, "/") && !strings.HasPrefix(image, "library/") {
-				return CISResult{
-					RuleID:      "CIS-1.1",
-					Description: "Use official base images",
-					Passed:      false,
-					Severity:    "MEDIUM",
-					Message:     "Base image does not appear to be official",
-				}
-			}
-			return CISResult{RuleID: "CIS-1.1", Passed: true}
-		}
+type OfficialBaseImageRule struct {
+	// AllowedRegistries are registries or repository prefixes trusted in
+	// addition to the Docker Hub official images.
+	AllowedRegistries []string
+}
+
+func (r OfficialBaseImageRule) ID() string { return "CIS-1.1" }
+
+func (r OfficialBaseImageRule) Check(target *Target) []CISResult {
+	stages := imageStages(target.Dockerfile)
+	if len(stages) == 0 {
+		return []CISResult{{
+			RuleID:      "CIS-1.1",
+			Description: "Use official base images",
+			Passed:      false,
+			Severity:    "HIGH",
+			Message:     "No FROM instruction found",
+		}}
 	}
 
-	return CISResult{
-		RuleID:      "CIS-1.1",
-		Description: "Use official base images",
-		Passed:      false,
-		Severity:    "HIGH",
-		Message:     "No FROM instruction found",
+	results := []CISResult{}
+	for _, stage := range stages {
+		if !isOfficialImage(stage.BaseImage) && !isAllowedImage(stage.BaseImage, r.AllowedRegistries) {
+			results = append(results, CISResult{
+				RuleID:      "CIS-1.1",
+				Description: "Use official base images",
+				Passed:      false,
+				Severity:    "MEDIUM",
+				Message:     "Base image " + stage.BaseImage + " does not appear to be official",
+				Stage:       stage.Label(),
+				Location:    locate(target.Dockerfile, stage.From, fromImage(stage)),
+				Remediation: "Use an official Docker Hub image or a base image from a registry your team trusts",
+			})
+			continue
+		}
+		results = append(results, CISResult{RuleID: "CIS-1.1", Description: "Use official base images", Passed: true, Stage: stage.Label()})
 	}
+	return results
 }
 
 // CIS-1.2 Uso de tag explícita (não latest)
 type ExplicitTagRule struct{}
 
-func (r ExplicitTagRule) Check(df string) CISResult {
-	lines := strings.Split(df, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "FROM") {
-			image := strings.Fields(line)[1]
-			if !strings.Contains(image, ":") || strings.HasSuffix(image, ":latest") {
-				return CISResult{
-					RuleID:      "CIS-1.2",
-					Description: "Use explicit image tag (not latest)",
-					Passed:      false,
-					Severity:    "HIGH",
-					Message:     "Image uses latest or no tag",
-				}
-			}
-			return CISResult{RuleID: "CIS-1.2", Passed: true}
+func (r ExplicitTagRule) ID() string { return "CIS-1.2" }
+
+func (r ExplicitTagRule) Check(target *Target) []CISResult {
+	stages := imageStages(target.Dockerfile)
+	if len(stages) == 0 {
+		return []CISResult{{
+			RuleID:      "CIS-1.2",
+			Description: "Use explicit image tag (not latest)",
+			Passed:      false,
+			Severity:    "HIGH",
+			Message:     "No FROM instruction found",
+		}}
+	}
+
+	results := []CISResult{}
+	for _, stage := range stages {
+		if stage.BaseImage != "scratch" && !hasExplicitTag(stage.BaseImage) {
+			results = append(results, CISResult{
+				RuleID:      "CIS-1.2",
+				Description: "Use explicit image tag (not latest)",
+				Passed:      false,
+				Severity:    "HIGH",
+				Message:     "Image " + stage.BaseImage + " uses latest or no tag",
+				Stage:       stage.Label(),
+				Location:    locate(target.Dockerfile, stage.From, fromImage(stage)),
+				Remediation: "Pin the base image to a version tag or digest, e.g. alpine:3.20",
+			})
+			continue
 		}
+		results = append(results, CISResult{RuleID: "CIS-1.2", Description: "Use explicit image tag (not latest)", Passed: true, Stage: stage.Label()})
 	}
-	return CISResult{RuleID: "CIS-1.2", Passed: false}
+	return results
 }
 
-// CIS-4.1: Container n;ao deve rodar como root
+// CIS-4.1: Container não deve rodar como root
 type NoRootUserRule struct{}
 
-func (r NoRootUserRule) Check(df string) CISResult {
-	if !strings.Contains(strings.ToLower(df), "user") {
-		return CISResult{
+func (r NoRootUserRule) ID() string { return "CIS-4.1" }
+
+func (r NoRootUserRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{
 			RuleID:      "CIS-4.1",
 			Description: "Container should not run as root",
 			Passed:      false,
 			Severity:    "HIGH",
-			Message:     "Missing USER instruction",
-		}
+			Message:     "No FROM instruction found",
+		}}
+	}
+
+	user := target.Dockerfile.LastInLineage(target.Final, "USER")
+	if user == nil {
+		return []CISResult{{
+			RuleID:      "CIS-4.1",
+			Description: "Container should not run as root",
+			Passed:      false,
+			Severity:    "HIGH",
+			Message:     "Missing USER instruction in the final stage",
+			Stage:       target.Final.Label(),
+			Location:    locate(target.Dockerfile, target.Final.From, ""),
+			Remediation: "Add a USER instruction with a non-root user to the final stage",
+		}}
 	}
-	return CISResult{RuleID: "CIS-4.1", Passed: true}
+	if len(user.Args) > 0 && isRootUser(user.Args[0]) {
+		return []CISResult{{
+			RuleID:      "CIS-4.1",
+			Description: "Container should not run as root",
+			Passed:      false,
+			Severity:    "HIGH",
+			Message:     "The final stage runs as " + user.Args[0],
+			Stage:       target.Final.Label(),
+			Location:    locate(target.Dockerfile, user, user.Args[0]),
+			Remediation: "Switch to a non-root user after the steps that need root, e.g. USER 10001:10001",
+		}}
+	}
+	return []CISResult{{RuleID: "CIS-4.1", Description: "Container should not run as root", Passed: true, Stage: target.Final.Label()}}
 }
 
 type CleanCacheRule struct{}
 
+func (r CleanCacheRule) ID() string { return "CIS-5.1" }
+
 // CIS-5.1 Limpeza de cache e arquivos temporários
-func (r CleanCacheRule) Check(df string) CISResult {
-	lower := strings.ToLower(df)
-	if strings.Contains(lower, "apt-get clean") ||
-		strings.Contains(lower, "rm -rf /var/lib/apt/lists") ||
-		strings.Contains(lower, "apk --no-cache") {
-		return CISResult{RuleID: "CIS-5.1", Passed: true}
+func (r CleanCacheRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{RuleID: "CIS-5.1", Description: "Remove cache and temporary files", Passed: true}}
+	}
+
+	var install *dockerfile.Instruction
+	for _, stage := range target.Dockerfile.Lineage(target.Final) {
+		for _, run := range stage.Find("RUN") {
+			script := strings.ToLower(runScript(run))
+			if !isPackageInstall(script) {
+				continue
+			}
+			install = run
+			if strings.Contains(script, "apt-get clean") ||
+				strings.Contains(script, "rm -rf /var/lib/apt/lists") ||
+				strings.Contains(script, "apk --no-cache") ||
+				strings.Contains(script, "apk add --no-cache") {
+				return []CISResult{{RuleID: "CIS-5.1", Description: "Remove cache and temporary files", Passed: true, Stage: target.Final.Label()}}
+			}
+		}
+	}
+
+	if install == nil {
+		return []CISResult{{RuleID: "CIS-5.1", Description: "Remove cache and temporary files", Passed: true, Stage: target.Final.Label()}}
 	}
 
-	return CISResult{
+	return []CISResult{{
 		RuleID:      "CIS-5.1",
 		Description: "Remove cache and temporary files",
 		Passed:      false,
 		Severity:    "MEDIUM",
 		Message:     "No cache cleanup detected",
-	}
+		Stage:       target.Final.Label(),
+		Location:    locate(target.Dockerfile, install, packageInstallPattern.FindString(strings.ToLower(runScript(install)))),
+		Remediation: "Use apk add --no-cache, or end the RUN with apt-get clean && rm -rf /var/lib/apt/lists/*",
+	}}
 }
 
 // CIS-4.6: HEALTHCHECK
 type HealthcheckRule struct{}
 
-func (r HealthcheckRule) Check(df string) CISResult {
-	if !strings.Contains(strings.ToLower(df), "healthcheck") {
-		return CISResult{
+func (r HealthcheckRule) ID() string { return "CIS-4.6" }
+
+func (r HealthcheckRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{
 			RuleID:      "CIS-4.6",
 			Description: "Container must define HEALTHCHECK",
 			Passed:      false,
 			Severity:    "LOW",
-			Message:     "Missing HEALTHCHECK instruction",
-		}
+			Message:     "No FROM instruction found",
+		}}
+	}
+
+	healthcheck := target.Dockerfile.LastInLineage(target.Final, "HEALTHCHECK")
+	if healthcheck == nil {
+		return []CISResult{{
+			RuleID:      "CIS-4.6",
+			Description: "Container must define HEALTHCHECK",
+			Passed:      false,
+			Severity:    "LOW",
+			Message:     "Missing HEALTHCHECK instruction in the final stage",
+			Stage:       target.Final.Label(),
+			Location:    locate(target.Dockerfile, target.Final.From, ""),
+			Remediation: "Add a HEALTHCHECK to the final stage, e.g. HEALTHCHECK CMD wget -qO- http://localhost:8080/health || exit 1",
+		}}
 	}
-	return CISResult{RuleID: "CIS-4.6", Passed: true}
+
+	if len(healthcheck.Args) > 0 && strings.EqualFold(healthcheck.Args[0], "NONE") {
+		return []CISResult{{
+			RuleID:      "CIS-4.6",
+			Description: "Container must define HEALTHCHECK",
+			Passed:      false,
+			Severity:    "LOW",
+			Message:     "HEALTHCHECK is disabled with NONE",
+			Stage:       target.Final.Label(),
+			Location:    locate(target.Dockerfile, healthcheck, healthcheck.Args[0]),
+			Remediation: "Replace HEALTHCHECK NONE with a command that probes the application",
+		}}
+	}
+	return []CISResult{{RuleID: "CIS-4.6", Description: "Container must define HEALTHCHECK", Passed: true, Stage: target.Final.Label()}}
 }
 
 type DockerIgnoreRule struct{}
 
+func (r DockerIgnoreRule) ID() string { return "CIS-5.2" }
+
 // CIS-5.2 Uso de .dockerignore
-func (r DockerIgnoreRule) Check(df string) CISResult {
-	if _, err := os.Stat(".dockerignore"); err == nil {
-		return CISResult{RuleID: "CIS-5.2", Passed: true}
+func (r DockerIgnoreRule) Check(target *Target) []CISResult {
+	if _, err := os.Stat(filepath.Join(contextDir(target.Dockerfile), ".dockerignore")); err == nil {
+		return []CISResult{{RuleID: "CIS-5.2", Description: "Use .dockerignore", Passed: true}}
 	}
 
-	return CISResult{
+	return []CISResult{{
 		RuleID:      "CIS-5.2",
 		Description: "Use .dockerignore",
 		Passed:      false,
 		Severity:    "LOW",
 		Message:     ".dockerignore file not found",
-	}
+		Location:    &Location{File: target.Dockerfile.Path},
+		Remediation: "Create a .dockerignore next to the Dockerfile to keep secrets and build artifacts out of the context",
+	}}
+}
+
+type MinimalPortExposureRule struct {
+	// MaxPorts is the number of ports the final stage may expose, 1 when zero.
+	MaxPorts int
 }
 
-type MinimalPortExposureRule struct{}
+func (r MinimalPortExposureRule) ID() string { return "CIS-6.1" }
 
 // CIS-6.1 Exposição mínima de portas
-func (r MinimalPortExposureRule) Check(df string) CISResult {
-	count := 0
-	for _, line := range strings.Split(df, "\n") {
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "EXPOSE") {
-			count++
-		}
+func (r MinimalPortExposureRule) Check(target *Target) []CISResult {
+	if target.Final == nil {
+		return []CISResult{{RuleID: "CIS-6.1", Description: "Expose minimum ports", Passed: true}}
 	}
 
-	if count > 1 {
-		return CISResult{
-			RuleID:      "CIS-6.1",
-			Description: "Expose minimum ports",
-			Passed:      false,
-			Severity:    "LOW",
-			Message:     "Multiple exposed ports detected",
+	maxPorts := r.MaxPorts
+	if maxPorts <= 0 {
+		maxPorts = 1
+	}
+
+	count := 0
+	lineage := target.Dockerfile.Lineage(target.Final)
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for _, expose := range lineage[i].Find("EXPOSE") {
+			for _, port := range expose.Args {
+				count++
+				if count > maxPorts {
+					return []CISResult{{
+						RuleID:      "CIS-6.1",
+						Description: "Expose minimum ports",
+						Passed:      false,
+						Severity:    "LOW",
+						Message:     portsMessage(maxPorts),
+						Stage:       target.Final.Label(),
+						Location:    locate(target.Dockerfile, expose, port),
+						Remediation: "Expose only the port the application listens on",
+					}}
+				}
+			}
 		}
 	}
 
-	return CISResult{RuleID: "CIS-6.1", Passed: true}
+	return []CISResult{{RuleID: "CIS-6.1", Description: "Expose minimum ports", Passed: true, Stage: target.Final.Label()}}
+}
+
+func portsMessage(maxPorts int) string {
+	if maxPorts == 1 {
+		return "Multiple exposed ports detected"
+	}
+	return fmt.Sprintf("More than %d exposed ports detected", maxPorts)
 }
 
 type MultiStageBuildRule struct{}
 
+func (r MultiStageBuildRule) ID() string { return "CIS-7.1" }
+
 // CIS-7.1 Uso de Multi-stage build
-func (r MultiStageBuildRule) Check(df string) CISResult {
-	count := 0
-	for _, line := range strings.Split(df, "\n") {
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "FROM") {
-			count++
+func (r MultiStageBuildRule) Check(target *Target) []CISResult {
+	if len(target.Dockerfile.Stages) <= 1 {
+		var from *dockerfile.Instruction
+		if len(target.Dockerfile.Stages) == 1 {
+			from = target.Dockerfile.Stages[0].From
 		}
-	}
-
-	if count <= 1 {
-		return CISResult{
+		return []CISResult{{
 			RuleID:      "CIS-7.1",
 			Description: "Use multi-stage builds when appropriate",
 			Passed:      false,
 			Severity:    "MEDIUM",
 			Message:     "Single stage build detected",
-		}
+			Location:    locate(target.Dockerfile, from, ""),
+			Remediation: "Build in a separate stage and copy only the artifacts into a slim runtime stage",
+		}}
 	}
 
-	return CISResult{RuleID: "CIS-7.1", Passed: true}
+	return []CISResult{{RuleID: "CIS-7.1", Description: "Use multi-stage builds when appropriate", Passed: true}}
 }
 
 type CombinedRunCommandRule struct{}
 
+func (r CombinedRunCommandRule) ID() string { return "CIS-8.1" }
+
 // CIS-8.1 Combinação de comandos RUN
-func (r CombinedRunCommandRule) Check(df string) CISResult {
-	for _, line := range strings.Split(df, "\n") {
-		if strings.HasPrefix(strings.TrimSpace(line), "RUN") && !strings.Contains(line, "&&") {
-			return CISResult{
-				RuleID:      "CIS-8.1",
-				Description: "Combine RUN instructions",
-				Passed:      false,
-				Severity:    "LOW",
-				Message:     "RUN instruction not combined",
+func (r CombinedRunCommandRule) Check(target *Target) []CISResult {
+	results := []CISResult{}
+	for _, stage := range target.Dockerfile.Stages {
+		for i := 1; i < len(stage.Instructions); i++ {
+			if stage.Instructions[i].Is("RUN") && stage.Instructions[i-1].Is("RUN") {
+				results = append(results, CISResult{
+					RuleID:      "CIS-8.1",
+					Description: "Combine RUN instructions",
+					Passed:      false,
+					Severity:    "LOW",
+					Message:     "Consecutive RUN instructions could be combined",
+					Stage:       stage.Label(),
+					Location:    locate(target.Dockerfile, stage.Instructions[i], ""),
+					Remediation: "Merge consecutive RUN instructions with && to reduce the number of layers",
+				})
+				break
 			}
 		}
 	}
-	return CISResult{RuleID: "CIS-8.1", Passed: true}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: "CIS-8.1", Description: "Combine RUN instructions", Passed: true}}
+	}
+	return results
 }
 
 type OptimizedOrderRule struct{}
 
+func (r OptimizedOrderRule) ID() string { return "CIS-9.1" }
+
 // CIS-9.1 Ordem otimizada para cache
-func (r OptimizedOrderRule) Check(df string) CISResult {
-	installIndex := -1
-	copyIndex := -1
+func (r OptimizedOrderRule) Check(target *Target) []CISResult {
+	results := []CISResult{}
+	for _, stage := range target.Dockerfile.Stages {
+		copiedContext := false
+		for _, inst := range stage.Instructions {
+			if inst.Is("COPY", "ADD") && copiesWholeContext(inst) {
+				copiedContext = true
+			}
+			if inst.Is("RUN") && copiedContext && isDependencyInstall(runScript(inst)) {
+				results = append(results, CISResult{
+					RuleID:      "CIS-9.1",
+					Description: "Optimize instruction order",
+					Passed:      false,
+					Severity:    "LOW",
+					Message:     "COPY before dependency install",
+					Stage:       stage.Label(),
+					Location:    locate(target.Dockerfile, inst, dependencyInstallPattern.FindString(strings.ToLower(runScript(inst)))),
+					Remediation: "Copy only the dependency manifests and install them before copying the rest of the sources",
+				})
+				break
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: "CIS-9.1", Description: "Optimize instruction order", Passed: true}}
+	}
+	return results
+}
 
-	lines := strings.Split(df, "\n")
+type RequiredLabelsRule struct {
+	// Labels are the LABEL keys the final stage must define.
+	Labels []string
+}
 
-	for i, line := range lines {
-		upper := strings.ToUpper(line)
-		if strings.Contains(upper, "INSTALL") && strings.HasPrefix(strings.TrimSpace(upper), "RUN") {
-			installIndex = i
-		}
-		if strings.HasPrefix(strings.TrimSpace(upper), "COPY") {
-			copyIndex = i
+func (r RequiredLabelsRule) ID() string { return "DKZ-LABELS" }
+
+// DKZ-LABELS Labels exigidos pela política do projeto
+func (r RequiredLabelsRule) Check(target *Target) []CISResult {
+	if len(r.Labels) == 0 || target.Final == nil {
+		return []CISResult{{RuleID: "DKZ-LABELS", Description: "Define the required labels", Passed: true}}
+	}
+
+	defined := map[string]bool{}
+	for _, stage := range target.Dockerfile.Lineage(target.Final) {
+		for _, label := range stage.Find("LABEL") {
+			for _, pair := range label.KeyValues() {
+				defined[pair.Key] = true
+			}
 		}
 	}
 
-	if installIndex > copyIndex {
-		return CISResult{
-			RuleID:      "CIS-9.1",
-			Description: "Optimize instruction order",
-			Passed:      false,
-			Severity:    "LOW",
-			Message:     "COPY before dependency install",
+	missing := []string{}
+	for _, key := range r.Labels {
+		if !defined[key] {
+			missing = append(missing, key)
 		}
 	}
+	if len(missing) == 0 {
+		return []CISResult{{RuleID: "DKZ-LABELS", Description: "Define the required labels", Passed: true, Stage: target.Final.Label()}}
+	}
 
-	return CISResult{RuleID: "CIS-9.1", Passed: true}
+	return []CISResult{{
+		RuleID:      "DKZ-LABELS",
+		Description: "Define the required labels",
+		Passed:      false,
+		Severity:    "LOW",
+		Message:     "Missing required labels: " + strings.Join(missing, ", "),
+		Stage:       target.Final.Label(),
+		Location:    locate(target.Dockerfile, target.Final.From, ""),
+		Remediation: "Add the labels to the final stage, e.g. LABEL " + missing[0] + "=\"...\"",
+	}}
 }
@@ -0,0 +1,296 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/config"
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+func newTarget(t *testing.T, content string, stage string) *Target {
+	t.Helper()
+	df, err := dockerfile.ParseString(content)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	final, err := df.Target(stage)
+	if err != nil {
+		t.Fatalf("unexpected target error: %v", err)
+	}
+	return &Target{Dockerfile: df, Final: final}
+}
+
+// passed reports whether every result of a rule passed.
+func passed(results []CISResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     CISRule
+		content  string
+		expected bool
+	}{
+		{
+			name:     "Official image",
+			rule:     OfficialBaseImageRule{},
+			content:  "FROM node:20-alpine\n",
+			expected: true,
+		},
+		{
+			name:     "Official image with docker.io prefix",
+			rule:     OfficialBaseImageRule{},
+			content:  "FROM docker.io/library/node:20-alpine\n",
+			expected: true,
+		},
+		{
+			name:     "Non official image",
+			rule:     OfficialBaseImageRule{},
+			content:  "FROM bitnami/node:20\n",
+			expected: false,
+		},
+		{
+			name:     "Allowed registry prefix does not match a sibling",
+			rule:     OfficialBaseImageRule{AllowedRegistries: []string{"ghcr.io/acme"}},
+			content:  "FROM ghcr.io/acme-fork/node:20\n",
+			expected: false,
+		},
+		{
+			name:     "Lowercase from with explicit tag",
+			rule:     ExplicitTagRule{},
+			content:  "from node:20-alpine\n",
+			expected: true,
+		},
+		{
+			name:     "Registry port is not a tag",
+			rule:     ExplicitTagRule{},
+			content:  "FROM localhost:5000/app\n",
+			expected: false,
+		},
+		{
+			name:     "Digest pinned image",
+			rule:     ExplicitTagRule{},
+			content:  "FROM alpine@sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1\n",
+			expected: true,
+		},
+		{
+			name:     "Tag from meta ARG",
+			rule:     ExplicitTagRule{},
+			content:  "ARG VERSION=3.19\nFROM alpine:${VERSION}\n",
+			expected: true,
+		},
+		{
+			name:     "The word user in a RUN is not a USER instruction",
+			rule:     NoRootUserRule{},
+			content:  "FROM alpine:3.19\nRUN adduser -D app\n",
+			expected: false,
+		},
+		{
+			name:     "USER instruction",
+			rule:     NoRootUserRule{},
+			content:  "FROM alpine:3.19\nuser app\n",
+			expected: true,
+		},
+		{
+			name:     "Cache cleanup in a continued RUN",
+			rule:     CleanCacheRule{},
+			content:  "FROM debian:12\nRUN apt-get update \\\n  && apt-get install -y curl \\\n  && rm -rf /var/lib/apt/lists/*\n",
+			expected: true,
+		},
+		{
+			name:     "HEALTHCHECK mentioned only in a comment",
+			rule:     HealthcheckRule{},
+			content:  "FROM alpine:3.19\n# TODO: healthcheck\n",
+			expected: false,
+		},
+		{
+			name:     "HEALTHCHECK NONE",
+			rule:     HealthcheckRule{},
+			content:  "FROM alpine:3.19\nHEALTHCHECK NONE\n",
+			expected: false,
+		},
+		{
+			name:     "Two ports on one EXPOSE",
+			rule:     MinimalPortExposureRule{},
+			content:  "FROM alpine:3.19\nEXPOSE 80 443\n",
+			expected: false,
+		},
+		{
+			name:     "Multi-stage build",
+			rule:     MultiStageBuildRule{},
+			content:  "FROM golang:1.22 AS builder\nFROM alpine:3.19\n",
+			expected: true,
+		},
+		{
+			name:     "Single multi-line RUN",
+			rule:     CombinedRunCommandRule{},
+			content:  "FROM alpine:3.19\nRUN apk add --no-cache curl \\\n    git\n",
+			expected: true,
+		},
+		{
+			name:     "Consecutive RUN instructions",
+			rule:     CombinedRunCommandRule{},
+			content:  "FROM alpine:3.19\nRUN apk add --no-cache curl\nRUN apk add --no-cache git\n",
+			expected: false,
+		},
+		{
+			name:     "Manifest copied before install",
+			rule:     OptimizedOrderRule{},
+			content:  "FROM node:20\nCOPY package.json ./\nRUN npm ci\nCOPY . .\n",
+			expected: true,
+		},
+		{
+			name:     "Whole context copied before install",
+			rule:     OptimizedOrderRule{},
+			content:  "FROM node:20\nCOPY . .\nRUN npm install\n",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := tt.rule.Check(newTarget(t, tt.content, ""))
+			if passed(results) != tt.expected {
+				t.Errorf("Expected passed=%v, got %+v", tt.expected, results)
+			}
+		})
+	}
+}
+
+const multiStageDockerfile = `FROM golang:1.22-alpine AS builder
+RUN adduser -D build
+USER build
+RUN go build -o /out/app
+FROM alpine:latest AS runtime
+COPY --from=builder /out/app /app
+EXPOSE 8080
+FROM runtime AS debug
+USER nobody
+`
+
+func TestRulesUseFinalStage(t *testing.T) {
+	results := NoRootUserRule{}.Check(newTarget(t, multiStageDockerfile, "runtime"))
+	if passed(results) {
+		t.Errorf("A USER in the builder stage must not satisfy the runtime stage: %+v", results)
+	}
+	if results[0].Stage != "runtime" {
+		t.Errorf("Expected finding on stage runtime, got %q", results[0].Stage)
+	}
+
+	results = NoRootUserRule{}.Check(newTarget(t, multiStageDockerfile, ""))
+	if !passed(results) {
+		t.Errorf("The debug stage defines USER and should pass: %+v", results)
+	}
+}
+
+func TestBaseImageRulesCheckEveryStage(t *testing.T) {
+	results := ExplicitTagRule{}.Check(newTarget(t, multiStageDockerfile, ""))
+
+	// debug is built FROM runtime, so only two images are checked.
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+	if !results[0].Passed || results[0].Stage != "builder" {
+		t.Errorf("Expected builder to pass, got %+v", results[0])
+	}
+	if results[1].Passed || results[1].Stage != "runtime" {
+		t.Errorf("Expected runtime to fail for alpine:latest, got %+v", results[1])
+	}
+}
+
+func TestAnalyzeUnknownTarget(t *testing.T) {
+	df, _ := dockerfile.ParseString(multiStageDockerfile)
+	if _, err := NewCISAnalyzer().Analyze(df, "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown target stage")
+	}
+}
+
+func TestFindingLocation(t *testing.T) {
+	content := "FROM golang:1.22 AS builder\nRUN apt-get update && \\\n    apt-get install -y curl\nFROM alpine:latest\nEXPOSE 80 443\n"
+	df, _ := dockerfile.ParseString(content)
+	df.Path = "build/Dockerfile"
+
+	results, err := NewCISAnalyzer().Analyze(df, "builder")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, r := range results {
+		if r.RuleID != "CIS-5.1" {
+			continue
+		}
+		if r.Passed {
+			t.Fatalf("Expected CIS-5.1 to fail")
+		}
+		loc := r.Location
+		if loc.File != "build/Dockerfile" || loc.StartLine != 3 || loc.StartColumn != 5 || loc.EndColumn != 5+len("apt-get install") {
+			t.Errorf("Unexpected location: %+v", loc)
+		}
+		if loc.Instruction != "RUN apt-get update && \\\n    apt-get install -y curl" {
+			t.Errorf("Unexpected instruction text: %q", loc.Instruction)
+		}
+		return
+	}
+	t.Errorf("CIS-5.1 result not found")
+}
+
+func TestConfiguredAnalyzer(t *testing.T) {
+	disabled := false
+	project := &config.Project{
+		Rules: map[string]config.RuleConfig{
+			"CIS-4.6": {Enabled: &disabled},
+			"CIS-1.2": {Severity: "low"},
+		},
+		AllowedRegistries: []string{"ghcr.io/acme"},
+		MaxExposedPorts:   2,
+		RequiredLabels:    []string{"team", "org.opencontainers.image.source"},
+	}
+	analyzer, err := NewConfiguredCISAnalyzer(project)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	df, _ := dockerfile.ParseString("FROM ghcr.io/acme/base\nLABEL team=platform\nEXPOSE 80 443\n")
+	results, err := analyzer.Analyze(df, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byRule := map[string]CISResult{}
+	for _, r := range results {
+		byRule[r.RuleID] = r
+	}
+	if _, ran := byRule["CIS-4.6"]; ran {
+		t.Errorf("Disabled rule CIS-4.6 must not run")
+	}
+	if !byRule["CIS-1.1"].Passed {
+		t.Errorf("Allowed registry should pass CIS-1.1: %+v", byRule["CIS-1.1"])
+	}
+	if r := byRule["CIS-1.2"]; r.Passed || r.Severity != "LOW" {
+		t.Errorf("Expected CIS-1.2 to fail with the overridden severity, got %+v", r)
+	}
+	if !byRule["CIS-6.1"].Passed {
+		t.Errorf("Two ports are allowed by maxExposedPorts: %+v", byRule["CIS-6.1"])
+	}
+	if r := byRule["DKZ-LABELS"]; r.Passed || r.Message != "Missing required labels: org.opencontainers.image.source" {
+		t.Errorf("Expected only the source label to be missing, got %+v", r)
+	}
+}
+
+func TestConfiguredAnalyzerRejectsUnknownRules(t *testing.T) {
+	project := &config.Project{Rules: map[string]config.RuleConfig{"CIS-99": {Severity: "high"}}}
+	if _, err := NewConfiguredCISAnalyzer(project); err == nil {
+		t.Errorf("Expected an unknown rule to be rejected")
+	}
+
+	project = &config.Project{Rules: map[string]config.RuleConfig{"CIS-6.1": {Severity: "urgent"}}}
+	if _, err := NewConfiguredCISAnalyzer(project); err == nil {
+		t.Errorf("Expected an unknown severity to be rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package security
+
+import (
+	"math"
+	"sort"
+)
+
+// severityWeights is how much a rule of each severity counts in the score.
+var severityWeights = map[string]int{
+	SeverityLow:    1,
+	SeverityMedium: 3,
+	SeverityHigh:   5,
+}
+
+// ScoreCard is the weighted security score of a set of results. Every rule
+// weighs as much as its severity; a rule with several results (one per
+// stage, for instance) earns its weight in proportion to those that did not
+// fail. Suppressed failures count as passed.
+type ScoreCard struct {
+	Score int    `json:"score"`
+	Grade string `json:"grade"`
+	// Categories breaks the score down by rule category, in Categories order.
+	Categories []CategoryScore `json:"categories"`
+	// Improvements lists the failing rules by the points fixing them would
+	// add, most first.
+	Improvements []Improvement `json:"improvements,omitempty"`
+}
+
+// CategoryScore is the score of the rules of a single category.
+type CategoryScore struct {
+	Category string `json:"category"`
+	Score    int    `json:"score"`
+	Rules    int    `json:"rules"`
+	Failing  int    `json:"failing"`
+}
+
+// Improvement is the score gained by fixing every failure of a rule.
+type Improvement struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Points      int    `json:"points"`
+}
+
+// ruleScore accumulates the results of one rule.
+type ruleScore struct {
+	id          string
+	description string
+	category    string
+	weight      int
+	results     int
+	failed      int
+}
+
+func (r *ruleScore) earned() float64 {
+	return float64(r.weight) * float64(r.results-r.failed) / float64(r.results)
+}
+
+// Evaluate computes the score card of results.
+func Evaluate(results []CISResult) ScoreCard {
+	rules := []*ruleScore{}
+	byID := map[string]*ruleScore{}
+	for _, r := range results {
+		rule, ok := byID[r.RuleID]
+		if !ok {
+			rule = &ruleScore{id: r.RuleID, description: r.Description, category: resultCategory(r)}
+			byID[r.RuleID] = rule
+			rules = append(rules, rule)
+		}
+		rule.weight = max(rule.weight, resultWeight(r))
+		rule.results++
+		if r.Failed() {
+			rule.failed++
+		}
+	}
+
+	card := ScoreCard{Score: weightedScore(rules), Categories: []CategoryScore{}}
+	card.Grade = Grade(card.Score)
+
+	for _, category := range Categories {
+		inCategory := []*ruleScore{}
+		failing := 0
+		for _, rule := range rules {
+			if rule.category == category {
+				inCategory = append(inCategory, rule)
+				if rule.failed > 0 {
+					failing++
+				}
+			}
+		}
+		if len(inCategory) > 0 {
+			card.Categories = append(card.Categories, CategoryScore{
+				Category: category,
+				Score:    weightedScore(inCategory),
+				Rules:    len(inCategory),
+				Failing:  failing,
+			})
+		}
+	}
+
+	total := 0
+	for _, rule := range rules {
+		total += rule.weight
+	}
+	for _, rule := range rules {
+		if rule.failed == 0 {
+			continue
+		}
+		card.Improvements = append(card.Improvements, Improvement{
+			RuleID:      rule.id,
+			Description: rule.description,
+			Category:    rule.category,
+			Points:      int(math.Round((float64(rule.weight) - rule.earned()) * 100 / float64(total))),
+		})
+	}
+	sort.SliceStable(card.Improvements, func(i, j int) bool {
+		return card.Improvements[i].Points > card.Improvements[j].Points
+	})
+	return card
+}
+
+// Score returns the weighted security score of results, from 0 to 100.
+func Score(results []CISResult) int {
+	return Evaluate(results).Score
+}
+
+// Grade converts a score into a letter grade.
+func Grade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+func weightedScore(rules []*ruleScore) int {
+	total, earned := 0, 0.0
+	for _, rule := range rules {
+		total += rule.weight
+		earned += rule.earned()
+	}
+	if total == 0 {
+		return 100
+	}
+	return int(math.Floor(earned * 100 / float64(total)))
+}
+
+// resultWeight weighs a result by its severity, falling back to the
+// severity of its rule and then to MEDIUM.
+func resultWeight(r CISResult) int {
+	severity := r.Severity
+	if severity == "" {
+		severity = ruleCatalog[r.RuleID].Severity
+	}
+	if weight, ok := severityWeights[severity]; ok {
+		return weight
+	}
+	return severityWeights[SeverityMedium]
+}
+
+func resultCategory(r CISResult) string {
+	if r.Category != "" {
+		return r.Category
+	}
+	if info, ok := ruleCatalog[r.RuleID]; ok {
+		return info.Category
+	}
+	return CategoryBuildHygiene
+}
@@ -0,0 +1,96 @@
+package security
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []CISResult
+		expected int
+		grade    string
+	}{
+		{
+			name:     "No results",
+			results:  nil,
+			expected: 100,
+			grade:    "A",
+		},
+		{
+			name: "Failing LOW costs less than failing HIGH",
+			results: []CISResult{
+				{RuleID: "CIS-4.1", Passed: true},
+				{RuleID: "CIS-4.6", Passed: false, Severity: SeverityLow},
+			},
+			expected: 83,
+			grade:    "B",
+		},
+		{
+			name: "Running as root",
+			results: []CISResult{
+				{RuleID: "CIS-4.1", Passed: false, Severity: SeverityHigh},
+				{RuleID: "CIS-4.6", Passed: true},
+			},
+			expected: 16,
+			grade:    "F",
+		},
+		{
+			name: "Rule with several results earns a share of its weight",
+			results: []CISResult{
+				{RuleID: "CIS-1.2", Passed: true, Stage: "build"},
+				{RuleID: "CIS-1.2", Passed: false, Severity: SeverityHigh, Stage: "runtime"},
+			},
+			expected: 50,
+			grade:    "F",
+		},
+		{
+			name: "Suppressed failures count as passed",
+			results: []CISResult{
+				{RuleID: "CIS-4.1", Passed: false, Severity: SeverityHigh, Suppression: &Suppression{Kind: SuppressedInline, Reason: "init drops privileges"}},
+			},
+			expected: 100,
+			grade:    "A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := Evaluate(tt.results)
+			if card.Score != tt.expected || card.Grade != tt.grade {
+				t.Errorf("Expected %d%% (%s), got %d%% (%s)", tt.expected, tt.grade, card.Score, card.Grade)
+			}
+		})
+	}
+}
+
+func TestEvaluateExplainsScore(t *testing.T) {
+	card := Evaluate([]CISResult{
+		{RuleID: "CIS-1.2", Passed: true},
+		{RuleID: "CIS-4.1", Passed: false, Severity: SeverityHigh},
+		{RuleID: "CIS-4.6", Passed: false, Severity: SeverityLow},
+		{RuleID: "ACME-1", Passed: false, Severity: SeverityMedium, Category: CategoryRuntime},
+	})
+
+	if len(card.Categories) != 3 {
+		t.Fatalf("Expected base image, privileges and runtime categories, got %+v", card.Categories)
+	}
+	if c := card.Categories[2]; c.Category != CategoryRuntime || c.Rules != 2 || c.Failing != 2 || c.Score != 0 {
+		t.Errorf("Unexpected runtime breakdown: %+v", c)
+	}
+
+	if len(card.Improvements) != 3 {
+		t.Fatalf("Expected three improvements, got %+v", card.Improvements)
+	}
+	first, last := card.Improvements[0], card.Improvements[2]
+	if first.RuleID != "CIS-4.1" || first.Points != 36 || last.RuleID != "CIS-4.6" || last.Points != 7 {
+		t.Errorf("Expected the HIGH finding first and the LOW one last, got %+v", card.Improvements)
+	}
+}
+
+func TestAnalyzerDescribesEveryResult(t *testing.T) {
+	results := analyze(t, "FROM alpine:3.20\nUSER app\n")
+	for _, r := range results {
+		if r.Description == "" || r.Severity == "" || r.Category == "" {
+			t.Errorf("Result lacks its rule description: %+v", r)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+// maxScannedFileSize is the size above which context files are only
+// checked by name.
+const maxScannedFileSize = 1 << 20
+
+const secretMountRemediation = "Pass the secret at build time with RUN --mount=type=secret,id=<name> and docker build --secret, so it never lands in a layer or in the image history"
+
+var secretNamePattern = regexp.MustCompile(`(?i)(passw(or)?d|passwd|pwd|secret|token|api_?key|access_?key|private_?key|credential|auth)`)
+
+// notSecretNamePattern matches names that refer to a secret without holding
+// it, such as PASSWORD_FILE or TOKEN_URL, or that hold checksums.
+var notSecretNamePattern = regexp.MustCompile(`(?i)(_FILE|_PATH|_DIR|_URL|_HOST|_PORT|_USER(NAME)?|_ID|_ENDPOINT|_REGION|_VERSION|SHA\d*|CHECKSUM|HASH|DIGEST)$`)
+
+var variableReferencePattern = regexp.MustCompile(`^\$\{?[A-Za-z_][A-Za-z0-9_]*(:?-[^}]*)?\}?$`)
+
+// secretValuePatterns are the formats of well-known credentials.
+// Generic ones are skipped in context files, where documentation is full of
+// examples.
+var secretValuePatterns = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Generic bool
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY`), false},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), false},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`), false},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`), false},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`), false},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`), false},
+	{"Stripe key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`), false},
+	{"npm token", regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`), false},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), true},
+	{"password in URL", regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s@$]+@`), true},
+}
+
+// credentialFilePatterns match the base names of files that usually hold
+// credentials.
+var credentialFilePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\.env(\..+)?$`),
+	regexp.MustCompile(`^(id_rsa|id_dsa|id_ecdsa|id_ed25519)$`),
+	regexp.MustCompile(`\.(pem|key|p12|pfx|jks|keystore|ppk|kdbx)$`),
+	regexp.MustCompile(`^\.(npmrc|pypirc|netrc|git-credentials|pgpass|htpasswd|dockercfg)$`),
+	regexp.MustCompile(`^(credentials|credentials\.json|service-account.*\.json|secrets?\.(json|ya?ml|env)|kubeconfig)$`),
+}
+
+// credentialDirPattern matches paths inside directories of credentials.
+var credentialDirPattern = regexp.MustCompile(`(^|/)(\.ssh|\.aws|\.gnupg|\.kube)(/|$)|(^|/)\.docker/config\.json$`)
+
+// exampleFilePattern matches templates that are meant to be committed.
+var exampleFilePattern = regexp.MustCompile(`(?i)\.(example|sample|template|dist)$`)
+
+// DKZ-SECRET-ENV Segredos em ENV e ARG
+type SecretEnvRule struct{}
+
+func (r SecretEnvRule) ID() string { return "DKZ-SECRET-ENV" }
+
+func (r SecretEnvRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+
+	for _, inst := range df.Find("ENV", "ARG") {
+		for _, pair := range inst.KeyValues() {
+			if reason := secretAssignment(inst, pair); reason != "" {
+				results = append(results, CISResult{
+					RuleID:      r.ID(),
+					Description: "Do not store secrets in ENV or ARG",
+					Passed:      false,
+					Severity:    SeverityHigh,
+					Message:     reason,
+					Stage:       instructionStage(df, inst),
+					Location:    locate(df, inst, pair.Key),
+					Remediation: secretMountRemediation,
+				})
+			}
+		}
+	}
+
+	for _, stage := range df.Stages {
+		for _, arg := range stage.Find("ARG") {
+			for _, pair := range arg.KeyValues() {
+				if strings.TrimSpace(pair.Value) != "" || !isSecretName(pair.Key) {
+					continue
+				}
+				if run := runUsing(stage, arg, pair.Key); run != nil {
+					results = append(results, CISResult{
+						RuleID:      r.ID(),
+						Description: "Do not store secrets in ENV or ARG",
+						Passed:      false,
+						Severity:    SeverityMedium,
+						Message:     "Build argument " + pair.Key + " is used by RUN, which records its value in the image history",
+						Stage:       stage.Label(),
+						Location:    locate(df, run, pair.Key),
+						Remediation: secretMountRemediation,
+					})
+				}
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not store secrets in ENV or ARG", Passed: true}}
+	}
+	return results
+}
+
+// secretAssignment explains why an ENV or ARG pair holds a secret, or
+// returns an empty string.
+func secretAssignment(inst *dockerfile.Instruction, pair dockerfile.KeyValue) string {
+	value := strings.TrimSpace(pair.Value)
+	if !pair.HasValue || value == "" {
+		return ""
+	}
+	if kind := secretValueKind(value, true); kind != "" {
+		return inst.Cmd + " " + pair.Key + " holds a secret value (" + kind + ")"
+	}
+	if variableReferencePattern.MatchString(value) {
+		if inst.Is("ENV") && isSecretName(pair.Key) {
+			return "ENV " + pair.Key + " copies a secret into the image configuration, where anyone who pulls the image can read it"
+		}
+		return ""
+	}
+	if isSecretName(pair.Key) {
+		return inst.Cmd + " " + pair.Key + " has a hard-coded value"
+	}
+	if isHighEntropy(value) {
+		return inst.Cmd + " " + pair.Key + " has a value that looks like a generated secret"
+	}
+	return ""
+}
+
+func isSecretName(name string) bool {
+	return secretNamePattern.MatchString(name) && !notSecretNamePattern.MatchString(name)
+}
+
+// secretValueKind returns the kind of well-known credential found in text,
+// or an empty string. generic enables the patterns prone to false positives.
+func secretValueKind(text string, generic bool) string {
+	for _, known := range secretValuePatterns {
+		if (generic || !known.Generic) && known.Pattern.MatchString(text) {
+			return known.Name
+		}
+	}
+	return ""
+}
+
+// isHighEntropy reports whether value looks like a random token: long,
+// without spaces or paths, mixing letters and digits, with high Shannon
+// entropy.
+func isHighEntropy(value string) bool {
+	if len(value) < 20 || strings.ContainsAny(value, " /:") {
+		return false
+	}
+	if !strings.ContainsAny(value, "0123456789") || strings.IndexFunc(value, isLetter) < 0 {
+		return false
+	}
+	return shannonEntropy(value) >= 4.0
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, r := range value {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(value))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// runUsing returns the first RUN of stage after arg that references name.
+func runUsing(stage *dockerfile.Stage, arg *dockerfile.Instruction, name string) *dockerfile.Instruction {
+	reference := regexp.MustCompile(`\$\{?` + regexp.QuoteMeta(name) + `\b`)
+	for _, run := range stage.Find("RUN") {
+		if run.StartLine > arg.StartLine && reference.MatchString(runScript(run)) {
+			return run
+		}
+	}
+	return nil
+}
+
+func instructionStage(df *dockerfile.Dockerfile, inst *dockerfile.Instruction) string {
+	if inst.Stage < 0 {
+		return ""
+	}
+	return df.Stages[inst.Stage].Label()
+}
+
+// DKZ-SECRET-COPY Cópia de arquivos de credenciais
+type SecretFileCopyRule struct{}
+
+func (r SecretFileCopyRule) ID() string { return "DKZ-SECRET-COPY" }
+
+func (r SecretFileCopyRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+
+	for _, inst := range df.Find("COPY", "ADD") {
+		if _, fromStage := inst.Flag("from"); fromStage || len(inst.Args) < 2 {
+			continue
+		}
+		for _, src := range inst.Args[:len(inst.Args)-1] {
+			if !isCredentialFile(src) {
+				continue
+			}
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Do not copy credential files into the image",
+				Passed:      false,
+				Severity:    SeverityHigh,
+				Message:     inst.Cmd + " copies the credential file " + src + " into the image",
+				Stage:       instructionStage(df, inst),
+				Location:    locate(df, inst, src),
+				Remediation: secretMountRemediation,
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not copy credential files into the image", Passed: true}}
+	}
+	return results
+}
+
+// isCredentialFile reports whether a path names a file that usually holds
+// credentials. Templates such as .env.example are not.
+func isCredentialFile(file string) bool {
+	file = strings.TrimPrefix(filepath.ToSlash(file), "./")
+	base := path.Base(file)
+	if exampleFilePattern.MatchString(base) {
+		return false
+	}
+	if credentialDirPattern.MatchString(file) {
+		return true
+	}
+	for _, pattern := range credentialFilePatterns {
+		if pattern.MatchString(base) {
+			return true
+		}
+	}
+	return false
+}
+
+// DKZ-SECRET-CONTEXT Segredos no contexto de build
+type SecretContextRule struct{}
+
+func (r SecretContextRule) ID() string { return "DKZ-SECRET-CONTEXT" }
+
+// Check looks for credentials in the files sent as build context, those in
+// the Dockerfile's directory that .dockerignore does not exclude. Findings
+// are HIGH when the whole context is copied into the image.
+func (r SecretContextRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	if df.Path == "" {
+		return []CISResult{{RuleID: r.ID(), Description: "Keep secrets out of the build context", Passed: true}}
+	}
+
+	dir := contextDir(df)
+	files, truncated, err := contextFiles(dir)
+	if err != nil {
+		return []CISResult{{RuleID: r.ID(), Description: "Keep secrets out of the build context", Passed: true}}
+	}
+
+	severity, consequence := SeverityMedium, "is sent to the Docker daemon with the build context"
+	for _, inst := range df.Find("COPY", "ADD") {
+		if copiesWholeContext(inst) {
+			severity, consequence = SeverityHigh, "is copied into the image with the whole build context"
+			break
+		}
+	}
+
+	results := []CISResult{}
+	for _, file := range files {
+		location := &Location{File: filepath.Join(dir, filepath.FromSlash(file))}
+		message := ""
+		if isCredentialFile(file) {
+			message = "Credential file " + file + " " + consequence
+		} else if kind, line := scanFileForSecrets(location.File); kind != "" {
+			message = "Found " + kind + " in " + file + ", which " + consequence
+			location.StartLine, location.EndLine = line, line
+		}
+		if message == "" {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Keep secrets out of the build context",
+			Passed:      false,
+			Severity:    severity,
+			Message:     message,
+			Location:    location,
+			Remediation: "Add the file to .dockerignore and provide the secret with RUN --mount=type=secret",
+		})
+	}
+	if truncated {
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Keep secrets out of the build context",
+			Passed:      false,
+			Severity:    SeverityLow,
+			Message:     fmt.Sprintf("The build context has more than %d files; only the first %d were scanned for secrets", maxContextFiles, maxContextFiles),
+			Location:    &Location{File: dir},
+			Remediation: "Exclude dependency and generated directories from the build context in .dockerignore",
+		})
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Keep secrets out of the build context", Passed: true}}
+	}
+	return results
+}
+
+// scanFileForSecrets returns the kind and line of the first well-known
+// credential in a text file, or an empty kind.
+func scanFileForSecrets(file string) (string, int) {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > maxScannedFileSize {
+		return "", 0
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || bytes.IndexByte(data[:min(len(data), 512)], 0) >= 0 {
+		return "", 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScannedFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		if kind := secretValueKind(scanner.Text(), false); kind != "" {
+			return kind, line
+		}
+	}
+	return "", 0
+}
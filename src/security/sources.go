@@ -0,0 +1,257 @@
+package security
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+var remoteSourcePattern = regexp.MustCompile(`^(https?|ftp)://`)
+var gitSourcePattern = regexp.MustCompile(`^git@|^git://|\.git(#.*)?$`)
+var archivePattern = regexp.MustCompile(`\.(tar|tar\.gz|tgz|tar\.bz2|tbz2?|tar\.xz|txz|tar\.zst)$`)
+var pipCommandPattern = regexp.MustCompile(`^pip[0-9.]*$`)
+var pythonCommandPattern = regexp.MustCompile(`^python[0-9.]*$`)
+
+// pipeToShellPatterns match scripts downloaded and executed in one go.
+var pipeToShellPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(curl|wget)\b[^|;&\n]*\|\s*(sudo\s+(-\S+\s+)*)?(env\s+(\S+=\S*\s+)*)?(ba|z|da|k|fi)?sh\b`),
+	regexp.MustCompile(`\b(curl|wget)\b[^|;&\n]*\|\s*(sudo\s+)?(python[0-9.]*|perl|ruby|node)\b`),
+	regexp.MustCompile(`\b(ba|z|da|k)?sh\s+(-c\s+)?["']?\$\(\s*(curl|wget)\b`),
+	regexp.MustCompile(`\b(ba|z|da|k)?sh\s+<\(\s*(curl|wget)\b`),
+}
+
+// CIS-4.9 Uso de COPY em vez de ADD
+type CopyInsteadOfAddRule struct{}
+
+func (r CopyInsteadOfAddRule) ID() string { return "CIS-4.9" }
+
+// Check flags ADD of local files. Local archives are allowed, since ADD
+// extracting them is the one thing COPY cannot do.
+func (r CopyInsteadOfAddRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, add := range df.Find("ADD") {
+		local := localAddSources(add)
+		if len(local) == 0 {
+			continue
+		}
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Use COPY instead of ADD",
+			Passed:      false,
+			Severity:    SeverityMedium,
+			Message:     "ADD is used to copy local files (" + strings.Join(local, ", ") + ")",
+			Stage:       instructionStage(df, add),
+			Location:    locate(df, add, add.Cmd),
+			Remediation: "Use COPY, which does not fetch URLs or extract archives behind your back",
+		})
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Use COPY instead of ADD", Passed: true}}
+	}
+	return results
+}
+
+// localAddSources returns the sources of an ADD that are local files other
+// than archives, or nil when the ADD copies from a stage or a context.
+func localAddSources(add *dockerfile.Instruction) []string {
+	if _, fromStage := add.Flag("from"); fromStage || len(add.Args) < 2 || len(add.Heredocs) > 0 {
+		return nil
+	}
+	local := []string{}
+	for _, src := range add.Args[:len(add.Args)-1] {
+		if remoteSourcePattern.MatchString(src) || gitSourcePattern.MatchString(src) || archivePattern.MatchString(strings.ToLower(src)) {
+			continue
+		}
+		local = append(local, src)
+	}
+	return local
+}
+
+// DKZ-ADD-CHECKSUM Download remoto sem verificação
+type RemoteAddChecksumRule struct{}
+
+func (r RemoteAddChecksumRule) ID() string { return "DKZ-ADD-CHECKSUM" }
+
+func (r RemoteAddChecksumRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, add := range df.Find("ADD") {
+		if _, ok := add.Flag("checksum"); ok || len(add.Args) < 2 {
+			continue
+		}
+		for _, src := range add.Args[:len(add.Args)-1] {
+			if !remoteSourcePattern.MatchString(src) || gitSourcePattern.MatchString(src) {
+				continue
+			}
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Verify remote downloads",
+				Passed:      false,
+				Severity:    SeverityMedium,
+				Message:     "ADD downloads " + src + " without verifying its checksum",
+				Stage:       instructionStage(df, add),
+				Location:    locate(df, add, src),
+				Remediation: "Add --checksum=sha256:<digest> to the ADD (Dockerfile syntax 1.6 or later), or download with curl and check the file with sha256sum -c",
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Verify remote downloads", Passed: true}}
+	}
+	return results
+}
+
+// DKZ-PIPE-SHELL Execução de scripts baixados (curl | sh)
+type PipeToShellRule struct{}
+
+func (r PipeToShellRule) ID() string { return "DKZ-PIPE-SHELL" }
+
+func (r PipeToShellRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, run := range df.Find("RUN") {
+		script := runScript(run)
+		for _, pattern := range pipeToShellPatterns {
+			match := pattern.FindString(script)
+			if match == "" {
+				continue
+			}
+			needle := strings.Fields(match)[0]
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Do not pipe downloaded scripts into a shell",
+				Passed:      false,
+				Severity:    SeverityHigh,
+				Message:     "A downloaded script is executed without verification",
+				Stage:       instructionStage(df, run),
+				Location:    locate(df, run, needle),
+				Remediation: "Download the script to a file, verify its checksum or signature, then run it",
+			})
+			break
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Do not pipe downloaded scripts into a shell", Passed: true}}
+	}
+	return results
+}
+
+// DKZ-PIN-PACKAGES Pacotes sem versão fixada
+type PinnedPackagesRule struct{}
+
+func (r PinnedPackagesRule) ID() string { return "DKZ-PIN-PACKAGES" }
+
+func (r PinnedPackagesRule) Check(target *Target) []CISResult {
+	df := target.Dockerfile
+	results := []CISResult{}
+	for _, run := range df.Find("RUN") {
+		for _, command := range shellCommands(runScript(run)) {
+			manager, unpinned := unpinnedPackages(strings.Fields(command))
+			if len(unpinned) == 0 {
+				continue
+			}
+			listed := unpinned
+			if len(listed) > 3 {
+				listed = append(listed[:3:3], "...")
+			}
+			results = append(results, CISResult{
+				RuleID:      r.ID(),
+				Description: "Pin package versions",
+				Passed:      false,
+				Severity:    SeverityLow,
+				Message:     manager + " installs packages without a version: " + strings.Join(listed, ", "),
+				Stage:       instructionStage(df, run),
+				Location:    locate(df, run, unpinned[0]),
+				Remediation: pinRemediations[manager],
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Pin package versions", Passed: true}}
+	}
+	return results
+}
+
+var pinRemediations = map[string]string{
+	"apt-get": "Pin the versions, e.g. apt-get install -y curl=7.88.1-10+deb12u5",
+	"apk":     "Pin the versions, e.g. apk add --no-cache curl=8.5.0-r0",
+	"pip":     "Pin the versions, e.g. pip install flask==3.0.2, or install from a requirements file with pinned versions",
+	"npm":     "Pin the versions of global packages, e.g. npm install -g pnpm@8.15.4",
+}
+
+// packageFlagsWithValue are the options of the package managers whose value
+// is the next word.
+var packageFlagsWithValue = map[string]bool{
+	"-o": true, "-t": true, "--virtual": true, "-X": true, "--repository": true,
+	"-r": true, "--requirement": true, "-c": true, "--constraint": true, "-i": true, "--index-url": true,
+	"--extra-index-url": true, "-e": true, "--editable": true, "--target": true, "--prefix": true, "--registry": true,
+}
+
+// unpinnedPackages returns the package manager of a command and the
+// packages it installs without a version.
+func unpinnedPackages(fields []string) (string, []string) {
+	for len(fields) > 0 && (fields[0] == "sudo" || strings.Contains(fields[0], "=")) {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return "", nil
+	}
+
+	var manager string
+	var start int
+	var pinned func(string) bool
+	switch {
+	case (fields[0] == "apt-get" || fields[0] == "apt") && slices.Contains(fields, "install"):
+		manager, start = "apt-get", slices.Index(fields, "install")+1
+		pinned = func(pkg string) bool {
+			return strings.Contains(pkg, "=") || strings.HasSuffix(pkg, ".deb") || strings.Contains(pkg, "/")
+		}
+	case fields[0] == "apk" && fields[1] == "add":
+		manager, start = "apk", 2
+		pinned = func(pkg string) bool { return strings.ContainsAny(pkg, "=~<>") || strings.HasSuffix(pkg, ".apk") }
+	case pipCommandPattern.MatchString(fields[0]) && fields[1] == "install":
+		manager, start = "pip", 2
+		pinned = pinnedPipRequirement
+	case pythonCommandPattern.MatchString(fields[0]) && len(fields) > 3 && fields[1] == "-m" && fields[2] == "pip" && fields[3] == "install":
+		manager, start = "pip", 4
+		pinned = pinnedPipRequirement
+	case fields[0] == "npm" && (fields[1] == "install" || fields[1] == "i" || fields[1] == "add") && (slices.Contains(fields, "-g") || slices.Contains(fields, "--global")):
+		manager, start = "npm", 2
+		// Scoped packages start with @, so only a later @ carries a version.
+		// Paths, tarballs and git URLs are pinned by what they point at.
+		pinned = func(pkg string) bool {
+			return strings.LastIndex(pkg, "@") > 0 || (!strings.HasPrefix(pkg, "@") && strings.ContainsAny(pkg, "/:"))
+		}
+	default:
+		return "", nil
+	}
+
+	unpinned := []string{}
+	for i := start; i < len(fields); i++ {
+		arg := strings.Trim(fields[i], `"'`)
+		if strings.HasPrefix(arg, "-") {
+			if packageFlagsWithValue[arg] {
+				i++
+			}
+			continue
+		}
+		if arg == "" || strings.HasPrefix(arg, "$") || arg == "\\" || pinned(arg) {
+			continue
+		}
+		unpinned = append(unpinned, arg)
+	}
+	return manager, unpinned
+}
+
+// pinnedPipRequirement reports whether a pip argument carries a version
+// specifier or points at a local path, archive or URL.
+func pinnedPipRequirement(pkg string) bool {
+	return strings.ContainsAny(pkg, "=<>~@/") || strings.HasSuffix(pkg, ".whl") || strings.HasSuffix(pkg, ".tar.gz") || pkg == "."
+}
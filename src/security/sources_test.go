@@ -0,0 +1,144 @@
+package security
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSourceRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     CISRule
+		content  string
+		expected bool
+	}{
+		{
+			name:     "ADD of local files",
+			rule:     CopyInsteadOfAddRule{},
+			content:  "FROM alpine:3.20\nADD app.py requirements.txt /app/\n",
+			expected: false,
+		},
+		{
+			name:     "ADD of a local archive",
+			rule:     CopyInsteadOfAddRule{},
+			content:  "FROM scratch\nADD rootfs.tar.gz /\n",
+			expected: true,
+		},
+		{
+			name:     "ADD from a stage",
+			rule:     CopyInsteadOfAddRule{},
+			content:  "FROM alpine:3.20 AS build\nFROM alpine:3.20\nADD --from=build /out/app /app\n",
+			expected: true,
+		},
+		{
+			name:     "Remote ADD without checksum",
+			rule:     RemoteAddChecksumRule{},
+			content:  "FROM alpine:3.20\nADD https://example.com/tool.tar.gz /tmp/\n",
+			expected: false,
+		},
+		{
+			name:     "Remote ADD with checksum",
+			rule:     RemoteAddChecksumRule{},
+			content:  "FROM alpine:3.20\nADD --checksum=sha256:24454f830cdb571e2c4ad15481119c43b3cafd48dd869a9b2945d1036d1dc68d https://example.com/tool.tar.gz /tmp/\n",
+			expected: true,
+		},
+		{
+			name:     "Git source",
+			rule:     RemoteAddChecksumRule{},
+			content:  "FROM alpine:3.20\nADD https://github.com/moby/buildkit.git#v0.14.1 /src\n",
+			expected: true,
+		},
+		{
+			name:     "curl piped into sh",
+			rule:     PipeToShellRule{},
+			content:  "FROM debian:12\nRUN curl -fsSL https://get.example.com | sh\n",
+			expected: false,
+		},
+		{
+			name:     "wget piped into sudo bash",
+			rule:     PipeToShellRule{},
+			content:  "FROM debian:12\nRUN wget -qO- https://get.example.com | sudo -E bash -\n",
+			expected: false,
+		},
+		{
+			name:     "Command substitution",
+			rule:     PipeToShellRule{},
+			content:  "FROM debian:12\nRUN sh -c \"$(curl -fsSL https://get.example.com)\"\n",
+			expected: false,
+		},
+		{
+			name:     "Download piped into another tool",
+			rule:     PipeToShellRule{},
+			content:  "FROM debian:12\nRUN curl -fsSL https://example.com/key.asc | gpg --dearmor -o /usr/share/keyrings/example.gpg\n",
+			expected: true,
+		},
+		{
+			name:     "Unpinned apt packages",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM debian:12\nRUN apt-get update && apt-get install -y --no-install-recommends curl=7.88.1-10+deb12u5 git\n",
+			expected: false,
+		},
+		{
+			name:     "Pinned apk packages",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM alpine:3.20\nRUN apk add --no-cache --virtual .build-deps curl=8.5.0-r0 git~2.45\n",
+			expected: true,
+		},
+		{
+			name:     "Unpinned pip package",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM python:3.12\nRUN pip install --no-cache-dir -r requirements.txt gunicorn\n",
+			expected: false,
+		},
+		{
+			name:     "Pinned pip packages",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM python:3.12\nRUN python -m pip install -r requirements.txt flask==3.0.2 .\n",
+			expected: true,
+		},
+		{
+			name:     "Unpinned global npm package",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM node:20\nRUN npm install -g @angular/cli\n",
+			expected: false,
+		},
+		{
+			name:     "Local npm install",
+			rule:     PinnedPackagesRule{},
+			content:  "FROM node:20\nRUN npm install express && npm install -g pnpm@8.15.4\n",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := tt.rule.Check(newTarget(t, tt.content, ""))
+			if passed(results) != tt.expected {
+				t.Errorf("Expected passed=%v, got %+v", tt.expected, results)
+			}
+		})
+	}
+}
+
+func TestPinnedPackagesRuleNamesThePackages(t *testing.T) {
+	content := "FROM debian:12\nRUN apt-get update && \\\n    apt-get install -y curl=7.88.1-10+deb12u5 git make gcc libc-dev\n"
+	results := PinnedPackagesRule{}.Check(newTarget(t, content, ""))
+	if len(results) != 1 ||
+		results[0].Message != "apt-get installs packages without a version: git, make, gcc, ..." ||
+		results[0].Location.StartLine != 3 ||
+		!strings.Contains(results[0].Remediation, "curl=") {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestFixReplacesAddWithCopy(t *testing.T) {
+	content := "FROM alpine:3.20\nADD --chown=app app.py /app/\nADD rootfs.tar.gz /\nADD https://example.com/tool /usr/bin/tool\nUSER app\n"
+	fixed, edits := fix(t, content, FixOptions{})
+	expected := "FROM alpine:3.20\nCOPY --chown=app app.py /app/\nADD rootfs.tar.gz /\nADD https://example.com/tool /usr/bin/tool\nUSER app\n"
+	if fixed != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, fixed)
+	}
+	if len(edits) != 1 || edits[0].RuleID != "CIS-4.9" {
+		t.Errorf("Unexpected edits: %+v", edits)
+	}
+}
@@ -0,0 +1,156 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+// PragmaRuleID identifies the findings raised for malformed ignore pragmas.
+const PragmaRuleID = "DKZ-IGNORE"
+
+// Suppression kinds.
+const (
+	SuppressedInline   = "inline"
+	SuppressedBaseline = "baseline"
+)
+
+// Suppression explains why a failed result is not counted as a failure.
+type Suppression struct {
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+}
+
+// pragmaPattern matches the ignore pragmas:
+//
+//	# dockeryzer:ignore CIS-6.1,CIS-8.1 -- reason
+//	# dockeryzer:ignore-file CIS-5.2 -- reason
+//
+// The first form applies to the next instruction, the second to the whole file.
+var pragmaPattern = regexp.MustCompile(`^#\s*dockeryzer:(ignore-file|ignore)\b\s*(.*)$`)
+
+// pragma is a parsed ignore comment.
+type pragma struct {
+	Rules  []string
+	Reason string
+	// Instruction is the instruction the pragma applies to, nil for ignore-file.
+	Instruction *dockerfile.Instruction
+}
+
+// parsePragmas returns the valid ignore pragmas of df together with a failed
+// result for each malformed one. Pragmas without a reason are not applied.
+func parsePragmas(df *dockerfile.Dockerfile) ([]pragma, []CISResult) {
+	pragmas := []pragma{}
+	invalid := []CISResult{}
+
+	for _, comment := range df.Comments {
+		match := pragmaPattern.FindStringSubmatch(comment.Text)
+		if match == nil {
+			continue
+		}
+
+		ids, reason, _ := strings.Cut(match[2], "--")
+		p := pragma{Rules: strings.FieldsFunc(ids, isRuleSeparator), Reason: strings.TrimSpace(reason)}
+		if match[1] == "ignore" {
+			p.Instruction = instructionAfter(df, comment.Line)
+		}
+
+		message := ""
+		switch {
+		case len(p.Rules) == 0:
+			message = "Ignore pragma does not name any rule"
+		case p.Reason == "":
+			message = "Ignore pragma for " + strings.Join(p.Rules, ", ") + " does not give a reason"
+		case match[1] == "ignore" && p.Instruction == nil:
+			message = "Ignore pragma is not followed by an instruction"
+		}
+		if message != "" {
+			invalid = append(invalid, CISResult{
+				RuleID:      PragmaRuleID,
+				Description: "Ignore pragmas must name rules and give a reason",
+				Passed:      false,
+				Severity:    SeverityLow,
+				Message:     message,
+				Location:    commentLocation(df, comment),
+				Remediation: "Write the pragma as # dockeryzer:ignore CIS-6.1 -- why the finding is accepted",
+			})
+			continue
+		}
+		pragmas = append(pragmas, p)
+	}
+	return pragmas, invalid
+}
+
+// applyPragmas marks the failed results matched by a pragma as suppressed.
+func applyPragmas(pragmas []pragma, results []CISResult) {
+	for i := range results {
+		r := &results[i]
+		if r.Passed || r.Suppression != nil {
+			continue
+		}
+		for _, p := range pragmas {
+			if p.matches(r) {
+				r.Suppression = &Suppression{Kind: SuppressedInline, Reason: p.Reason}
+				break
+			}
+		}
+	}
+}
+
+func (p pragma) matches(r *CISResult) bool {
+	named := false
+	for _, id := range p.Rules {
+		if strings.EqualFold(id, r.RuleID) {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return false
+	}
+	if p.Instruction == nil {
+		return true
+	}
+	return r.Location != nil && r.Location.StartLine >= p.Instruction.StartLine && r.Location.StartLine <= p.Instruction.EndLine
+}
+
+// Fingerprint identifies a finding independently of its line number, so it
+// survives edits elsewhere in the Dockerfile.
+func Fingerprint(r CISResult) string {
+	subject := r.Message
+	if r.Location != nil && r.Location.Instruction != "" {
+		subject = strings.Join(strings.Fields(r.Location.Instruction), " ")
+	}
+	sum := sha256.Sum256([]byte(r.RuleID + "\x00" + r.Stage + "\x00" + subject))
+	return hex.EncodeToString(sum[:8])
+}
+
+func instructionAfter(df *dockerfile.Dockerfile, line int) *dockerfile.Instruction {
+	for _, inst := range df.Instructions {
+		if inst.StartLine > line {
+			return inst
+		}
+	}
+	return nil
+}
+
+func commentLocation(df *dockerfile.Dockerfile, comment dockerfile.Comment) *Location {
+	text := df.Lines[comment.Line-1]
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	return &Location{
+		File:        df.Path,
+		StartLine:   comment.Line,
+		StartColumn: start + 1,
+		EndLine:     comment.Line,
+		EndColumn:   len(strings.TrimRight(text, " \t")) + 1,
+		Instruction: comment.Text,
+		Source:      []string{text},
+	}
+}
+
+func isRuleSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
@@ -0,0 +1,103 @@
+package security
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/dockerfile"
+)
+
+func analyze(t *testing.T, content string) []CISResult {
+	t.Helper()
+	df, err := dockerfile.ParseString(content)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	results, err := NewCISAnalyzer().Analyze(df, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return results
+}
+
+func findResult(results []CISResult, ruleID string) *CISResult {
+	for i := range results {
+		if results[i].RuleID == ruleID && !results[i].Passed {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+func TestIgnorePragma(t *testing.T) {
+	results := analyze(t, `FROM alpine:3.19
+# dockeryzer:ignore CIS-6.1 -- metrics port is only reachable inside the cluster
+EXPOSE 80 9090
+# dockeryzer:ignore-file CIS-4.6, CIS-7.1 -- probed by the orchestrator
+`)
+
+	for _, id := range []string{"CIS-6.1", "CIS-4.6", "CIS-7.1"} {
+		r := findResult(results, id)
+		if r == nil || r.Suppression == nil || r.Suppression.Kind != SuppressedInline || r.Failed() {
+			t.Errorf("Expected %s to be suppressed inline, got %+v", id, r)
+		}
+	}
+	if r := findResult(results, "CIS-4.1"); r == nil || !r.Failed() {
+		t.Errorf("Rules not named by a pragma must still fail, got %+v", r)
+	}
+}
+
+func TestIgnorePragmaOnlyCoversNextInstruction(t *testing.T) {
+	results := analyze(t, `FROM alpine:3.19
+# dockeryzer:ignore CIS-6.1 -- reviewed
+RUN apk add --no-cache curl
+EXPOSE 80 9090
+`)
+
+	if r := findResult(results, "CIS-6.1"); r == nil || !r.Failed() {
+		t.Errorf("Expected CIS-6.1 on a later instruction to fail, got %+v", r)
+	}
+}
+
+func TestIgnorePragmaRequiresReason(t *testing.T) {
+	results := analyze(t, "FROM alpine:3.19\n# dockeryzer:ignore CIS-6.1\nEXPOSE 80 9090\n")
+
+	if r := findResult(results, "CIS-6.1"); r == nil || !r.Failed() {
+		t.Errorf("A pragma without reason must not suppress, got %+v", r)
+	}
+	invalid := findResult(results, PragmaRuleID)
+	if invalid == nil || invalid.Location.StartLine != 2 {
+		t.Errorf("Expected a finding for the malformed pragma on line 2, got %+v", invalid)
+	}
+}
+
+func TestFingerprintIgnoresLineNumbers(t *testing.T) {
+	before := findResult(analyze(t, "FROM alpine:latest\n"), "CIS-1.2")
+	after := findResult(analyze(t, "# syntax=docker/dockerfile:1\n\nFROM   alpine:latest\n"), "CIS-1.2")
+
+	if before.Fingerprint == "" || before.Fingerprint != after.Fingerprint {
+		t.Errorf("Expected equal fingerprints, got %q and %q", before.Fingerprint, after.Fingerprint)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := NewBaseline(analyze(t, "FROM alpine:latest\n")).Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := analyze(t, "FROM alpine:latest\nEXPOSE 80 443\n")
+	baseline.Apply(results)
+
+	if r := findResult(results, "CIS-1.2"); r.Failed() || r.Suppression.Kind != SuppressedBaseline {
+		t.Errorf("Expected CIS-1.2 to be suppressed by the baseline, got %+v", r)
+	}
+	if r := findResult(results, "CIS-6.1"); !r.Failed() {
+		t.Errorf("Expected the new CIS-6.1 finding to be reported, got %+v", r)
+	}
+}
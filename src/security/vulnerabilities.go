@@ -0,0 +1,124 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/sbom"
+	"github.com/jorgevvs2/dockeryzer/src/vuln"
+)
+
+// vulnerabilitySeverities map the severities of advisories to those of
+// results. Advisories without a severity count as medium.
+var vulnerabilitySeverities = map[string]string{
+	vuln.SeverityCritical: SeverityHigh,
+	vuln.SeverityHigh:     SeverityHigh,
+	vuln.SeverityMedium:   SeverityMedium,
+	vuln.SeverityLow:      SeverityLow,
+	vuln.SeverityUnknown:  SeverityMedium,
+}
+
+// DKZ-VULN Vulnerabilidades conhecidas nos pacotes e runtimes da imagem
+type VulnerabilityRule struct {
+	// Database holds the advisories. The rule is skipped when it is nil.
+	Database *vuln.Database
+}
+
+func (r VulnerabilityRule) ID() string { return "DKZ-VULN" }
+
+// CheckImage matches the packages of the image filesystem and the runtimes
+// named in its configuration against the advisory database, with one
+// result per vulnerable package and advisory. Without the filesystem only
+// the runtimes are matched.
+func (r VulnerabilityRule) CheckImage(target *ImageTarget) []CISResult {
+	if r.Database == nil {
+		return nil
+	}
+
+	packages := []sbom.Package{}
+	var osRelease map[string]string
+	if target.Filesystem != nil {
+		document, err := sbom.Generate(target.Name, target.Filesystem)
+		if err != nil {
+			return []CISResult{r.failure("The image packages could not be listed: " + err.Error())}
+		}
+		packages = document.Packages
+		osRelease = target.Filesystem.OSRelease()
+	}
+	if target.Inspect.Config != nil {
+		packages = append(packages, vuln.RuntimePackages(target.Inspect.Config.Env)...)
+	}
+
+	findings, err := r.Database.Match(osRelease, packages)
+	if err != nil {
+		return []CISResult{r.failure("The vulnerability database could not be read: " + err.Error())}
+	}
+	if len(findings) == 0 {
+		return []CISResult{{RuleID: r.ID(), Description: "Packages should not have known vulnerabilities", Passed: true}}
+	}
+
+	results := []CISResult{}
+	for _, finding := range findings {
+		results = append(results, CISResult{
+			RuleID:      r.ID(),
+			Description: "Packages should not have known vulnerabilities",
+			Passed:      false,
+			Severity:    vulnerabilitySeverities[finding.Severity],
+			Message:     vulnerabilityMessage(finding),
+			Remediation: vulnerabilityRemediation(finding),
+		})
+	}
+	return results
+}
+
+// failure reports that the image could not be checked, which must not pass
+// for a clean image.
+func (r VulnerabilityRule) failure(message string) CISResult {
+	return CISResult{
+		RuleID:      r.ID(),
+		Description: "Packages should not have known vulnerabilities",
+		Passed:      false,
+		Severity:    SeverityHigh,
+		Message:     message,
+		Remediation: "Import the advisories again with dockeryzer vulndb import",
+	}
+}
+
+// vulnerabilityMessage describes a finding, e.g. "CVE-2024-0727
+// (DSA-5632-1) in openssl 3.0.11-1~deb12u2 (Debian:12), MEDIUM 5.5, fixed
+// in 3.0.13-1~deb12u1: ...".
+func vulnerabilityMessage(finding vuln.Finding) string {
+	var b strings.Builder
+	b.WriteString(finding.ID)
+	if len(finding.Aliases) > 0 {
+		b.WriteString(" (" + strings.Join(finding.Aliases, ", ") + ")")
+	}
+	fmt.Fprintf(&b, " in %s %s (%s), %s", finding.Package.Name, finding.Package.Version, finding.Ecosystem, finding.Severity)
+	if finding.Score > 0 {
+		fmt.Fprintf(&b, " %.1f", finding.Score)
+	}
+	if finding.Fixed != "" {
+		b.WriteString(", fixed in " + finding.Fixed)
+	} else {
+		b.WriteString(", no fix available")
+	}
+	if finding.Summary != "" {
+		b.WriteString(": " + finding.Summary)
+	}
+	return b.String()
+}
+
+func vulnerabilityRemediation(finding vuln.Finding) string {
+	name := finding.Package.Name
+	if finding.Fixed == "" {
+		return "No fixed version is published yet; remove " + name + " if the image does not need it"
+	}
+	switch finding.Package.Type {
+	case sbom.TypeAPK, sbom.TypeDeb, sbom.TypeRPM:
+		return fmt.Sprintf("Rebuild on an updated base image or upgrade %s to %s or later", name, finding.Fixed)
+	case vuln.TypeRuntime:
+		return fmt.Sprintf("Move to a base image with %s %s or later", name, finding.Fixed)
+	default:
+		return fmt.Sprintf("Upgrade %s to %s or later", name, finding.Fixed)
+	}
+}
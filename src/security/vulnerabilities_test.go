@@ -0,0 +1,108 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/config"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs/imagefstest"
+	"github.com/jorgevvs2/dockeryzer/src/vuln"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+)
+
+const testAdvisories = `[
+  {
+    "id": "DSA-5632-1",
+    "aliases": ["CVE-2024-0727"],
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+    "affected": [{
+      "package": {"ecosystem": "Debian:12", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.13-1~deb12u1"}]}]
+    }]
+  },
+  {
+    "id": "BIT-node-2024-27983",
+    "aliases": ["CVE-2024-27983"],
+    "affected": [{
+      "package": {"ecosystem": "Bitnami", "name": "node"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "20.0.0"}, {"fixed": "20.12.1"}]}]
+    }]
+  }
+]`
+
+// newVulnerabilityDatabase imports testAdvisories into a temporary
+// directory.
+func newVulnerabilityDatabase(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "advisories.json")
+	if err := os.WriteFile(dump, []byte(testAdvisories), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := vuln.Import(filepath.Join(dir, "db"), dump); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return filepath.Join(dir, "db")
+}
+
+func TestVulnerabilityRule(t *testing.T) {
+	db, err := vuln.Open(newVulnerabilityDatabase(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rule := VulnerabilityRule{Database: db}
+
+	target := newImageTarget(t,
+		imagefstest.File{Name: "etc/os-release", Content: []byte("ID=debian\nVERSION_ID=\"12\"\n")},
+		imagefstest.File{Name: "var/lib/dpkg/status", Content: []byte("Package: libssl3\nStatus: install ok installed\nSource: openssl\nVersion: 3.0.11-1~deb12u2\nArchitecture: amd64\n")},
+	)
+	target.Inspect = image.InspectResponse{Config: &dockerspec.DockerOCIImageConfig{}}
+	target.Inspect.Config.Env = []string{"NODE_VERSION=20.11.1"}
+
+	results := rule.CheckImage(target)
+	if len(results) != 2 {
+		t.Fatalf("Expected two findings, got %+v", results)
+	}
+	if r := results[0]; r.Passed || r.Severity != SeverityHigh ||
+		r.Message != "CVE-2024-0727 (DSA-5632-1) in libssl3 3.0.11-1~deb12u2 (Debian:12), CRITICAL 9.8, fixed in 3.0.13-1~deb12u1" ||
+		!strings.Contains(r.Remediation, "3.0.13-1~deb12u1") {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if r := results[1]; r.Severity != SeverityMedium || !strings.Contains(r.Message, "CVE-2024-27983") || !strings.Contains(r.Remediation, "node 20.12.1") {
+		t.Errorf("Unexpected result %+v", r)
+	}
+
+	// Without the filesystem only the runtimes are matched.
+	results = rule.CheckImage(&ImageTarget{Name: "app:1.0", Inspect: target.Inspect})
+	if len(results) != 1 || !strings.Contains(results[0].Message, "node 20.11.1") {
+		t.Errorf("Expected the node finding, got %+v", results)
+	}
+
+	if results := rule.CheckImage(newImageTarget(t)); len(results) != 1 || !results[0].Passed {
+		t.Errorf("Expected a passed result, got %+v", results)
+	}
+	if results := (VulnerabilityRule{}).CheckImage(target); results != nil {
+		t.Errorf("Expected no results without a database, got %+v", results)
+	}
+}
+
+func TestConfiguredVulnerabilityDatabase(t *testing.T) {
+	analyzer, err := NewConfiguredCISAnalyzer(&config.Project{VulnerabilityDatabase: newVulnerabilityDatabase(t)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results := analyzer.AnalyzeImage(&ImageTarget{
+		Name:    "app:1.0",
+		Inspect: image.InspectResponse{Config: &dockerspec.DockerOCIImageConfig{}},
+	})
+	if len(results) != 1 || !results[0].Passed || results[0].Category != CategoryVulnerabilities {
+		t.Errorf("Expected a passed vulnerability result, got %+v", results)
+	}
+
+	if _, err := NewConfiguredCISAnalyzer(&config.Project{VulnerabilityDatabase: t.TempDir()}); err == nil {
+		t.Errorf("Expected an error for a directory without a database")
+	}
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// maxBuildErrorLines bounds the build output quoted when a build fails.
+const maxBuildErrorLines = 20
+
+// buildStepPattern matches the BuildKit plain progress line that starts a
+// step, e.g. "#7 [builder 3/5] RUN npm ci".
+var buildStepPattern = regexp.MustCompile(`^#(\d+) \[([^\]]+)\] (.*)$`)
+
+// buildCachedPattern matches the line that reports a step as cached.
+var buildCachedPattern = regexp.MustCompile(`^#(\d+) CACHED$`)
+
+// BuildStats describes a build of an image.
+type BuildStats struct {
+	Dockerfile string `json:"dockerfile"`
+	// DurationSeconds is the wall time of the build.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Steps counts the instructions built, FROM and the internal steps of
+	// BuildKit aside. Cached counts those that the build cache provided.
+	Steps  int `json:"steps"`
+	Cached int `json:"cached"`
+	// Order is the rank of the build among those sharing the build cache,
+	// from 1: later builds may reuse the layers of earlier ones.
+	Order int `json:"order,omitempty"`
+	// NoCache reports a build that did not use the build cache.
+	NoCache bool `json:"noCache"`
+}
+
+// BuildImage builds the Dockerfile with the build context in contextDir
+// and tags the image with tag. It runs docker build with BuildKit and its
+// plain progress output, from which it counts the cache hits. Cancelling ctx
+// stops the build.
+func BuildImage(ctx context.Context, contextDir, dockerfile, tag string, noCache bool) (BuildStats, error) {
+	args := []string{"build", "--progress=plain", "-t", tag, "-f", dockerfile}
+	if noCache {
+		args = append(args, "--no-cache")
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", append(args, contextDir)...)
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	stats := BuildStats{Dockerfile: dockerfile, DurationSeconds: time.Since(start).Seconds(), NoCache: noCache}
+	if err != nil {
+		return stats, fmt.Errorf("failed to build %s: %w\n%s", dockerfile, err, lastLines(output.String(), maxBuildErrorLines))
+	}
+	stats.Steps, stats.Cached = parseBuildProgress(&output)
+	return stats, nil
+}
+
+// RemoveImage removes a tagged image from the Docker daemon, along with its
+// untagged parents.
+func RemoveImage(tag string) error {
+	_, err := getDockerClient().ImageRemove(context.Background(), tag, image.RemoveOptions{PruneChildren: true})
+	return err
+}
+
+// parseBuildProgress counts the steps of a BuildKit plain progress output
+// and those served from the build cache.
+func parseBuildProgress(r io.Reader) (steps, cached int) {
+	counted := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := buildStepPattern.FindStringSubmatch(line); match != nil {
+			if match[2] == "internal" || strings.HasPrefix(match[3], "FROM ") || counted[match[1]] {
+				continue
+			}
+			counted[match[1]] = true
+			steps++
+		} else if match := buildCachedPattern.FindStringSubmatch(line); match != nil && counted[match[1]] {
+			cached++
+		}
+	}
+	return steps, cached
+}
+
+// lastLines returns the last n lines of text.
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
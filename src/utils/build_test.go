@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBuildProgress(t *testing.T) {
+	output := `#0 building with "default" instance using docker driver
+
+#1 [internal] load build definition from Dockeryzer.Dockerfile
+#1 transferring dockerfile: 312B done
+#1 DONE 0.0s
+
+#2 [internal] load metadata for docker.io/library/node:20-alpine
+#2 DONE 0.8s
+
+#3 [deps 1/4] FROM docker.io/library/node:20-alpine@sha256:0123
+#3 CACHED
+
+#4 [deps 2/4] WORKDIR /app
+#4 CACHED
+
+#5 [deps 3/4] COPY package.json package-lock.json ./
+#5 CACHED
+
+#6 [deps 4/4] RUN npm ci --omit=dev
+#6 0.512 added 120 packages in 3s
+#6 DONE 3.4s
+
+#7 [stage-1 2/3] COPY --from=deps /app/node_modules ./node_modules
+#7 DONE 0.3s
+
+#6 [deps 4/4] RUN npm ci --omit=dev
+#6 DONE 3.4s
+
+#8 exporting to image
+#8 DONE 0.1s
+`
+
+	steps, cached := parseBuildProgress(strings.NewReader(output))
+	if steps != 4 || cached != 2 {
+		t.Errorf("Expected 4 steps with 2 cached, got %d steps with %d cached", steps, cached)
+	}
+
+	steps, cached = parseBuildProgress(strings.NewReader("Step 1/2 : FROM alpine\n"))
+	if steps != 0 || cached != 0 {
+		t.Errorf("Expected no steps outside of BuildKit output, got %d and %d", steps, cached)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	if got := lastLines("a\nb\nc\n", 2); got != "b\nc" {
+		t.Errorf("Expected the last 2 lines, got %q", got)
+	}
+	if got := lastLines("a", 5); got != "a" {
+		t.Errorf("Expected the whole text, got %q", got)
+	}
+}
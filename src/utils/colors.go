@@ -1,17 +1,13 @@
 package utils
 
 import (
-	"fmt"
 	"github.com/fatih/color"
-	"os"
 )
 
 func safePrintf(output *color.Color, format string, args ...interface{}) {
 	_, err := output.Printf(format, args...)
 	if err != nil {
-		fmt.Println("Failed to call colored Printf()")
-		fmt.Println(err)
-		os.Exit(0)
+		Fatal(ExitFailure, "Failed to call colored Printf():", err)
 	}
 }
 
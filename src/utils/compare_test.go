@@ -9,18 +9,22 @@ import (
 	"testing"
 
 	"github.com/docker/docker/api/types"
+	"github.com/fatih/color"
 )
 
 // Helper to capture stdout
 func captureOutput(f func()) string {
 	old := os.Stdout
+	oldColor := color.Output
 	r, w, _ := os.Pipe()
 	os.Stdout = w
+	color.Output = w
 
 	f()
 
 	w.Close()
 	os.Stdout = old
+	color.Output = oldColor
 
 	var buf bytes.Buffer
 	io.Copy(&buf, r)
@@ -3,20 +3,22 @@ package utils
 import (
 	"context"
 	"fmt"
-	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
 )
 
 func getDockerClient() *client.Client {
 	ctx := context.Background()
 	cli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
-		fmt.Println("Failed to retrieve Docker client")
-		fmt.Println(err)
-		os.Exit(0)
+		Fatal(ExitDockerError, "Failed to retrieve Docker client:", err)
 	}
 	cli.NegotiateAPIVersion(ctx)
 	return cli
@@ -27,14 +29,123 @@ func GetDockerImageInspectByIdOrName(idOrName string) types.ImageInspect {
 
 	imageInspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), idOrName)
 	if err != nil {
-		fmt.Printf("Failed to retrieve image using the provided name: %s\n", idOrName)
-		fmt.Println(err)
-		os.Exit(0)
+		Fatal(ExitDockerError, fmt.Sprintf("Failed to retrieve image using the provided name: %s\n%s", idOrName, err))
 	}
 
 	return imageInspect
 }
 
+// ExportImage saves an image through the Docker API and reads its
+// filesystem. The caller closes the image to remove the exported files.
+func ExportImage(idOrName string) (*imagefs.Image, error) {
+	archive, err := getDockerClient().ImageSave(context.Background(), []string{idOrName})
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	return imagefs.ReadArchive(archive)
+}
+
 func ExecDockerBuildCommand(imageName string) *exec.Cmd {
 	return exec.Command("docker", "build", "-t", imageName, "-f", "Dockeryzer.Dockerfile", ".")
 }
+
+// PinImage returns reference pinned to its most specific version tag and
+// the digest of the matching local image, e.g. node:20.11.1@sha256:..., or
+// an empty string when the image is not available locally or has no version
+// tag of the same variant.
+func PinImage(reference string) string {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return ""
+	}
+	defer dockerClient.Close()
+
+	imageInspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), reference)
+	if err != nil {
+		return ""
+	}
+
+	repository := imageRepository(reference)
+	tag := versionTag(reference, imageInspect.RepoTags)
+	if tag == "" {
+		return ""
+	}
+	pinned := repositoryName(reference) + ":" + tag
+	for _, repoDigest := range imageInspect.RepoDigests {
+		if name, sum, found := strings.Cut(repoDigest, "@"); found && imageRepository(name) == repository {
+			return pinned + "@" + sum
+		}
+	}
+	return pinned
+}
+
+// versionTagPattern splits a tag into its numeric version and its variant,
+// e.g. 20.11.1 and -alpine for 20.11.1-alpine.
+var versionTagPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(.*)$`)
+
+// versionTag returns the tag of repoTags, of the repository of reference,
+// with the most specific numeric version and the same variant as the tag of
+// reference, e.g. 20.11.1-alpine for node:20-alpine. It returns an empty
+// string when there is none.
+func versionTag(reference string, repoTags []string) string {
+	variant := tagVariant(imageTag(reference))
+	best, bestVersion := "", []int(nil)
+	for _, repoTag := range repoTags {
+		if imageRepository(repoTag) != imageRepository(reference) {
+			continue
+		}
+		tag := imageTag(repoTag)
+		match := versionTagPattern.FindStringSubmatch(tag)
+		if match == nil || match[2] != variant {
+			continue
+		}
+		version := []int{}
+		for _, field := range strings.Split(match[1], ".") {
+			n, _ := strconv.Atoi(field)
+			version = append(version, n)
+		}
+		if len(version) > len(bestVersion) || (len(version) == len(bestVersion) && slices.Compare(version, bestVersion) > 0) {
+			best, bestVersion = tag, version
+		}
+	}
+	return best
+}
+
+// tagVariant returns the variant of an image tag: the suffix after the
+// version, e.g. -alpine for 20-alpine, the tag itself with a leading dash
+// for a tag without version such as alpine, and nothing for latest.
+func tagVariant(tag string) string {
+	if match := versionTagPattern.FindStringSubmatch(tag); match != nil {
+		return match[2]
+	}
+	if tag == "" || tag == "latest" {
+		return ""
+	}
+	return "-" + tag
+}
+
+// imageTag returns the tag of an image reference, empty when it has none.
+func imageTag(reference string) string {
+	name := strings.Split(reference, "@")[0]
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// repositoryName returns an image reference without its tag and digest.
+func repositoryName(reference string) string {
+	name := strings.Split(reference, "@")[0]
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[:i]
+	}
+	return name
+}
+
+// imageRepository returns the repository of an image reference without its
+// tag, digest and implicit Docker Hub prefixes.
+func imageRepository(reference string) string {
+	name := strings.TrimPrefix(repositoryName(reference), "docker.io/")
+	return strings.TrimPrefix(name, "library/")
+}
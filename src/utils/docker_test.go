@@ -0,0 +1,57 @@
+package utils
+
+import "testing"
+
+func TestVersionTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		repoTags  []string
+		expected  string
+	}{
+		{
+			name:      "Latest",
+			reference: "node:latest",
+			repoTags:  []string{"node:latest", "node:20", "node:20-bookworm-slim", "node:20.11-alpine", "node:20.11.1", "node:20.11"},
+			expected:  "20.11.1",
+		},
+		{
+			name:      "Variant",
+			reference: "node:20-alpine",
+			repoTags:  []string{"node:20-alpine", "node:20.11.1", "node:20.11.1-alpine3.19", "node:20.11.1-alpine", "node:alpine"},
+			expected:  "20.11.1-alpine",
+		},
+		{
+			name:      "Variant without version",
+			reference: "docker.io/library/python:slim",
+			repoTags:  []string{"python:slim", "python:3.12.2-slim", "python:3.12-slim", "python:3.12.2"},
+			expected:  "3.12.2-slim",
+		},
+		{
+			name:      "No tag",
+			reference: "golang",
+			repoTags:  []string{"golang:1.22.1", "golang:1.22.0", "golang:1.22"},
+			expected:  "1.22.1",
+		},
+		{
+			name:      "No version tag",
+			reference: "node:latest",
+			repoTags:  []string{"node:latest", "node:lts-bookworm", "node:iron"},
+			expected:  "",
+		},
+		{
+			name:      "Other repository",
+			reference: "node:latest",
+			repoTags:  []string{"mirror.example.com/node:20.11.1"},
+			expected:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tag := versionTag(tt.reference, tt.repoTags); tag != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, tag)
+			}
+		})
+	}
+}
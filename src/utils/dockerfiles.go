@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
@@ -101,7 +100,7 @@ func getDockerfileContent(ignoreComments bool) string {
 	// Use the embedded API key
 	apiKey := config.APIKey
 	if apiKey == "" {
-		log.Fatal("API key not set in binary. Please rebuild with -ldflags")
+		Fatal(ExitAIError, "API key not set in binary. Please rebuild with -ldflags")
 	}
 
 	// Detectar tecnologias do projeto (heurística + AI se necessário)
@@ -161,7 +160,7 @@ func getDockerfileContent(ignoreComments bool) string {
 func CreateDockerfileContent(ignoreComments bool) {
 	f, err := os.Create("Dockeryzer.Dockerfile")
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitFailure, err)
 	}
 
 	defer f.Close()
@@ -170,7 +169,7 @@ func CreateDockerfileContent(ignoreComments bool) {
 	_, err2 := f.WriteString(content)
 
 	if err2 != nil {
-		log.Fatal(err2)
+		Fatal(ExitFailure, err2)
 	}
 }
 
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"log"
 	"os"
 )
 
@@ -59,7 +58,7 @@ dist
 func CreateDockerignoreContent() {
 	f, err := os.Create(".dockerignore")
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitFailure, err)
 	}
 
 	defer DeferCloseFile(f)
@@ -68,6 +67,6 @@ func CreateDockerignoreContent() {
 	_, err2 := f.WriteString(content)
 
 	if err2 != nil {
-		log.Fatal(err2)
+		Fatal(ExitFailure, err2)
 	}
 }
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// Exit codes shared by every command so CI pipelines can tell failures apart.
+const (
+	ExitOK = 0
+	// ExitPolicyViolation means the analysis ran but the results broke the
+	// --fail-on or --min-score thresholds.
+	ExitPolicyViolation = 1
+	// ExitUsage means the command line was invalid.
+	ExitUsage = 2
+	// ExitDockerError means the Docker daemon could not be reached or refused a request.
+	ExitDockerError = 3
+	// ExitAIError means the AI provider was not configured or failed.
+	ExitAIError = 4
+	// ExitFailure covers any other error, such as unreadable or unwritable files.
+	ExitFailure = 5
+)
+
+// Fatal prints args to stderr and exits with code.
+func Fatal(code int, args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+	os.Exit(code)
+}
@@ -1,13 +1,12 @@
 package utils
 
 import (
-	"log"
 	"os"
 )
 
 func DeferCloseFile(f *os.File) {
 	err := f.Close()
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitFailure, err)
 	}
 }
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// Identifiers of the checks behind the image improvement suggestions.
+const (
+	ImageSizeCheck    = "IMG-SIZE"
+	ImageLayersCheck  = "IMG-LAYERS"
+	ImageRuntimeCheck = "IMG-RUNTIME"
+	ImageWasteCheck   = "IMG-WASTE"
+)
+
+// ImageChecks describes the checks run against an image, keyed by ID.
+var ImageChecks = map[string]string{
+	ImageSizeCheck:    "Image size should stay under 250 MB",
+	ImageLayersCheck:  "Image should have at most 10 layers",
+	ImageRuntimeCheck: "Language runtime should be detectable and supported",
+	ImageWasteCheck:   "Layers should not carry files deleted or overwritten later",
+}
+
+// ImageSuggestion is an improvement suggestion raised by one of the ImageChecks.
+type ImageSuggestion struct {
+	CheckID string `json:"checkId"`
+	Message string `json:"message"`
+}
+
+// ImageAnalysis is the serializable result of analyzing an image.
+type ImageAnalysis struct {
+	Name      string        `json:"name"`
+	ID        string        `json:"id"`
+	Tags      []string      `json:"tags"`
+	SizeBytes int64         `json:"sizeBytes"`
+	Size      string        `json:"size"`
+	Layers    int           `json:"layers"`
+	Language  *LanguageInfo `json:"language"`
+	// Runtimes are all the runtimes detected, the most confident first.
+	Runtimes []LanguageInfo `json:"runtimes"`
+	// BaseOS is only filled in when the filesystem is analyzed.
+	BaseOS      *OSInfo           `json:"baseOS,omitempty"`
+	Author      string            `json:"author"`
+	Created     string            `json:"created"`
+	OS          string            `json:"os"`
+	Suggestions []ImageSuggestion `json:"suggestions"`
+	// LayerBreakdown is only filled in when the layers are analyzed.
+	LayerBreakdown *LayerBreakdown `json:"layerBreakdown,omitempty"`
+}
+
+// GetImageAnalysis analyzes an image from its configuration and, when fsys
+// is not nil, from its filesystem.
+func GetImageAnalysis(name string, imageInspect image.InspectResponse, fsys *imagefs.Image) ImageAnalysis {
+	tags := imageInspect.RepoTags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	languages := DetectLanguages(imageInspect, fsys)
+	var language *LanguageInfo
+	if len(languages) > 0 {
+		language = &languages[0]
+	}
+
+	return ImageAnalysis{
+		Name:        name,
+		ID:          imageInspect.ID,
+		Tags:        tags,
+		SizeBytes:   imageInspect.Size,
+		Size:        GetImageSizeString(imageInspect),
+		Layers:      GetImageNumberOfLayers(imageInspect),
+		Language:    language,
+		Runtimes:    languages,
+		BaseOS:      DetectOS(fsys),
+		Author:      GetImageAuthor(imageInspect),
+		Created:     imageInspect.Created,
+		OS:          imageInspect.Os,
+		Suggestions: GetImageImprovementSuggestions(imageInspect, fsys),
+	}
+}
+
+// GetImageImprovementSuggestions checks the image against the ImageChecks.
+// The runtimes are detected from the filesystem too when fsys is not nil.
+func GetImageImprovementSuggestions(imageInspect image.InspectResponse, fsys *imagefs.Image) []ImageSuggestion {
+	suggestions := []ImageSuggestion{}
+
+	languages := DetectLanguages(imageInspect, fsys)
+	isBigImage := GetImageSizeInMBs(imageInspect) > 250
+	hasManyLayers := GetImageNumberOfLayers(imageInspect) > 10
+	hasOutdatedLanguage := hasOutdatedLanguage(languages)
+
+	if isBigImage {
+		suggestions = append(suggestions, ImageSuggestion{
+			CheckID: ImageSizeCheck,
+			Message: "Consider reducing the size of your image. Try using smaller base images and ensure that no unnecessary files are included.",
+		})
+	}
+
+	if hasManyLayers {
+		suggestions = append(suggestions, ImageSuggestion{
+			CheckID: ImageLayersCheck,
+			Message: "Your image has multiple layers. Consider applying a multi-build stage strategy or combining commands to reduce the number of layers.",
+		})
+	}
+
+	for _, suggestion := range languageSuggestions(languages) {
+		suggestions = append(suggestions, ImageSuggestion{
+			CheckID: ImageRuntimeCheck,
+			Message: strings.TrimPrefix(suggestion, "  - "),
+		})
+	}
+
+	shouldShowSuggestions := isBigImage || hasManyLayers || hasOutdatedLanguage
+	if shouldShowSuggestions && len(languages) == 0 {
+		suggestions = append(suggestions, ImageSuggestion{
+			CheckID: ImageRuntimeCheck,
+			Message: "No programming language runtime detected. Ensure your image is configured correctly if it requires a runtime environment.",
+		})
+	}
+
+	return suggestions
+}
+
+func printImageSuggestions(suggestions []ImageSuggestion) {
+	if len(suggestions) == 0 {
+		return
+	}
+
+	fmt.Println("\n Improvement suggestions:")
+	for _, suggestion := range suggestions {
+		fmt.Printf("  - %s\n", suggestion.Message)
+	}
+}
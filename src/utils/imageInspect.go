@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
 )
 
 func GetImageSizeInMBs(imageInspect image.InspectResponse) float32 {
@@ -80,66 +81,40 @@ func GetImageLayersWithColor(imageInspect image.InspectResponse) string {
 	return ErrorSprintf("%d", numberOfLayers)
 }
 
-func PrintImageResults(name string, imageInspect image.InspectResponse, minimal bool, ignoreSuggestions bool) {
+// PrintImageResults prints the details of an image. The runtimes and the
+// base OS are read from the filesystem too when fsys is not nil.
+func PrintImageResults(name string, imageInspect image.InspectResponse, fsys *imagefs.Image, minimal bool, ignoreSuggestions bool) {
 	fmt.Printf("Details of image ")
 	BoldPrintf("%s:\n", name)
 	fmt.Printf("  - Tags: %s\n", imageInspect.RepoTags)
 	fmt.Println(GetImageSizeWithColor(imageInspect))
 	fmt.Println(GetImageLayersWithColor(imageInspect))
 
-	// Nova função para detectar a linguagem principal
-	PrintLanguageWithColor(imageInspect)
+	// Runtimes detectados, do mais para o menos confiável
+	printLanguages(DetectLanguages(imageInspect, fsys))
 
 	if !minimal {
 		fmt.Printf("  - Author: %s\n", GetImageAuthor(imageInspect))
 		fmt.Printf("  - Creation date: %s\n", GetImageFormattedCreationDate(imageInspect))
 		fmt.Printf("  - OS: %s\n", imageInspect.Os)
+		if baseOS := DetectOS(fsys); baseOS != nil {
+			fmt.Printf("  - Base OS: %s\n", baseOS.PrettyName)
+		}
 	}
 
-	sizeInMBs := GetImageSizeInMBs(imageInspect)
-	numberOfLayers := GetImageNumberOfLayers(imageInspect)
-	hasOutdatedLanguage := HasOutdatedLanguage(imageInspect)
-
-	isBigImage := sizeInMBs > 250
-	hasManyLayers := numberOfLayers > 10
-
-	shouldShowSuggestions := isBigImage || hasManyLayers || hasOutdatedLanguage
-
 	if ignoreSuggestions {
 		return
 	}
 
-	if shouldShowSuggestions {
-		fmt.Println("\n Improvement suggestions:")
-	}
-
-	if isBigImage {
-		fmt.Println("  - Consider reducing the size of your image. Try using smaller base images and ensure that no unnecessary files are included.")
-	}
-
-	if hasManyLayers {
-		fmt.Println("  - Your image has multiple layers. Consider applying a multi-build stage strategy or combining commands to reduce the number of layers.")
-	}
-
-	// Sugestões específicas por linguagem
-	languageSuggestions := GetLanguageImprovementSuggestions(imageInspect)
-	for _, suggestion := range languageSuggestions {
-		fmt.Println(suggestion)
-	}
-
-	// Se nenhuma linguagem foi detectada
-	lang := DetectPrimaryLanguage(imageInspect)
-	if lang == nil && !ignoreSuggestions && shouldShowSuggestions {
-		fmt.Println("  - No programming language runtime detected. Ensure your image is configured correctly if it requires a runtime environment.")
-	}
+	printImageSuggestions(GetImageImprovementSuggestions(imageInspect, fsys))
 }
 
-func PrintImageAnalyzeResults(name string, imageInspect image.InspectResponse) {
-	PrintImageResults(name, imageInspect, false, false)
+func PrintImageAnalyzeResults(name string, imageInspect image.InspectResponse, fsys *imagefs.Image) {
+	PrintImageResults(name, imageInspect, fsys, false, false)
 }
 
-func PrintImageCompareResults(name string, imageInspect image.InspectResponse) {
-	PrintImageResults(name, imageInspect, true, true)
+func PrintImageCompareResults(name string, imageInspect image.InspectResponse, fsys *imagefs.Image) {
+	PrintImageResults(name, imageInspect, fsys, true, true)
 }
 
 func PrintImageCompareLayersResults(image1 string, image1Inspect image.InspectResponse, image2 string, image2Inspect image.InspectResponse) {
@@ -226,9 +201,9 @@ func PrintImageCompareSizeResults(image1 string, image1Inspect image.InspectResp
 	fmt.Printf(" than image ")
 	ErrorPrintf("%s", biggerImage)
 	fmt.Printf(" (")
-	SuccessPrintf(minorImageString)
+	SuccessPrintf("%s", minorImageString)
 	fmt.Printf(" < ")
-	ErrorPrintf(biggerImageString)
+	ErrorPrintf("%s", biggerImageString)
 	fmt.Println(").")
 }
 
@@ -273,13 +248,16 @@ func PrintImageCompareLanguageResults(image1 string, image1Inspect image.Inspect
 		return
 	}
 
-	if major1 == major2 {
-		fmt.Printf("  - Both images use %s version %s (minor version may differ)\n",
+	minor1 := getMinorVersion(lang1.Version)
+	minor2 := getMinorVersion(lang2.Version)
+
+	if major1 == major2 && minor1 == minor2 {
+		fmt.Printf("  - Both images use %s version %s (patch version may differ)\n",
 			lang1.Name, lang1.Version)
 		return
 	}
 
-	if major1 > major2 {
+	if major1 > major2 || (major1 == major2 && minor1 > minor2) {
 		fmt.Printf("  - Image ")
 		SuccessPrintf("%s", image1)
 		fmt.Printf(" uses newer %s (", lang1.Name)
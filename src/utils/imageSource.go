@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// Prefixes of image references read without a Docker daemon.
+const (
+	OCILayoutSource     = "oci:"
+	DockerArchiveSource = "docker-archive:"
+	RegistrySource      = "registry:"
+)
+
+// LoadImage reads the configuration of an image and, unless metadataOnly,
+// its filesystem. The reference is one of
+//
+//	oci:<dir>                an OCI image layout directory
+//	docker-archive:<file>    a tarball written by docker save
+//	registry:<image>         an image pulled from a registry
+//	<image>                  an image of the Docker daemon
+//
+// The filesystem is nil with metadataOnly; otherwise the caller closes it.
+func LoadImage(reference string, metadataOnly bool) (types.ImageInspect, *imagefs.Image, error) {
+	var img *imagefs.Image
+	var err error
+	switch {
+	case strings.HasPrefix(reference, OCILayoutSource):
+		img, err = imagefs.LoadOCILayout(strings.TrimPrefix(reference, OCILayoutSource))
+	case strings.HasPrefix(reference, DockerArchiveSource):
+		img, err = readArchiveFile(strings.TrimPrefix(reference, DockerArchiveSource))
+	case strings.HasPrefix(reference, RegistrySource):
+		img, err = imagefs.Pull(context.Background(), strings.TrimPrefix(reference, RegistrySource), imagefs.PullOptions{ConfigOnly: metadataOnly})
+	default:
+		return loadDaemonImage(reference, metadataOnly)
+	}
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+
+	imageInspect := img.Inspect()
+	if metadataOnly {
+		img.Close()
+		return imageInspect, nil, nil
+	}
+	return imageInspect, img, nil
+}
+
+// LoadImageExitCode returns the exit code for an error of LoadImage:
+// ExitDockerError for the images of the Docker daemon and ExitFailure for
+// the sources read without it.
+func LoadImageExitCode(reference string) int {
+	for _, source := range []string{OCILayoutSource, DockerArchiveSource, RegistrySource} {
+		if strings.HasPrefix(reference, source) {
+			return ExitFailure
+		}
+	}
+	return ExitDockerError
+}
+
+// readArchiveFile reads a docker save tarball from disk.
+func readArchiveFile(name string) (*imagefs.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return imagefs.ReadArchive(f)
+}
+
+// loadDaemonImage inspects and, unless metadataOnly, exports an image of the
+// Docker daemon.
+func loadDaemonImage(idOrName string, metadataOnly bool) (types.ImageInspect, *imagefs.Image, error) {
+	dockerClient := getDockerClient()
+	imageInspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), idOrName)
+	if err != nil {
+		return imageInspect, nil, fmt.Errorf("failed to retrieve image using the provided name: %s\n%w", idOrName, err)
+	}
+	if metadataOnly {
+		return imageInspect, nil, nil
+	}
+	img, err := ExportImage(idOrName)
+	if err != nil {
+		return imageInspect, nil, fmt.Errorf("failed to export the image filesystem: %w", err)
+	}
+	return imageInspect, img, nil
+}
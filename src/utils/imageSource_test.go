@@ -0,0 +1,22 @@
+package utils
+
+import "testing"
+
+func TestLoadImageExitCode(t *testing.T) {
+	tests := []struct {
+		reference string
+		expected  int
+	}{
+		{"node:20-alpine", ExitDockerError},
+		{"sha256:0123", ExitDockerError},
+		{"oci:./layout", ExitFailure},
+		{"docker-archive:app.tar", ExitFailure},
+		{"registry:ghcr.io/acme/app:1.0", ExitFailure},
+	}
+
+	for _, tt := range tests {
+		if code := LoadImageExitCode(tt.reference); code != tt.expected {
+			t.Errorf("Expected exit code %d for %s, got %d", tt.expected, tt.reference, code)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/jorgevvs2/dockeryzer/src/config"
@@ -15,12 +14,12 @@ func CreateDockerfileWithLangChain(ignoreComments bool) {
 
 	apiKey := config.APIKey
 	if apiKey == "" {
-		log.Fatal("API key not set in binary. Please rebuild with -ldflags")
+		Fatal(ExitAIError, "API key not set in binary. Please rebuild with -ldflags")
 	}
 
 	projectTree, err := GetProjectStructure()
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitFailure, err)
 	}
 
 	tech := DetectProjectSmart(apiKey)
@@ -41,7 +40,7 @@ func CreateDockerfileWithLangChain(ignoreComments bool) {
 		openai.WithModel("gpt-4.1-mini"),
 	)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitAIError, err)
 	}
 
 	response, err := llms.GenerateFromSinglePrompt(
@@ -51,11 +50,11 @@ func CreateDockerfileWithLangChain(ignoreComments bool) {
 	)
 
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitAIError, err)
 	}
 
 	err = os.WriteFile("Dockeryzer.Dockerfile", []byte(response), 0644)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(ExitFailure, err)
 	}
 }
@@ -2,245 +2,245 @@ package utils
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/eol"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
 )
 
 type LanguageInfo struct {
-	Name    string
-	Version string
-	Color   string // "success", "warning", "error"
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Color   string `json:"status"` // "success", "warning", "error"
+	// EOL é a data de fim de suporte da versão, quando conhecida
+	EOL string `json:"eol,omitempty"`
+	// Confidence vai de 0 a 1, conforme a evidência mais forte encontrada
+	Confidence float64 `json:"confidence"`
+	// Source é a evidência, como "ENV NODE_VERSION" ou o caminho de um arquivo
+	Source string `json:"source,omitempty"`
 }
 
-// Detecta a linguagem principal da imagem
-func DetectPrimaryLanguage(imageInspect image.InspectResponse) *LanguageInfo {
-	envVars := imageInspect.Config.Env
-	cmd := imageInspect.Config.Cmd
-	entrypoint := imageInspect.Config.Entrypoint
-	workingDir := imageInspect.Config.WorkingDir
-
-	// Ordem de prioridade baseada em especificidade das variáveis
-	// Quanto mais específica a variável, maior a confiança
-
-	// 1. Node.js - verifica NODE_VERSION (muito específico)
-	if nodeVersion := detectNodeJSVersion(envVars); nodeVersion != "" {
-		return &LanguageInfo{
-			Name:    "Node.js",
-			Version: nodeVersion,
-			Color:   getNodeJSColor(nodeVersion),
-		}
-	}
+// Tabela de fim de suporte usada para classificar as versões
+var runtimeEndOfLife = eol.Embedded()
 
-	// 2. Python - verifica PYTHON_VERSION
-	if pythonVersion := detectPythonVersion(envVars); pythonVersion != "" {
-		return &LanguageInfo{
-			Name:    "Python",
-			Version: pythonVersion,
-			Color:   getPythonColor(pythonVersion),
-		}
-	}
-
-	// 3. Java - verifica JAVA_VERSION ou JAVA_HOME
-	if javaVersion := detectJavaVersion(envVars); javaVersion != "" {
-		return &LanguageInfo{
-			Name:    "Java",
-			Version: javaVersion,
-			Color:   getJavaColor(javaVersion),
-		}
-	}
+// Data de referência para o fim de suporte, substituída nos testes
+var now = time.Now
 
-	// 4. Go - verifica GOLANG_VERSION, GO_VERSION ou GOPATH
-	if goVersion := detectGoVersion(envVars); goVersion != "" {
-		return &LanguageInfo{
-			Name:    "Go",
-			Version: goVersion,
-			Color:   getGoColor(goVersion),
-		}
-	}
+// UseRuntimeEndOfLife substitui a tabela de fim de suporte dos runtimes,
+// por exemplo pela carregada com eol.Load a partir de um arquivo local.
+func UseRuntimeEndOfLife(dataset *eol.Dataset) {
+	runtimeEndOfLife = dataset
+}
 
-	// 5. PHP - verifica PHP_VERSION
-	if phpVersion := detectPHPVersion(envVars); phpVersion != "" {
-		return &LanguageInfo{
-			Name:    "PHP",
-			Version: phpVersion,
-			Color:   getPHPColor(phpVersion),
-		}
+// Detecta a linguagem principal da imagem, a de maior confiança entre as
+// encontradas na configuração. DetectLanguages também considera o sistema de
+// arquivos e retorna todos os runtimes.
+func DetectPrimaryLanguage(imageInspect image.InspectResponse) *LanguageInfo {
+	languages := DetectLanguages(imageInspect, nil)
+	if len(languages) == 0 {
+		return nil
 	}
+	return &languages[0]
+}
 
-	// 6. Ruby - verifica RUBY_VERSION
-	if rubyVersion := detectRubyVersion(envVars); rubyVersion != "" {
-		return &LanguageInfo{
-			Name:    "Ruby",
-			Version: rubyVersion,
-			Color:   getRubyColor(rubyVersion),
-		}
-	}
+// Confiança de cada tipo de evidência, de 0 a 1
+const (
+	confidenceBuildInfo  = 0.95 // versão gravada pelo compilador no binário
+	confidenceVersionEnv = 0.9  // variável de versão das imagens oficiais
+	confidenceFilesystem = 0.8  // runtime instalado no sistema de arquivos
+	confidenceBinary     = 0.7  // formato do binário executado
+	confidenceHomeEnv    = 0.6  // variável de diretório, sem versão
+	confidenceCommand    = 0.5  // nome do executável no CMD/ENTRYPOINT
+	confidenceHeuristic  = 0.3  // caminho do binário e tamanho da imagem
+)
 
-	// 7. .NET - verifica DOTNET_VERSION ou ASPNETCORE_VERSION
-	if dotnetVersion := detectDotNetVersion(envVars); dotnetVersion != "" {
-		return &LanguageInfo{
-			Name:    ".NET",
-			Version: dotnetVersion,
-			Color:   getDotNetColor(dotnetVersion),
-		}
-	}
+// Variáveis que indicam cada runtime, em ordem de prioridade. As de versão
+// são as definidas pelas imagens oficiais; as de diretório só indicam que o
+// runtime está instalado.
+var envRuntimes = []struct {
+	name     string
+	versions []string
+	homes    []string
+}{
+	{"Node.js", []string{"NODE_VERSION"}, nil},
+	{"Bun", []string{"BUN_VERSION"}, []string{"BUN_INSTALL", "BUN_INSTALL_BIN"}},
+	{"Deno", []string{"DENO_VERSION"}, []string{"DENO_DIR", "DENO_INSTALL_ROOT"}},
+	{"Python", []string{"PYTHON_VERSION"}, nil},
+	{"Kotlin", []string{"KOTLIN_VERSION"}, []string{"KOTLIN_HOME"}},
+	{"Scala", []string{"SCALA_VERSION"}, []string{"SCALA_HOME"}},
+	{"Groovy", []string{"GROOVY_VERSION"}, []string{"GROOVY_HOME"}},
+	{"Java", []string{"JAVA_VERSION"}, []string{"JAVA_HOME"}},
+	{"Go", []string{"GOLANG_VERSION", "GO_VERSION"}, []string{"GOPATH"}},
+	{"PHP", []string{"PHP_VERSION"}, nil},
+	{"Ruby", []string{"RUBY_VERSION"}, nil},
+	{".NET", []string{"DOTNET_VERSION", "ASPNETCORE_VERSION"}, nil},
+	{"Rust", []string{"RUST_VERSION"}, []string{"CARGO_HOME"}},
+	{"Elixir", []string{"ELIXIR_VERSION"}, []string{"MIX_HOME"}},
+	{"Erlang", []string{"OTP_VERSION"}, nil},
+	{"Dart", []string{"DART_VERSION"}, []string{"DART_SDK"}},
+	{"Swift", []string{"SWIFT_VERSION"}, nil},
+}
 
-	// 8. Rust - verifica RUST_VERSION ou CARGO_HOME
-	if rustVersion := detectRustVersion(envVars); rustVersion != "" {
-		return &LanguageInfo{
-			Name:    "Rust",
-			Version: rustVersion,
-			Color:   "success",
+// DetectLanguages retorna os runtimes da imagem, do mais para o menos
+// confiável, a partir das variáveis de ambiente, do CMD/ENTRYPOINT e, quando
+// fsys não é nil, dos arquivos e do binário executado pela imagem.
+func DetectLanguages(imageInspect image.InspectResponse, fsys *imagefs.Image) []LanguageInfo {
+	config := imageInspect.Config
+	if config == nil {
+		return []LanguageInfo{}
+	}
+	found := []LanguageInfo{}
+
+	// 1. Variáveis de ambiente
+	for _, runtime := range envRuntimes {
+		if variable, version := envValue(config.Env, runtime.versions); variable != "" {
+			found = append(found, LanguageInfo{Name: runtime.name, Version: version, Confidence: confidenceVersionEnv, Source: "ENV " + variable})
+		} else if variable, value := envValue(config.Env, runtime.homes); variable != "" {
+			version := "detected"
+			if variable == "JAVA_HOME" {
+				version = javaHomeVersion(value)
+			}
+			found = append(found, LanguageInfo{Name: runtime.name, Version: version, Confidence: confidenceHomeEnv, Source: "ENV " + variable})
 		}
 	}
 
-	// 9. Detecção por CMD/Entrypoint (para linguagens interpretadas)
-	if lang := detectByCommand(cmd, entrypoint); lang != nil {
-		return lang
+	// 2. Binário executado e runtimes instalados
+	if fsys != nil {
+		found = append(found, detectFromFilesystem(fsys, config.Env, config.Entrypoint, config.Cmd, config.WorkingDir)...)
 	}
 
-	// 10. Detecção por padrões de binários compilados (Go, Rust, C/C++)
-	if lang := detectCompiledBinary(entrypoint, cmd, workingDir, imageInspect.Size); lang != nil {
-		return lang
-	}
-
-	return nil
-}
+	// 3. Detecção por CMD/Entrypoint (para linguagens interpretadas)
+	found = append(found, detectByCommand(config.Cmd, config.Entrypoint)...)
 
-// Detectores de versão específicos (apenas por variáveis de ambiente)
-func detectNodeJSVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "NODE_VERSION=") {
-			return strings.TrimPrefix(envVar, "NODE_VERSION=")
+	// 4. Padrões de binários compilados, só sem outra evidência
+	if len(found) == 0 && fsys == nil {
+		if lang := detectCompiledBinary(config.Entrypoint, config.Cmd, config.WorkingDir, imageInspect.Size); lang != nil {
+			found = append(found, *lang)
 		}
 	}
-	return ""
-}
 
-func detectPythonVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "PYTHON_VERSION=") {
-			return strings.TrimPrefix(envVar, "PYTHON_VERSION=")
-		}
-	}
-	return ""
+	return mergeLanguages(found)
 }
 
-func detectJavaVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "JAVA_VERSION=") {
-			return strings.TrimPrefix(envVar, "JAVA_VERSION=")
+// mergeLanguages junta as evidências de cada runtime, mantendo a de maior
+// confiança e a versão mais precisa, e ordena por confiança. Em caso de
+// empate vale a ordem de detecção.
+func mergeLanguages(found []LanguageInfo) []LanguageInfo {
+	languages := []LanguageInfo{}
+	for _, candidate := range found {
+		i := slices.IndexFunc(languages, func(lang LanguageInfo) bool { return lang.Name == candidate.Name })
+		if i < 0 {
+			languages = append(languages, candidate)
+			continue
 		}
-		if strings.HasPrefix(envVar, "JAVA_HOME=") {
-			path := strings.TrimPrefix(envVar, "JAVA_HOME=")
-			if strings.Contains(path, "java-") {
-				parts := strings.Split(path, "java-")
-				if len(parts) > 1 {
-					version := strings.Split(parts[1], "/")[0]
-					return version
-				}
+		lang := &languages[i]
+		if candidate.Confidence > lang.Confidence {
+			version := lang.Version
+			*lang = candidate
+			if !hasVersion(candidate.Version) {
+				lang.Version = version
 			}
-			return "detected"
-		}
-	}
-	return ""
-}
-
-func detectGoVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "GOLANG_VERSION=") {
-			return strings.TrimPrefix(envVar, "GOLANG_VERSION=")
-		}
-		if strings.HasPrefix(envVar, "GO_VERSION=") {
-			return strings.TrimPrefix(envVar, "GO_VERSION=")
+		} else if !hasVersion(lang.Version) && hasVersion(candidate.Version) {
+			lang.Version = candidate.Version
 		}
 	}
 
-	// Verifica GOPATH apenas se não houver outras linguagens mais óbvias
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "GOPATH=") {
-			return "detected"
-		}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].Confidence > languages[j].Confidence })
+	for i := range languages {
+		classifyLanguage(&languages[i])
 	}
-
-	return ""
+	return languages
 }
 
-func detectPHPVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "PHP_VERSION=") {
-			return strings.TrimPrefix(envVar, "PHP_VERSION=")
-		}
-	}
-	return ""
+// hasVersion diz se a versão foi de fato encontrada
+func hasVersion(version string) bool {
+	return version != "" && version != "detected" && version != "unknown" && version != "compiled"
 }
 
-func detectRubyVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "RUBY_VERSION=") {
-			return strings.TrimPrefix(envVar, "RUBY_VERSION=")
+// envValue retorna a primeira das variáveis definida com valor
+func envValue(envVars []string, names []string) (string, string) {
+	for _, name := range names {
+		for _, envVar := range envVars {
+			if value, ok := strings.CutPrefix(envVar, name+"="); ok && value != "" {
+				return name, value
+			}
 		}
 	}
-	return ""
+	return "", ""
 }
 
-func detectDotNetVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "DOTNET_VERSION=") {
-			return strings.TrimPrefix(envVar, "DOTNET_VERSION=")
-		}
-		if strings.HasPrefix(envVar, "ASPNETCORE_VERSION=") {
-			return strings.TrimPrefix(envVar, "ASPNETCORE_VERSION=")
+// javaHomeVersion extrai a versão de caminhos como /usr/lib/jvm/java-17-openjdk
+func javaHomeVersion(path string) string {
+	if strings.Contains(path, "java-") {
+		parts := strings.Split(path, "java-")
+		if len(parts) > 1 {
+			return strings.Split(parts[1], "/")[0]
 		}
 	}
-	return ""
+	return "detected"
 }
 
-func detectRustVersion(envVars []string) string {
-	for _, envVar := range envVars {
-		if strings.HasPrefix(envVar, "RUST_VERSION=") {
-			return strings.TrimPrefix(envVar, "RUST_VERSION=")
-		}
-		if strings.HasPrefix(envVar, "CARGO_HOME=") {
-			return "detected"
-		}
-	}
-	return ""
+// Executáveis que indicam cada runtime no CMD/ENTRYPOINT
+var commandRuntimes = map[string]string{
+	"node":     "Node.js",
+	"nodejs":   "Node.js",
+	"npm":      "Node.js",
+	"npx":      "Node.js",
+	"yarn":     "Node.js",
+	"pnpm":     "Node.js",
+	"bun":      "Bun",
+	"bunx":     "Bun",
+	"deno":     "Deno",
+	"gunicorn": "Python",
+	"uvicorn":  "Python",
+	"java":     "Java",
+	"kotlin":   "Kotlin",
+	"scala":    "Scala",
+	"groovy":   "Groovy",
+	"php":      "PHP",
+	"php-fpm":  "PHP",
+	"ruby":     "Ruby",
+	"bundle":   "Ruby",
+	"rails":    "Ruby",
+	"dotnet":   ".NET",
+	"elixir":   "Elixir",
+	"mix":      "Elixir",
+	"iex":      "Elixir",
+	"erl":      "Erlang",
+	"dart":     "Dart",
+	"swift":    "Swift",
 }
 
-// Detecção por comando (para linguagens interpretadas)
-func detectByCommand(cmd []string, entrypoint []string) *LanguageInfo {
-	allCommands := append(entrypoint, cmd...)
-	commandStr := strings.Join(allCommands, " ")
-
-	// Ordem de prioridade
-	if strings.Contains(commandStr, "node") || strings.Contains(commandStr, "npm") {
-		return &LanguageInfo{Name: "Node.js", Version: "unknown", Color: "warning"}
-	}
-
-	if strings.Contains(commandStr, "python") {
-		return &LanguageInfo{Name: "Python", Version: "unknown", Color: "warning"}
-	}
-
-	if strings.Contains(commandStr, "java -jar") || strings.Contains(commandStr, "java ") {
-		return &LanguageInfo{Name: "Java", Version: "unknown", Color: "warning"}
-	}
-
-	if strings.Contains(commandStr, "php") {
-		return &LanguageInfo{Name: "PHP", Version: "unknown", Color: "warning"}
-	}
-
-	if strings.Contains(commandStr, "ruby") {
-		return &LanguageInfo{Name: "Ruby", Version: "unknown", Color: "warning"}
-	}
-
-	if strings.Contains(commandStr, "dotnet") {
-		return &LanguageInfo{Name: ".NET", Version: "unknown", Color: "warning"}
+// Executáveis versionados, como python3.12 e php-fpm8.2
+var versionedCommand = regexp.MustCompile(`^(python|php-fpm|php)[0-9.]*$`)
+
+// Detecção por comando (para linguagens interpretadas). Considera o nome de
+// cada palavra do comando, inclusive dentro de sh -c.
+func detectByCommand(cmd []string, entrypoint []string) []LanguageInfo {
+	found := []LanguageInfo{}
+	for _, arg := range append(slices.Clone(entrypoint), cmd...) {
+		for _, field := range strings.Fields(arg) {
+			name := path.Base(strings.Trim(field, `"';&|()`))
+			if match := versionedCommand.FindStringSubmatch(name); match != nil {
+				name = match[1]
+			}
+			runtime, ok := commandRuntimes[name]
+			if name == "python" {
+				runtime, ok = "Python", true
+			}
+			if ok && !slices.ContainsFunc(found, func(lang LanguageInfo) bool { return lang.Name == runtime }) {
+				found = append(found, LanguageInfo{Name: runtime, Version: "unknown", Confidence: confidenceCommand, Source: "command " + name})
+			}
+		}
 	}
-
-	return nil
+	return found
 }
 
 // Detecção de binários compilados (Go, Rust, C/C++)
@@ -276,17 +276,19 @@ func detectCompiledBinary(entrypoint []string, cmd []string, workingDir string,
 			// Se a imagem é extremamente pequena (< 20MB), é muito provável que seja Go
 			if sizeInMB < 20 {
 				return &LanguageInfo{
-					Name:    "Go",
-					Version: "compiled",
-					Color:   "success",
+					Name:       "Go",
+					Version:    "compiled",
+					Confidence: confidenceHeuristic,
+					Source:     "ENTRYPOINT " + binary,
 				}
 			}
 			// Se tem working dir /app e é pequena, também é provável Go
 			if hasGoWorkingDir && isSmallImage {
 				return &LanguageInfo{
-					Name:    "Go",
-					Version: "compiled",
-					Color:   "success",
+					Name:       "Go",
+					Version:    "compiled",
+					Confidence: confidenceHeuristic,
+					Source:     "ENTRYPOINT " + binary,
 				}
 			}
 		}
@@ -295,105 +297,29 @@ func detectCompiledBinary(entrypoint []string, cmd []string, workingDir string,
 	return nil
 }
 
-// Funções de colorização por linguagem
-func getNodeJSColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
-	}
-	major := getMajorVersion(version)
-	if major < 14 {
-		return "error"
-	}
-	if major >= 14 && major <= 16 {
-		return "warning"
-	}
-	return "success"
-}
-
-func getPythonColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
-	}
-	major := getMajorVersion(version)
-	if major < 3 {
-		return "error"
-	}
-	if major == 3 {
-		minor := getMinorVersion(version)
-		if minor < 8 {
-			return "warning"
-		}
-	}
-	return "success"
-}
-
-func getJavaColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
-	}
-	major := getMajorVersion(version)
-	if major < 11 {
-		return "error"
-	}
-	if major >= 11 && major < 17 {
-		return "warning"
-	}
-	return "success"
-}
-
-func getGoColor(version string) string {
-	if version == "detected" || version == "unknown" || version == "compiled" {
-		return "success"
-	}
-	major := getMajorVersion(version)
-	minor := getMinorVersion(version)
-
-	if major < 1 {
-		return "error"
-	}
-	if major == 1 && minor < 19 {
-		return "warning"
-	}
-	return "success"
-}
-
-func getPHPColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
-	}
-	major := getMajorVersion(version)
-	if major < 7 {
-		return "error"
-	}
-	if major == 7 {
-		return "warning"
-	}
-	return "success"
-}
-
-func getRubyColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
-	}
-	major := getMajorVersion(version)
-	if major < 2 {
-		return "error"
+// classifyLanguage classifica a versão do runtime pela tabela de fim de
+// suporte. Versões desconhecidas e fora da tabela geram aviso; runtimes fora
+// da tabela e binários Go, que não dependem de runtime, são aceitos.
+func classifyLanguage(lang *LanguageInfo) {
+	lang.Color = "success"
+	if !runtimeEndOfLife.Has(lang.Name) || (lang.Name == "Go" && !hasVersion(lang.Version)) {
+		return
 	}
-	if major == 2 {
-		return "warning"
+	lang.Color = "warning"
+	if !hasVersion(lang.Version) {
+		return
 	}
-	return "success"
-}
 
-func getDotNetColor(version string) string {
-	if version == "detected" || version == "unknown" {
-		return "warning"
+	status := runtimeEndOfLife.Evaluate(lang.Name, lang.Version, now())
+	if status.Release != nil {
+		lang.EOL = status.Release.EOL
 	}
-	major := getMajorVersion(version)
-	if major < 6 {
-		return "warning"
+	switch status.State {
+	case eol.StateSupported:
+		lang.Color = "success"
+	case eol.StateEnded:
+		lang.Color = "error"
 	}
-	return "success"
 }
 
 // Utilitários para extrair versões
@@ -423,63 +349,71 @@ func getMinorVersion(version string) int {
 
 // Função para imprimir linguagem detectada com cor
 func PrintLanguageWithColor(imageInspect image.InspectResponse) {
-	lang := DetectPrimaryLanguage(imageInspect)
+	printLanguages(DetectLanguages(imageInspect, nil))
+}
 
-	if lang == nil {
+// printLanguages imprime cada runtime com a versão colorida e a confiança
+func printLanguages(languages []LanguageInfo) {
+	if len(languages) == 0 {
 		fmt.Printf("  - Language: ")
 		fmt.Println(WarningSprintf("<none detected>"))
 		return
 	}
 
-	fmt.Printf("  - %s version: ", lang.Name)
+	for _, lang := range languages {
+		fmt.Printf("  - %s version: %s", lang.Name, LanguageVersionWithColor(lang))
+		fmt.Printf(" (%.0f%% confidence)\n", lang.Confidence*100)
+	}
+}
+
+// LanguageVersionWithColor retorna a versão colorida conforme o status
+func LanguageVersionWithColor(lang LanguageInfo) string {
 	switch lang.Color {
 	case "success":
-		fmt.Println(SuccessSprintf("%s", lang.Version))
+		return SuccessSprintf("%s", lang.Version)
 	case "warning":
-		fmt.Println(WarningSprintf("%s", lang.Version))
+		return WarningSprintf("%s", lang.Version)
 	case "error":
-		fmt.Println(ErrorSprintf("%s", lang.Version))
+		return ErrorSprintf("%s", lang.Version)
 	default:
-		fmt.Println(lang.Version)
+		return lang.Version
 	}
 }
 
 // Função para verificar se a linguagem está desatualizada
 func HasOutdatedLanguage(imageInspect image.InspectResponse) bool {
-	lang := DetectPrimaryLanguage(imageInspect)
-
-	if lang == nil {
-		return false
-	}
+	return hasOutdatedLanguage(DetectLanguages(imageInspect, nil))
+}
 
-	return lang.Color == "error" || lang.Color == "warning"
+func hasOutdatedLanguage(languages []LanguageInfo) bool {
+	return slices.ContainsFunc(languages, func(lang LanguageInfo) bool {
+		return lang.Color == "error" || lang.Color == "warning"
+	})
 }
 
 // Função para obter sugestões de melhoria de linguagem
 func GetLanguageImprovementSuggestions(imageInspect image.InspectResponse) []string {
-	lang := DetectPrimaryLanguage(imageInspect)
-	suggestions := []string{}
+	return languageSuggestions(DetectLanguages(imageInspect, nil))
+}
 
-	if lang == nil {
-		return suggestions
-	}
+// languageSuggestions retorna uma sugestão por runtime desatualizado ou sem
+// versão conhecida
+func languageSuggestions(languages []LanguageInfo) []string {
+	suggestions := []string{}
+	for _, lang := range languages {
+		if lang.Color == "success" {
+			continue
+		}
 
-	if lang.Color == "error" {
-		suggestions = append(suggestions,
-			fmt.Sprintf("  - %s version %s is outdated and may have security vulnerabilities. Consider upgrading to a newer version.",
-				lang.Name, lang.Version))
-	} else if lang.Color == "warning" {
-		if lang.Version == "unknown" {
+		if !hasVersion(lang.Version) {
 			suggestions = append(suggestions,
 				fmt.Sprintf("  - %s runtime detected but version could not be determined. Consider using official base images with explicit version tags.",
 					lang.Name))
 		} else {
-			suggestions = append(suggestions,
-				fmt.Sprintf("  - %s version %s is approaching end-of-life. Consider upgrading to ensure continued support.",
-					lang.Name, lang.Version))
+			status := runtimeEndOfLife.Evaluate(lang.Name, lang.Version, now())
+			suggestions = append(suggestions, "  - "+status.Message())
 		}
 	}
-
 	return suggestions
 }
 
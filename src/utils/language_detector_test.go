@@ -1,13 +1,23 @@
 package utils
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/image"
+	"github.com/jorgevvs2/dockeryzer/src/eol"
 	specs "github.com/moby/docker-image-spec/specs-go/v1"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+// TestMain pins the date used with the end-of-life table, so that the
+// expectations do not change as time goes by.
+func TestMain(m *testing.M) {
+	now = func() time.Time { return time.Date(2023, 3, 20, 0, 0, 0, 0, time.UTC) }
+	os.Exit(m.Run())
+}
+
 // Helper function to create mock ImageInspect
 func createMockImageInspect(envVars []string, cmd []string, entrypoint []string, workingDir string, size int64) image.InspectResponse {
 	return image.InspectResponse{
@@ -152,7 +162,7 @@ func TestDetectGo(t *testing.T) {
 			expectedColor: "success",
 		},
 		{
-			name:          "Go old version",
+			name:          "Go past EOL",
 			envVars:       []string{"GOLANG_VERSION=1.16.0"},
 			cmd:           []string{},
 			entrypoint:    []string{},
@@ -160,7 +170,7 @@ func TestDetectGo(t *testing.T) {
 			size:          20000000,
 			expectedLang:  "Go",
 			expectedVer:   "1.16.0",
-			expectedColor: "warning",
+			expectedColor: "error",
 		},
 	}
 
@@ -277,25 +287,39 @@ func TestDetectJava(t *testing.T) {
 			expectedColor: "success",
 		},
 		{
-			name:          "Java 11 - warning",
-			envVars:       []string{"JAVA_VERSION=11.0.1"},
+			name:          "Java 8 LTS",
+			envVars:       []string{"JAVA_VERSION=8"},
+			expectedLang:  "Java",
+			expectedVer:   "8",
+			expectedColor: "success",
+		},
+		{
+			name:          "Java 19 - approaching EOL",
+			envVars:       []string{"JAVA_VERSION=jdk-19.0.2+7"},
 			expectedLang:  "Java",
-			expectedVer:   "11.0.1",
+			expectedVer:   "jdk-19.0.2+7",
 			expectedColor: "warning",
 		},
 		{
-			name:          "Java 8 - old",
-			envVars:       []string{"JAVA_VERSION=8"},
+			name:          "Java 16 - past EOL",
+			envVars:       []string{"JAVA_VERSION=16.0.2"},
 			expectedLang:  "Java",
-			expectedVer:   "8",
+			expectedVer:   "16.0.2",
 			expectedColor: "error",
 		},
+		{
+			name:          "Java legacy numbering",
+			envVars:       []string{"JAVA_VERSION=1.8.0_362"},
+			expectedLang:  "Java",
+			expectedVer:   "1.8.0_362",
+			expectedColor: "success",
+		},
 		{
 			name:          "Java with JAVA_HOME",
 			envVars:       []string{"JAVA_HOME=/usr/lib/jvm/java-17-openjdk"},
 			expectedLang:  "Java",
 			expectedVer:   "17-openjdk",
-			expectedColor: "error",
+			expectedColor: "success",
 		},
 	}
 
@@ -341,7 +365,7 @@ func TestDetectPHP(t *testing.T) {
 			name:          "PHP 7.4",
 			envVars:       []string{"PHP_VERSION=7.4.0"},
 			expectedVer:   "7.4.0",
-			expectedColor: "warning",
+			expectedColor: "error",
 		},
 		{
 			name:          "PHP 5.6",
@@ -451,7 +475,7 @@ func TestDetectDotNet(t *testing.T) {
 			name:          "ASP.NET Core 5.0",
 			envVars:       []string{"ASPNETCORE_VERSION=5.0"},
 			expectedVer:   "5.0",
-			expectedColor: "warning",
+			expectedColor: "error",
 		},
 	}
 
@@ -608,16 +632,37 @@ func TestGetLanguageImprovementSuggestions(t *testing.T) {
 		name                string
 		envVars             []string
 		expectedSuggestions int
+		expectedMessage     string
 	}{
 		{
 			name:                "Outdated language",
 			envVars:             []string{"NODE_VERSION=12.0.0"},
 			expectedSuggestions: 1,
+			expectedMessage:     "  - Node.js 12 reached EOL on 2022-04-30. Upgrade to Node.js 18 (LTS), supported until 2025-04-30.",
 		},
 		{
 			name:                "Warning language",
 			envVars:             []string{"NODE_VERSION=14.0.0"},
 			expectedSuggestions: 1,
+			expectedMessage:     "  - Node.js 14 reaches EOL on 2023-04-30. Upgrade to Node.js 18 (LTS), supported until 2025-04-30.",
+		},
+		{
+			name:                "Release without LTS",
+			envVars:             []string{"PYTHON_VERSION=3.6.15"},
+			expectedSuggestions: 1,
+			expectedMessage:     "  - Python 3.6 reached EOL on 2021-12-23. Upgrade to Python 3.11, supported until 2027-10-31.",
+		},
+		{
+			name:                "Release older than the table",
+			envVars:             []string{"RUBY_VERSION=1.9.3"},
+			expectedSuggestions: 1,
+			expectedMessage:     "  - Ruby 1.9 reached EOL. Upgrade to Ruby 3.2, supported until 2026-03-31.",
+		},
+		{
+			name:                "Unknown version",
+			envVars:             []string{"JAVA_HOME=/opt/java/openjdk"},
+			expectedSuggestions: 1,
+			expectedMessage:     "  - Java runtime detected but version could not be determined. Consider using official base images with explicit version tags.",
 		},
 		{
 			name:                "Current language",
@@ -637,12 +682,39 @@ func TestGetLanguageImprovementSuggestions(t *testing.T) {
 			suggestions := GetLanguageImprovementSuggestions(imageInspect)
 
 			if len(suggestions) != tt.expectedSuggestions {
-				t.Errorf("Expected %d suggestions, got %d", tt.expectedSuggestions, len(suggestions))
+				t.Fatalf("Expected %d suggestions, got %d", tt.expectedSuggestions, len(suggestions))
+			}
+			if tt.expectedMessage != "" && suggestions[0] != tt.expectedMessage {
+				t.Errorf("Expected suggestion %q, got %q", tt.expectedMessage, suggestions[0])
 			}
 		})
 	}
 }
 
+// Test the end-of-life table replaced by a local file
+func TestUseRuntimeEndOfLife(t *testing.T) {
+	defer UseRuntimeEndOfLife(runtimeEndOfLife)
+
+	path := t.TempDir() + "/eol.json"
+	content := `{"runtimes": {"Node.js": {"releases": [{"release": "18", "eol": "2023-01-01", "lts": true}]}}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dataset, err := eol.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	UseRuntimeEndOfLife(dataset)
+
+	lang := DetectPrimaryLanguage(createMockImageInspect([]string{"NODE_VERSION=18.17.0"}, []string{}, []string{}, "/app", 50000000))
+	if lang == nil || lang.Color != "error" || lang.EOL != "2023-01-01" {
+		t.Errorf("Expected Node.js 18 past its overridden EOL, got %+v", lang)
+	}
+	if suggestions := GetLanguageImprovementSuggestions(createMockImageInspect([]string{"NODE_VERSION=18.17.0"}, []string{}, []string{}, "/app", 50000000)); len(suggestions) != 1 || suggestions[0] != "  - Node.js 18 reached EOL on 2023-01-01." {
+		t.Errorf("Unexpected suggestions %v", suggestions)
+	}
+}
+
 // Test Version Extraction Helpers
 func TestGetMajorVersion(t *testing.T) {
 	tests := []struct {
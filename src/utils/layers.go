@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/go-units"
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// maxWastedFiles bounds the wasted files listed by PrintLayerBreakdown.
+const maxWastedFiles = 10
+
+// maxInstructionWidth bounds the instruction column of the layer table.
+const maxInstructionWidth = 70
+
+var nopPrefixPattern = regexp.MustCompile(`^/bin/sh -c #\(nop\)\s+`)
+var shellPrefixPattern = regexp.MustCompile(`^(RUN )?/bin/sh -c `)
+
+// LayerInfo describes one layer of an image.
+type LayerInfo struct {
+	Index       int    `json:"index"`
+	Instruction string `json:"instruction"`
+	SizeBytes   int64  `json:"sizeBytes"`
+	Size        string `json:"size"`
+	DiffID      string `json:"diffId,omitempty"`
+}
+
+// LayerBreakdown is the per-layer size report of an image, with the bytes
+// wasted by files deleted or overwritten in later layers.
+type LayerBreakdown struct {
+	Layers      []LayerInfo          `json:"layers"`
+	TotalBytes  int64                `json:"totalBytes"`
+	WastedBytes int64                `json:"wastedBytes"`
+	Efficiency  float64              `json:"efficiency"`
+	WastedFiles []imagefs.WastedFile `json:"wastedFiles"`
+}
+
+func GetLayerBreakdown(fsys *imagefs.Image) LayerBreakdown {
+	efficiency := fsys.Efficiency()
+	breakdown := LayerBreakdown{
+		Layers:      []LayerInfo{},
+		TotalBytes:  efficiency.TotalBytes,
+		WastedBytes: efficiency.WastedBytes,
+		Efficiency:  efficiency.Score,
+		WastedFiles: efficiency.Files,
+	}
+	for i, layer := range fsys.Layers {
+		breakdown.Layers = append(breakdown.Layers, LayerInfo{
+			Index:       i,
+			Instruction: LayerInstruction(layer.CreatedBy),
+			SizeBytes:   layer.Size,
+			Size:        units.HumanSize(float64(layer.Size)),
+			DiffID:      layer.DiffID,
+		})
+	}
+	return breakdown
+}
+
+// LayerInstruction turns the created_by of an image history entry into the
+// Dockerfile instruction it came from.
+func LayerInstruction(createdBy string) string {
+	instruction := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(createdBy), "# buildkit"))
+	if nopPrefixPattern.MatchString(instruction) {
+		return nopPrefixPattern.ReplaceAllString(instruction, "")
+	}
+	if shellPrefixPattern.MatchString(instruction) {
+		return shellPrefixPattern.ReplaceAllString(instruction, "RUN ")
+	}
+	if instruction == "" {
+		return "<unknown>"
+	}
+	return instruction
+}
+
+// GetImageSuggestionsWithLayers replaces the generic size suggestion with
+// one naming the largest layer, and adds a suggestion about wasted space.
+func GetImageSuggestionsWithLayers(imageInspect image.InspectResponse, fsys *imagefs.Image, breakdown LayerBreakdown) []ImageSuggestion {
+	suggestions := []ImageSuggestion{}
+	for _, suggestion := range GetImageImprovementSuggestions(imageInspect, fsys) {
+		if suggestion.CheckID == ImageSizeCheck && len(breakdown.Layers) > 0 && breakdown.TotalBytes > 0 {
+			largest := breakdown.Layers[0]
+			for _, layer := range breakdown.Layers {
+				if layer.SizeBytes > largest.SizeBytes {
+					largest = layer
+				}
+			}
+			suggestion.Message = fmt.Sprintf("Layer %d (%s) adds %s, %.0f%% of the image. Start there to reduce its size.",
+				largest.Index, truncate(largest.Instruction, maxInstructionWidth), largest.Size, 100*float64(largest.SizeBytes)/float64(breakdown.TotalBytes))
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if breakdown.Efficiency < 95 && len(breakdown.WastedFiles) > 0 {
+		suggestions = append(suggestions, ImageSuggestion{
+			CheckID: ImageWasteCheck,
+			Message: fmt.Sprintf("%s are wasted by files deleted or overwritten in later layers, e.g. %s. Remove temporary files in the same RUN that creates them.",
+				units.HumanSize(float64(breakdown.WastedBytes)), breakdown.WastedFiles[0].Path),
+		})
+	}
+	return suggestions
+}
+
+func GetEfficiencyWithColor(efficiency float64) string {
+	if efficiency >= 95 {
+		return SuccessSprintf("%.1f%%", efficiency)
+	}
+	if efficiency >= 80 {
+		return WarningSprintf("%.1f%%", efficiency)
+	}
+	return ErrorSprintf("%.1f%%", efficiency)
+}
+
+func PrintLayerBreakdown(breakdown LayerBreakdown) {
+	fmt.Println("\n Layers:")
+	fmt.Printf("  %3s  %9s  %s\n", "#", "SIZE", "INSTRUCTION")
+	for _, layer := range breakdown.Layers {
+		fmt.Printf("  %3d  %9s  %s\n", layer.Index, layer.Size, truncate(layer.Instruction, maxInstructionWidth))
+	}
+
+	fmt.Printf("\n  - Total layer size: %s\n", units.HumanSize(float64(breakdown.TotalBytes)))
+	fmt.Printf("  - Wasted space: %s\n", units.HumanSize(float64(breakdown.WastedBytes)))
+	fmt.Printf("  - Efficiency: %s\n", GetEfficiencyWithColor(breakdown.Efficiency))
+
+	if len(breakdown.WastedFiles) > 0 {
+		fmt.Println("\n Wasted files (deleted or overwritten in later layers):")
+		for i, file := range breakdown.WastedFiles {
+			if i == maxWastedFiles {
+				fmt.Printf("  ... and %d more\n", len(breakdown.WastedFiles)-maxWastedFiles)
+				break
+			}
+			fmt.Printf("  %9s  %s (%d %s)\n", units.HumanSize(float64(file.Size)), file.Path, file.Copies, plural(file.Copies, "copy", "copies"))
+		}
+	}
+}
+
+// PrintImageAnalyzeResultsWithLayers prints the analysis of an image with
+// its layer breakdown and the suggestions drawn from it.
+func PrintImageAnalyzeResultsWithLayers(name string, imageInspect image.InspectResponse, fsys *imagefs.Image, breakdown LayerBreakdown) {
+	PrintImageResults(name, imageInspect, fsys, false, true)
+	PrintLayerBreakdown(breakdown)
+	printImageSuggestions(GetImageSuggestionsWithLayers(imageInspect, fsys, breakdown))
+}
+
+// truncate shortens text to width characters, ending it with "...".
+func truncate(text string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= width {
+		return text
+	}
+	return text[:width-3] + "..."
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+// Tests for LayerInstruction
+func TestLayerInstruction(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdBy string
+		expected  string
+	}{
+		{
+			name:      "Classic builder metadata",
+			createdBy: "/bin/sh -c #(nop)  CMD [\"node\" \"server.js\"]",
+			expected:  "CMD [\"node\" \"server.js\"]",
+		},
+		{
+			name:      "Classic builder RUN",
+			createdBy: "/bin/sh -c apk add --no-cache curl",
+			expected:  "RUN apk add --no-cache curl",
+		},
+		{
+			name:      "BuildKit RUN",
+			createdBy: "RUN /bin/sh -c npm ci # buildkit",
+			expected:  "RUN npm ci",
+		},
+		{
+			name:      "BuildKit COPY",
+			createdBy: "COPY . . # buildkit",
+			expected:  "COPY . .",
+		},
+		{
+			name:      "No history",
+			createdBy: "",
+			expected:  "<unknown>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := LayerInstruction(tt.createdBy); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// Tests for GetImageSuggestionsWithLayers
+func TestGetImageSuggestionsWithLayers(t *testing.T) {
+	breakdown := LayerBreakdown{
+		Layers: []LayerInfo{
+			{Index: 0, Instruction: "ADD file:abc in /", SizeBytes: 80000000, Size: "80MB"},
+			{Index: 1, Instruction: "RUN apt-get update && apt-get install -y build-essential", SizeBytes: 300000000, Size: "300MB"},
+			{Index: 2, Instruction: "RUN rm -rf /var/lib/apt/lists/*", SizeBytes: 0, Size: "0B"},
+		},
+		TotalBytes:  380000000,
+		WastedBytes: 40000000,
+		Efficiency:  89.5,
+		WastedFiles: []imagefs.WastedFile{{Path: "/var/lib/apt/lists/deb.debian.org_debian_dists_bookworm_main_binary-amd64_Packages.lz4", Size: 40000000, Copies: 1}},
+	}
+
+	imageInspect := createMockImageInspect(nil, nil, nil, "", 0)
+	imageInspect.Size = 380000000
+	suggestions := GetImageSuggestionsWithLayers(imageInspect, nil, breakdown)
+	checks := map[string]string{}
+	for _, suggestion := range suggestions {
+		checks[suggestion.CheckID] = suggestion.Message
+	}
+	if !strings.HasPrefix(checks[ImageSizeCheck], "Layer 1 (RUN apt-get update") || !strings.Contains(checks[ImageSizeCheck], "300MB, 79% of the image") {
+		t.Errorf("Expected the size suggestion to name layer 1, got %q", checks[ImageSizeCheck])
+	}
+	if !strings.HasPrefix(checks[ImageWasteCheck], "40MB are wasted") {
+		t.Errorf("Expected a wasted space suggestion, got %q", checks[ImageWasteCheck])
+	}
+
+	imageInspect.Size = 50000000
+	suggestions = GetImageSuggestionsWithLayers(imageInspect, nil, LayerBreakdown{Efficiency: 100})
+	if len(suggestions) != 0 {
+		t.Errorf("Expected no suggestions for a small efficient image, got %+v", suggestions)
+	}
+}
+
+// Tests for PrintLayerBreakdown
+func TestPrintLayerBreakdown(t *testing.T) {
+	breakdown := LayerBreakdown{
+		Layers:      []LayerInfo{{Index: 0, Instruction: "ADD file:abc in /", SizeBytes: 7800000, Size: "7.8MB"}},
+		TotalBytes:  7800000,
+		WastedBytes: 0,
+		Efficiency:  100,
+		WastedFiles: []imagefs.WastedFile{},
+	}
+
+	output := captureOutput(func() { PrintLayerBreakdown(breakdown) })
+	for _, expected := range []string{"    0      7.8MB  ADD file:abc in /", "Total layer size: 7.8MB", "Efficiency: 100.0%"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "Wasted files") {
+		t.Errorf("Expected no wasted files section, got:\n%s", output)
+	}
+}
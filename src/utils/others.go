@@ -27,18 +27,18 @@ func HandleCommandOutput(cmd *exec.Cmd) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		ErrorPrintf("Error on create pipe to handle stdout: %s\n", err)
-		os.Exit(0)
+		os.Exit(ExitFailure)
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		ErrorPrintf("Error on create pipe to handle stderr: %s\n", err)
-		os.Exit(0)
+		os.Exit(ExitFailure)
 	}
 
 	err = cmd.Start()
 	if err != nil {
 		ErrorPrintf("Error on start command: %s\n", err)
-		os.Exit(0)
+		os.Exit(ExitDockerError)
 	}
 
 	go func() {
@@ -58,6 +58,6 @@ func HandleCommandOutput(cmd *exec.Cmd) {
 	err = cmd.Wait()
 	if err != nil {
 		ErrorPrintf("Error on waiting command finish: %s\n", err)
-		os.Exit(0)
+		os.Exit(ExitDockerError)
 	}
 }
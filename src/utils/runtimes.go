@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs"
+)
+
+const (
+	// maxBinarySize bounds the bytes read from the executable of an image.
+	maxBinarySize = 256 << 20
+	// maxVersionFileSize bounds the files read for runtime versions.
+	maxVersionFileSize = 64 << 10
+	// maxLinkHops bounds the symbolic links followed to resolve a path.
+	maxLinkHops = 40
+)
+
+// defaultPath is searched for commands when the image does not set PATH.
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+var elfMagic = []byte("\x7fELF")
+
+// OSInfo is the base distribution of an image, read from its os-release
+// file.
+type OSInfo struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	PrettyName string `json:"prettyName"`
+}
+
+// DetectOS returns the distribution of the image filesystem, nil when fsys
+// is nil or has no os-release file, as in scratch images.
+func DetectOS(fsys *imagefs.Image) *OSInfo {
+	if fsys == nil {
+		return nil
+	}
+	release := fsys.OSRelease()
+	if release == nil {
+		return nil
+	}
+	info := &OSInfo{ID: release["ID"], Name: release["NAME"], Version: release["VERSION_ID"], PrettyName: release["PRETTY_NAME"]}
+	if info.PrettyName == "" {
+		info.PrettyName = strings.TrimSpace(info.Name + " " + info.Version)
+	}
+	return info
+}
+
+// installedExecutables map the executables of bin directories to their
+// runtime. Versioned names such as python3.12 are matched by
+// versionedCommand.
+var installedExecutables = map[string]string{
+	"node":    "Node.js",
+	"bun":     "Bun",
+	"deno":    "Deno",
+	"kotlinc": "Kotlin",
+	"scala":   "Scala",
+	"groovy":  "Groovy",
+	"java":    "Java",
+	"go":      "Go",
+	"php":     "PHP",
+	"ruby":    "Ruby",
+	"dotnet":  ".NET",
+	"rustc":   "Rust",
+	"elixir":  "Elixir",
+	"erl":     "Erlang",
+	"dart":    "Dart",
+	"swift":   "Swift",
+}
+
+// runtimePaths are files that give the version of a runtime. Those marked
+// present also show that the runtime is installed, like the libraries of a
+// JVM language bundled with an application.
+var runtimePaths = []struct {
+	name    string
+	pattern *regexp.Regexp
+	present bool
+}{
+	{"Python", regexp.MustCompile(`^/usr(?:/local)?/lib/python(\d+\.\d+)/`), false},
+	{"Ruby", regexp.MustCompile(`^/usr(?:/local)?/lib/ruby/(\d+\.\d+)\.\d+/`), false},
+	{"Erlang", regexp.MustCompile(`/lib/erlang/releases/(\d+)/`), true},
+	{".NET", regexp.MustCompile(`/dotnet/shared/Microsoft\.NETCore\.App/(\d+\.\d+\.\d+)/`), true},
+	{"Kotlin", regexp.MustCompile(`/kotlin-stdlib-(\d[\w.]*)\.jar$`), true},
+	{"Scala", regexp.MustCompile(`/scala3?-library(?:_3)?-(\d[\w.]*)\.jar$`), true},
+	{"Groovy", regexp.MustCompile(`/groovy-(\d[\w.]*)\.jar$`), true},
+	{"Swift", regexp.MustCompile(`^/usr/lib/swift/linux/libswiftCore\.so$`), true},
+}
+
+// versionFiles are files holding the exact version of a runtime and the
+// pattern that extracts it.
+var versionFiles = []struct {
+	name    string
+	path    string
+	pattern *regexp.Regexp
+}{
+	{"Node.js", "/usr/local/include/node/node_version.h", regexp.MustCompile(`(?s)NODE_MAJOR_VERSION (\d+).*NODE_MINOR_VERSION (\d+).*NODE_PATCH_VERSION (\d+)`)},
+	{"Go", "/usr/local/go/VERSION", regexp.MustCompile(`^go(\d+\.\d+(?:\.\d+)?)`)},
+	{"PHP", "/usr/local/include/php/main/php_version.h", regexp.MustCompile(`PHP_VERSION "([^"]+)"`)},
+	{"Dart", "/usr/lib/dart/version", regexp.MustCompile(`^(\d+\.\d+\.\d+)`)},
+}
+
+// detectFromFilesystem finds the runtimes installed in the image and the
+// language of the binary it runs.
+func detectFromFilesystem(fsys *imagefs.Image, env, entrypoint, cmd []string, workingDir string) []LanguageInfo {
+	found := []LanguageInfo{}
+	if binary := executedBinary(entrypoint, cmd); binary != "" {
+		if lang := detectBinary(fsys, resolveCommand(fsys, binary, env, workingDir)); lang != nil {
+			found = append(found, *lang)
+		}
+	}
+
+	installed := map[string]*LanguageInfo{}
+	install := func(name, source string) *LanguageInfo {
+		if installed[name] == nil {
+			installed[name] = &LanguageInfo{Name: name, Version: "detected", Confidence: confidenceFilesystem, Source: source}
+		}
+		return installed[name]
+	}
+	versions := map[string]string{}
+	javaHomes := []string{}
+	for _, file := range fsys.Files() {
+		if file.Mode.IsDir() {
+			continue
+		}
+		if dir := path.Base(path.Dir(file.Path)); dir == "bin" || dir == "sbin" || file.Path == "/usr/share/dotnet/dotnet" {
+			name := path.Base(file.Path)
+			if match := versionedCommand.FindStringSubmatch(name); match != nil {
+				name = match[1]
+			}
+			runtime, ok := installedExecutables[name]
+			if name == "python" {
+				runtime, ok = "Python", true
+			}
+			if ok {
+				install(runtime, file.Path)
+				if runtime == "Java" {
+					javaHomes = append(javaHomes, path.Dir(path.Dir(file.Path)))
+				}
+			}
+		}
+		for _, marker := range runtimePaths {
+			match := marker.pattern.FindStringSubmatch(file.Path)
+			if match == nil {
+				continue
+			}
+			if marker.present {
+				install(marker.name, file.Path)
+			}
+			if len(match) > 1 && NewerVersion(match[1], versions[marker.name]) {
+				versions[marker.name] = match[1]
+			}
+		}
+	}
+
+	for _, versionFile := range versionFiles {
+		if content, err := fsys.ReadFile(versionFile.path, maxVersionFileSize); err == nil {
+			if match := versionFile.pattern.FindStringSubmatch(string(content)); match != nil {
+				versions[versionFile.name] = strings.Join(match[1:], ".")
+			}
+		}
+	}
+	for _, home := range javaHomes {
+		if version := javaReleaseVersion(fsys, home); version != "" {
+			versions["Java"] = version
+			break
+		}
+	}
+
+	for _, runtime := range runtimeOrder() {
+		if lang := installed[runtime]; lang != nil {
+			if version, ok := versions[runtime]; ok {
+				lang.Version = version
+			}
+			found = append(found, *lang)
+		}
+	}
+	return found
+}
+
+// runtimeOrder lists the runtimes in detection priority.
+func runtimeOrder() []string {
+	names := []string{}
+	for _, runtime := range envRuntimes {
+		names = append(names, runtime.name)
+	}
+	return names
+}
+
+// NewerVersion reports whether version is newer than current, comparing the
+// major and minor numbers.
+func NewerVersion(version, current string) bool {
+	if current == "" {
+		return true
+	}
+	if getMajorVersion(version) != getMajorVersion(current) {
+		return getMajorVersion(version) > getMajorVersion(current)
+	}
+	return getMinorVersion(version) > getMinorVersion(current)
+}
+
+// javaReleaseVersion reads JAVA_VERSION from the release file of a JDK or
+// JRE home.
+func javaReleaseVersion(fsys *imagefs.Image, home string) string {
+	content, err := fsys.ReadFile(path.Join(home, "release"), maxVersionFileSize)
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "JAVA_VERSION="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// commandWrappers are init processes and tools that run the actual command
+// of an image.
+var commandWrappers = map[string]bool{
+	"tini":        true,
+	"tini-static": true,
+	"dumb-init":   true,
+	"catatonit":   true,
+	"env":         true,
+	"exec":        true,
+}
+
+// executedBinary returns the program the image runs, skipping init
+// processes, gosu and shells running a command with -c. It returns an
+// empty string for shell scripts.
+func executedBinary(entrypoint, cmd []string) string {
+	args := append(slices.Clone(entrypoint), cmd...)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := path.Base(arg)
+		switch {
+		case strings.HasPrefix(arg, "-") || strings.Contains(arg, "="):
+		case commandWrappers[name]:
+		case name == "gosu" || name == "su-exec":
+			// The next argument is the user.
+			i++
+		case name == "sh" || name == "bash" || name == "ash":
+			if i+2 < len(args) && args[i+1] == "-c" {
+				fields := slices.DeleteFunc(strings.Fields(args[i+2]), func(field string) bool { return commandWrappers[field] })
+				if len(fields) > 0 {
+					return fields[0]
+				}
+			}
+			return ""
+		default:
+			return arg
+		}
+	}
+	return ""
+}
+
+// resolveCommand returns the absolute path of a command, looking it up in
+// the PATH of the image when it has no slash.
+func resolveCommand(fsys *imagefs.Image, command string, env []string, workingDir string) string {
+	if strings.Contains(command, "/") {
+		if !path.IsAbs(command) {
+			return path.Join("/", workingDir, command)
+		}
+		return command
+	}
+	searchPath := defaultPath
+	if _, value := envValue(env, []string{"PATH"}); value != "" {
+		searchPath = value
+	}
+	for _, dir := range strings.Split(searchPath, ":") {
+		candidate := path.Join("/", dir, command)
+		if _, ok := resolvePath(fsys, candidate); ok {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// resolvePath returns the entry at p, following the symbolic links of p and
+// of its parent directories, as for /bin/sh when /bin links to usr/bin.
+func resolvePath(fsys *imagefs.Image, p string) (*imagefs.File, bool) {
+	p = path.Clean("/" + p)
+	for hops := 0; hops < maxLinkHops; hops++ {
+		resolved, linked := "/", false
+		parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		for i, part := range parts {
+			current := path.Join(resolved, part)
+			// Layers may leave out the entries of parent directories.
+			file, ok := fsys.Stat(current)
+			if ok && file.Mode&fs.ModeSymlink != 0 {
+				target := file.Linkname
+				if !path.IsAbs(target) {
+					target = path.Join(resolved, target)
+				}
+				p = path.Join(append([]string{target}, parts[i+1:]...)...)
+				linked = true
+				break
+			}
+			resolved = current
+		}
+		if !linked {
+			return fsys.Stat(resolved)
+		}
+	}
+	return nil, false
+}
+
+// detectBinary identifies the language of an ELF executable: Go from the
+// build information its compiler embeds, Swift and Rust from their runtime,
+// and C/C++ for other statically linked binaries. Dynamic executables of
+// interpreters are left to the other detections.
+func detectBinary(fsys *imagefs.Image, p string) *LanguageInfo {
+	if p == "" {
+		return nil
+	}
+	file, ok := resolvePath(fsys, p)
+	if !ok || !file.Mode.IsRegular() {
+		return nil
+	}
+	data, err := fsys.ReadFile(file.Path, maxBinarySize)
+	if err != nil || !bytes.HasPrefix(data, elfMagic) {
+		return nil
+	}
+
+	if info, err := buildinfo.Read(bytes.NewReader(data)); err == nil {
+		version, _, _ := strings.Cut(strings.TrimPrefix(info.GoVersion, "go"), " ")
+		return &LanguageInfo{Name: "Go", Version: version, Confidence: confidenceBuildInfo, Source: file.Path}
+	}
+	executable, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	libraries, _ := executable.ImportedLibraries()
+	if slices.ContainsFunc(libraries, func(library string) bool { return strings.HasPrefix(library, "libswiftCore") }) {
+		return &LanguageInfo{Name: "Swift", Version: "detected", Confidence: confidenceBinary, Source: file.Path}
+	}
+	if bytes.Contains(data, []byte("/rustc/")) {
+		return &LanguageInfo{Name: "Rust", Version: "detected", Confidence: confidenceBinary, Source: file.Path}
+	}
+	static := !slices.ContainsFunc(executable.Progs, func(prog *elf.Prog) bool { return prog.Type == elf.PT_INTERP })
+	if static {
+		return &LanguageInfo{Name: "C/C++", Version: "static", Confidence: confidenceBinary, Source: file.Path}
+	}
+	return nil
+}
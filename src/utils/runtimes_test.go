@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/imagefs/imagefstest"
+)
+
+// staticELF returns a minimal statically linked executable followed by
+// extra bytes.
+func staticELF(extra string) []byte {
+	var buf bytes.Buffer
+	header := elf.Header64{Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT), Ehsize: 64}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.WriteString(extra)
+	return buf.Bytes()
+}
+
+func TestDetectLanguagesFromFilesystem(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skip("test binary not found:", err)
+	}
+	goBinary, err := os.ReadFile(executable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fsys := imagefstest.New(t, []imagefstest.File{
+		{Name: "bin", Link: "usr/bin"},
+		{Name: "usr/bin/tini", Content: staticELF("")},
+		{Name: "usr/bin/server", Content: goBinary},
+		{Name: "usr/local/bin/node", Content: []byte("node")},
+		{Name: "usr/local/include/node/node_version.h", Content: []byte("#define NODE_MAJOR_VERSION 20\n#define NODE_MINOR_VERSION 11\n#define NODE_PATCH_VERSION 1\n")},
+		{Name: "usr/local/bin/python3", Link: "python3.12"},
+		{Name: "usr/local/bin/python3.12", Content: []byte("python")},
+		{Name: "usr/local/lib/python3.12/os.py", Content: []byte("")},
+		{Name: "app/lib/kotlin-stdlib-1.9.22.jar", Content: []byte("")},
+		{Name: "etc/os-release", Content: []byte("NAME=\"Debian GNU/Linux\"\nID=debian\nVERSION_ID=\"12\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n")},
+	})
+	imageInspect := createMockImageInspect([]string{"PATH=/usr/local/bin:/bin"}, []string{"server", "--port=8080"}, []string{"/bin/tini", "--"}, "/app", 0)
+
+	got := []string{}
+	for _, lang := range DetectLanguages(imageInspect, fsys) {
+		got = append(got, lang.Name+" "+lang.Version+" "+lang.Source)
+	}
+	goVersion, _, _ := strings.Cut(strings.TrimPrefix(runtime.Version(), "go"), " ")
+	want := []string{
+		"Go " + goVersion + " /usr/bin/server",
+		"Node.js 20.11.1 /usr/local/bin/node",
+		"Python 3.12 /usr/local/bin/python3",
+		"Kotlin 1.9.22 /app/lib/kotlin-stdlib-1.9.22.jar",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got runtimes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The configuration alone gives the command runtimes only.
+	languages := DetectLanguages(createMockImageInspect([]string{"NODE_VERSION=20.11.1"}, []string{"sh", "-c", "exec python3 worker.py"}, nil, "/app", 0), nil)
+	if len(languages) != 2 || languages[0].Name != "Node.js" || languages[0].Confidence != confidenceVersionEnv ||
+		languages[1].Name != "Python" || languages[1].Version != "unknown" || languages[1].Confidence != confidenceCommand {
+		t.Errorf("Unexpected runtimes %+v", languages)
+	}
+
+	if baseOS := DetectOS(fsys); baseOS == nil || baseOS.ID != "debian" || baseOS.Version != "12" || baseOS.PrettyName != "Debian GNU/Linux 12 (bookworm)" {
+		t.Errorf("Unexpected base OS %+v", baseOS)
+	}
+	if baseOS := DetectOS(imagefstest.New(t)); baseOS != nil {
+		t.Errorf("Expected no base OS, got %+v", baseOS)
+	}
+}
+
+func TestDetectBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []imagefstest.File
+		command  []string
+		expected string
+	}{
+		{
+			name:     "Static C/C++ binary",
+			files:    []imagefstest.File{{Name: "app/server", Content: staticELF("")}},
+			command:  []string{"./server"},
+			expected: "C/C++ static",
+		},
+		{
+			name:     "Rust binary",
+			files:    []imagefstest.File{{Name: "app/server", Content: staticELF("/rustc/82e1608dfa6e0b5569232559e3d385fea5a93112/library/std/src/panicking.rs")}},
+			command:  []string{"/app/server"},
+			expected: "Rust detected",
+		},
+		{
+			name:     "Shell script",
+			files:    []imagefstest.File{{Name: "app/start.sh", Content: []byte("#!/bin/sh\n")}},
+			command:  []string{"/app/start.sh"},
+			expected: "",
+		},
+		{
+			name:     "Missing binary",
+			command:  []string{"/app/server"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := imagefstest.New(t, tt.files)
+			languages := DetectLanguages(createMockImageInspect(nil, tt.command, nil, "/app", 0), fsys)
+			got := ""
+			if len(languages) > 0 {
+				got = languages[0].Name + " " + languages[0].Version
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExecutedBinary(t *testing.T) {
+	tests := []struct {
+		entrypoint []string
+		cmd        []string
+		expected   string
+	}{
+		{nil, []string{"/app/server"}, "/app/server"},
+		{[]string{"/sbin/tini", "--"}, []string{"node", "index.js"}, "node"},
+		{[]string{"dumb-init", "gosu", "app"}, []string{"/usr/local/bin/api", "-v"}, "/usr/local/bin/api"},
+		{[]string{"/bin/sh", "-c"}, []string{"exec ./server --port 8080"}, "./server"},
+		{[]string{"docker-entrypoint.sh"}, []string{"postgres"}, "docker-entrypoint.sh"},
+		{[]string{"/bin/sh"}, nil, ""},
+		{nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := executedBinary(tt.entrypoint, tt.cmd); got != tt.expected {
+			t.Errorf("executedBinary(%q, %q) = %q, want %q", tt.entrypoint, tt.cmd, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package vuln
+
+import (
+	"math"
+	"strings"
+)
+
+// Weights of the CVSS v3 base metrics, keyed by metric and value.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector like
+// CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H.
+func cvss3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, false
+	}
+	metrics := map[string]string{}
+	for _, part := range parts[1:] {
+		if key, value, found := strings.Cut(part, ":"); found {
+			metrics[key] = value
+		}
+	}
+
+	scopeChanged := metrics["S"] == "C"
+	if metrics["S"] != "C" && metrics["S"] != "U" {
+		return 0, false
+	}
+	weights := map[string]float64{}
+	for metric, values := range cvss3Weights {
+		weight, ok := values[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		weights[metric] = weight
+	}
+	// Privileges weigh more when the scope changes.
+	if scopeChanged && metrics["PR"] == "L" {
+		weights["PR"] = 0.68
+	} else if scopeChanged && metrics["PR"] == "H" {
+		weights["PR"] = 0.5
+	}
+
+	iss := 1 - (1-weights["C"])*(1-weights["I"])*(1-weights["A"])
+	impact := 6.42 * iss
+	if scopeChanged {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * weights["AV"] * weights["AC"] * weights["PR"] * weights["UI"]
+	if scopeChanged {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp rounds up to one decimal as CVSS v3.1 specifies, avoiding
+// floating point artifacts.
+func roundUp(value float64) float64 {
+	n := int(math.Round(value * 100000))
+	if n%10000 == 0 {
+		return float64(n) / 100000
+	}
+	return float64(n/10000+1) / 10
+}
+
+// cvssRating returns the qualitative rating of a CVSS score.
+func cvssRating(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
@@ -0,0 +1,288 @@
+package vuln
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatabaseEnv names the environment variable that overrides the default
+// database directory.
+const DatabaseEnv = "DOCKERYZER_VULN_DB"
+
+// indexFile marks a directory as a database and lists its ecosystems.
+const indexFile = "index.json"
+
+// schemaVersion is bumped whenever the layout of the database changes.
+const schemaVersion = 1
+
+// Ecosystem describes the advisories imported for one ecosystem.
+type Ecosystem struct {
+	// File holds the advisories, relative to the database directory.
+	File       string `json:"file"`
+	Advisories int    `json:"advisories"`
+}
+
+// index is the content of the index file.
+type index struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	Updated       time.Time            `json:"updated"`
+	Ecosystems    map[string]Ecosystem `json:"ecosystems"`
+}
+
+// Database is a directory of OSV advisories, one file per ecosystem. The
+// files of an ecosystem are read the first time a package of the ecosystem
+// is matched.
+type Database struct {
+	Dir string
+	// Updated is the time of the last import.
+	Updated    time.Time
+	Ecosystems map[string]Ecosystem
+	// packages holds the advisories of the ecosystems read so far, by
+	// ecosystem and package key.
+	packages map[string]map[string][]*Advisory
+}
+
+// DefaultDir returns the database directory used when none is given: the
+// DOCKERYZER_VULN_DB variable or dockeryzer/vulndb in the user cache.
+func DefaultDir() string {
+	if dir := os.Getenv(DatabaseEnv); dir != "" {
+		return dir
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cache, "dockeryzer", "vulndb")
+}
+
+// Open reads the index of the database in dir.
+func Open(dir string) (*Database, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s is not a vulnerability database, create it with dockeryzer vulndb import", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, indexFile), err)
+	}
+	if idx.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("%s was created by another version of dockeryzer, import the advisories again", dir)
+	}
+	if idx.Ecosystems == nil {
+		idx.Ecosystems = map[string]Ecosystem{}
+	}
+	return &Database{Dir: dir, Updated: idx.Updated, Ecosystems: idx.Ecosystems, packages: map[string]map[string][]*Advisory{}}, nil
+}
+
+// Imported reports whether a database was imported in dir.
+func Imported(dir string) bool {
+	if dir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, indexFile))
+	return err == nil
+}
+
+// ImportStats summarizes an import.
+type ImportStats struct {
+	// Advisories is the number of advisories read from the dumps.
+	Advisories int
+	// Withdrawn is the number of withdrawn advisories, which are removed.
+	Withdrawn int
+	// Ecosystems are the ecosystems whose files were written.
+	Ecosystems []string
+}
+
+// Import adds the advisories of OSV dumps to the database in dir, creating
+// it if needed. An advisory already in the database is replaced when the
+// imported one was modified at the same time or later. No network access is
+// needed, so dumps downloaded elsewhere can be imported on air-gapped hosts.
+func Import(dir string, sources ...string) (ImportStats, error) {
+	stats := ImportStats{}
+	db, err := Open(dir)
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(dir, indexFile)); !errors.Is(statErr, os.ErrNotExist) {
+			return stats, err
+		}
+		db = &Database{Dir: dir, Ecosystems: map[string]Ecosystem{}}
+	}
+
+	// imported holds the advisories read, by ecosystem and ID, keeping only
+	// the affected packages of that ecosystem.
+	imported := map[string]map[string]*Advisory{}
+	withdrawn := map[string]bool{}
+	for _, source := range sources {
+		err := ReadAdvisories(source, func(advisory *Advisory) error {
+			stats.Advisories++
+			if advisory.Withdrawn != "" {
+				stats.Withdrawn++
+				withdrawn[advisory.ID] = true
+			}
+			for ecosystem, part := range splitByEcosystem(advisory) {
+				if imported[ecosystem] == nil {
+					imported[ecosystem] = map[string]*Advisory{}
+				}
+				if current, ok := imported[ecosystem][part.ID]; !ok || !newer(current, part) {
+					imported[ecosystem][part.ID] = part
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return stats, err
+	}
+	for ecosystem, advisories := range imported {
+		merged := map[string]*Advisory{}
+		if _, ok := db.Ecosystems[ecosystem]; ok {
+			existing, err := db.readEcosystem(ecosystem)
+			if err != nil {
+				return stats, err
+			}
+			for _, advisory := range existing {
+				merged[advisory.ID] = advisory
+			}
+		}
+		for id, advisory := range advisories {
+			if current, ok := merged[id]; !ok || !newer(current, advisory) {
+				merged[id] = advisory
+			}
+		}
+
+		list := []*Advisory{}
+		for id, advisory := range merged {
+			if !withdrawn[id] && advisory.Withdrawn == "" {
+				list = append(list, advisory)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		file := ecosystemFile(ecosystem)
+		if err := writeJSON(filepath.Join(dir, file), list); err != nil {
+			return stats, err
+		}
+		db.Ecosystems[ecosystem] = Ecosystem{File: file, Advisories: len(list)}
+		stats.Ecosystems = append(stats.Ecosystems, ecosystem)
+	}
+	sort.Strings(stats.Ecosystems)
+
+	// The index is written last so that an interrupted import leaves the
+	// previous database usable.
+	idx := index{SchemaVersion: schemaVersion, Updated: time.Now().UTC(), Ecosystems: db.Ecosystems}
+	return stats, writeJSON(filepath.Join(dir, indexFile), idx)
+}
+
+// splitByEcosystem returns a copy of advisory per ecosystem it affects,
+// each keeping only the affected packages of its ecosystem.
+func splitByEcosystem(advisory *Advisory) map[string]*Advisory {
+	parts := map[string]*Advisory{}
+	for _, affected := range advisory.Affected {
+		ecosystem := affected.Package.Ecosystem
+		if ecosystem == "" || affected.Package.Name == "" {
+			continue
+		}
+		part, ok := parts[ecosystem]
+		if !ok {
+			clone := *advisory
+			clone.Affected = nil
+			part = &clone
+			parts[ecosystem] = part
+		}
+		part.Affected = append(part.Affected, affected)
+	}
+	return parts
+}
+
+// newer reports whether a was modified after b. Times that do not parse
+// compare as strings.
+func newer(a, b *Advisory) bool {
+	at, aErr := time.Parse(time.RFC3339Nano, a.Modified)
+	bt, bErr := time.Parse(time.RFC3339Nano, b.Modified)
+	if aErr != nil || bErr != nil {
+		return a.Modified > b.Modified
+	}
+	return at.After(bt)
+}
+
+// ecosystemFile returns the file name of an ecosystem, e.g.
+// ubuntu-22-04-lts.json for Ubuntu:22.04:LTS.
+func ecosystemFile(ecosystem string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(ecosystem))
+	return name + ".json"
+}
+
+// writeJSON writes v to a temporary file renamed over name.
+func writeJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (db *Database) readEcosystem(ecosystem string) ([]*Advisory, error) {
+	data, err := os.ReadFile(filepath.Join(db.Dir, db.Ecosystems[ecosystem].File))
+	if err != nil {
+		return nil, err
+	}
+	advisories := []*Advisory{}
+	if err := json.Unmarshal(data, &advisories); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.Ecosystems[ecosystem].File, err)
+	}
+	return advisories, nil
+}
+
+// advisories returns the advisories of a package, reading the file of its
+// ecosystem on first use.
+func (db *Database) advisories(ecosystem, name string) ([]*Advisory, error) {
+	packages, ok := db.packages[ecosystem]
+	if !ok {
+		list, err := db.readEcosystem(ecosystem)
+		if err != nil {
+			return nil, err
+		}
+		packages = map[string][]*Advisory{}
+		for _, advisory := range list {
+			seen := map[string]bool{}
+			for _, affected := range advisory.Affected {
+				key := packageKey(ecosystem, affected.Package.Name)
+				if !seen[key] {
+					seen[key] = true
+					packages[key] = append(packages[key], advisory)
+				}
+			}
+		}
+		db.packages[ecosystem] = packages
+	}
+	return packages[packageKey(ecosystem, name)], nil
+}
+
+// packageKey normalizes package names where the ecosystem ignores case and
+// punctuation, as PyPI does.
+func packageKey(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+	}
+	return name
+}
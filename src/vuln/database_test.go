@@ -0,0 +1,239 @@
+package vuln
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jorgevvs2/dockeryzer/src/sbom"
+)
+
+const debianAdvisory = `{
+  "id": "DSA-5632-1",
+  "modified": "2024-02-01T00:00:00Z",
+  "aliases": ["CVE-2024-0727"],
+  "summary": "openssl security update",
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:L/AC:L/PR:N/UI:R/S:U/C:N/I:N/A:H"}],
+  "affected": [{
+    "package": {"ecosystem": "Debian:12", "name": "openssl"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.13-1~deb12u1"}]}]
+  }]
+}`
+
+const npmAdvisories = `[
+  {
+    "id": "GHSA-35jh-r3h4-6jhm",
+    "modified": "2024-01-10T00:00:00Z",
+    "aliases": ["CVE-2021-23337"],
+    "summary": "Command Injection in lodash",
+    "database_specific": {"severity": "HIGH", "cwe_ids": ["CWE-77"]},
+    "affected": [{
+      "package": {"ecosystem": "npm", "name": "lodash"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]
+    }]
+  },
+  {
+    "id": "GHSA-p6mc-m468-83gw",
+    "modified": "2024-01-10T00:00:00Z",
+    "summary": "Prototype pollution in lodash",
+    "database_specific": {"severity": "moderate"},
+    "affected": [{
+      "package": {"ecosystem": "npm", "name": "lodash"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "4.0.0"}, {"last_affected": "4.17.15"}]}]
+    }]
+  }
+]`
+
+const pypiAdvisory = `{
+  "id": "PYSEC-2023-74",
+  "modified": "2023-05-01T00:00:00Z",
+  "aliases": ["CVE-2023-32681", "GHSA-j8r2-6x86-q33q"],
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "Requests"},
+    "versions": ["2.30.0"]
+  }]
+}`
+
+const goAdvisory = `{
+  "id": "GO-2024-2598",
+  "modified": "2024-03-05T00:00:00Z",
+  "aliases": ["CVE-2024-24783"],
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "stdlib"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.21.8"}, {"introduced": "1.22.0-0"}, {"fixed": "1.22.1"}]}]
+  }]
+}`
+
+// writeDump writes the advisories of the tests as a directory, a zip
+// archive and a JSON list, and returns their paths.
+func writeDump(t *testing.T) []string {
+	t.Helper()
+	dir := t.TempDir()
+	advisories := filepath.Join(dir, "advisories", "debian")
+	if err := os.MkdirAll(advisories, 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFile(t, filepath.Join(advisories, "DSA-5632-1.json"), debianAdvisory)
+	writeFile(t, filepath.Join(advisories, "README.md"), "not an advisory")
+	writeFile(t, filepath.Join(dir, "npm.json"), npmAdvisories)
+
+	archive, err := os.Create(filepath.Join(dir, "all.zip"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zw := zip.NewWriter(archive)
+	for name, content := range map[string]string{"PYSEC-2023-74.json": pypiAdvisory, "GO-2024-2598.json": goAdvisory} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	archive.Close()
+
+	return []string{filepath.Join(dir, "advisories"), filepath.Join(dir, "npm.json"), filepath.Join(dir, "all.zip")}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func importDatabase(t *testing.T) *Database {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "vulndb")
+	stats, err := Import(dir, writeDump(t)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Advisories != 5 || strings.Join(stats.Ecosystems, ",") != "Debian:12,Go,PyPI,npm" {
+		t.Fatalf("unexpected import: %+v", stats)
+	}
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return db
+}
+
+func TestImport(t *testing.T) {
+	db := importDatabase(t)
+	if db.Ecosystems["npm"].Advisories != 2 || db.Ecosystems["Debian:12"].File != "debian-12.json" {
+		t.Errorf("unexpected ecosystems: %+v", db.Ecosystems)
+	}
+
+	// A newer version of an advisory replaces the imported one and a
+	// withdrawn advisory is removed.
+	dir := t.TempDir()
+	updated := strings.Replace(debianAdvisory, "2024-02-01", "2024-03-01", 1)
+	updated = strings.Replace(updated, "openssl security update", "openssl update", 1)
+	writeFile(t, filepath.Join(dir, "DSA-5632-1.json"), updated)
+	writeFile(t, filepath.Join(dir, "old.json"), debianAdvisory)
+	withdrawn := strings.Replace(npmAdvisories, `"summary": "Prototype pollution in lodash",`, `"withdrawn": "2024-02-01T00:00:00Z",`, 1)
+	writeFile(t, filepath.Join(dir, "npm.json"), withdrawn)
+	stats, err := Import(db.Dir, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Withdrawn != 1 {
+		t.Errorf("unexpected import: %+v", stats)
+	}
+
+	db, err = Open(db.Dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	advisories, err := db.advisories("Debian:12", "openssl")
+	if err != nil || len(advisories) != 1 || advisories[0].Summary != "openssl update" {
+		t.Errorf("expected the newer advisory, got %+v (%v)", advisories, err)
+	}
+	if db.Ecosystems["npm"].Advisories != 1 || db.Ecosystems["Go"].Advisories != 1 {
+		t.Errorf("unexpected ecosystems: %+v", db.Ecosystems)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "broken.json"), `{"id": `)
+	writeFile(t, filepath.Join(dir, "anonymous.json"), `{"summary": "no id"}`)
+	for _, source := range []string{filepath.Join(dir, "missing.json"), filepath.Join(dir, "broken.json"), filepath.Join(dir, "anonymous.json")} {
+		if _, err := Import(filepath.Join(dir, "db"), source); err == nil {
+			t.Errorf("expected an error importing %s", source)
+		}
+	}
+	if _, err := Open(dir); err == nil {
+		t.Errorf("expected an error opening a directory without a database")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db := importDatabase(t)
+	debian := map[string]string{"ID": "debian", "VERSION_ID": "12"}
+	packages := []sbom.Package{
+		{Name: "libssl3", Version: "3.0.11-1~deb12u2", Type: sbom.TypeDeb, Source: "openssl"},
+		{Name: "openssl", Version: "3.0.13-1~deb12u1", Type: sbom.TypeDeb},
+		{Name: "lodash", Version: "4.17.15", Type: sbom.TypeNPM},
+		{Name: "lodash", Version: "4.17.15", Type: sbom.TypeNPM, Location: "/app/package-lock.json"},
+		{Name: "requests", Version: "2.30.0", Type: sbom.TypePyPI},
+		{Name: "stdlib", Version: "1.22.0", Type: sbom.TypeGo},
+		{Name: "github.com/acme/app", Version: "v1.0.0", Type: sbom.TypeGo},
+	}
+	findings, err := db.Match(debian, packages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, strings.Join([]string{f.Package.Name, f.ID, f.Severity, f.Fixed}, " "))
+	}
+	want := []string{
+		"lodash CVE-2021-23337 HIGH 4.17.21",
+		"libssl3 CVE-2024-0727 MEDIUM 3.0.13-1~deb12u1",
+		"lodash GHSA-p6mc-m468-83gw MEDIUM ",
+		"requests CVE-2023-32681 UNKNOWN ",
+		"stdlib CVE-2024-24783 UNKNOWN 1.22.1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got findings\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if findings[1].Score != 5.5 || findings[1].Ecosystem != "Debian:12" || strings.Join(findings[1].Aliases, ",") != "DSA-5632-1" {
+		t.Errorf("unexpected finding %+v", findings[1])
+	}
+
+	// Debian advisories do not apply to other releases.
+	findings, err = db.Match(map[string]string{"ID": "debian", "VERSION_ID": "11"}, packages[:1])
+	if err != nil || len(findings) != 0 {
+		t.Errorf("expected no findings for Debian 11, got %+v (%v)", findings, err)
+	}
+}
+
+func TestMergeFinding(t *testing.T) {
+	findings := mergeFinding(nil, Finding{ID: "CVE-2023-32681", Aliases: []string{"PYSEC-2023-74"}, Severity: SeverityUnknown})
+	findings = mergeFinding(findings, Finding{ID: "CVE-2023-32681", Aliases: []string{"GHSA-j8r2-6x86-q33q"}, Severity: SeverityMedium, Fixed: "2.31.0"})
+	findings = mergeFinding(findings, Finding{ID: "CVE-2024-35195", Severity: SeverityMedium})
+	if len(findings) != 2 {
+		t.Fatalf("expected two findings, got %+v", findings)
+	}
+	if f := findings[0]; f.Severity != SeverityMedium || f.Fixed != "2.31.0" || len(f.Aliases) != 2 {
+		t.Errorf("unexpected merged finding %+v", f)
+	}
+}
+
+func TestRuntimePackages(t *testing.T) {
+	packages := RuntimePackages([]string{"PATH=/usr/local/bin", "NODE_VERSION=20.11.1", "GOLANG_VERSION=1.22.1", "PYTHON_VERSION="})
+	if len(packages) != 2 {
+		t.Fatalf("expected node and Go, got %+v", packages)
+	}
+	if p := packages[0]; p.Name != "node" || p.Version != "20.11.1" || p.Type != TypeRuntime || p.Location != "ENV NODE_VERSION" {
+		t.Errorf("unexpected package %+v", p)
+	}
+	if p := packages[1]; p.Name != "stdlib" || p.Type != sbom.TypeGo {
+		t.Errorf("unexpected package %+v", p)
+	}
+}
@@ -0,0 +1,359 @@
+package vuln
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jorgevvs2/dockeryzer/src/sbom"
+)
+
+// Severities of findings, from most to least severe.
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+var severityRanks = map[string]int{SeverityCritical: 4, SeverityHigh: 3, SeverityMedium: 2, SeverityLow: 1}
+
+// severityLabels normalizes the severity labels used by GitHub and the
+// distributions.
+var severityLabels = map[string]string{
+	"critical":    SeverityCritical,
+	"high":        SeverityHigh,
+	"important":   SeverityHigh,
+	"moderate":    SeverityMedium,
+	"medium":      SeverityMedium,
+	"low":         SeverityLow,
+	"negligible":  SeverityLow,
+	"unimportant": SeverityLow,
+}
+
+// TypeRuntime is the type of the language runtimes found in the image
+// configuration, which are matched against the Bitnami advisories.
+const TypeRuntime = "runtime"
+
+// runtimeVariables map the variables the official images set to the
+// runtime they install and its package in the advisories.
+var runtimeVariables = []struct {
+	variable string
+	kind     string
+	name     string
+}{
+	{"GOLANG_VERSION", sbom.TypeGo, "stdlib"},
+	{"NODE_VERSION", TypeRuntime, "node"},
+	{"PYTHON_VERSION", TypeRuntime, "python"},
+	{"PHP_VERSION", TypeRuntime, "php"},
+	{"RUBY_VERSION", TypeRuntime, "ruby"},
+}
+
+// Finding is a package affected by an advisory.
+type Finding struct {
+	Package   sbom.Package
+	Ecosystem string
+	// ID is the CVE of the advisory when it has one, its OSV ID otherwise.
+	ID      string
+	Aliases []string
+	Summary string
+	// Severity is one of the Severity constants and Score the CVSS v3 base
+	// score, 0 when the advisory has no CVSS v3 vector.
+	Severity string
+	Score    float64
+	// Fixed is the first version without the vulnerability, empty when
+	// there is no fix yet.
+	Fixed string
+}
+
+// IDs returns the ID and the aliases of the finding.
+func (f Finding) IDs() []string {
+	return append([]string{f.ID}, f.Aliases...)
+}
+
+// RuntimePackages returns the language runtimes an image installs
+// according to the version variables of its configuration, e.g.
+// NODE_VERSION=20.11.1.
+func RuntimePackages(env []string) []sbom.Package {
+	packages := []sbom.Package{}
+	for _, variable := range env {
+		key, value, _ := strings.Cut(variable, "=")
+		for _, runtime := range runtimeVariables {
+			if key == runtime.variable && value != "" {
+				packages = append(packages, sbom.Package{
+					Name:     runtime.name,
+					Version:  value,
+					Type:     runtime.kind,
+					PURL:     "pkg:generic/" + runtime.name + "@" + value,
+					Location: "ENV " + key,
+				})
+			}
+		}
+	}
+	return packages
+}
+
+// ecosystemPrefixes returns the ecosystems whose advisories apply to a
+// package: ecosystems named like a prefix, or followed by more qualifiers,
+// e.g. Ubuntu:22.04 matches Ubuntu:22.04:LTS.
+func ecosystemPrefixes(p sbom.Package, osRelease map[string]string) []string {
+	distro, release := osRelease["ID"], osRelease["VERSION_ID"]
+	major, _, _ := strings.Cut(release, ".")
+	switch p.Type {
+	case sbom.TypeAPK:
+		switch distro {
+		case "alpine":
+			parts := strings.SplitN(release, ".", 3)
+			if len(parts) >= 2 {
+				return []string{"Alpine:v" + parts[0] + "." + parts[1]}
+			}
+		case "wolfi":
+			return []string{"Wolfi"}
+		case "chainguard":
+			return []string{"Chainguard"}
+		}
+	case sbom.TypeDeb:
+		switch distro {
+		case "debian":
+			if major != "" {
+				return []string{"Debian:" + major}
+			}
+		case "ubuntu":
+			if release != "" {
+				return []string{"Ubuntu:" + release}
+			}
+		}
+	case sbom.TypeRPM:
+		switch distro {
+		case "rocky":
+			return []string{"Rocky Linux:" + major}
+		case "almalinux":
+			return []string{"AlmaLinux:" + major}
+		case "mariner":
+			return []string{"Mariner:" + release}
+		case "azurelinux":
+			return []string{"Azure Linux:" + release}
+		}
+	case sbom.TypeNPM:
+		return []string{"npm"}
+	case sbom.TypePyPI:
+		return []string{"PyPI"}
+	case sbom.TypeGo:
+		return []string{"Go"}
+	case sbom.TypeCargo:
+		return []string{"crates.io"}
+	case sbom.TypeGem:
+		return []string{"RubyGems"}
+	case TypeRuntime:
+		return []string{"Bitnami"}
+	}
+	return nil
+}
+
+// matchVersion normalizes the version of a package for comparison with
+// the advisories of its ecosystem.
+func matchVersion(p sbom.Package) string {
+	version := p.Version
+	if p.Type == sbom.TypeGo {
+		// Go reports versions like v1.2.3 and go1.22.1 X:boringcrypto.
+		version, _, _ = strings.Cut(version, " ")
+		version = strings.TrimPrefix(version, "v")
+	}
+	return version
+}
+
+// Match returns the findings of packages, which are the packages of an
+// image as listed by sbom.Generate and RuntimePackages. osRelease is the
+// os-release of the image, which selects the advisories of its
+// distribution release. Findings are sorted by severity, most severe
+// first.
+func (db *Database) Match(osRelease map[string]string, packages []sbom.Package) ([]Finding, error) {
+	ecosystems := slices.Sorted(maps.Keys(db.Ecosystems))
+	findings := []Finding{}
+	seen := map[string]bool{}
+	for _, p := range packages {
+		if seen[p.Type+"\x00"+p.Name+"\x00"+p.Version] {
+			continue
+		}
+		seen[p.Type+"\x00"+p.Name+"\x00"+p.Version] = true
+
+		packageFindings := []Finding{}
+		for _, prefix := range ecosystemPrefixes(p, osRelease) {
+			for _, ecosystem := range ecosystems {
+				if ecosystem != prefix && !strings.HasPrefix(ecosystem, prefix+":") {
+					continue
+				}
+				names := []string{p.Name}
+				if p.Source != "" && p.Source != p.Name {
+					names = append(names, p.Source)
+				}
+				for _, name := range names {
+					advisories, err := db.advisories(ecosystem, name)
+					if err != nil {
+						return nil, err
+					}
+					for _, advisory := range advisories {
+						if finding, ok := matchAdvisory(advisory, ecosystem, name, p); ok {
+							packageFindings = mergeFinding(packageFindings, finding)
+						}
+					}
+				}
+			}
+		}
+		findings = append(findings, packageFindings...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] > severityRanks[b.Severity]
+		}
+		if a.Package.Name != b.Package.Name {
+			return a.Package.Name < b.Package.Name
+		}
+		return a.ID < b.ID
+	})
+	return findings, nil
+}
+
+// matchAdvisory checks the version of p against the affected entries of
+// advisory for the package name in ecosystem.
+func matchAdvisory(advisory *Advisory, ecosystem, name string, p sbom.Package) (Finding, bool) {
+	version := matchVersion(p)
+	for _, affected := range advisory.Affected {
+		if affected.Package.Ecosystem != ecosystem || packageKey(ecosystem, affected.Package.Name) != packageKey(ecosystem, name) {
+			continue
+		}
+		isAffected, fixed := affects(ecosystem, affected, version)
+		if !isAffected {
+			continue
+		}
+
+		finding := Finding{Package: p, Ecosystem: ecosystem, ID: advisory.ID, Summary: advisory.Summary, Fixed: fixed}
+		for _, alias := range advisory.Aliases {
+			if strings.HasPrefix(alias, "CVE-") && !strings.HasPrefix(finding.ID, "CVE-") {
+				finding.Aliases = append(finding.Aliases, finding.ID)
+				finding.ID = alias
+			} else {
+				finding.Aliases = append(finding.Aliases, alias)
+			}
+		}
+		finding.Severity, finding.Score = severity(advisory, affected)
+		return finding, true
+	}
+	return Finding{}, false
+}
+
+// affects reports whether version is affected, either listed in the
+// versions or inside a range, and returns the first fixed version after it.
+func affects(ecosystem string, affected Affected, version string) (bool, string) {
+	isAffected := slices.Contains(affected.Versions, version)
+	fixed := ""
+	for _, r := range affected.Ranges {
+		compare := comparator(ecosystem, r.Type)
+		if compare == nil {
+			continue
+		}
+		if !inRange(r, version, compare) {
+			continue
+		}
+		isAffected = true
+		for _, event := range r.Events {
+			if event.Fixed != "" && compare(event.Fixed, version) > 0 && (fixed == "" || compare(event.Fixed, fixed) < 0) {
+				fixed = event.Fixed
+			}
+		}
+	}
+	return isAffected, fixed
+}
+
+// inRange evaluates the events of a range in version order, as the OSV
+// schema specifies: introduced versions start an affected range, fixed and
+// last affected versions end it.
+func inRange(r Range, version string, compare compareFunc) bool {
+	events := slices.Clone(r.Events)
+	eventVersion := func(e Event) string { return e.Introduced + e.Fixed + e.LastAffected }
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := eventVersion(events[i]), eventVersion(events[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return compare(a, b) < 0
+	})
+
+	affected := false
+	for _, event := range events {
+		switch {
+		case event.Introduced != "":
+			if event.Introduced == "0" || compare(version, event.Introduced) >= 0 {
+				affected = true
+			}
+		case event.Fixed != "":
+			if compare(version, event.Fixed) >= 0 {
+				affected = false
+			}
+		case event.LastAffected != "":
+			if compare(version, event.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// severity returns the severity of an advisory from its CVSS v3 vector, or
+// else from the label of the distribution or of GitHub.
+func severity(advisory *Advisory, affected Affected) (string, float64) {
+	labels := []string{}
+	for _, s := range advisory.Severity {
+		if s.Type == "CVSS_V3" {
+			if score, ok := cvss3BaseScore(s.Score); ok {
+				return cvssRating(score), score
+			}
+		} else {
+			labels = append(labels, s.Score)
+		}
+	}
+	if affected.EcosystemSpecific != nil {
+		labels = append(labels, affected.EcosystemSpecific.Severity)
+	}
+	if advisory.DatabaseSpecific != nil {
+		labels = append(labels, advisory.DatabaseSpecific.Severity)
+	}
+	for _, label := range labels {
+		if severity, ok := severityLabels[strings.ToLower(label)]; ok {
+			return severity, 0
+		}
+	}
+	return SeverityUnknown, 0
+}
+
+// mergeFinding adds finding to the findings of a package unless an
+// advisory with a common ID was already found, as when both GitHub and the
+// language's own database publish the same CVE. The merged finding keeps
+// the higher severity.
+func mergeFinding(findings []Finding, finding Finding) []Finding {
+	for i, existing := range findings {
+		ids := existing.IDs()
+		if !slices.ContainsFunc(finding.IDs(), func(id string) bool { return slices.Contains(ids, id) }) {
+			continue
+		}
+		for _, id := range finding.IDs() {
+			if !slices.Contains(ids, id) {
+				existing.Aliases = append(existing.Aliases, id)
+				ids = append(ids, id)
+			}
+		}
+		if severityRanks[finding.Severity] > severityRanks[existing.Severity] {
+			existing.Severity, existing.Score = finding.Severity, finding.Score
+		}
+		if existing.Fixed == "" {
+			existing.Fixed = finding.Fixed
+		}
+		findings[i] = existing
+		return findings
+	}
+	return append(findings, finding)
+}
@@ -0,0 +1,168 @@
+// Package vuln matches the packages of an image against a local database of
+// OSV advisories, which is imported from OSV JSON dumps without network
+// access.
+package vuln
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAdvisorySize bounds the JSON files read from a dump.
+const maxAdvisorySize = 64 << 20
+
+// Advisory is an OSV advisory, keeping the fields used for matching. See
+// https://ossf.github.io/osv-schema/.
+type Advisory struct {
+	ID        string     `json:"id"`
+	Modified  string     `json:"modified,omitempty"`
+	Withdrawn string     `json:"withdrawn,omitempty"`
+	Aliases   []string   `json:"aliases,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Severity  []Score    `json:"severity,omitempty"`
+	Affected  []Affected `json:"affected,omitempty"`
+	// DatabaseSpecific holds the severity label of GitHub advisories.
+	DatabaseSpecific *Specific `json:"database_specific,omitempty"`
+}
+
+// Score is a severity score, a CVSS vector or a distribution's label.
+type Score struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected lists the affected versions of one package.
+type Affected struct {
+	Package  AffectedPackage `json:"package"`
+	Ranges   []Range         `json:"ranges,omitempty"`
+	Versions []string        `json:"versions,omitempty"`
+	// EcosystemSpecific holds the severity label of distribution advisories.
+	EcosystemSpecific *Specific `json:"ecosystem_specific,omitempty"`
+}
+
+// AffectedPackage names a package within an ecosystem such as npm or
+// Debian:12.
+type AffectedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// Range is a list of events where versions start or stop being affected.
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is a version where a range starts (Introduced) or ends (Fixed,
+// LastAffected).
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// Specific is the part of the database and ecosystem specific fields that
+// is kept: the severity label.
+type Specific struct {
+	Severity string `json:"severity,omitempty"`
+}
+
+// UnmarshalJSON accepts any object, keeping only a string severity.
+func (s *Specific) UnmarshalJSON(data []byte) error {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	s.Severity, _ = fields["severity"].(string)
+	return nil
+}
+
+// ReadAdvisories calls fn with the advisories of an OSV dump: a JSON file
+// holding an advisory or a list of them, a directory of such files, or a
+// zip archive like the all.zip files published by osv.dev.
+func ReadAdvisories(path string, fn func(*Advisory) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(name, ".json") {
+				return err
+			}
+			return readAdvisoryFile(name, fn)
+		})
+	}
+	if strings.HasSuffix(path, ".zip") {
+		return readAdvisoryZip(path, fn)
+	}
+	return readAdvisoryFile(path, fn)
+}
+
+func readAdvisoryFile(name string, fn func(*Advisory) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return decodeAdvisories(name, f, fn)
+}
+
+func readAdvisoryZip(name string, fn func(*Advisory) error) error {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = decodeAdvisories(name+"/"+file.Name, content, fn)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeAdvisories reads one advisory or a list of advisories.
+func decodeAdvisories(name string, r io.Reader, fn func(*Advisory) error) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxAdvisorySize))
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	var advisories []*Advisory
+	if bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &advisories)
+	} else {
+		advisory := &Advisory{}
+		err = json.Unmarshal(data, advisory)
+		advisories = []*Advisory{advisory}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, advisory := range advisories {
+		if advisory.ID == "" {
+			return fmt.Errorf("%s: advisory without an id", name)
+		}
+		if err := fn(advisory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,471 @@
+package vuln
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// compareFunc orders two versions of an ecosystem, returning -1, 0 or 1.
+type compareFunc func(a, b string) int
+
+// ecosystemComparators order the versions of ECOSYSTEM ranges by the part
+// of the ecosystem name before the colon.
+var ecosystemComparators = map[string]compareFunc{
+	"Alpine":      compareAPK,
+	"Wolfi":       compareAPK,
+	"Chainguard":  compareAPK,
+	"Debian":      compareDpkg,
+	"Ubuntu":      compareDpkg,
+	"AlmaLinux":   compareRPM,
+	"Rocky Linux": compareRPM,
+	"Red Hat":     compareRPM,
+	"Mariner":     compareRPM,
+	"Azure Linux": compareRPM,
+	"npm":         compareSemver,
+	"Go":          compareSemver,
+	"crates.io":   compareSemver,
+	"Bitnami":     compareSemver,
+	"PyPI":        comparePEP440,
+	"RubyGems":    compareGem,
+}
+
+// comparator returns the ordering of a range, nil when it is not supported.
+func comparator(ecosystem, rangeType string) compareFunc {
+	switch rangeType {
+	case "SEMVER":
+		return compareSemver
+	case "ECOSYSTEM":
+		base, _, _ := strings.Cut(ecosystem, ":")
+		return ecosystemComparators[base]
+	default:
+		return nil
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// compareNumbers compares two runs of digits of any length.
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// leadingDigits splits s after its leading digits.
+func leadingDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareDpkg orders Debian versions, [epoch:]upstream[-revision], as
+// dpkg --compare-versions does.
+func compareDpkg(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitDpkg(a)
+	bEpoch, bUpstream, bRevision := splitDpkg(b)
+	if c := compareNumbers(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	if c := verrevcmp(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return verrevcmp(aRevision, bRevision)
+}
+
+func splitDpkg(v string) (epoch, upstream, revision string) {
+	epoch, upstream, found := strings.Cut(v, ":")
+	if !found {
+		epoch, upstream = "0", v
+	}
+	if i := strings.LastIndex(upstream, "-"); i >= 0 {
+		upstream, revision = upstream[:i], upstream[i+1:]
+	}
+	return epoch, upstream, revision
+}
+
+// dpkgOrder ranks a character of the non-digit parts: the tilde sorts
+// before everything, even the end of the part, and letters before other
+// characters.
+func dpkgOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case isAlpha(s[0]):
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+func verrevcmp(a, b string) int {
+	for a != "" || b != "" {
+		for a != "" && !isDigit(a[0]) || b != "" && !isDigit(b[0]) {
+			if ac, bc := dpkgOrder(a), dpkgOrder(b); ac != bc {
+				return sign(ac - bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+		var aNumber, bNumber string
+		aNumber, a = leadingDigits(a)
+		bNumber, b = leadingDigits(b)
+		if c := compareNumbers(aNumber, bNumber); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareRPM orders rpm versions, [epoch:]version-release, as rpmvercmp
+// does.
+func compareRPM(a, b string) int {
+	aEpoch, aVersion, found := strings.Cut(a, ":")
+	if !found {
+		aEpoch, aVersion = "0", a
+	}
+	bEpoch, bVersion, found := strings.Cut(b, ":")
+	if !found {
+		bEpoch, bVersion = "0", b
+	}
+	if c := compareNumbers(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	aVersion, aRelease, _ := strings.Cut(aVersion, "-")
+	bVersion, bRelease, _ := strings.Cut(bVersion, "-")
+	if c := rpmvercmp(aVersion, bVersion); c != 0 || aRelease == "" || bRelease == "" {
+		return c
+	}
+	return rpmvercmp(aRelease, bRelease)
+}
+
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isSeparator := func(c byte) bool { return !isDigit(c) && !isAlpha(c) && c != '~' && c != '^' }
+	for a != "" || b != "" {
+		for a != "" && isSeparator(a[0]) {
+			a = a[1:]
+		}
+		for b != "" && isSeparator(b[0]) {
+			b = b[1:]
+		}
+
+		// A tilde sorts before everything, a caret after the end of the
+		// version but before anything else.
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case a == "":
+				return -1
+			case b == "":
+				return 1
+			case !strings.HasPrefix(a, "^"):
+				return 1
+			case !strings.HasPrefix(b, "^"):
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if a == "" || b == "" {
+			break
+		}
+
+		numeric := isDigit(a[0])
+		split := func(s string) (string, string) {
+			i := 0
+			for i < len(s) && (numeric && isDigit(s[i]) || !numeric && isAlpha(s[i])) {
+				i++
+			}
+			return s[:i], s[i:]
+		}
+		var aSegment, bSegment string
+		aSegment, a = split(a)
+		bSegment, b = split(b)
+		if bSegment == "" {
+			// Numeric segments are newer than alphabetic ones.
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		var c int
+		if numeric {
+			c = compareNumbers(aSegment, bSegment)
+		} else {
+			c = strings.Compare(aSegment, bSegment)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+var apkVersionPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)*)([a-z]?)((?:_[a-z]+[0-9]*)*)(?:-r([0-9]+))?$`)
+var apkSuffixPattern = regexp.MustCompile(`_([a-z]+)([0-9]*)`)
+
+// apkSuffixRanks orders the suffixes of apk versions: pre-releases before
+// the release, which ranks 0, and patch levels after it.
+var apkSuffixRanks = map[string]int{"alpha": -4, "beta": -3, "pre": -2, "rc": -1, "cvs": 1, "svn": 2, "git": 3, "hg": 4, "p": 5}
+
+// compareAPK orders Alpine versions like 1.36.1_git20230717-r4. Versions
+// outside the format apk accepts are compared as strings.
+func compareAPK(a, b string) int {
+	am, bm := apkVersionPattern.FindStringSubmatch(a), apkVersionPattern.FindStringSubmatch(b)
+	if am == nil || bm == nil {
+		return strings.Compare(a, b)
+	}
+
+	aNumbers, bNumbers := strings.Split(am[1], "."), strings.Split(bm[1], ".")
+	for i := 0; i < len(aNumbers) && i < len(bNumbers); i++ {
+		if c := compareNumbers(aNumbers[i], bNumbers[i]); c != 0 {
+			return c
+		}
+	}
+	if c := sign(len(aNumbers) - len(bNumbers)); c != 0 {
+		return c
+	}
+	if c := strings.Compare(am[2], bm[2]); c != 0 {
+		return c
+	}
+
+	aSuffixes, bSuffixes := apkSuffixPattern.FindAllStringSubmatch(am[3], -1), apkSuffixPattern.FindAllStringSubmatch(bm[3], -1)
+	for i := 0; i < len(aSuffixes) || i < len(bSuffixes); i++ {
+		aRank, bRank := 0, 0
+		aNumber, bNumber := "", ""
+		if i < len(aSuffixes) {
+			aRank, aNumber = apkSuffixRanks[aSuffixes[i][1]], aSuffixes[i][2]
+		}
+		if i < len(bSuffixes) {
+			bRank, bNumber = apkSuffixRanks[bSuffixes[i][1]], bSuffixes[i][2]
+		}
+		if aRank != bRank {
+			return sign(aRank - bRank)
+		}
+		if c := compareNumbers(aNumber, bNumber); c != 0 {
+			return c
+		}
+	}
+	return compareNumbers(am[4], bm[4])
+}
+
+// compareSemver orders semantic versions, with or without a leading v.
+// Missing minor and patch numbers count as zero and build metadata is
+// ignored.
+func compareSemver(a, b string) int {
+	a, _, _ = strings.Cut(strings.TrimPrefix(a, "v"), "+")
+	b, _, _ = strings.Cut(strings.TrimPrefix(b, "v"), "+")
+	aCore, aPre, aHasPre := strings.Cut(a, "-")
+	bCore, bPre, bHasPre := strings.Cut(b, "-")
+
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < max(len(aParts), len(bParts), 3); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if c := compareIdentifier(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case !aHasPre && !bHasPre:
+		return 0
+	case !aHasPre:
+		return 1
+	case !bHasPre:
+		return -1
+	}
+	aIdentifiers, bIdentifiers := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aIdentifiers) && i < len(bIdentifiers); i++ {
+		if c := compareIdentifier(aIdentifiers[i], bIdentifiers[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(aIdentifiers) - len(bIdentifiers))
+}
+
+// compareIdentifier compares numeric identifiers by value, and before
+// alphanumeric ones, which are compared as strings.
+func compareIdentifier(a, b string) int {
+	aNumber, aRest := leadingDigits(a)
+	bNumber, bRest := leadingDigits(b)
+	aNumeric, bNumeric := aNumber != "" && aRest == "", bNumber != "" && bRest == ""
+	switch {
+	case aNumeric && bNumeric:
+		return compareNumbers(aNumber, bNumber)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+var pep440Pattern = regexp.MustCompile(`^v?(?:([0-9]+)!)?([0-9]+(?:\.[0-9]+)*)(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?([0-9]*))?(?:-([0-9]+)|[-_.]?(post|rev|r)[-_.]?([0-9]*))?(?:[-_.]?(dev)[-_.]?([0-9]*))?(?:\+.*)?$`)
+
+var pep440PreRanks = map[string]int{"a": 0, "alpha": 0, "b": 1, "beta": 1, "c": 2, "rc": 2, "pre": 2, "preview": 2}
+
+// pep440Key is the sort key of a Python version.
+type pep440Key struct {
+	epoch   int
+	release []int
+	// pre is the pre-release phase and number: {-1} for a development
+	// release without a pre-release, {3} for no pre-release.
+	pre [2]int
+	// post is -1 without a post-release.
+	post int
+	// dev is MaxInt without a development release.
+	dev int
+}
+
+func parsePEP440(v string) (pep440Key, bool) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+	if m == nil {
+		return pep440Key{}, false
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	key := pep440Key{epoch: atoi(m[1]), pre: [2]int{3, 0}, post: -1, dev: int(^uint(0) >> 1)}
+	for _, part := range strings.Split(m[2], ".") {
+		key.release = append(key.release, atoi(part))
+	}
+	for len(key.release) > 1 && key.release[len(key.release)-1] == 0 {
+		key.release = key.release[:len(key.release)-1]
+	}
+	if m[3] != "" {
+		key.pre = [2]int{pep440PreRanks[m[3]], atoi(m[4])}
+	}
+	switch {
+	case m[5] != "":
+		key.post = atoi(m[5])
+	case m[6] != "":
+		key.post = atoi(m[7])
+	}
+	if m[8] != "" {
+		key.dev = atoi(m[9])
+		if m[3] == "" && key.post < 0 {
+			key.pre = [2]int{-1, 0}
+		}
+	}
+	return key, true
+}
+
+// comparePEP440 orders Python versions as PEP 440 does, e.g. 1.0.dev1 <
+// 1.0a1 < 1.0rc1 < 1.0 < 1.0.post1. Local versions are ignored.
+func comparePEP440(a, b string) int {
+	aKey, aOK := parsePEP440(a)
+	bKey, bOK := parsePEP440(b)
+	if !aOK || !bOK {
+		return strings.Compare(a, b)
+	}
+	if aKey.epoch != bKey.epoch {
+		return sign(aKey.epoch - bKey.epoch)
+	}
+	for i := 0; i < max(len(aKey.release), len(bKey.release)); i++ {
+		aPart, bPart := 0, 0
+		if i < len(aKey.release) {
+			aPart = aKey.release[i]
+		}
+		if i < len(bKey.release) {
+			bPart = bKey.release[i]
+		}
+		if aPart != bPart {
+			return sign(aPart - bPart)
+		}
+	}
+	for _, pair := range [][2]int{{aKey.pre[0], bKey.pre[0]}, {aKey.pre[1], bKey.pre[1]}, {aKey.post, bKey.post}} {
+		if pair[0] != pair[1] {
+			return sign(pair[0] - pair[1])
+		}
+	}
+	switch {
+	case aKey.dev == bKey.dev:
+		return 0
+	case aKey.dev < bKey.dev:
+		return -1
+	default:
+		return 1
+	}
+}
+
+var gemSegmentPattern = regexp.MustCompile(`[0-9]+|[a-z]+`)
+
+// compareGem orders RubyGems versions, where a segment with letters makes
+// a pre-release, e.g. 7.1.0.rc1 < 7.1.0. The platform of versions like
+// 1.16.2-x86_64-linux is ignored.
+func compareGem(a, b string) int {
+	a, _, _ = strings.Cut(a, "-")
+	b, _, _ = strings.Cut(b, "-")
+	aSegments := gemSegmentPattern.FindAllString(strings.ToLower(a), -1)
+	bSegments := gemSegmentPattern.FindAllString(strings.ToLower(b), -1)
+	for i := 0; i < max(len(aSegments), len(bSegments)); i++ {
+		aSegment, bSegment := "0", "0"
+		if i < len(aSegments) {
+			aSegment = aSegments[i]
+		}
+		if i < len(bSegments) {
+			bSegment = bSegments[i]
+		}
+		aNumeric, bNumeric := isDigit(aSegment[0]), isDigit(bSegment[0])
+		var c int
+		switch {
+		case aNumeric && bNumeric:
+			c = compareNumbers(aSegment, bSegment)
+		case aNumeric:
+			c = 1
+		case bNumeric:
+			c = -1
+		default:
+			c = strings.Compare(aSegment, bSegment)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package vuln
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		compare compareFunc
+		a, b    string
+		want    int
+	}{
+		{"dpkg equal", compareDpkg, "1.2.3-1", "1.2.3-1", 0},
+		{"dpkg revision", compareDpkg, "3.0.11-1~deb12u2", "3.0.13-1~deb12u1", -1},
+		{"dpkg tilde before release", compareDpkg, "1.0~rc1", "1.0", -1},
+		{"dpkg epoch", compareDpkg, "1:1.0", "2.0", 1},
+		{"dpkg letters before symbols", compareDpkg, "1.0a", "1.0+", -1},
+		{"dpkg plus suffix", compareDpkg, "2.36-9+deb12u4", "2.36-9", 1},
+		{"dpkg numbers", compareDpkg, "1.10", "1.9", 1},
+		{"rpm release", compareRPM, "3.0.7-24.el9", "3.0.7-25.el9_3", -1},
+		{"rpm epoch", compareRPM, "1:3.0.7-27.el9", "3.0.8-1.el9", 1},
+		{"rpm numeric over alpha", compareRPM, "1.0.1", "1.0.a", 1},
+		{"rpm tilde", compareRPM, "1.0~beta", "1.0", -1},
+		{"rpm caret", compareRPM, "1.0^git1", "1.0", 1},
+		{"rpm caret before next", compareRPM, "1.0^git1", "1.0.1", -1},
+		{"rpm without release", compareRPM, "5.1.8", "5.1.8-9.el9", 0},
+		{"apk revision", compareAPK, "1.36.1-r15", "1.36.1-r2", 1},
+		{"apk pre-release", compareAPK, "1.2.4_rc1-r0", "1.2.4-r0", -1},
+		{"apk patch level", compareAPK, "1.2.4_p1-r0", "1.2.4-r0", 1},
+		{"apk git snapshot", compareAPK, "1.2.4_git20230717-r4", "1.2.4_git20230101-r9", 1},
+		{"apk letter", compareAPK, "1.1.1w-r0", "1.1.1v-r3", 1},
+		{"apk more numbers", compareAPK, "3.1.4.1-r0", "3.1.4-r5", 1},
+		{"semver", compareSemver, "4.17.20", "4.17.21", -1},
+		{"semver leading v", compareSemver, "v0.17.0", "0.17.0", 0},
+		{"semver pre-release", compareSemver, "1.0.0-rc.1", "1.0.0", -1},
+		{"semver numeric identifiers", compareSemver, "1.0.0-rc.10", "1.0.0-rc.9", 1},
+		{"semver short", compareSemver, "1.22", "1.22.0", 0},
+		{"semver Go pseudo-version", compareSemver, "0.0.0-20240101000000-abcdef123456", "0.1.0", -1},
+		{"pep440 post", comparePEP440, "2.0.post1", "2.0", 1},
+		{"pep440 dev", comparePEP440, "2.0.dev1", "2.0a1", -1},
+		{"pep440 pre-releases", comparePEP440, "2.0rc1", "2.0b2", 1},
+		{"pep440 trailing zeros", comparePEP440, "2.0", "2", 0},
+		{"pep440 preview is not a post-release", comparePEP440, "2.0preview1", "2.0", -1},
+		{"pep440 epoch", comparePEP440, "1!1.0", "2.0", 1},
+		{"pep440 local", comparePEP440, "2.1.0+cpu", "2.1.0", 0},
+		{"gem", compareGem, "7.0.8.1", "7.0.8", 1},
+		{"gem pre-release", compareGem, "7.1.0.rc1", "7.1.0", -1},
+		{"gem platform", compareGem, "1.16.2-x86_64-linux", "1.16.2", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.compare(tt.a, tt.b); got != tt.want {
+				t.Errorf("compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := tt.compare(tt.b, tt.a); got != -tt.want {
+				t.Errorf("compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector string
+		score  float64
+		rating string
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, SeverityCritical},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1, SeverityMedium},
+		{"CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8, SeverityHigh},
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:N/A:L", 3.7, SeverityLow},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", 9.9, SeverityCritical},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0, SeverityUnknown},
+	}
+	for _, tt := range tests {
+		score, ok := cvss3BaseScore(tt.vector)
+		if !ok || score != tt.score || cvssRating(score) != tt.rating {
+			t.Errorf("%s: got %v (%s), want %v (%s)", tt.vector, score, cvssRating(score), tt.score, tt.rating)
+		}
+	}
+
+	for _, vector := range []string{"", "CVSS:2.0/AV:N", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"} {
+		if _, ok := cvss3BaseScore(vector); ok {
+			t.Errorf("expected %q to be rejected", vector)
+		}
+	}
+}